@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 
 	"dotfiles/internal/config"
@@ -38,13 +37,7 @@ Popular packages:
 • Productivity: fzf, ripgrep, bat, eza, tmux, neovim
 • Applications: visual-studio-code, rectangle, slack`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 
 		// Load existing config
 		cfg, err := config.Load(configPath)
@@ -144,13 +137,7 @@ Examples:
   dotfiles remove --all-brews                   # Remove all brews from config
   dotfiles remove --all-brews --uninstall       # Remove and uninstall all brews`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 
 		cfg, err := config.Load(configPath)
 		if err != nil {
@@ -378,4 +365,4 @@ func init() {
 
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(removeCmd)
-}
\ No newline at end of file
+}
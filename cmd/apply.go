@@ -0,0 +1,516 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dotfiles/internal/config"
+	"dotfiles/internal/hooks"
+	"dotfiles/internal/pkgmanager"
+	"dotfiles/internal/scripts"
+	"dotfiles/internal/secrets"
+	"dotfiles/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+// PlanAction is a single step the apply command will perform
+type PlanAction struct {
+	Kind    string `json:"kind"`              // secret, tap, brew, cask, stow, hook, script
+	Name    string `json:"name"`              // Private file, package, stow package, hook command or script
+	Action  string `json:"action"`            // decrypt, install, uninstall, link, unlink, run
+	Manager string `json:"manager,omitempty"` // Package manager of packages listed under "packages"
+	Phase   string `json:"phase,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+
+	pm      pkgmanager.PackageManager // Package manager for Manager, the system one when nil
+	hook    config.Hook               // Hook to run for hook actions
+	payload hooks.Payload             // Event the hook runs for
+	script  scripts.Script            // Script to run for script actions
+}
+
+// ApplyPlan is the ordered set of actions needed to converge the machine
+type ApplyPlan struct {
+	PackageManager string       `json:"package_manager"`
+	Actions        []PlanAction `json:"actions"`
+	Converged      bool         `json:"converged"`
+	Notes          []string     `json:"notes,omitempty"` // What the plan leaves untouched, and why
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "🎯 Converge this machine to your configuration",
+	Long: `🎯 Apply Configuration
+
+Compare config.json with the current machine and compute a plan of private
+files to decrypt from private.enc/, taps to add, packages to install, stow
+packages to link, hooks to run and pending scripts from scripts/. The plan is shown first and then executed in order, with a
+snapshot taken beforehand.
+
+With --output json or yaml and without --plan, the plan, prompt and
+progress go to stderr and stdout gets the result once apply is done.
+
+Examples:
+  dotfiles apply                              # Show plan, confirm, then apply
+  dotfiles apply --yes                        # Apply without confirmation
+  dotfiles apply --plan                       # Only show the plan
+  dotfiles apply --plan --json                # Plan as JSON (for CI)
+  dotfiles apply --yes --output json          # Apply, then print the result as JSON
+  dotfiles apply --plan --detailed-exitcode   # Exit 2 if the machine has not converged`,
+	Run: func(cmd *cobra.Command, args []string) {
+		planOnly, _ := cmd.Flags().GetBool("plan")
+		yes, _ := cmd.Flags().GetBool("yes")
+		noSnapshot, _ := cmd.Flags().GetBool("no-snapshot")
+		detailedExit, _ := cmd.Flags().GetBool("detailed-exitcode")
+
+		// --json is kept as a shorthand for --output json
+		if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
+			outputFlag = outputJSON
+		}
+
+		cfg, err := loadMergedConfig()
+		if err != nil {
+			fail("Error loading configuration", err)
+		}
+
+		pm, err := pkgmanager.GetPackageManager()
+		if err != nil {
+			fail("Error", err)
+		}
+
+		if !pm.IsAvailable() {
+			fail("Error", fmt.Errorf("%s not found, please install it first", pm.GetName()))
+		}
+
+		// Warnings and progress go to stderr with structured output, so
+		// stdout only holds the plan or the result
+		var plan ApplyPlan
+		humanOutputToStderr(func() { plan = buildApplyPlan(cfg, pm) })
+
+		if planOnly || plan.Converged {
+			code := ExitOK
+			if detailedExit && !plan.Converged {
+				code = ExitDrift
+			}
+			printReport(plan, code, func() { printApplyPlan(plan) })
+			return
+		}
+
+		result := ApplyResult{Plan: plan}
+		humanOutputToStderr(func() {
+			printApplyPlan(plan)
+			if !yes && !askConfirmation("\nApply this plan? (y/N): ", false) {
+				fmt.Println("❌ Apply cancelled.")
+				return
+			}
+
+			if !noSnapshot {
+				fmt.Println("📸 Creating snapshot before apply...")
+				timestamp, err := snapshot.CreateAutoSnapshot(appCtx.Root, "Before apply")
+				if err != nil {
+					fmt.Printf("⚠️  Warning: Could not create snapshot: %v\n", err)
+				} else {
+					fmt.Printf("   ✅ Snapshot created: %s\n", timestamp)
+					result.Snapshot = timestamp
+				}
+				fmt.Println()
+			}
+
+			result.Applied = true
+			result.Failed = executeApplyPlan(plan, pm)
+		})
+
+		code := ExitOK
+		if result.Failed > 0 {
+			code = ExitError
+		}
+		printReport(result, code, func() {
+			if !result.Applied {
+				return
+			}
+			fmt.Println()
+			if result.Failed > 0 {
+				fmt.Printf("⚠️  Apply finished with %d failed action(s)\n", result.Failed)
+			} else {
+				fmt.Println("✅ Apply complete! Your machine matches config.json")
+			}
+		})
+	},
+}
+
+// ApplyResult is the outcome of executing an apply plan
+type ApplyResult struct {
+	Plan     ApplyPlan `json:"plan"`
+	Applied  bool      `json:"applied"`            // False when the plan was cancelled
+	Snapshot string    `json:"snapshot,omitempty"` // Snapshot taken before applying
+	Failed   int       `json:"failed"`             // Actions that failed
+}
+
+// buildApplyPlan works out which actions are needed to converge the machine
+func buildApplyPlan(cfg *config.Config, pm pkgmanager.PackageManager) ApplyPlan {
+	plan := ApplyPlan{
+		PackageManager: pm.GetName(),
+		Actions:        []PlanAction{},
+	}
+
+	// Private files come first, stow packages and hooks may need them
+	decrypts := secretActions()
+	plan.Actions = append(plan.Actions, decrypts...)
+
+	installs := packageActions(cfg, pm)
+	if len(installs) > 0 {
+		payload := hooks.Payload{Event: hooks.Install, Root: appCtx.Root, Packages: actionNames(installs)}
+		if cfg.Hooks != nil {
+			payload.Phase = hooks.Pre
+			plan.Actions = append(plan.Actions, hookActions(cfg.Hooks.PreInstall, payload)...)
+		}
+		plan.Actions = append(plan.Actions, installs...)
+		for _, action := range installs {
+			if pkgConfig, exists := cfg.PackageConfigs[action.Name]; exists {
+				pkgPayload := hooks.Payload{Event: hooks.Install, Root: appCtx.Root, Packages: []string{action.Name}}
+				pkgPayload.Phase = hooks.Pre
+				plan.Actions = append(plan.Actions, hookActions(pkgConfig.PreInstall, pkgPayload)...)
+				pkgPayload.Phase = hooks.Post
+				plan.Actions = append(plan.Actions, hookActions(pkgConfig.PostInstall, pkgPayload)...)
+			}
+		}
+		if cfg.Hooks != nil {
+			payload.Phase = hooks.Post
+			plan.Actions = append(plan.Actions, hookActions(cfg.Hooks.PostInstall, payload)...)
+		}
+	}
+
+	var links []PlanAction
+	stowDir := appCtx.StowDir()
+	for _, pkg := range cfg.Stow {
+		pkgPath := filepath.Join(stowDir, pkg)
+		if _, err := os.Stat(pkgPath); os.IsNotExist(err) {
+			continue
+		}
+		if checkIfStowed(pkg, stowDir, appCtx.Home) {
+			continue
+		}
+
+		action := PlanAction{Kind: "stow", Name: pkg, Action: "link"}
+		if conflicts := findStowConflicts(packageStowOptions(stowDir, appCtx.Home), pkg); len(conflicts) > 0 {
+			action.Detail = fmt.Sprintf("%d conflict(s)", len(conflicts))
+		}
+		links = append(links, action)
+	}
+
+	if len(links) > 0 {
+		payload := hooks.Payload{Event: hooks.Stow, Root: appCtx.Root, Packages: actionNames(links)}
+		if cfg.Hooks != nil {
+			payload.Phase = hooks.Pre
+			plan.Actions = append(plan.Actions, hookActions(cfg.Hooks.PreStow, payload)...)
+		}
+		plan.Actions = append(plan.Actions, links...)
+		if cfg.Hooks != nil {
+			payload.Phase = hooks.Post
+			plan.Actions = append(plan.Actions, hookActions(cfg.Hooks.PostStow, payload)...)
+		}
+	}
+
+	// Bootstrap scripts run last, once packages and links are in place
+	pending := scriptActions()
+	plan.Actions = append(plan.Actions, pending...)
+
+	plan.Converged = len(decrypts) == 0 && len(installs) == 0 && len(links) == 0 && len(pending) == 0
+	return plan
+}
+
+// packageActions returns install actions for the configured packages that
+// are missing: the top-level ones with the system package manager pm, then
+// those listed per package manager under "packages", as install does
+func packageActions(cfg *config.Config, pm pkgmanager.PackageManager) []PlanAction {
+	// pm was detected already, so the error can only repeat it
+	sets, _ := configuredManagers(cfg)
+
+	var installs []PlanAction
+	for _, set := range sets {
+		if set.Primary {
+			isHomebrew := pm.GetName() == "homebrew"
+			if isHomebrew {
+				installs = append(installs, missingPackages(set.Taps, "tap", pm)...)
+			}
+			installs = append(installs, missingPackages(set.Brews, "brew", pm)...)
+			if isHomebrew {
+				installs = append(installs, missingPackages(set.Casks, "cask", pm)...)
+			}
+			continue
+		}
+
+		if set.Count() == 0 {
+			continue
+		}
+		if set.Manager == nil {
+			fmt.Printf("⚠️  Unknown package manager %q, skipping %d package(s)\n\n", set.Name, set.Count())
+			continue
+		}
+		if !set.Available() {
+			fmt.Printf("⚠️  %s not found, skipping %d package(s)\n\n", set.Name, set.Count())
+			continue
+		}
+		for _, action := range missingPackages(set.Brews, "brew", set.Manager) {
+			action.Manager = set.Name
+			action.pm = set.Manager
+			installs = append(installs, action)
+		}
+	}
+	return installs
+}
+
+// missingPackages returns install actions for configured packages that are not installed
+func missingPackages(packages []string, pkgType string, pm pkgmanager.PackageManager) []PlanAction {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	isInstalled := installedChecker(pkgType, pm)
+	var actions []PlanAction
+	for _, pkg := range packages {
+		if !isInstalled(pkg) {
+			actions = append(actions, PlanAction{Kind: pkgType, Name: pkg, Action: "install"})
+		}
+	}
+
+	return actions
+}
+
+// installedPackages returns uninstall actions for the given packages that are installed
+func installedPackages(packages []string, pkgType string, pm pkgmanager.PackageManager) []PlanAction {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	isInstalled := installedChecker(pkgType, pm)
+	var actions []PlanAction
+	for _, pkg := range packages {
+		if isInstalled(pkg) {
+			actions = append(actions, PlanAction{Kind: pkgType, Name: pkg, Action: "uninstall"})
+		}
+	}
+
+	return actions
+}
+
+// installedChecker returns a function reporting whether a package of
+// pkgType is installed, listing the installed packages once
+func installedChecker(pkgType string, pm pkgmanager.PackageManager) func(string) bool {
+	installedSet := make(map[string]bool)
+	if installed, err := pm.ListInstalled(pkgType); err == nil {
+		for _, pkg := range installed {
+			installedSet[pkg] = true
+		}
+	}
+
+	return func(pkg string) bool {
+		if installedSet[pkg] {
+			return true
+		}
+		if len(installedSet) == 0 {
+			// Listing failed or returned nothing, fall back to a direct check
+			ok, err := pm.IsInstalled(pkg, pkgType)
+			return err == nil && ok
+		}
+		return false
+	}
+}
+
+// hookActions turns hooks into run actions for the payload's event, leaving
+// out hooks whose OS or host condition does not match this machine
+func hookActions(list []config.Hook, payload hooks.Payload) []PlanAction {
+	phase := payload.HookType()
+	if payload.Event == hooks.Install && len(payload.Packages) == 1 {
+		phase = payload.Packages[0] + " " + phase
+	}
+
+	engine := hooks.NewEngine()
+	var actions []PlanAction
+	for _, hook := range list {
+		if engine.SkipReason(hook) != "" {
+			continue
+		}
+		actions = append(actions, PlanAction{Kind: "hook", Name: hook.Command, Action: "run", Phase: phase, hook: hook, payload: payload})
+	}
+	return actions
+}
+
+// secretActions returns decrypt actions for encrypted private files that are
+// missing on this machine. Local files that differ are left alone.
+func secretActions() []PlanAction {
+	statuses, err := secretStore().Status()
+	if err != nil {
+		fmt.Printf("⚠️  Could not read private.enc/: %v\n", err)
+		return nil
+	}
+
+	var actions []PlanAction
+	unreadable := 0
+	for _, status := range statuses {
+		switch status.State {
+		case secrets.Missing:
+			actions = append(actions, PlanAction{Kind: "secret", Name: status.Path, Action: "decrypt"})
+		case secrets.Unreadable:
+			unreadable++
+		}
+	}
+
+	if _, err := secretStore().Identities(); err != nil && len(actions) > 0 {
+		fmt.Printf("⚠️  %d file(s) in private.enc/ cannot be decrypted: %v\n\n", len(actions), err)
+		return nil
+	}
+	if unreadable > 0 {
+		fmt.Printf("⚠️  %d file(s) in private.enc/ cannot be decrypted with this machine's key\n\n", unreadable)
+	}
+	return actions
+}
+
+// scriptActions returns run actions for the pending scripts
+func scriptActions() []PlanAction {
+	pending, state, err := pendingScripts()
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+		return nil
+	}
+
+	var actions []PlanAction
+	for _, script := range pending {
+		detail := "run once"
+		if state.Status(script) == "changed" {
+			detail = "changed"
+		} else if script.Kind == scripts.OnChange {
+			detail = "first run"
+		}
+		actions = append(actions, PlanAction{Kind: "script", Name: script.Name, Action: "run", Detail: detail, script: script})
+	}
+	return actions
+}
+
+// actionNames returns the names of the actions
+func actionNames(actions []PlanAction) []string {
+	names := make([]string, 0, len(actions))
+	for _, action := range actions {
+		names = append(names, action.Name)
+	}
+	return names
+}
+
+func printApplyPlan(plan ApplyPlan) {
+	fmt.Println("📋 Apply Plan")
+	fmt.Println(strings.Repeat("=", 13))
+	fmt.Printf("Package Manager: %s\n", plan.PackageManager)
+	fmt.Println()
+
+	if plan.Converged {
+		fmt.Println("✅ No changes. This machine already matches your configuration.")
+		return
+	}
+
+	installs, links, decrypts, hookCount, scriptCount := 0, 0, 0, 0, 0
+	for _, action := range plan.Actions {
+		switch action.Kind {
+		case "secret":
+			decrypts++
+			fmt.Printf("  + %-6s %s\n", action.Kind, action.Name)
+		case "hook":
+			hookCount++
+			fmt.Printf("  > %-6s %s: %s\n", action.Kind, action.Phase, action.Name)
+		case "script":
+			scriptCount++
+			fmt.Printf("  > %-6s %s (%s)\n", action.Kind, action.Name, action.Detail)
+		case "stow":
+			links++
+			line := fmt.Sprintf("  ~ %-6s %s", action.Kind, action.Name)
+			if action.Detail != "" {
+				line += fmt.Sprintf(" (%s)", action.Detail)
+			}
+			fmt.Println(line)
+		default:
+			installs++
+			fmt.Println(describePackageAction("+", action))
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Plan: %d to decrypt, %d to install, %d to link, %d hook(s) and %d script(s) to run.\n", decrypts, installs, links, hookCount, scriptCount)
+}
+
+// describePackageAction formats a package action as a plan line, naming
+// the package manager of packages listed under "packages"
+func describePackageAction(sign string, action PlanAction) string {
+	line := fmt.Sprintf("  %s %-6s %s", sign, action.Kind, action.Name)
+	if action.Manager != "" {
+		line += fmt.Sprintf(" (%s)", action.Manager)
+	}
+	return line
+}
+
+// executeApplyPlan runs the plan in order and returns the number of failed actions
+func executeApplyPlan(plan ApplyPlan, pm pkgmanager.PackageManager) int {
+	failed := 0
+	var scriptState *scripts.State
+
+	for _, action := range plan.Actions {
+		var err error
+		switch action.Kind {
+		case "hook":
+			err = RunHooks([]config.Hook{action.hook}, action.payload)
+		case "script":
+			fmt.Printf("📜 Running %s...\n", action.Name)
+			if scriptState == nil {
+				scriptState, err = scripts.LoadState(appCtx.ScriptsStatePath())
+			}
+			if err == nil {
+				err = runScript(action.script, scriptState)
+			}
+		case "secret":
+			fmt.Printf("🔓 Decrypting %s...\n", action.Name)
+			_, err = secretStore().Decrypt([]string{action.Name}, false)
+		case "stow":
+			if action.Action == "unlink" {
+				fmt.Printf("✂️  Unlinking %s...\n", action.Name)
+				err = unstowPackages([]string{action.Name}, appCtx.StowDir(), appCtx.Home)
+			} else {
+				fmt.Printf("🔗 Linking %s...\n", action.Name)
+				err = stowPackages([]string{action.Name}, appCtx.StowDir(), appCtx.Home)
+			}
+		default:
+			pm := pm
+			if action.pm != nil {
+				pm = action.pm
+			}
+			if action.Action == "uninstall" {
+				fmt.Printf("🗑️  Uninstalling %s %s...\n", action.Kind, action.Name)
+				if uninstaller, ok := pm.(pkgmanager.Uninstaller); ok {
+					err = uninstaller.Uninstall([]string{action.Name}, action.Kind)
+				} else {
+					err = fmt.Errorf("%s can't uninstall packages", pm.GetName())
+				}
+				break
+			}
+			fmt.Printf("📦 Installing %s %s...\n", action.Kind, action.Name)
+			err = pm.Install([]string{action.Name}, action.Kind)
+		}
+
+		if err != nil {
+			fmt.Printf("   ❌ %s %s failed: %v\n", action.Kind, action.Name, err)
+			failed++
+		} else if action.Kind != "hook" {
+			fmt.Printf("   ✅ %s\n", action.Name)
+		}
+	}
+
+	return failed
+}
+
+func init() {
+	applyCmd.Flags().Bool("plan", false, "Only show the plan without applying it")
+	applyCmd.Flags().Bool("json", false, "Output the plan as JSON (same as --output json)")
+	applyCmd.Flags().BoolP("yes", "y", false, "Apply without asking for confirmation")
+	applyCmd.Flags().Bool("no-snapshot", false, "Skip creating automatic snapshot before applying")
+	applyCmd.Flags().Bool("detailed-exitcode", false, "Exit with code 2 when the machine has not converged")
+
+	rootCmd.AddCommand(applyCmd)
+}
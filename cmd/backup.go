@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"dotfiles/internal/config"
 	"github.com/spf13/cobra"
@@ -17,13 +16,7 @@ var backupCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		backupPath := args[0]
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 
 		// Load current config
 		cfg, err := config.Load(configPath)
@@ -59,13 +52,7 @@ var restoreCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 
 		// Create backup of current config if it exists
 		if !cmd.Flags().Changed("no-backup") {
@@ -122,4 +109,4 @@ func init() {
 
 	rootCmd.AddCommand(backupCmd)
 	rootCmd.AddCommand(restoreCmd)
-}
\ No newline at end of file
+}
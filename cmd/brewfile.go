@@ -3,9 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
-	"dotfiles/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -14,14 +12,7 @@ var brewfileCmd = &cobra.Command{
 	Short: "Generate a Brewfile from your configuration",
 	Long:  `Creates a Brewfile based on your config.json packages`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
-		cfg, err := config.Load(configPath)
+		cfg, err := loadMergedConfig()
 		if err != nil {
 			fmt.Printf("Error loading configuration: %v\n", err)
 			os.Exit(1)
@@ -29,7 +20,7 @@ var brewfileCmd = &cobra.Command{
 
 		brewfileContent := cfg.GenerateBrewfile()
 
-		output, _ := cmd.Flags().GetString("output")
+		output, _ := cmd.Flags().GetString("file")
 		if output == "" {
 			output = "./Brewfile"
 		}
@@ -44,6 +35,7 @@ var brewfileCmd = &cobra.Command{
 }
 
 func init() {
-	brewfileCmd.Flags().StringP("output", "o", "./Brewfile", "Output path for the Brewfile")
+	brewfileCmd.Flags().StringP("file", "o", "./Brewfile", "Output path for the Brewfile")
+	brewfileCmd.Annotations = map[string]string{legacyOutputPath: "file"} // --output used to be the path
 	rootCmd.AddCommand(brewfileCmd)
-}
\ No newline at end of file
+}
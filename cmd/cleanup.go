@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"dotfiles/internal/linker"
 	"github.com/spf13/cobra"
 )
 
@@ -21,14 +22,22 @@ What gets cleaned:
 • Old versions of upgraded casks
 • Homebrew download cache
 • Symlinks to deleted formulas
+• Dotfile links whose source was removed from its stow package
 
 Examples:
   dotfiles cleanup              # Clean up old versions and cache
   dotfiles cleanup --dry-run    # Show what would be cleaned
-  dotfiles cleanup --cache-only # Only clear cache, keep old versions`,
+  dotfiles cleanup --cache-only # Only clear cache, keep old versions
+  dotfiles cleanup --links      # Only remove orphaned dotfile links`,
 	Run: func(cmd *cobra.Command, args []string) {
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		cacheOnly, _ := cmd.Flags().GetBool("cache-only")
+		linksOnly, _ := cmd.Flags().GetBool("links")
+
+		if linksOnly {
+			cleanupOrphanedLinks(dryRun)
+			return
+		}
 
 		// Check if brew is available
 		if _, err := exec.LookPath("brew"); err != nil {
@@ -96,6 +105,9 @@ Examples:
 				}
 			}
 			fmt.Println()
+
+			cleanupOrphanedLinks(dryRun)
+			fmt.Println()
 		}
 
 		// Step 5: Show disk space after cleanup
@@ -138,9 +150,73 @@ func showOldVersions() {
 	cmd.Run()
 }
 
+// cleanupOrphanedLinks removes recorded dotfile links whose source no longer
+// exists and forgets records for links that were replaced outside dotfiles
+func cleanupOrphanedLinks(dryRun bool) {
+	fmt.Println("🔗 Cleaning up orphaned dotfile links...")
+
+	manifest := loadLinkManifest()
+	if manifest == nil || len(manifest.Packages) == 0 {
+		fmt.Println("✅ No recorded links")
+		return
+	}
+
+	removed, forgotten := 0, 0
+	for _, pkg := range manifest.PackageNames() {
+		record := manifest.Packages[pkg]
+		opts := linker.Options{
+			Dir:      record.StowDir,
+			Target:   record.TargetDir,
+			Dotfiles: record.Dotfiles,
+			DryRun:   dryRun,
+		}
+
+		lnk, err := linker.New(opts)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping %s: %v\n", pkg, err)
+			continue
+		}
+
+		ops, err := lnk.Prune(manifest.Orphans(pkg))
+		if err != nil {
+			fmt.Printf("⚠️  Error cleaning %s: %v\n", pkg, err)
+			continue
+		}
+		for _, op := range ops {
+			if dryRun {
+				fmt.Printf("[DRY RUN] Would remove %s (%s)\n", op.Path, pkg)
+			} else {
+				fmt.Printf("   🗑️  Removed %s (%s)\n", op.Path, pkg)
+			}
+		}
+		removed += len(ops)
+		manifest.Apply(pkg, opts, ops)
+
+		// Links that were deleted or replaced are no longer ours to manage
+		for _, drift := range manifest.Verify(pkg) {
+			if drift.Problem == "missing" || drift.Problem == "replaced" || drift.Problem == "retargeted" {
+				manifest.Remove(drift.Target)
+				forgotten++
+			}
+		}
+	}
+
+	if dryRun {
+		return
+	}
+
+	saveLinkManifest(manifest)
+	if removed == 0 && forgotten == 0 {
+		fmt.Println("✅ No orphaned links found")
+	} else {
+		fmt.Printf("✅ Removed %d orphaned link(s), forgot %d stale record(s)\n", removed, forgotten)
+	}
+}
+
 func init() {
 	cleanupCmd.Flags().Bool("dry-run", false, "Show what would be cleaned without actually cleaning")
 	cleanupCmd.Flags().Bool("cache-only", false, "Only clear cache, don't remove old versions")
+	cleanupCmd.Flags().Bool("links", false, "Only remove orphaned dotfile links")
 
 	rootCmd.AddCommand(cleanupCmd)
 }
@@ -0,0 +1,487 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dotfiles/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "⚙️  Inspect, validate, convert and migrate the layered configuration",
+	Long: `⚙️  Layered Configuration
+
+config.json (or config.yaml / config.toml) is the base of a stack of files,
+merged in this order:
+
+• config.json              - Shared by every machine
+• config.<os>.json         - Per OS, e.g. config.darwin.json, config.linux.json
+• config.<hostname>.json   - Per machine (short hostname, then the full one)
+• profiles/<name>.json     - The active profile, if any
+
+Overlay files use the same keys as config.json, in any of the formats (e.g.
+config.linux.yaml). List entries are added, and
+an entry starting with ! removes one inherited from a lower layer:
+
+  config.linux.json: {"casks": ["!docker"], "packages": {"flatpak": ["org.gimp.GIMP"]}}
+
+Map keys (package_versions, package_mappings, variables, ...) override lower
+layers, and a key starting with ! removes the inherited key. Hooks from every
+layer run, lowest layer first. Hooks from overlay files are quarantined unless
+the file is in "trusted_sources" in config.json, e.g. after
+'dotfiles hooks trust config.darwin.json --remember'; trusted_sources in
+overlay files are ignored.
+
+'list', 'install', 'status', 'diff', 'apply' and 'doctor' use the merged view.
+Commands that change the configuration, such as 'add' and 'remove', edit
+config.json only.
+
+The active profile is the --profile flag, $DOTFILES_PROFILE, or the one set
+with 'dotfiles config profile <name>' (stored per machine in state/profile).
+
+Examples:
+  dotfiles config layers          # Show which files are merged on this machine
+  dotfiles config explain docker  # Show which layers added or removed docker
+  dotfiles config profile work    # Layer profiles/work.json on this machine
+  dotfiles config convert yaml    # Switch to config.yaml, which allows comments
+  dotfiles config migrate         # Upgrade files written by older versions
+  dotfiles config validate        # Check every file against the schema`,
+}
+
+// configFormats are the formats the configuration can be written in
+var configFormats = []string{"json", "yaml", "toml"}
+
+var configConvertCmd = &cobra.Command{
+	Use:   "convert <json|yaml|toml>",
+	Short: "Convert the configuration file to another format",
+	Long: `Rewrite config.json as config.yaml or config.toml (or back). Every command
+detects the format from the file name, and edits such as 'dotfiles add' keep
+the comments and key order of YAML and TOML files.
+
+Comments can't be carried over from one format to another. Overlay files
+(config.<os>.*, config.<hostname>.*) may use any format and are not converted.
+
+Examples:
+  dotfiles config convert yaml    # config.json -> config.yaml
+  dotfiles config convert toml    # -> config.toml`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := args[0]
+		if !contains(configFormats, format) {
+			fmt.Printf("❌ Unknown format '%s' (use %s)\n", format, strings.Join(configFormats, ", "))
+			os.Exit(1)
+		}
+
+		source := appCtx.ConfigPath()
+		if config.Format(source) == format {
+			fmt.Printf("✅ %s is already %s\n", filepath.Base(source), format)
+			return
+		}
+		if _, err := os.Stat(source); err != nil {
+			fmt.Printf("❌ No configuration at %s\n", source)
+			os.Exit(1)
+		}
+
+		cfg, err := config.Load(source)
+		if err != nil {
+			fmt.Printf("❌ Error loading configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		target := filepath.Join(appCtx.Root, "config."+format)
+		if _, err := os.Stat(target); err == nil {
+			fmt.Printf("❌ %s already exists\n", target)
+			os.Exit(1)
+		}
+		if err := cfg.Save(target); err != nil {
+			fmt.Printf("❌ Error writing %s: %v\n", target, err)
+			os.Exit(1)
+		}
+		if err := os.Remove(source); err != nil {
+			fmt.Printf("❌ Error removing %s: %v\n", source, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("✅ Converted %s to %s\n", filepath.Base(source), filepath.Base(target))
+	},
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade configuration files written by older versions",
+	Long: `Upgrade config.json, its overlays, profiles and snapshots to the current
+configuration version. Files are edited in place, keeping their comments and
+key order, after a copy of each is saved to backups/migrate-<time>/.
+
+Older files are also upgraded in memory whenever they are read, so migrating
+is only needed to update the files themselves.
+
+Examples:
+  dotfiles config migrate --dry-run  # Show which files would be upgraded
+  dotfiles config migrate            # Upgrade them`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		var plans []*config.MigrationPlan
+		for _, path := range migrationFiles() {
+			plan, err := config.PlanMigration(path)
+			if err != nil {
+				fmt.Printf("❌ Error reading %s: %v\n", relToRoot(path), err)
+				os.Exit(1)
+			}
+			if len(plan.Applied) > 0 {
+				plans = append(plans, plan)
+			}
+		}
+
+		if len(plans) == 0 {
+			fmt.Printf("✅ Every file is at configuration version %d\n", config.CurrentVersion)
+			return
+		}
+
+		if dryRun {
+			fmt.Println("🔍 Dry run - the following files would be upgraded:")
+		} else {
+			fmt.Println("🔄 Upgrading configuration files:")
+		}
+		fmt.Println()
+		for _, plan := range plans {
+			fmt.Printf("  📄 %s: version %d → %d\n", relToRoot(plan.Path), plan.From, config.CurrentVersion)
+			for _, migration := range plan.Applied {
+				fmt.Printf("     • %s\n", migration.Description)
+			}
+		}
+		if dryRun {
+			return
+		}
+
+		backupDir := filepath.Join(appCtx.BackupsDir(), "migrate-"+time.Now().Format("20060102-150405"))
+		for _, plan := range plans {
+			if _, err := plan.Backup(backupDir); err != nil {
+				fmt.Printf("❌ Error backing up %s: %v\n", relToRoot(plan.Path), err)
+				os.Exit(1)
+			}
+			if err := plan.Write(); err != nil {
+				fmt.Printf("❌ Error upgrading %s: %v\n", relToRoot(plan.Path), err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Println()
+		fmt.Printf("✅ Upgraded %d file(s); originals saved to %s\n", len(plans), relToRoot(backupDir))
+	},
+}
+
+// migrationFiles lists the files holding configuration: config.json, its
+// overlays, profiles and snapshots
+func migrationFiles() []string {
+	var files []string
+	if _, err := os.Stat(appCtx.ConfigPath()); err == nil {
+		files = append(files, appCtx.ConfigPath())
+	}
+	for _, ext := range config.Extensions {
+		overlays, _ := filepath.Glob(filepath.Join(appCtx.Root, "config.*"+ext))
+		for _, overlay := range overlays {
+			if overlay != appCtx.ConfigPath() {
+				files = append(files, overlay)
+			}
+		}
+	}
+	for _, ext := range config.Extensions {
+		profiles, _ := filepath.Glob(filepath.Join(appCtx.ProfilesDir(), "*"+ext))
+		files = append(files, profiles...)
+	}
+	snapshots, _ := filepath.Glob(filepath.Join(appCtx.SnapshotsDir(), "*.json"))
+	return append(files, snapshots...)
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Check configuration files against the schema",
+	Long: `Check config.json, its overlays and profiles (or the given files) against
+the JSON Schema printed by 'dotfiles schema config'. Each problem is reported
+with its line and JSON path, e.g.
+
+  • line 8: $.hooks.post_install[0].comand: unknown field
+
+Exits with status 2 when a file has problems and 1 when one can't be read.
+
+Examples:
+  dotfiles config validate
+  dotfiles config validate profiles/work.yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		files := args
+		if len(files) == 0 {
+			files = configFiles()
+		}
+		if len(files) == 0 {
+			fmt.Printf("❌ No configuration at %s\n", appCtx.ConfigPath())
+			os.Exit(1)
+		}
+
+		fmt.Println("🔍 Validating configuration:")
+		fmt.Println()
+		failed, unreadable := 0, 0
+		for _, path := range files {
+			schema, prefix := configSchema(), []string(nil)
+			if wrapped, err := config.IsWrapped(path); err == nil && wrapped {
+				schema, prefix = profileSchema(), []string{"config"}
+			}
+			if err := config.ValidateFile(path, schema, prefix...); err != nil {
+				if isUnreadable(err) {
+					unreadable++
+					fmt.Printf("  ❌ %s: %v\n", relToRoot(path), err)
+					continue
+				}
+				failed++
+				fmt.Printf("  ❌ %s (%d problem(s))\n", relToRoot(path), problemCount(err))
+				printProblems(err)
+				continue
+			}
+			fmt.Printf("  ✅ %s\n", relToRoot(path))
+		}
+
+		fmt.Println()
+		if unreadable > 0 {
+			fmt.Printf("❌ %d of %d file(s) couldn't be read\n", unreadable, len(files))
+			os.Exit(ExitError)
+		}
+		if failed > 0 {
+			fmt.Printf("❌ %d of %d file(s) have problems\n", failed, len(files))
+			os.Exit(ExitDrift)
+		}
+		fmt.Printf("✅ %d file(s) are valid\n", len(files))
+	},
+}
+
+// configFiles lists the configuration files that exist: config.json, the
+// overlays for this machine and every profile
+func configFiles() []string {
+	var files []string
+	for _, layer := range config.LayerPaths(appCtx.ConfigPath(), appCtx.Profile()) {
+		if _, err := os.Stat(layer.Path); err == nil && !contains(files, layer.Path) {
+			files = append(files, layer.Path)
+		}
+	}
+	for _, ext := range config.Extensions {
+		profiles, _ := filepath.Glob(filepath.Join(appCtx.ProfilesDir(), "*"+ext))
+		for _, profile := range profiles {
+			if !contains(files, profile) {
+				files = append(files, profile)
+			}
+		}
+	}
+	return files
+}
+
+var configLayersCmd = &cobra.Command{
+	Use:   "layers",
+	Short: "Show the configuration files merged on this machine",
+	Run: func(cmd *cobra.Command, args []string) {
+		stack, err := loadConfigStack()
+		if err != nil {
+			fmt.Printf("❌ Error loading configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("⚙️  Configuration layers (lowest first):")
+		fmt.Println()
+		for _, candidate := range config.LayerPaths(appCtx.ConfigPath(), appCtx.Profile()) {
+			layer, found := findLayer(stack, candidate.Path)
+			if !found {
+				fmt.Printf("  ➖ %-8s %s (not present)\n", candidate.Name, relToRoot(candidate.Path))
+				continue
+			}
+			cfg := layer.Config
+			fmt.Printf("  ✅ %-8s %s (%d brews, %d casks, %d taps, %d stow)\n", layer.Name, relToRoot(layer.Path),
+				len(cfg.Brews), len(cfg.Casks), len(cfg.Taps), len(cfg.Stow))
+		}
+
+		merged := stack.Config
+		fmt.Println()
+		fmt.Printf("📊 Merged: %d brews, %d casks, %d taps, %d stow packages\n",
+			len(merged.Brews), len(merged.Casks), len(merged.Taps), len(merged.Stow))
+		if appCtx.Profile() == "" {
+			fmt.Println("💡 No active profile. Set one with 'dotfiles config profile <name>'")
+		}
+	},
+}
+
+var configExplainCmd = &cobra.Command{
+	Use:   "explain <package>",
+	Short: "Show which layers contributed a package",
+	Long: `Show every configuration layer that added, removed or configured a package
+(or tap, stow package or other entry), and whether it is in the merged
+configuration.
+
+Examples:
+  dotfiles config explain docker
+  dotfiles config explain vim`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		stack, err := loadConfigStack()
+		if err != nil {
+			fmt.Printf("❌ Error loading configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		origins := stack.Explain(name)
+		if len(origins) == 0 {
+			fmt.Printf("🔍 %s is not in any configuration layer\n", name)
+			return
+		}
+
+		fmt.Printf("🔍 %s:\n", name)
+		fmt.Println()
+		for _, origin := range origins {
+			switch {
+			case origin.Removed:
+				fmt.Printf("  ➖ %-24s removed from %s\n", origin.Layer.Label(), origin.Field)
+			case origin.Value != "":
+				fmt.Printf("  ✏️  %-24s %s = %s\n", origin.Layer.Label(), origin.Field, origin.Value)
+			default:
+				fmt.Printf("  ➕ %-24s %s\n", origin.Layer.Label(), origin.Field)
+			}
+		}
+
+		fmt.Println()
+		if fields := mergedFields(stack.Config, name); len(fields) > 0 {
+			fmt.Printf("📋 Merged: %s\n", strings.Join(fields, ", "))
+		} else {
+			fmt.Println("📋 Merged: not configured on this machine")
+		}
+	},
+}
+
+var configProfileCmd = &cobra.Command{
+	Use:   "profile [name]",
+	Short: "Show or set the profile layered on this machine",
+	Long: `Show or set the configuration profile layered on config.json on this machine.
+The profile is read from profiles/<name>.json; it is recorded in state/profile,
+which is not shared. --profile and $DOTFILES_PROFILE override it.
+
+Examples:
+  dotfiles config profile          # Show the active profile
+  dotfiles config profile work     # Layer profiles/work.json
+  dotfiles config profile --clear  # Stop layering a profile`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clearProfile, _ := cmd.Flags().GetBool("clear")
+
+		if clearProfile {
+			if err := os.Remove(appCtx.ActiveProfilePath()); err != nil && !os.IsNotExist(err) {
+				fmt.Printf("❌ Error clearing profile: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("✅ No profile is layered on this machine")
+			return
+		}
+
+		if len(args) == 0 {
+			if profile := appCtx.Profile(); profile != "" {
+				fmt.Printf("📋 Active profile: %s\n", profile)
+			} else {
+				fmt.Println("📋 No active profile")
+			}
+			return
+		}
+
+		name := args[0]
+		path := config.FindFile(appCtx.ProfilesDir(), name)
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("❌ Profile '%s' not found at %s\n", name, path)
+			os.Exit(1)
+		}
+
+		if err := appCtx.EnsureStateDir(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(appCtx.ActiveProfilePath(), []byte(name+"\n"), 0644); err != nil {
+			fmt.Printf("❌ Error saving profile: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Layering profile '%s' on this machine\n", name)
+	},
+}
+
+// loadConfigStack reads config.json with the overlays for this machine
+func loadConfigStack() (*config.Stack, error) {
+	return config.LoadStack(appCtx.ConfigPath(), appCtx.Profile())
+}
+
+// loadMergedConfig returns the configuration for this machine, with every
+// layer merged. Use config.Load for configuration that is saved back.
+func loadMergedConfig() (*config.Config, error) {
+	return config.LoadMerged(appCtx.ConfigPath(), appCtx.Profile())
+}
+
+// findLayer returns the loaded layer read from path
+func findLayer(stack *config.Stack, path string) (config.Layer, bool) {
+	for _, layer := range stack.Layers {
+		if layer.Path == path {
+			return layer, true
+		}
+	}
+	return config.Layer{}, false
+}
+
+// mergedFields lists the fields of the merged configuration naming name
+func mergedFields(cfg *config.Config, name string) []string {
+	var fields []string
+	lists := []struct {
+		field   string
+		entries []string
+	}{
+		{"brews", cfg.Brews},
+		{"casks", cfg.Casks},
+		{"taps", cfg.Taps},
+		{"stow", cfg.Stow},
+	}
+	for _, list := range lists {
+		if contains(list.entries, name) {
+			fields = append(fields, list.field)
+		}
+	}
+	var managers []string
+	for manager, packages := range cfg.Packages {
+		if contains(packages, name) {
+			managers = append(managers, "packages."+manager)
+		}
+	}
+	sort.Strings(managers)
+	fields = append(fields, managers...)
+	if version, ok := cfg.PackageVersions[name]; ok {
+		fields = append(fields, "version "+version)
+	}
+	return fields
+}
+
+// relToRoot returns path relative to the dotfiles root when it is inside it
+func relToRoot(path string) string {
+	if rel, err := filepath.Rel(appCtx.Root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func init() {
+	configProfileCmd.Flags().Bool("clear", false, "Stop layering a profile on this machine")
+	configMigrateCmd.Flags().Bool("dry-run", false, "Show which files would be upgraded without changing them")
+
+	configCmd.AddCommand(configConvertCmd)
+	configCmd.AddCommand(configLayersCmd)
+	configCmd.AddCommand(configMigrateCmd)
+	configCmd.AddCommand(configExplainCmd)
+	configCmd.AddCommand(configProfileCmd)
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
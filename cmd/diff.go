@@ -2,12 +2,9 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
-	"dotfiles/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -23,84 +20,112 @@ Examples:
   dotfiles diff                # Show all differences
   dotfiles diff --type=brews   # Only show brew differences
   dotfiles diff --type=casks   # Only show cask differences
-  dotfiles diff --verbose      # Show all packages including synced ones`,
+  dotfiles diff --type=flatpak # Only show one package manager from "packages"
+  dotfiles diff --verbose      # Show all packages including synced ones
+  dotfiles diff --output json  # Every package, for scripts
+
+Exits with code 2 when there are differences.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		pkgType, _ := cmd.Flags().GetString("type")
 		verbose, _ := cmd.Flags().GetBool("verbose")
 
-		home, err := os.UserHomeDir()
+		cfg, err := loadMergedConfig()
 		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
+			fail("Error loading configuration", err)
 		}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
-		cfg, err := config.Load(configPath)
-		if err != nil {
-			fmt.Printf("❌ Error loading configuration: %v\n", err)
-			os.Exit(1)
-		}
-
-		fmt.Println("📊 Configuration Diff")
-		fmt.Println("=" + strings.Repeat("=", 21))
-		fmt.Println()
+		report := DiffReport{Diffs: []PackageDiff{}}
 
-		hasDiff := false
+		// The system package manager's list includes its entry under "packages", if any
+		sets, _ := configuredManagers(cfg)
+		brews := cfg.Brews
+		for _, set := range sets {
+			if set.Primary {
+				brews = set.Brews
+			}
+		}
 
 		// Diff brews
 		if pkgType == "" || pkgType == "brews" || pkgType == "brew" {
-			diffBrews := getDiffBrews(cfg.Brews, verbose)
-			if diffBrews.HasDiff || verbose {
-				fmt.Println("🍺 Homebrew Formulas:")
-				printDiff(diffBrews, verbose)
-				fmt.Println()
-				hasDiff = hasDiff || diffBrews.HasDiff
+			if diff, err := getDiffBrews(brews); err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Could not get installed brews: %v", err))
+			} else {
+				report.add(diff)
 			}
 		}
 
 		// Diff casks
 		if pkgType == "" || pkgType == "casks" || pkgType == "cask" {
-			diffCasks := getDiffCasks(cfg.Casks, verbose)
-			if diffCasks.HasDiff || verbose {
-				fmt.Println("📦 Homebrew Casks:")
-				printDiff(diffCasks, verbose)
-				fmt.Println()
-				hasDiff = hasDiff || diffCasks.HasDiff
+			if diff, err := getDiffCasks(cfg.Casks); err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Could not get installed casks: %v", err))
+			} else {
+				report.add(diff)
 			}
 		}
 
-		// Summary
-		if !hasDiff && !verbose {
-			fmt.Println("✅ No differences found - everything is in sync!")
-		} else if hasDiff {
-			fmt.Println("💡 Suggested actions:")
-			fmt.Println("   • Install missing packages: dotfiles install")
-			fmt.Println("   • Add untracked packages: dotfiles scan")
-			fmt.Println("   • Remove from config: dotfiles remove <package>")
+		// Diff other package managers (flatpak, cargo, ...)
+		diffOtherManagers(&report, sets, pkgType)
+
+		code := ExitOK
+		if report.HasDiff {
+			code = ExitDrift
 		}
+		printReport(report, code, func() { printDiffReport(report, verbose) })
 	},
 }
 
+// DiffReport is the result of 'dotfiles diff'
+type DiffReport struct {
+	Diffs    []PackageDiff `json:"diffs"`
+	Warnings []string      `json:"warnings,omitempty"`
+	HasDiff  bool          `json:"has_diff"`
+}
+
+func (r *DiffReport) add(diff PackageDiff) {
+	r.Diffs = append(r.Diffs, diff)
+	r.HasDiff = r.HasDiff || diff.HasDiff
+}
+
+// PackageDiff compares the configured packages of one type or package
+// manager with the installed ones
 type PackageDiff struct {
-	Missing  []string // In config but not installed
-	Extra    []string // Installed but not in config
-	Synced   []string // Both in config and installed
-	HasDiff  bool
+	Type    string   `json:"type"`    // brews, casks or a package manager name
+	Missing []string `json:"missing"` // In config but not installed
+	Extra   []string `json:"extra"`   // Installed but not in config
+	Synced  []string `json:"synced"`  // Both in config and installed
+	HasDiff bool     `json:"has_diff"`
+}
+
+func getDiffBrews(configured []string) (PackageDiff, error) {
+	installed, err := getInstalledBrews()
+	if err != nil {
+		return PackageDiff{}, err
+	}
+
+	diff := diffPackages(configured, installed)
+	diff.Type = "brews"
+	return diff, nil
 }
 
-func getDiffBrews(configured []string, verbose bool) PackageDiff {
+func getDiffCasks(configured []string) (PackageDiff, error) {
+	installed, err := getInstalledCasks()
+	if err != nil {
+		return PackageDiff{}, err
+	}
+
+	diff := diffPackages(configured, installed)
+	diff.Type = "casks"
+	return diff, nil
+}
+
+// diffPackages compares configured packages with installed ones
+func diffPackages(configured, installed []string) PackageDiff {
 	diff := PackageDiff{
 		Missing: []string{},
 		Extra:   []string{},
 		Synced:  []string{},
 	}
 
-	installed, err := getInstalledBrews()
-	if err != nil {
-		fmt.Printf("⚠️  Could not get installed brews: %v\n", err)
-		return diff
-	}
-
 	installedMap := make(map[string]bool)
 	for _, pkg := range installed {
 		installedMap[pkg] = true
@@ -136,52 +161,70 @@ func getDiffBrews(configured []string, verbose bool) PackageDiff {
 	return diff
 }
 
-func getDiffCasks(configured []string, verbose bool) PackageDiff {
-	diff := PackageDiff{
-		Missing: []string{},
-		Extra:   []string{},
-		Synced:  []string{},
-	}
+// diffOtherManagers diffs the packages listed for package managers other
+// than the system one
+func diffOtherManagers(report *DiffReport, sets []managerSet, pkgType string) {
+	for _, set := range sets {
+		if set.Primary || (pkgType != "" && pkgType != set.Name) {
+			continue
+		}
+		if set.Manager == nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Unknown package manager %q in config", set.Name))
+			continue
+		}
+		if !set.Available() {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s not found, skipping %d package(s)", set.Name, set.Count()))
+			continue
+		}
 
-	installed, err := getInstalledCasks()
-	if err != nil {
-		fmt.Printf("⚠️  Could not get installed casks: %v\n", err)
-		return diff
-	}
+		installed, err := set.Manager.ListInstalled("brew")
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Could not get installed %s packages: %v", set.Name, err))
+			continue
+		}
 
-	installedMap := make(map[string]bool)
-	for _, pkg := range installed {
-		installedMap[pkg] = true
+		diff := diffPackages(set.Brews, installed)
+		diff.Type = set.Name
+		report.add(diff)
 	}
+}
 
-	configuredMap := make(map[string]bool)
-	for _, pkg := range configured {
-		configuredMap[pkg] = true
-	}
+// printDiffReport prints a diff report for people. Sections without
+// differences are only shown with verbose.
+func printDiffReport(report DiffReport, verbose bool) {
+	fmt.Println("📊 Configuration Diff")
+	fmt.Println("=" + strings.Repeat("=", 21))
+	fmt.Println()
 
-	// Find missing (in config but not installed)
-	for _, pkg := range configured {
-		if !installedMap[pkg] {
-			diff.Missing = append(diff.Missing, pkg)
-			diff.HasDiff = true
-		} else {
-			diff.Synced = append(diff.Synced, pkg)
-		}
+	for _, warning := range report.Warnings {
+		fmt.Printf("⚠️  %s\n\n", warning)
 	}
 
-	// Find extra (installed but not in config)
-	for _, pkg := range installed {
-		if !configuredMap[pkg] {
-			diff.Extra = append(diff.Extra, pkg)
-			diff.HasDiff = true
+	for _, diff := range report.Diffs {
+		if !diff.HasDiff && !verbose {
+			continue
 		}
+		switch diff.Type {
+		case "brews":
+			fmt.Println("🍺 Homebrew Formulas:")
+		case "casks":
+			fmt.Println("📦 Homebrew Casks:")
+		default:
+			fmt.Printf("📦 %s Packages:\n", diff.Type)
+		}
+		printDiff(diff, verbose)
+		fmt.Println()
 	}
 
-	sort.Strings(diff.Missing)
-	sort.Strings(diff.Extra)
-	sort.Strings(diff.Synced)
-
-	return diff
+	// Summary
+	if !report.HasDiff && !verbose {
+		fmt.Println("✅ No differences found - everything is in sync!")
+	} else if report.HasDiff {
+		fmt.Println("💡 Suggested actions:")
+		fmt.Println("   • Install missing packages: dotfiles install")
+		fmt.Println("   • Add untracked packages: dotfiles scan")
+		fmt.Println("   • Remove from config: dotfiles remove <package>")
+	}
 }
 
 func printDiff(diff PackageDiff, verbose bool) {
@@ -212,7 +255,7 @@ func printDiff(diff PackageDiff, verbose bool) {
 }
 
 func init() {
-	diffCmd.Flags().String("type", "", "Filter by type (brews, casks)")
+	diffCmd.Flags().String("type", "", "Filter by type (brews, casks) or package manager name")
 	diffCmd.Flags().BoolP("verbose", "v", false, "Show all packages including synced ones")
 
 	rootCmd.AddCommand(diffCmd)
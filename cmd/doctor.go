@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"dotfiles/internal/config"
+	"dotfiles/internal/linker"
 	"dotfiles/internal/pkgmanager"
 	"github.com/spf13/cobra"
 )
@@ -29,184 +30,232 @@ Runs comprehensive diagnostics on your dotfiles setup to identify issues:
 Examples:
   dotfiles doctor              # Run all health checks
   dotfiles doctor --fix        # Auto-fix common issues
-  dotfiles doctor --verbose    # Show detailed output`,
+  dotfiles doctor --verbose    # Show detailed output
+  dotfiles doctor --output json
+
+Exits with code 2 when an issue is found; warnings alone exit with 0.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fix, _ := cmd.Flags().GetBool("fix")
 		verbose, _ := cmd.Flags().GetBool("verbose")
 
-		fmt.Println("🏥 Running Dotfiles Health Check...")
-		fmt.Println("=" + strings.Repeat("=", 35))
-		fmt.Println()
-
-		issues := 0
-		warnings := 0
+		report := DoctorReport{Checks: []HealthCheck{}}
 
 		// Check 1: Dotfiles directory exists
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Cannot get home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		dotfilesDir := filepath.Join(home, ".dotfiles")
+		dotfilesDir := appCtx.Root
 		if _, err := os.Stat(dotfilesDir); os.IsNotExist(err) {
-			fmt.Println("❌ Dotfiles directory not found")
-			fmt.Println("   Expected: ~/.dotfiles")
-			fmt.Println("   💡 Run: dotfiles setup <repo-url> or dotfiles init")
-			issues++
+			report.add(HealthCheck{
+				Section: "directory",
+				Status:  "error",
+				Message: "Dotfiles directory not found",
+				Details: []string{"Expected: " + dotfilesDir},
+				Hints:   []string{"Run: dotfiles setup <repo-url> or dotfiles init"},
+			}, 1)
 		} else {
-			fmt.Println("✅ Dotfiles directory exists")
+			check := HealthCheck{Section: "directory", Status: "ok", Message: "Dotfiles directory exists"}
 			if verbose {
-				fmt.Printf("   Location: %s\n", dotfilesDir)
+				check.Details = []string{"Location: " + dotfilesDir}
 			}
+			report.add(check, 0)
 		}
-		fmt.Println()
 
 		// Check 2: Configuration file
-		configPath := filepath.Join(dotfilesDir, "config.json")
-		cfg, err := config.Load(configPath)
+		// Overlays (config.<os>.json, config.<hostname>.json, profiles/) are merged
+		var cfg *config.Config
+		stack, err := loadConfigStack()
 		if err != nil {
-			fmt.Println("❌ Configuration file invalid or missing")
-			fmt.Printf("   Error: %v\n", err)
-			fmt.Println("   💡 Run: dotfiles init")
-			issues++
+			report.add(HealthCheck{
+				Section: "config",
+				Status:  "error",
+				Message: "Configuration file invalid or missing",
+				Details: []string{fmt.Sprintf("Error: %v", err)},
+				Hints:   []string{"Run: dotfiles init"},
+			}, 1)
 		} else {
-			fmt.Println("✅ Configuration file is valid")
+			cfg = stack.Config
+			check := HealthCheck{Section: "config", Status: "ok", Message: "Configuration file is valid"}
 			if verbose {
+				for _, layer := range stack.Layers[1:] {
+					check.Details = append(check.Details, "Layer: "+layer.Label())
+				}
 				totalPkgs := len(cfg.Brews) + len(cfg.Casks) + len(cfg.Taps) + len(cfg.Stow)
-				fmt.Printf("   Packages: %d total (%d brews, %d casks, %d taps, %d stow)\n",
-					totalPkgs, len(cfg.Brews), len(cfg.Casks), len(cfg.Taps), len(cfg.Stow))
+				check.Details = append(check.Details, fmt.Sprintf("Packages: %d total (%d brews, %d casks, %d taps, %d stow)",
+					totalPkgs, len(cfg.Brews), len(cfg.Casks), len(cfg.Taps), len(cfg.Stow)))
 			}
+			report.add(check, 0)
 		}
-		fmt.Println()
 
 		// Check 3: Git repository
 		gitDir := filepath.Join(dotfilesDir, ".git")
 		if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-			fmt.Println("⚠️  Not a git repository")
-			fmt.Println("   💡 Run: git init in ~/.dotfiles to enable version control")
-			warnings++
+			report.add(HealthCheck{
+				Section: "git",
+				Status:  "warning",
+				Message: "Not a git repository",
+				Hints:   []string{fmt.Sprintf("Run: git init in %s to enable version control", dotfilesDir)},
+			}, 1)
 		} else {
-			fmt.Println("✅ Git repository initialized")
+			report.add(HealthCheck{Section: "git", Status: "ok", Message: "Git repository initialized"}, 0)
 
 			// Check for remote
-			os.Chdir(dotfilesDir)
 			remoteCmd := exec.Command("git", "remote", "-v")
+			remoteCmd.Dir = dotfilesDir
 			remoteOutput, _ := remoteCmd.Output()
 			if len(remoteOutput) == 0 {
-				fmt.Println("   ⚠️  No remote repository configured")
-				fmt.Println("   💡 Add remote: git remote add origin <url>")
-				warnings++
+				report.add(HealthCheck{
+					Section: "git",
+					Status:  "warning",
+					Message: "No remote repository configured",
+					Hints:   []string{"Add remote: git remote add origin <url>"},
+				}, 1)
 			} else if verbose {
-				fmt.Println("   Remote configured")
+				report.add(HealthCheck{Section: "git", Status: "ok", Message: "Remote configured"}, 0)
 			}
 		}
-		fmt.Println()
 
 		// Check 4: Required dependencies
-		fmt.Println("📋 Checking Dependencies...")
-
 		// Get package manager
 		pm, err := pkgmanager.GetPackageManager()
 		pmAvailable := false
 		if err == nil && pm.IsAvailable() {
-			fmt.Printf("✅ %s installed\n", pm.GetName())
+			report.add(HealthCheck{Section: "dependencies", Status: "ok", Message: pm.GetName() + " installed"}, 0)
 			pmAvailable = true
 		} else {
-			fmt.Printf("❌ Package manager not found\n")
-			issues++
+			check := HealthCheck{Section: "dependencies", Status: "error", Message: "Package manager not found"}
 			if runtime.GOOS == "darwin" {
-				fmt.Println("   💡 Install Homebrew: /bin/bash -c \"$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)\"")
+				check.Hints = []string{"Install Homebrew: /bin/bash -c \"$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)\""}
 			}
+			report.add(check, 1)
 		}
 
 		// Check git
 		if _, err := exec.LookPath("git"); err == nil {
-			fmt.Printf("✅ git installed\n")
+			report.add(HealthCheck{Section: "dependencies", Status: "ok", Message: "git installed"}, 0)
 		} else {
-			fmt.Printf("❌ git not found\n")
-			issues++
+			check := HealthCheck{Section: "dependencies", Status: "error", Message: "git not found"}
 			if pmAvailable {
 				if pm.GetName() == "homebrew" {
-					fmt.Println("   💡 Install git: brew install git")
+					check.Hints = []string{"Install git: brew install git"}
 				} else if pm.GetName() == "pacman" {
-					fmt.Println("   💡 Install git: sudo pacman -S git")
+					check.Hints = []string{"Install git: sudo pacman -S git"}
 				} else {
-					fmt.Println("   💡 Install git with your package manager")
+					check.Hints = []string{"Install git with your package manager"}
 				}
 			}
+			report.add(check, 1)
 		}
 
-		// Check stow
-		if _, err := exec.LookPath("stow"); err == nil {
-			fmt.Printf("✅ stow installed\n")
-		} else {
-			fmt.Printf("❌ stow not found\n")
-			issues++
-			if pmAvailable {
-				if pm.GetName() == "homebrew" {
-					fmt.Println("   💡 Install GNU Stow: brew install stow")
-				} else if pm.GetName() == "pacman" {
-					fmt.Println("   💡 Install GNU Stow: sudo pacman -S stow")
-				} else {
-					fmt.Println("   💡 Install GNU Stow with your package manager")
-				}
-			}
+		// Symlinks are created by the built-in linker, GNU Stow is optional
+		if verbose {
+			report.add(HealthCheck{Section: "dependencies", Status: "ok", Message: "Symlinks managed natively (GNU Stow not required)"}, 0)
 		}
-		fmt.Println()
 
 		// Check 5: Broken symlinks
 		if cfg != nil && len(cfg.Stow) > 0 {
-			fmt.Println("🔗 Checking Symlinks...")
-			brokenLinks := checkBrokenSymlinks(home, verbose)
-			if len(brokenLinks) > 0 {
-				fmt.Printf("❌ Found %d broken symlinks:\n", len(brokenLinks))
-				for _, link := range brokenLinks {
-					fmt.Printf("   • %s\n", link)
+			manifest := loadLinkManifest()
+			if manifest != nil && len(manifest.Packages) > 0 {
+				// Recorded links tell us exactly what to check
+				drift := manifest.VerifyAll()
+				if len(drift) > 0 {
+					check := HealthCheck{
+						Section: "symlinks",
+						Status:  "error",
+						Message: fmt.Sprintf("Found %d drifted links", len(drift)),
+						Hints:   []string{"Run: dotfiles restow <package>"},
+					}
+					for _, d := range drift {
+						check.Details = append(check.Details, fmt.Sprintf("• %s (%s, package %s)", d.Target, d.Problem, d.Package))
+					}
+					if fix {
+						check.Details = append(check.Details, "🔧 Removed orphaned links")
+						humanOutputToStderr(func() { cleanupOrphanedLinks(false) })
+					}
+					report.add(check, len(drift))
+				} else {
+					check := HealthCheck{Section: "symlinks", Status: "ok", Message: "No broken symlinks found"}
+					if verbose {
+						linkCount := 0
+						for _, record := range manifest.Packages {
+							linkCount += len(record.Links)
+						}
+						check.Details = []string{fmt.Sprintf("%d recorded links verified", linkCount)}
+					}
+					report.add(check, 0)
 				}
-				if fix {
-					fmt.Println("   🔧 Auto-fix not implemented for broken symlinks")
-					fmt.Println("   💡 Run: dotfiles restow <package>")
+
+				// Files rendered from .tmpl templates that are out of date
+				renderer := templateRenderer()
+				var stale []linker.Drift
+				for _, pkg := range manifest.PackageNames() {
+					stale = append(stale, manifest.VerifyRendered(pkg, renderer)...)
+				}
+				if len(stale) > 0 {
+					check := HealthCheck{
+						Section: "symlinks",
+						Status:  "warning",
+						Message: fmt.Sprintf("Found %d stale rendered templates", len(stale)),
+						Hints:   []string{"Run: dotfiles restow <package>"},
+					}
+					for _, d := range stale {
+						check.Details = append(check.Details, fmt.Sprintf("• %s (%s, package %s)", d.Target, d.Problem, d.Package))
+					}
+					report.add(check, len(stale))
 				}
-				issues += len(brokenLinks)
 			} else {
-				fmt.Println("✅ No broken symlinks found")
+				brokenLinks := checkBrokenSymlinks(appCtx.Home, verbose)
+				if len(brokenLinks) > 0 {
+					check := HealthCheck{
+						Section: "symlinks",
+						Status:  "error",
+						Message: fmt.Sprintf("Found %d broken symlinks", len(brokenLinks)),
+						Hints:   []string{"Run: dotfiles restow <package>"},
+					}
+					for _, link := range brokenLinks {
+						check.Details = append(check.Details, "• "+link)
+					}
+					if fix {
+						check.Details = append(check.Details, "🔧 Auto-fix not implemented for broken symlinks")
+					}
+					report.add(check, len(brokenLinks))
+				} else {
+					report.add(HealthCheck{Section: "symlinks", Status: "ok", Message: "No broken symlinks found"}, 0)
+				}
 			}
-			fmt.Println()
 		}
 
 		// Check 6: Configuration drift
 		if pmAvailable && cfg != nil {
-			fmt.Println("📊 Checking Configuration Drift...")
 			drift := checkConfigDrift(cfg)
+			report.Drift = &drift
 			if drift.MissingBrews > 0 || drift.MissingCasks > 0 {
-				fmt.Printf("⚠️  Configuration drift detected:\n")
+				check := HealthCheck{
+					Section: "drift",
+					Status:  "warning",
+					Message: "Configuration drift detected",
+					Hints:   []string{"Run: dotfiles diff"},
+				}
 				if drift.MissingBrews > 0 {
-					fmt.Printf("   • %d packages configured but not installed\n", drift.MissingBrews)
+					check.Details = append(check.Details, fmt.Sprintf("• %d packages configured but not installed", drift.MissingBrews))
 				}
 				if drift.MissingCasks > 0 && runtime.GOOS == "darwin" {
-					fmt.Printf("   • %d casks configured but not installed\n", drift.MissingCasks)
+					check.Details = append(check.Details, fmt.Sprintf("• %d casks configured but not installed", drift.MissingCasks))
 				}
 				if drift.ExtraBrews > 0 {
-					fmt.Printf("   • %d packages installed but not in config\n", drift.ExtraBrews)
+					check.Details = append(check.Details, fmt.Sprintf("• %d packages installed but not in config", drift.ExtraBrews))
 				}
 				if drift.ExtraCasks > 0 && runtime.GOOS == "darwin" {
-					fmt.Printf("   • %d casks installed but not in config\n", drift.ExtraCasks)
+					check.Details = append(check.Details, fmt.Sprintf("• %d casks installed but not in config", drift.ExtraCasks))
 				}
-				fmt.Println("   💡 Run: dotfiles diff")
 				if drift.ExtraBrews > 0 || drift.ExtraCasks > 0 {
-					fmt.Println("   💡 Run: dotfiles scan to add missing packages")
+					check.Hints = append(check.Hints, "Run: dotfiles scan to add missing packages")
 				}
-				warnings++
+				report.add(check, 1)
 			} else {
-				fmt.Println("✅ Configuration in sync with installed packages")
+				report.add(HealthCheck{Section: "drift", Status: "ok", Message: "Configuration in sync with installed packages"}, 0)
 			}
-			fmt.Println()
 		}
 
 		// Check 7: Stow directory structure
 		if cfg != nil && len(cfg.Stow) > 0 {
-			fmt.Println("📁 Checking Stow Packages...")
 			stowDir := filepath.Join(dotfilesDir, "stow")
 			missingPkgs := []string{}
 			for _, pkg := range cfg.Stow {
@@ -216,39 +265,114 @@ Examples:
 				}
 			}
 			if len(missingPkgs) > 0 {
-				fmt.Printf("❌ %d stow packages missing:\n", len(missingPkgs))
+				check := HealthCheck{Section: "stow", Status: "error", Message: fmt.Sprintf("%d stow packages missing", len(missingPkgs))}
 				for _, pkg := range missingPkgs {
-					fmt.Printf("   • %s (expected at: stow/%s)\n", pkg, pkg)
+					check.Details = append(check.Details, fmt.Sprintf("• %s (expected at: stow/%s)", pkg, pkg))
 				}
-				issues += len(missingPkgs)
+				report.add(check, len(missingPkgs))
 			} else {
-				fmt.Println("✅ All stow packages exist")
+				report.add(HealthCheck{Section: "stow", Status: "ok", Message: "All stow packages exist"}, 0)
 			}
-			fmt.Println()
 		}
 
-		// Summary
-		fmt.Println("=" + strings.Repeat("=", 35))
-		if issues == 0 && warnings == 0 {
-			fmt.Println("🎉 All checks passed! Your dotfiles are healthy.")
-		} else {
-			if issues > 0 {
-				fmt.Printf("❌ Found %d issue(s)\n", issues)
+		report.Healthy = report.Issues == 0
+		code := ExitOK
+		if !report.Healthy {
+			code = ExitDrift
+		}
+		printReport(report, code, func() { printDoctorReport(report) })
+	},
+}
+
+// DoctorReport is the result of 'dotfiles doctor'
+type DoctorReport struct {
+	Checks   []HealthCheck `json:"checks"`
+	Drift    *ConfigDrift  `json:"drift,omitempty"` // Set when the package manager is available
+	Issues   int           `json:"issues"`
+	Warnings int           `json:"warnings"`
+	Healthy  bool          `json:"healthy"` // No issues; warnings are allowed
+}
+
+// HealthCheck is the outcome of one doctor check
+type HealthCheck struct {
+	Section string   `json:"section"` // directory, config, git, dependencies, symlinks, drift or stow
+	Status  string   `json:"status"`  // ok, warning or error
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+	Hints   []string `json:"hints,omitempty"` // Commands that fix the problem
+}
+
+// add records a check, counting count issues or warnings for it
+func (r *DoctorReport) add(check HealthCheck, count int) {
+	switch check.Status {
+	case "error":
+		r.Issues += count
+	case "warning":
+		r.Warnings += count
+	}
+	r.Checks = append(r.Checks, check)
+}
+
+// doctorSections are the headings of the sections that have one
+var doctorSections = map[string]string{
+	"dependencies": "📋 Checking Dependencies...",
+	"symlinks":     "🔗 Checking Symlinks...",
+	"drift":        "📊 Checking Configuration Drift...",
+	"stow":         "📁 Checking Stow Packages...",
+}
+
+// printDoctorReport prints a doctor report for people
+func printDoctorReport(report DoctorReport) {
+	fmt.Println("🏥 Running Dotfiles Health Check...")
+	fmt.Println("=" + strings.Repeat("=", 35))
+	fmt.Println()
+
+	icons := map[string]string{"ok": "✅", "warning": "⚠️ ", "error": "❌"}
+	for i, check := range report.Checks {
+		if i == 0 || check.Section != report.Checks[i-1].Section {
+			if i > 0 {
+				fmt.Println()
 			}
-			if warnings > 0 {
-				fmt.Printf("⚠️  Found %d warning(s)\n", warnings)
+			if heading, ok := doctorSections[check.Section]; ok {
+				fmt.Println(heading)
 			}
-			fmt.Println()
-			fmt.Println("💡 Review the suggestions above to fix issues")
 		}
-	},
+
+		fmt.Printf("%s %s\n", icons[check.Status], check.Message)
+		for _, detail := range check.Details {
+			fmt.Printf("   %s\n", detail)
+		}
+		for _, hint := range check.Hints {
+			fmt.Printf("   💡 %s\n", hint)
+		}
+	}
+	if len(report.Checks) > 0 {
+		fmt.Println()
+	}
+
+	// Summary
+	fmt.Println("=" + strings.Repeat("=", 35))
+	if report.Issues == 0 && report.Warnings == 0 {
+		fmt.Println("🎉 All checks passed! Your dotfiles are healthy.")
+	} else {
+		if report.Issues > 0 {
+			fmt.Printf("❌ Found %d issue(s)\n", report.Issues)
+		}
+		if report.Warnings > 0 {
+			fmt.Printf("⚠️  Found %d warning(s)\n", report.Warnings)
+		}
+		fmt.Println()
+		fmt.Println("💡 Review the suggestions above to fix issues")
+	}
 }
 
+// ConfigDrift counts the packages that differ between the configuration
+// and the system
 type ConfigDrift struct {
-	MissingBrews int
-	MissingCasks int
-	ExtraBrews   int
-	ExtraCasks   int
+	MissingBrews int `json:"missing_brews"`
+	MissingCasks int `json:"missing_casks"`
+	ExtraBrews   int `json:"extra_brews"`
+	ExtraCasks   int `json:"extra_casks"`
 }
 
 func checkBrokenSymlinks(homeDir string, verbose bool) []string {
@@ -34,23 +34,17 @@ Examples:
   dotfiles export work-mac                            # Export current config as "work-mac"
   dotfiles export personal --description="Home setup" # With description
   dotfiles export minimal --brews-only                # Only export brews
-  dotfiles export full --output=~/my-profile.json     # Custom output location`,
+  dotfiles export full --file=~/my-profile.json       # Custom output location`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		profileName := args[0]
 		description, _ := cmd.Flags().GetString("description")
-		output, _ := cmd.Flags().GetString("output")
+		output, _ := cmd.Flags().GetString("file")
 		brewsOnly, _ := cmd.Flags().GetBool("brews-only")
 		casksOnly, _ := cmd.Flags().GetBool("casks-only")
 		machine, _ := cmd.Flags().GetString("machine")
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -91,7 +85,7 @@ Examples:
 
 		// Determine output path
 		if output == "" {
-			profilesDir := filepath.Join(home, ".dotfiles", "profiles")
+			profilesDir := appCtx.ProfilesDir()
 			os.MkdirAll(profilesDir, 0755)
 			output = filepath.Join(profilesDir, profileName+".json")
 		}
@@ -134,6 +128,8 @@ var importProfileCmd = &cobra.Command{
 
 Import a previously exported machine profile.
 You can merge it with your current config or replace it entirely.
+Hooks in the profile are quarantined until approved with 'dotfiles hooks trust',
+unless you pass --trust or the profile's path is in "trusted_sources".
 
 Examples:
   dotfiles import-profile work-mac.json              # Import and merge
@@ -144,6 +140,7 @@ Examples:
 		profilePath := args[0]
 		replace, _ := cmd.Flags().GetBool("replace")
 		install, _ := cmd.Flags().GetBool("install")
+		trust, _ := cmd.Flags().GetBool("trust")
 
 		// Read profile
 		data, err := os.ReadFile(profilePath)
@@ -167,17 +164,25 @@ Examples:
 		fmt.Printf("   Created: %s\n", profile.CreatedAt)
 		fmt.Println()
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
+		if profile.Config == nil {
+			fmt.Println("❌ Profile has no configuration")
 			os.Exit(1)
 		}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
+		hookSource := absPath(profilePath)
 
 		if replace {
-			// Replace entire config
-			if err := profile.Config.Save(configPath); err != nil {
+			// Replace entire config; hooks are imported with provenance and
+			// trusted_sources are kept from the current config
+			newCfg := withoutHooks(profile.Config)
+			if current, err := config.Load(configPath); err == nil {
+				newCfg.TrustedSources = current.TrustedSources
+			}
+			printIncomingHooks(newCfg, profile.Config, hookSource, trust)
+			importHooks(newCfg, profile.Config, hookSource, trust)
+
+			if err := newCfg.Save(configPath); err != nil {
 				fmt.Printf("❌ Error saving configuration: %v\n", err)
 				os.Exit(1)
 			}
@@ -187,7 +192,7 @@ Examples:
 			cfg, err := config.Load(configPath)
 			if err != nil {
 				// If config doesn't exist, use profile config
-				cfg = profile.Config
+				cfg = withoutHooks(profile.Config)
 			} else {
 				// Merge
 				cfg.Brews = mergeUnique(cfg.Brews, profile.Config.Brews)
@@ -195,6 +200,8 @@ Examples:
 				cfg.Taps = mergeUnique(cfg.Taps, profile.Config.Taps)
 				cfg.Stow = mergeUnique(cfg.Stow, profile.Config.Stow)
 			}
+			printIncomingHooks(cfg, profile.Config, hookSource, trust)
+			importHooks(cfg, profile.Config, hookSource, trust)
 
 			if err := cfg.Save(configPath); err != nil {
 				fmt.Printf("❌ Error saving configuration: %v\n", err)
@@ -218,30 +225,16 @@ Examples:
 var listProfilesCmd = &cobra.Command{
 	Use:   "list-profiles",
 	Short: "📋 List all exported profiles",
-	Long:  `📋 List all machine-specific profiles stored in ~/.dotfiles/profiles/`,
+	Long:  `📋 List all machine-specific profiles stored in profiles/ of the dotfiles root`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		profilesDir := filepath.Join(home, ".dotfiles", "profiles")
-		if _, err := os.Stat(profilesDir); os.IsNotExist(err) {
-			fmt.Println("📋 No profiles found")
-			fmt.Println()
-			fmt.Println("💡 Create a profile:")
-			fmt.Println("   dotfiles export <profile-name>")
-			return
-		}
+		result := ProfileList{Profiles: []ProfileInfo{}}
 
+		profilesDir := appCtx.ProfilesDir()
 		entries, err := os.ReadDir(profilesDir)
-		if err != nil {
-			fmt.Printf("❌ Error reading profiles directory: %v\n", err)
-			os.Exit(1)
+		if err != nil && !os.IsNotExist(err) {
+			fail("Error reading profiles directory", err)
 		}
 
-		profiles := []MachineProfile{}
 		for _, entry := range entries {
 			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
 				profilePath := filepath.Join(profilesDir, entry.Name())
@@ -255,32 +248,79 @@ var listProfilesCmd = &cobra.Command{
 					continue
 				}
 
-				profiles = append(profiles, profile)
+				info := ProfileInfo{
+					Name:        profile.Name,
+					Description: profile.Description,
+					Machine:     profile.Machine,
+					Platform:    profile.Platform,
+					CreatedAt:   profile.CreatedAt,
+					File:        "profiles/" + entry.Name(),
+				}
+				if profile.Config != nil {
+					info.Packages = PackageCounts{
+						Brews: len(profile.Config.Brews),
+						Casks: len(profile.Config.Casks),
+						Taps:  len(profile.Config.Taps),
+						Stow:  len(profile.Config.Stow),
+					}
+				}
+				result.Profiles = append(result.Profiles, info)
 			}
 		}
 
-		if len(profiles) == 0 {
-			fmt.Println("📋 No profiles found")
-			return
-		}
+		printReport(result, ExitOK, func() { printProfileList(result) })
+	},
+}
 
-		fmt.Printf("📋 Found %d profile(s):\n", len(profiles))
+// ProfileList is the result of 'dotfiles list-profiles'
+type ProfileList struct {
+	Profiles []ProfileInfo `json:"profiles"`
+}
+
+// ProfileInfo summarizes an exported machine profile
+type ProfileInfo struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Machine     string        `json:"machine,omitempty"`
+	Platform    string        `json:"platform,omitempty"`
+	CreatedAt   string        `json:"created_at"`
+	Packages    PackageCounts `json:"packages"`
+	File        string        `json:"file"` // Relative to the dotfiles root
+}
+
+func printProfileList(result ProfileList) {
+	if len(result.Profiles) == 0 {
+		fmt.Println("📋 No profiles found")
 		fmt.Println()
+		fmt.Println("💡 Create a profile:")
+		fmt.Println("   dotfiles export <profile-name>")
+		return
+	}
 
-		for i, profile := range profiles {
-			fmt.Printf("%d. %s\n", i+1, profile.Name)
-			if profile.Description != "" {
-				fmt.Printf("   Description: %s\n", profile.Description)
-			}
-			fmt.Printf("   Created: %s\n", profile.CreatedAt)
-			fmt.Printf("   Packages: %d brews, %d casks, %d taps\n",
-				len(profile.Config.Brews),
-				len(profile.Config.Casks),
-				len(profile.Config.Taps))
-			fmt.Printf("   File: profiles/%s.json\n", profile.Name)
-			fmt.Println()
+	fmt.Printf("📋 Found %d profile(s):\n", len(result.Profiles))
+	fmt.Println()
+
+	for i, profile := range result.Profiles {
+		fmt.Printf("%d. %s\n", i+1, profile.Name)
+		if profile.Description != "" {
+			fmt.Printf("   Description: %s\n", profile.Description)
 		}
-	},
+		fmt.Printf("   Created: %s\n", profile.CreatedAt)
+		fmt.Printf("   Packages: %d brews, %d casks, %d taps\n",
+			profile.Packages.Brews, profile.Packages.Casks, profile.Packages.Taps)
+		fmt.Printf("   File: %s\n", profile.File)
+		fmt.Println()
+	}
+}
+
+// withoutHooks returns a copy of cfg without hooks or trusted sources, for
+// importing the hooks separately with their provenance
+func withoutHooks(cfg *config.Config) *config.Config {
+	copied := *cfg
+	copied.Hooks = nil
+	copied.PackageConfigs = nil
+	copied.TrustedSources = nil
+	return &copied
 }
 
 func mergeUnique(a, b []string) []string {
@@ -306,13 +346,15 @@ func mergeUnique(a, b []string) []string {
 
 func init() {
 	exportCmd.Flags().StringP("description", "d", "", "Profile description")
-	exportCmd.Flags().StringP("output", "o", "", "Output file path (default: ~/.dotfiles/profiles/<name>.json)")
+	exportCmd.Flags().StringP("file", "o", "", "Output file path (default: profiles/<name>.json in the dotfiles root)")
+	exportCmd.Annotations = map[string]string{legacyOutputPath: "file"} // --output used to be the path
 	exportCmd.Flags().Bool("brews-only", false, "Only export brew packages")
 	exportCmd.Flags().Bool("casks-only", false, "Only export cask packages")
 	exportCmd.Flags().StringP("machine", "m", "", "Machine identifier (e.g., 'work-macbook-pro')")
 
 	importProfileCmd.Flags().Bool("replace", false, "Replace current config instead of merging")
 	importProfileCmd.Flags().Bool("install", false, "Install packages after importing")
+	importProfileCmd.Flags().Bool("trust", false, "Trust the profile's hooks instead of quarantining them")
 
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(importProfileCmd)
@@ -50,7 +50,7 @@ var githubSetupCmd = &cobra.Command{
 		}
 
 		// Use private directory for SSH keys
-		privateSshDir := filepath.Join(home, ".dotfiles", "private", ".ssh")
+		privateSshDir := filepath.Join(appCtx.PrivateDir(), ".ssh")
 		keyPath := filepath.Join(privateSshDir, "id_"+keyType)
 		pubKeyPath := keyPath + ".pub"
 
@@ -201,7 +201,7 @@ func showPublicKey(pubKeyPath string) {
 }
 
 func setupSSHStowPackage(home, privateSshDir string) error {
-	stowDir := filepath.Join(home, ".dotfiles", "stow")
+	stowDir := appCtx.StowDir()
 	sshStowDir := filepath.Join(stowDir, "ssh")
 
 	// Create ssh stow package directory
@@ -260,4 +260,4 @@ func init() {
 	githubCmd.AddCommand(githubSetupCmd)
 	githubCmd.AddCommand(githubTestCmd)
 	rootCmd.AddCommand(githubCmd)
-}
\ No newline at end of file
+}
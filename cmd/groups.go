@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
@@ -32,20 +31,10 @@ var groupsListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all package groups",
 	Run: func(cmd *cobra.Command, args []string) {
-		home, _ := os.UserHomeDir()
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
-			fmt.Printf("❌ Error loading configuration: %v\n", err)
-			os.Exit(1)
-		}
-
-		if cfg.Groups == nil || len(cfg.Groups) == 0 {
-			fmt.Println("📋 No groups defined")
-			fmt.Println()
-			fmt.Println("💡 Create a group:")
-			fmt.Println("   dotfiles groups create <name> <package1>,<package2>,...")
-			return
+			fail("Error loading configuration", err)
 		}
 
 		// Sort groups by name
@@ -55,25 +44,52 @@ var groupsListCmd = &cobra.Command{
 		}
 		sort.Strings(groupNames)
 
-		fmt.Printf("🏷️  Found %d group(s):\n", len(cfg.Groups))
-		fmt.Println("=" + strings.Repeat("=", 30))
-		fmt.Println()
-
+		result := GroupList{Groups: []Group{}}
 		for _, name := range groupNames {
-			packages := cfg.Groups[name]
-			fmt.Printf("📦 %s (%d packages)\n", name, len(packages))
-			if len(packages) > 0 {
-				fmt.Printf("   %s\n", strings.Join(packages, ", "))
-			}
-			fmt.Println()
+			result.Groups = append(result.Groups, Group{Name: name, Packages: cfg.Groups[name]})
 		}
 
-		fmt.Println("💡 Usage:")
-		fmt.Println("   dotfiles groups show <name>       # View group details")
-		fmt.Println("   dotfiles groups install <name>    # Install group packages")
+		printReport(result, ExitOK, func() { printGroupList(result) })
 	},
 }
 
+// GroupList is the result of 'dotfiles groups list'
+type GroupList struct {
+	Groups []Group `json:"groups"` // By name
+}
+
+// Group is a named set of packages
+type Group struct {
+	Name     string   `json:"name"`
+	Packages []string `json:"packages"`
+}
+
+func printGroupList(result GroupList) {
+	if len(result.Groups) == 0 {
+		fmt.Println("📋 No groups defined")
+		fmt.Println()
+		fmt.Println("💡 Create a group:")
+		fmt.Println("   dotfiles groups create <name> <package1>,<package2>,...")
+		return
+	}
+
+	fmt.Printf("🏷️  Found %d group(s):\n", len(result.Groups))
+	fmt.Println("=" + strings.Repeat("=", 30))
+	fmt.Println()
+
+	for _, group := range result.Groups {
+		fmt.Printf("📦 %s (%d packages)\n", group.Name, len(group.Packages))
+		if len(group.Packages) > 0 {
+			fmt.Printf("   %s\n", strings.Join(group.Packages, ", "))
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("💡 Usage:")
+	fmt.Println("   dotfiles groups show <name>       # View group details")
+	fmt.Println("   dotfiles groups install <name>    # Install group packages")
+}
+
 var groupsCreateCmd = &cobra.Command{
 	Use:   "create <name> <packages>",
 	Short: "Create a new package group",
@@ -87,8 +103,7 @@ Example:
 		groupName := args[0]
 		packagesStr := args[1]
 
-		home, _ := os.UserHomeDir()
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -137,8 +152,7 @@ var groupsAddCmd = &cobra.Command{
 		groupName := args[0]
 		packageName := args[1]
 
-		home, _ := os.UserHomeDir()
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -182,8 +196,7 @@ var groupsRemoveCmd = &cobra.Command{
 		groupName := args[0]
 		packageName := args[1]
 
-		home, _ := os.UserHomeDir()
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -229,8 +242,7 @@ var groupsShowCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		groupName := args[0]
 
-		home, _ := os.UserHomeDir()
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -264,8 +276,7 @@ var groupsInstallCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		groupName := args[0]
 
-		home, _ := os.UserHomeDir()
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
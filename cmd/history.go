@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"dotfiles/internal/config"
+	"dotfiles/internal/journal"
+	"dotfiles/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "📜 Show the changes made to your configuration",
+	Long: `📜 Configuration History
+
+Every command that changes config.json, an overlay or a profile (add,
+remove, groups, hooks, import, clone, templates, restores and so on) is
+recorded in state/journal.jsonl with its arguments and what it changed.
+The journal only grows; undoing a change records another entry.
+
+Examples:
+  dotfiles history          # Last 20 changes
+  dotfiles history -n 50    # Last 50 changes
+  dotfiles history --all    # Everything`,
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("number")
+		all, _ := cmd.Flags().GetBool("all")
+
+		entries, err := journal.Read(appCtx.JournalPath())
+		if err != nil {
+			fail("Error reading history", err)
+		}
+
+		undone := journal.Undone(entries)
+		result := HistoryResult{Changes: []HistoryEntry{}, Total: len(entries)}
+		for i := len(entries) - 1; i >= 0 && (all || len(result.Changes) < limit); i-- {
+			entry := historyEntry(entries[i], entries[i].Changes)
+			entry.UndoneBy = undone[entries[i].ID]
+			result.Changes = append(result.Changes, entry)
+		}
+
+		printReport(result, ExitOK, func() { printHistory(result) })
+	},
+}
+
+// HistoryResult is the result of 'dotfiles history'
+type HistoryResult struct {
+	Changes []HistoryEntry `json:"changes"` // Newest first
+	Total   int            `json:"total"`   // Changes recorded, including those not shown
+}
+
+// HistoryEntry is a recorded configuration change
+type HistoryEntry struct {
+	ID       int             `json:"id"`
+	Time     time.Time       `json:"time"`
+	Command  string          `json:"command"` // e.g. "dotfiles add git"
+	File     string          `json:"file"`    // Relative to the dotfiles root
+	Changes  []config.Change `json:"changes"`
+	Undoes   int             `json:"undoes,omitempty"`    // Change this entry undid
+	UndoneBy int             `json:"undone_by,omitempty"` // Change that undid this entry
+}
+
+// historyEntry describes a journal entry with the given changes
+func historyEntry(entry journal.Entry, changes []config.Change) HistoryEntry {
+	if changes == nil {
+		changes = []config.Change{}
+	}
+	return HistoryEntry{
+		ID:      entry.ID,
+		Time:    entry.Time,
+		Command: describeCommand(entry),
+		File:    entry.File,
+		Changes: changes,
+		Undoes:  entry.Undoes,
+	}
+}
+
+func printHistory(result HistoryResult) {
+	if result.Total == 0 {
+		fmt.Println("📜 No configuration changes recorded yet")
+		return
+	}
+
+	fmt.Println("📜 Configuration History")
+	fmt.Println(strings.Repeat("=", 24))
+	fmt.Println()
+
+	for _, entry := range result.Changes {
+		line := fmt.Sprintf("#%-4d %s  %s", entry.ID, entry.Time.Local().Format("2006-01-02 15:04"), entry.Command)
+		if entry.File != filepath.Base(appCtx.ConfigPath()) {
+			line += fmt.Sprintf("  (%s)", entry.File)
+		}
+		if entry.UndoneBy != 0 {
+			line += fmt.Sprintf("  [undone by #%d]", entry.UndoneBy)
+		}
+		fmt.Println(line)
+		printChanges(entry.Changes, "       ")
+	}
+
+	if len(result.Changes) < result.Total {
+		fmt.Println()
+		fmt.Printf("💡 %d older change(s) not shown, use --all to see them\n", result.Total-len(result.Changes))
+	}
+}
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [n]",
+	Short: "↩️  Undo the last configuration changes",
+	Long: `↩️  Undo
+
+Reverse the last n configuration changes recorded by 'dotfiles history'
+(default 1), newest first. Each file gets back exactly the content it had,
+comments included. Changes already undone are skipped, so running undo
+again goes further back.
+
+Undo refuses to run when a file was edited after the change being undone,
+since that edit would be lost; --force undoes anyway. A snapshot is taken
+first, and installed packages and links are left alone.
+
+Examples:
+  dotfiles undo             # Undo the last change
+  dotfiles undo 3           # Undo the last three changes
+  dotfiles undo --dry-run   # Show what would be undone`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		n := 1
+		if len(args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				fail("Invalid number of changes", fmt.Errorf("%q", args[0]))
+			}
+		}
+
+		entries, err := journal.Read(appCtx.JournalPath())
+		if err != nil {
+			fail("Error reading history", err)
+		}
+
+		undo := journal.Undoable(entries, n)
+		result := UndoResult{Changes: []HistoryEntry{}, DryRun: dryRun, Undone: []UndoneChange{}}
+		for _, entry := range undo {
+			result.Changes = append(result.Changes, historyEntry(entry, journal.Reversal(entry, "", nil).Changes))
+		}
+		if len(undo) == 0 {
+			printReport(result, ExitOK, func() { fmt.Println("📜 Nothing to undo") })
+			return
+		}
+
+		// Progress and the prompt go to stderr with structured output, so
+		// stdout only holds the result
+		humanOutputToStderr(func() {
+			fmt.Printf("↩️  Undoing %d change(s):\n", len(undo))
+			fmt.Println()
+			for _, entry := range result.Changes {
+				fmt.Printf("#%-4d %s  %s\n", entry.ID, entry.Time.Local().Format("2006-01-02 15:04"), entry.Command)
+				printChanges(entry.Changes, "       ")
+			}
+			fmt.Println()
+			if len(undo) < n {
+				fmt.Printf("💡 Only %d change(s) can be undone\n", len(undo))
+				fmt.Println()
+			}
+		})
+
+		if err := journal.Check(appCtx.Root, undo); err != nil {
+			var conflict *journal.ConflictError
+			if !errors.As(err, &conflict) {
+				fail("Error", err)
+			}
+			if !force {
+				fail("Error", fmt.Errorf("%v (use --force to undo anyway)", err))
+			}
+			humanOutputToStderr(func() { fmt.Printf("⚠️  %v\n", err) })
+		}
+
+		if dryRun {
+			printReport(result, ExitOK, func() {})
+			return
+		}
+
+		var failedID int
+		var revertErr error
+		humanOutputToStderr(func() {
+			if !yes && !askConfirmation("Undo these changes? (y/N): ", false) {
+				fmt.Println("❌ Undo cancelled.")
+				return
+			}
+
+			if timestamp, err := snapshot.CreateAutoSnapshot(appCtx.Root, "Before undo"); err != nil {
+				fmt.Printf("⚠️  Warning: Could not create snapshot: %v\n", err)
+			} else {
+				fmt.Printf("📸 Snapshot created: %s\n", timestamp)
+				result.Snapshot = timestamp
+			}
+
+			for _, entry := range undo {
+				if revertErr = journal.Revert(appCtx.Root, entry); revertErr != nil {
+					failedID = entry.ID
+					return
+				}
+				undone := UndoneChange{ID: entry.ID}
+				recorded, err := appendJournal(journal.Reversal(entry, cmd.CommandPath(), os.Args[1:]))
+				if err != nil {
+					fmt.Printf("⚠️  Undid #%d but could not record it: %v\n", entry.ID, err)
+				} else {
+					undone.RecordedAs = recorded.ID
+					fmt.Printf("✅ Undid #%d (recorded as #%d)\n", entry.ID, recorded.ID)
+				}
+				result.Undone = append(result.Undone, undone)
+			}
+		})
+		if revertErr != nil {
+			fail(fmt.Sprintf("Error undoing #%d", failedID), revertErr)
+		}
+
+		printReport(result, ExitOK, func() {})
+	},
+}
+
+// UndoResult is the result of 'dotfiles undo'
+type UndoResult struct {
+	Changes  []HistoryEntry `json:"changes"` // Changes to undo, newest first, with what undoing them changes
+	DryRun   bool           `json:"dry_run"`
+	Undone   []UndoneChange `json:"undone"`             // Empty for a dry run or when cancelled
+	Snapshot string         `json:"snapshot,omitempty"` // Snapshot taken before undoing
+}
+
+// UndoneChange is a change undo reverted
+type UndoneChange struct {
+	ID         int `json:"id"`
+	RecordedAs int `json:"recorded_as,omitempty"` // Journal entry of the undo; 0 if it couldn't be recorded
+}
+
+// appendJournal records an entry in the history of configuration changes
+func appendJournal(entry journal.Entry) (journal.Entry, error) {
+	if err := appCtx.EnsureStateDir(); err != nil {
+		return journal.Entry{}, err
+	}
+	return journal.Append(appCtx.JournalPath(), entry)
+}
+
+// recordConfigChanges journals every configuration file the command saves
+// under the dotfiles root
+func recordConfigChanges(cmd *cobra.Command) {
+	command := cmd.CommandPath()
+	args := os.Args[1:]
+	config.ObserveSaves(func(event config.SaveEvent) {
+		rel, err := filepath.Rel(appCtx.Root, event.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			// e.g. 'dotfiles backup' writing elsewhere
+			return
+		}
+		_, err = appendJournal(journal.Entry{
+			Command: command,
+			Args:    args,
+			File:    filepath.ToSlash(rel),
+			Changes: event.Changes,
+			Before:  journal.Content(event.Before),
+			After:   journal.Content(event.After),
+		})
+		if err != nil {
+			fmt.Printf("⚠️  Could not record the change in the history: %v\n", err)
+		}
+	})
+}
+
+// describeCommand returns the command line of an entry, e.g. "dotfiles add git"
+func describeCommand(entry journal.Entry) string {
+	if len(entry.Args) == 0 {
+		return entry.Command
+	}
+	return "dotfiles " + strings.Join(entry.Args, " ")
+}
+
+// printChanges prints changes one section per line, e.g. "brews: +git -wget"
+func printChanges(changes []config.Change, indent string) {
+	for _, change := range changes {
+		var parts []string
+		for _, entry := range change.Added {
+			parts = append(parts, "+"+entry)
+		}
+		for _, entry := range change.Removed {
+			parts = append(parts, "-"+entry)
+		}
+		fmt.Printf("%s%s: %s\n", indent, change.Section, strings.Join(parts, " "))
+	}
+}
+
+func init() {
+	historyCmd.Flags().IntP("number", "n", 20, "Number of changes to show")
+	historyCmd.Flags().Bool("all", false, "Show every recorded change")
+	undoCmd.Flags().Bool("dry-run", false, "Show what would be undone without changing anything")
+	undoCmd.Flags().Bool("force", false, "Undo even if the files changed since")
+	undoCmd.Flags().BoolP("yes", "y", false, "Undo without asking for confirmation")
+
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(undoCmd)
+}
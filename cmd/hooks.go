@@ -3,11 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"dotfiles/internal/config"
+	"dotfiles/internal/hooks"
 	"github.com/spf13/cobra"
 )
 
@@ -22,17 +22,40 @@ Hooks allow you to automate custom tasks during dotfiles operations.
 Available hook types:
 • pre_install / post_install - Before/after package installation
 • pre_sync / post_sync - Before/after repo sync
-• pre_stow / post_stow - Before/after stowing dotfiles
+• pre_stow / post_stow - Before/after stowing dotfiles (also restow)
+• pre_unstow / post_unstow - Before/after unstowing dotfiles
+• pre_update / post_update - Before/after package updates
+• pre_snapshot_restore / post_snapshot_restore - Around 'snapshot restore'
+• pre_template_apply / post_template_apply - Around 'clone template:<name>'
+
+A failing pre hook aborts the operation; a failing post hook is reported.
+Hooks run with 'sh -c' and receive the event as JSON on stdin and as
+environment variables: DOTFILES_EVENT, DOTFILES_PHASE, DOTFILES_HOOK,
+DOTFILES_ROOT, DOTFILES_OS, DOTFILES_HOSTNAME and, when relevant,
+DOTFILES_PACKAGES, DOTFILES_SNAPSHOT and DOTFILES_TEMPLATE.
+
+Hook options (flags on 'hooks add', or a hook object in config.json):
+• --timeout 30s           - Kill the hook after this long (default 10m)
+• --workdir scripts       - Working directory, relative to the dotfiles root
+• --continue-on-error     - Don't abort the operation when the hook fails
+• --os darwin             - Only run on these operating systems
+• --host 'work-*'         - Only run on hosts matching these patterns
 
 Package-specific hooks:
 • dotfiles hooks pkg <package> add post_install <command>
 • dotfiles hooks pkg <package> list
 • dotfiles hooks pkg <package> remove <index>
 
+Imported hooks:
+Hooks added by 'clone', templates and 'import-profile' record their source
+and are quarantined until approved with 'dotfiles hooks trust', unless the
+source is listed in "trusted_sources" in config.json.
+
 Examples:
   dotfiles hooks list                                        # List all hooks
   dotfiles hooks add pre_install "brew update"               # Add pre-install hook
   dotfiles hooks add post_install "echo 'Done!'"             # Add post-install hook
+  dotfiles hooks add post_stow "./reload.sh" --os darwin --timeout 30s
   dotfiles hooks remove pre_install 0                        # Remove first pre-install hook
   dotfiles hooks clear post_sync                             # Remove all post-sync hooks
 
@@ -45,64 +68,110 @@ Examples:
 var hooksListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all configured hooks",
+	Long: `List the hooks of every configuration layer: config.json and the overlays
+for this machine (config.<os>.json, config.<hostname>.json, the active
+profile). Overlay hooks show the overlay file as their source.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
+		stack, err := loadConfigStack()
 		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
+			fail("Error loading configuration", err)
+		}
+		cfg := stack.Config
+
+		// Indexes count within each list, as 'hooks remove' expects them.
+		// Overlay hooks come after those of config.json.
+		result := HookList{Hooks: []HookInfo{}}
+		indexes := make(map[string]int)
+		for _, ref := range cfg.HookRefs() {
+			key := ref.String()
+			result.Hooks = append(result.Hooks, HookInfo{
+				Type:            ref.Type,
+				Package:         ref.Package,
+				Index:           indexes[key],
+				Command:         ref.Hook.Command,
+				Timeout:         ref.Hook.Timeout,
+				WorkDir:         ref.Hook.WorkDir,
+				ContinueOnError: ref.Hook.ContinueOnError,
+				OS:              ref.Hook.OS,
+				Hosts:           ref.Hook.Hosts,
+				Source:          ref.Hook.Source(),
+				Quarantined:     ref.Hook.Quarantined(),
+			})
+			indexes[key]++
+		}
+
+		printReport(result, ExitOK, func() { printHookList(cfg) })
+	},
+}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
-		cfg, err := config.Load(configPath)
-		if err != nil {
-			fmt.Printf("❌ Error loading configuration: %v\n", err)
-			os.Exit(1)
-		}
+// HookList is the result of 'dotfiles hooks list'
+type HookList struct {
+	Hooks []HookInfo `json:"hooks"` // Global hooks by type, then package hooks
+}
 
-		if cfg.Hooks == nil || isHooksEmpty(cfg.Hooks) {
-			fmt.Println("🪝 No hooks configured")
-			fmt.Println()
-			fmt.Println("💡 Add a hook:")
-			fmt.Println("   dotfiles hooks add pre_install 'brew update'")
-			return
-		}
+// HookInfo is a configured hook and where it is configured
+type HookInfo struct {
+	Type            string   `json:"type"`
+	Package         string   `json:"package,omitempty"` // Set for package-specific hooks
+	Index           int      `json:"index"`             // Position in its list
+	Command         string   `json:"command"`
+	Timeout         string   `json:"timeout,omitempty"`
+	WorkDir         string   `json:"workdir,omitempty"`
+	ContinueOnError bool     `json:"continue_on_error"`
+	OS              []string `json:"os,omitempty"`
+	Hosts           []string `json:"hosts,omitempty"`
+	Source          string   `json:"source"`
+	Quarantined     bool     `json:"quarantined"` // Imported from an untrusted source and won't run
+}
 
-		fmt.Println("🪝 Configured Hooks:")
-		fmt.Println("=" + strings.Repeat("=", 19))
+func printHookList(cfg *config.Config) {
+	if len(cfg.HookRefs()) == 0 {
+		fmt.Println("🪝 No hooks configured")
 		fmt.Println()
+		fmt.Println("💡 Add a hook:")
+		fmt.Println("   dotfiles hooks add pre_install 'brew update'")
+		return
+	}
 
-		printHookSection("Pre-Install", cfg.Hooks.PreInstall)
-		printHookSection("Post-Install", cfg.Hooks.PostInstall)
-		printHookSection("Pre-Sync", cfg.Hooks.PreSync)
-		printHookSection("Post-Sync", cfg.Hooks.PostSync)
-		printHookSection("Pre-Stow", cfg.Hooks.PreStow)
-		printHookSection("Post-Stow", cfg.Hooks.PostStow)
-
-		// Print package-specific hooks
-		if cfg.PackageConfigs != nil && len(cfg.PackageConfigs) > 0 {
-			fmt.Println("📦 Package-Specific Hooks:")
-			fmt.Println("=" + strings.Repeat("=", 24))
-			fmt.Println()
-			for pkg, pkgConfig := range cfg.PackageConfigs {
-				if len(pkgConfig.PreInstall) > 0 || len(pkgConfig.PostInstall) > 0 {
-					fmt.Printf("🔧 %s:\n", pkg)
-					if len(pkgConfig.PreInstall) > 0 {
-						fmt.Println("   Pre-Install:")
-						for i, hook := range pkgConfig.PreInstall {
-							fmt.Printf("      %d. %s\n", i, hook)
-						}
+	fmt.Println("🪝 Configured Hooks:")
+	fmt.Println("=" + strings.Repeat("=", 19))
+	fmt.Println()
+
+	if cfg.Hooks != nil {
+		for _, hookType := range config.HookTypes() {
+			printHookSection(hookType, *cfg.Hooks.For(hookType))
+		}
+	}
+
+	// Print package-specific hooks
+	if cfg.PackageConfigs != nil && len(cfg.PackageConfigs) > 0 {
+		fmt.Println("📦 Package-Specific Hooks:")
+		fmt.Println("=" + strings.Repeat("=", 24))
+		fmt.Println()
+		for pkg, pkgConfig := range cfg.PackageConfigs {
+			if len(pkgConfig.PreInstall) > 0 || len(pkgConfig.PostInstall) > 0 {
+				fmt.Printf("🔧 %s:\n", pkg)
+				if len(pkgConfig.PreInstall) > 0 {
+					fmt.Println("   Pre-Install:")
+					for i, hook := range pkgConfig.PreInstall {
+						fmt.Printf("      %d. %s\n", i, describeHook(hook))
 					}
-					if len(pkgConfig.PostInstall) > 0 {
-						fmt.Println("   Post-Install:")
-						for i, hook := range pkgConfig.PostInstall {
-							fmt.Printf("      %d. %s\n", i, hook)
-						}
+				}
+				if len(pkgConfig.PostInstall) > 0 {
+					fmt.Println("   Post-Install:")
+					for i, hook := range pkgConfig.PostInstall {
+						fmt.Printf("      %d. %s\n", i, describeHook(hook))
 					}
-					fmt.Println()
 				}
+				fmt.Println()
 			}
 		}
-	},
+	}
+
+	if quarantined := quarantinedHooks(cfg, ""); len(quarantined) > 0 {
+		fmt.Printf("⛔ %d imported hook(s) are quarantined and won't run\n", len(quarantined))
+		fmt.Println("💡 Review and approve them with: dotfiles hooks trust")
+	}
 }
 
 var hooksAddCmd = &cobra.Command{
@@ -111,15 +180,13 @@ var hooksAddCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		hookType := args[0]
-		command := args[1]
-
-		home, err := os.UserHomeDir()
+		hook, err := hookFromFlags(cmd, args[1])
 		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
+			fmt.Printf("❌ %v\n", err)
 			os.Exit(1)
 		}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -130,31 +197,20 @@ var hooksAddCmd = &cobra.Command{
 			cfg.Hooks = &config.Hooks{}
 		}
 
-		switch hookType {
-		case "pre_install":
-			cfg.Hooks.PreInstall = append(cfg.Hooks.PreInstall, command)
-		case "post_install":
-			cfg.Hooks.PostInstall = append(cfg.Hooks.PostInstall, command)
-		case "pre_sync":
-			cfg.Hooks.PreSync = append(cfg.Hooks.PreSync, command)
-		case "post_sync":
-			cfg.Hooks.PostSync = append(cfg.Hooks.PostSync, command)
-		case "pre_stow":
-			cfg.Hooks.PreStow = append(cfg.Hooks.PreStow, command)
-		case "post_stow":
-			cfg.Hooks.PostStow = append(cfg.Hooks.PostStow, command)
-		default:
+		hooks := cfg.Hooks.For(hookType)
+		if hooks == nil {
 			fmt.Printf("❌ Invalid hook type: %s\n", hookType)
-			fmt.Println("Valid types: pre_install, post_install, pre_sync, post_sync, pre_stow, post_stow")
+			fmt.Printf("Valid types: %s\n", strings.Join(config.HookTypes(), ", "))
 			os.Exit(1)
 		}
+		*hooks = append(*hooks, hook)
 
 		if err := cfg.Save(configPath); err != nil {
 			fmt.Printf("❌ Error saving configuration: %v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("✅ Added %s hook: %s\n", hookType, command)
+		fmt.Printf("✅ Added %s hook: %s\n", hookType, describeHook(hook))
 	},
 }
 
@@ -167,13 +223,7 @@ var hooksRemoveCmd = &cobra.Command{
 		index := 0
 		fmt.Sscanf(args[1], "%d", &index)
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -185,21 +235,8 @@ var hooksRemoveCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		var hooks *[]string
-		switch hookType {
-		case "pre_install":
-			hooks = &cfg.Hooks.PreInstall
-		case "post_install":
-			hooks = &cfg.Hooks.PostInstall
-		case "pre_sync":
-			hooks = &cfg.Hooks.PreSync
-		case "post_sync":
-			hooks = &cfg.Hooks.PostSync
-		case "pre_stow":
-			hooks = &cfg.Hooks.PreStow
-		case "post_stow":
-			hooks = &cfg.Hooks.PostStow
-		default:
+		hooks := cfg.Hooks.For(hookType)
+		if hooks == nil {
 			fmt.Printf("❌ Invalid hook type: %s\n", hookType)
 			os.Exit(1)
 		}
@@ -228,13 +265,7 @@ var hooksClearCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		hookType := args[0]
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -246,30 +277,13 @@ var hooksClearCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		var count int
-		switch hookType {
-		case "pre_install":
-			count = len(cfg.Hooks.PreInstall)
-			cfg.Hooks.PreInstall = []string{}
-		case "post_install":
-			count = len(cfg.Hooks.PostInstall)
-			cfg.Hooks.PostInstall = []string{}
-		case "pre_sync":
-			count = len(cfg.Hooks.PreSync)
-			cfg.Hooks.PreSync = []string{}
-		case "post_sync":
-			count = len(cfg.Hooks.PostSync)
-			cfg.Hooks.PostSync = []string{}
-		case "pre_stow":
-			count = len(cfg.Hooks.PreStow)
-			cfg.Hooks.PreStow = []string{}
-		case "post_stow":
-			count = len(cfg.Hooks.PostStow)
-			cfg.Hooks.PostStow = []string{}
-		default:
+		hooks := cfg.Hooks.For(hookType)
+		if hooks == nil {
 			fmt.Printf("❌ Invalid hook type: %s\n", hookType)
 			os.Exit(1)
 		}
+		count := len(*hooks)
+		*hooks = nil
 
 		if err := cfg.Save(configPath); err != nil {
 			fmt.Printf("❌ Error saving configuration: %v\n", err)
@@ -280,47 +294,301 @@ var hooksClearCmd = &cobra.Command{
 	},
 }
 
-func printHookSection(name string, hooks []string) {
+func printHookSection(name string, hooks []config.Hook) {
 	if len(hooks) == 0 {
 		return
 	}
 
 	fmt.Printf("📌 %s:\n", name)
 	for i, hook := range hooks {
-		fmt.Printf("   %d. %s\n", i, hook)
+		fmt.Printf("   %d. %s\n", i, describeHook(hook))
 	}
 	fmt.Println()
 }
 
-func isHooksEmpty(hooks *config.Hooks) bool {
-	return len(hooks.PreInstall) == 0 &&
-		len(hooks.PostInstall) == 0 &&
-		len(hooks.PreSync) == 0 &&
-		len(hooks.PostSync) == 0 &&
-		len(hooks.PreStow) == 0 &&
-		len(hooks.PostStow) == 0
+// describeHook returns the hook command followed by its options
+func describeHook(hook config.Hook) string {
+	var options []string
+	if hook.Timeout != "" {
+		options = append(options, "timeout "+hook.Timeout)
+	}
+	if hook.WorkDir != "" {
+		options = append(options, "in "+hook.WorkDir)
+	}
+	if hook.ContinueOnError {
+		options = append(options, "continue on error")
+	}
+	if len(hook.OS) > 0 {
+		options = append(options, "os "+strings.Join(hook.OS, ","))
+	}
+	if len(hook.Hosts) > 0 {
+		options = append(options, "hosts "+strings.Join(hook.Hosts, ","))
+	}
+	if hook.Source() != config.LocalSource {
+		options = append(options, "from "+hook.Source())
+	}
+	description := hook.Command
+	if len(options) > 0 {
+		description = fmt.Sprintf("%s (%s)", hook.Command, strings.Join(options, ", "))
+	}
+	if hook.Quarantined() {
+		description = "⛔ " + description + " [quarantined]"
+	}
+	return description
+}
+
+// hookFromFlags builds a hook from a command and the hook option flags
+func hookFromFlags(cmd *cobra.Command, command string) (config.Hook, error) {
+	hook := config.Hook{Command: command, Provenance: &config.Provenance{Source: config.LocalSource, AddedAt: time.Now()}}
+	hook.Timeout, _ = cmd.Flags().GetString("timeout")
+	hook.WorkDir, _ = cmd.Flags().GetString("workdir")
+	hook.ContinueOnError, _ = cmd.Flags().GetBool("continue-on-error")
+	hook.OS, _ = cmd.Flags().GetStringSlice("os")
+	hook.Hosts, _ = cmd.Flags().GetStringSlice("host")
+
+	if hook.Timeout != "" {
+		if _, err := time.ParseDuration(hook.Timeout); err != nil {
+			return hook, fmt.Errorf("invalid timeout %q, use a duration such as 30s or 5m", hook.Timeout)
+		}
+	}
+	return hook, nil
 }
 
-// RunHooks executes a list of hook commands
-func RunHooks(hooks []string, hookType string) error {
-	if len(hooks) == 0 {
+// fireHooks runs the configured hooks for the payload's event and phase.
+// Callers abort the operation when a pre hook fails and warn when a post
+// hook fails.
+func fireHooks(cfg *config.Config, payload hooks.Payload) error {
+	if cfg == nil || cfg.Hooks == nil {
 		return nil
 	}
+	return RunHooks(*cfg.Hooks.For(payload.HookType()), payload)
+}
 
-	fmt.Printf("🪝 Running %s hooks...\n", hookType)
-	for i, hook := range hooks {
-		fmt.Printf("   [%d/%d] %s\n", i+1, len(hooks), hook)
+// loadHookConfig returns the merged configuration whose hooks fire for an
+// event, or nil after a warning when it can't be read, which skips hooks
+func loadHookConfig() *config.Config {
+	cfg, err := loadMergedConfig()
+	if err != nil {
+		fmt.Printf("⚠️  Could not load configuration, skipping hooks: %v\n", err)
+		return nil
+	}
+	return cfg
+}
 
-		cmd := exec.Command("sh", "-c", hook)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+// RunHooks executes a list of hooks for an event
+func RunHooks(list []config.Hook, payload hooks.Payload) error {
+	if len(list) == 0 {
+		return nil
+	}
+	if payload.Root == "" {
+		payload.Root = appCtx.Root
+	}
+
+	label := payload.HookType()
+	if len(payload.Packages) == 1 && payload.Event == hooks.Install {
+		label = fmt.Sprintf("%s %s", payload.Packages[0], label)
+	}
 
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("hook failed: %v", err)
+	engine := hooks.NewEngine()
+	engine.OnProgress = func(progress hooks.Progress) {
+		switch progress.Status {
+		case hooks.Started:
+			fmt.Printf("   [%d/%d] %s\n", progress.Index, progress.Total, progress.Hook.Command)
+		case hooks.Skipped:
+			fmt.Printf("   [%d/%d] ⏭️  %s (skipped: %s)\n", progress.Index, progress.Total, progress.Hook.Command, progress.Reason)
+		case hooks.Failed:
+			if progress.Hook.ContinueOnError {
+				fmt.Printf("   ⚠️  Hook failed, continuing: %v\n", progress.Err)
+			}
 		}
 	}
+
+	fmt.Printf("🪝 Running %s hooks...\n", label)
+	err := engine.Run(list, payload)
 	fmt.Println()
-	return nil
+	return err
+}
+
+var hooksTrustCmd = &cobra.Command{
+	Use:   "trust [source]",
+	Short: "Review and approve quarantined hooks",
+	Long: `Review and approve quarantined hooks.
+
+Hooks that arrive through 'dotfiles clone', 'clone template:<name>' or
+'import-profile' record where they came from. Unless that source is listed in
+"trusted_sources" in config.json, they are quarantined: they show up in
+'dotfiles hooks list' but never run until approved here. Hooks in overlay
+files (config.<os>.json, config.<hostname>.json, profiles/) are quarantined
+the same way; they are reviewed a file at a time, and trusting one adds the
+file to "trusted_sources".
+
+Each quarantined hook is shown with its source and you are asked to trust it.
+Give a source (or a prefix ending in *) to only review hooks from there.
+
+Examples:
+  dotfiles hooks trust                                   # Review every quarantined hook
+  dotfiles hooks trust --all                             # Trust all without asking
+  dotfiles hooks trust 'https://gist.github.com/me/*' --remember
+                                                         # Trust and import future hooks from there
+  dotfiles hooks trust 'profiles/*' --remember           # Run hooks from profile overlays`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		remember, _ := cmd.Flags().GetBool("remember")
+
+		source := ""
+		if len(args) == 1 {
+			source = args[0]
+		}
+		if remember && source == "" {
+			fmt.Println("❌ --remember needs a source to add to trusted_sources")
+			os.Exit(1)
+		}
+
+		configPath := appCtx.ConfigPath()
+		stack, err := loadConfigStack()
+		if err != nil {
+			fmt.Printf("❌ Error loading configuration: %v\n", err)
+			os.Exit(1)
+		}
+		// Trust is saved to config.json, hooks of overlays are found in the
+		// merged configuration
+		cfg := stack.Layers[0].Config
+
+		quarantined := quarantinedHooks(cfg, source)
+		overlayHooks := quarantinedOverlayHooks(stack, source)
+		total := len(quarantined)
+		for _, refs := range overlayHooks {
+			total += len(refs)
+		}
+		if total == 0 && !remember {
+			fmt.Println("✅ No quarantined hooks")
+			return
+		}
+
+		trusted := 0
+		for _, ref := range quarantined {
+			fmt.Printf("🪝 %s\n", ref)
+			fmt.Printf("   Command: %s\n", ref.Hook.Command)
+			fmt.Printf("   Source:  %s (added %s)\n", ref.Hook.Source(), ref.Hook.Provenance.AddedAt.Format("2006-01-02 15:04"))
+			if !all && !askConfirmation("   Trust this hook? (y/N): ", false) {
+				fmt.Println("   ⛔ Still quarantined")
+				fmt.Println()
+				continue
+			}
+			ref.Hook.Trust()
+			trusted++
+			fmt.Println("   ✅ Trusted")
+			fmt.Println()
+		}
+
+		// Overlay hooks are quarantined again whenever the file is read, so
+		// they are trusted a whole file at a time through trusted_sources
+		for _, overlay := range stack.OverlaySources() {
+			refs := overlayHooks[overlay]
+			if len(refs) == 0 {
+				continue
+			}
+			fmt.Printf("📄 %s (overlay file)\n", overlay)
+			for _, ref := range refs {
+				fmt.Printf("   🪝 %s: %s\n", ref, ref.Hook.Command)
+			}
+			if !all && !askConfirmation(fmt.Sprintf("   Trust every hook in %s? (y/N): ", overlay), false) {
+				fmt.Println("   ⛔ Still quarantined")
+				fmt.Println()
+				continue
+			}
+			if !contains(cfg.TrustedSources, overlay) {
+				cfg.TrustedSources = append(cfg.TrustedSources, overlay)
+			}
+			trusted += len(refs)
+			fmt.Printf("   ✅ Trusted, %s was added to trusted_sources\n", overlay)
+			fmt.Println()
+		}
+
+		if remember && !contains(cfg.TrustedSources, source) {
+			cfg.TrustedSources = append(cfg.TrustedSources, source)
+			fmt.Printf("✅ Hooks imported from %s will no longer be quarantined\n", source)
+		}
+
+		if err := cfg.Save(configPath); err != nil {
+			fmt.Printf("❌ Error saving configuration: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📊 Trusted %d of %d quarantined hook(s)\n", trusted, total)
+	},
+}
+
+// quarantinedHooks returns the quarantined hooks, only those from source
+// when it is set (a trailing * matches a prefix)
+func quarantinedHooks(cfg *config.Config, source string) []config.HookRef {
+	filter := &config.Config{TrustedSources: []string{source}}
+
+	var refs []config.HookRef
+	for _, ref := range cfg.HookRefs() {
+		if !ref.Hook.Quarantined() {
+			continue
+		}
+		if source != "" && !filter.IsTrustedSource(ref.Hook.Source()) {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// quarantinedOverlayHooks returns the quarantined hooks of the overlay
+// files, keyed by file, only those from source when it is set
+func quarantinedOverlayHooks(stack *config.Stack, source string) map[string][]config.HookRef {
+	overlays := stack.OverlaySources()
+	refs := make(map[string][]config.HookRef)
+	for _, ref := range quarantinedHooks(stack.Config, source) {
+		if overlay := ref.Hook.Source(); contains(overlays, overlay) {
+			refs[overlay] = append(refs[overlay], ref)
+		}
+	}
+	return refs
+}
+
+// printIncomingHooks shows the hooks importing src into dst would add, and
+// reports whether any would be added. trust marks the source as trusted.
+func printIncomingHooks(dst, src *config.Config, source string, trust bool) bool {
+	incoming := dst.NewHooks(src)
+	if len(incoming) == 0 {
+		return false
+	}
+
+	fmt.Printf("🪝 Hooks this would add (%d):\n", len(incoming))
+	for _, ref := range incoming {
+		hook := *ref.Hook
+		hook.Provenance = nil
+		fmt.Printf("  • %s: %s\n", ref, describeHook(hook))
+	}
+	if trust || dst.IsTrustedSource(source) {
+		fmt.Printf("  ✅ %s is a trusted source, these hooks will run on the next operation\n", source)
+	} else {
+		fmt.Printf("  ⛔ %s is not a trusted source, these hooks stay quarantined until\n", source)
+		fmt.Println("     approved with 'dotfiles hooks trust'")
+	}
+	fmt.Println()
+	return true
+}
+
+// importHooks adds the new hooks from src to dst with source as provenance.
+// They are quarantined unless trust is set or the source is trusted.
+func importHooks(dst, src *config.Config, source string, trust bool) {
+	quarantine := !trust && !dst.IsTrustedSource(source)
+	added := dst.ImportHooks(src, source, quarantine)
+	if len(added) == 0 {
+		return
+	}
+
+	if quarantine {
+		fmt.Printf("⛔ Added %d hook(s) from %s in quarantine\n", len(added), source)
+		fmt.Println("💡 Review and approve them with: dotfiles hooks trust")
+	} else {
+		fmt.Printf("🪝 Added %d hook(s) from %s\n", len(added), source)
+	}
 }
 
 var hooksPkgCmd = &cobra.Command{
@@ -336,13 +604,7 @@ var hooksPkgListCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		packageName := args[0]
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -367,7 +629,7 @@ var hooksPkgListCmd = &cobra.Command{
 		if len(pkgConfig.PreInstall) > 0 {
 			fmt.Println("Pre-Install:")
 			for i, hook := range pkgConfig.PreInstall {
-				fmt.Printf("  %d. %s\n", i, hook)
+				fmt.Printf("  %d. %s\n", i, describeHook(hook))
 			}
 			fmt.Println()
 		}
@@ -375,7 +637,7 @@ var hooksPkgListCmd = &cobra.Command{
 		if len(pkgConfig.PostInstall) > 0 {
 			fmt.Println("Post-Install:")
 			for i, hook := range pkgConfig.PostInstall {
-				fmt.Printf("  %d. %s\n", i, hook)
+				fmt.Printf("  %d. %s\n", i, describeHook(hook))
 			}
 			fmt.Println()
 		}
@@ -389,20 +651,18 @@ var hooksPkgAddCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		packageName := args[0]
 		hookType := args[1]
-		command := args[2]
-
-		if hookType != "pre_install" && hookType != "post_install" {
-			fmt.Println("❌ Invalid hook type. Use 'pre_install' or 'post_install'")
+		hook, err := hookFromFlags(cmd, args[2])
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
 			os.Exit(1)
 		}
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
+		if hookType != "pre_install" && hookType != "post_install" {
+			fmt.Println("❌ Invalid hook type. Use 'pre_install' or 'post_install'")
 			os.Exit(1)
 		}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -415,9 +675,9 @@ var hooksPkgAddCmd = &cobra.Command{
 
 		pkgConfig := cfg.PackageConfigs[packageName]
 		if hookType == "pre_install" {
-			pkgConfig.PreInstall = append(pkgConfig.PreInstall, command)
+			pkgConfig.PreInstall = append(pkgConfig.PreInstall, hook)
 		} else {
-			pkgConfig.PostInstall = append(pkgConfig.PostInstall, command)
+			pkgConfig.PostInstall = append(pkgConfig.PostInstall, hook)
 		}
 		cfg.PackageConfigs[packageName] = pkgConfig
 
@@ -426,7 +686,7 @@ var hooksPkgAddCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		fmt.Printf("✅ Added %s hook for package '%s': %s\n", hookType, packageName, command)
+		fmt.Printf("✅ Added %s hook for package '%s': %s\n", hookType, packageName, describeHook(hook))
 	},
 }
 
@@ -445,13 +705,7 @@ var hooksPkgRemoveCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -469,7 +723,7 @@ var hooksPkgRemoveCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		var hooks *[]string
+		var hooks *[]config.Hook
 		if hookType == "pre_install" {
 			hooks = &pkgConfig.PreInstall
 		} else {
@@ -501,10 +755,21 @@ var hooksPkgRemoveCmd = &cobra.Command{
 }
 
 func init() {
+	for _, c := range []*cobra.Command{hooksAddCmd, hooksPkgAddCmd} {
+		c.Flags().String("timeout", "", "Kill the hook after this duration, e.g. 30s (default 10m)")
+		c.Flags().String("workdir", "", "Working directory, relative to the dotfiles root")
+		c.Flags().Bool("continue-on-error", false, "Don't abort the operation when the hook fails")
+		c.Flags().StringSlice("os", nil, "Only run on these operating systems (darwin, linux)")
+		c.Flags().StringSlice("host", nil, "Only run on hosts matching these glob patterns")
+	}
+
 	hooksCmd.AddCommand(hooksListCmd)
 	hooksCmd.AddCommand(hooksAddCmd)
 	hooksCmd.AddCommand(hooksRemoveCmd)
 	hooksCmd.AddCommand(hooksClearCmd)
+	hooksTrustCmd.Flags().Bool("all", false, "Trust every matching hook without asking")
+	hooksTrustCmd.Flags().Bool("remember", false, "Add the source to trusted_sources so future hooks from it aren't quarantined")
+	hooksCmd.AddCommand(hooksTrustCmd)
 
 	hooksPkgCmd.AddCommand(hooksPkgListCmd)
 	hooksPkgCmd.AddCommand(hooksPkgAddCmd)
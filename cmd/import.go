@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"dotfiles/internal/config"
@@ -25,13 +24,7 @@ var importCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 
 		// Load existing config or create new one
 		cfg, err := config.Load(configPath)
@@ -173,4 +166,4 @@ func mergeSlices(existing, new []string) []string {
 func init() {
 	importCmd.Flags().Bool("replace", false, "Replace existing packages instead of merging")
 	rootCmd.AddCommand(importCmd)
-}
\ No newline at end of file
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"dotfiles/internal/config"
 	"github.com/spf13/cobra"
@@ -12,16 +13,12 @@ import (
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize a new dotfiles configuration",
-	Long:  `Creates a new config.json file in ~/.dotfiles/`,
-	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
+	Long: `Creates a new config.json file in ~/.dotfiles/
 
-		configDir := filepath.Join(home, ".dotfiles")
-		configPath := filepath.Join(configDir, "config.json")
+Use --format=yaml or --format=toml to create config.yaml or config.toml
+instead; both allow comments, which edits such as 'dotfiles add' keep.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := appCtx.ConfigPath()
 
 		// Check if config already exists
 		if _, err := os.Stat(configPath); err == nil {
@@ -29,6 +26,13 @@ var initCmd = &cobra.Command{
 			return
 		}
 
+		format, _ := cmd.Flags().GetString("format")
+		if !contains(configFormats, format) {
+			fmt.Printf("Unknown format '%s' (use %s)\n", format, strings.Join(configFormats, ", "))
+			os.Exit(1)
+		}
+		configPath = filepath.Join(appCtx.Root, "config."+format)
+
 		// Create initial empty config
 		cfg := &config.Config{
 			Brews: []string{"git"},
@@ -46,5 +50,6 @@ var initCmd = &cobra.Command{
 }
 
 func init() {
+	initCmd.Flags().String("format", "json", "Configuration file format: json, yaml or toml")
 	rootCmd.AddCommand(initCmd)
-}
\ No newline at end of file
+}
@@ -3,10 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
+	"time"
 
 	"dotfiles/internal/config"
-	"dotfiles/internal/pkgmanager"
+	"dotfiles/internal/hooks"
+	"dotfiles/internal/installer"
 	"dotfiles/internal/snapshot"
 	"github.com/spf13/cobra"
 )
@@ -14,42 +15,90 @@ import (
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Generate package file and install packages",
-	Long:  `Generates a package list file from your configuration and installs packages using the system package manager`,
+	Long: `Generates a package list file from your configuration and installs packages using the system package manager,
+then installs packages listed per package manager under "packages" (e.g. flatpak, cargo, brew on Linux).
+
+Packages are installed one by one through a pool of workers (--jobs). Packages of the
+same system package manager still install one at a time since it holds a global lock.
+Results are recorded in state/install-journal.json; after a failure, --resume retries
+only the packages that did not install.
+
+Pending run_once_/run_onchange_ scripts in scripts/ run after the packages are installed
+(see 'dotfiles scripts').`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
+		cfg, err := loadMergedConfig()
 		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
+			fmt.Printf("Error loading configuration: %v\n", err)
 			os.Exit(1)
 		}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
-		cfg, err := config.Load(configPath)
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		noScripts, _ := cmd.Flags().GetBool("no-scripts")
+
+		// Group packages by package manager: the system one plus any listed under "packages"
+		sets, err := configuredManagers(cfg)
 		if err != nil {
-			fmt.Printf("Error loading configuration: %v\n", err)
-			os.Exit(1)
+			if len(sets) == 0 {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("⚠️  %v, installing the \"packages\" section only\n", err)
 		}
 
-		// Get the appropriate package manager for this OS
-		pm, err := pkgmanager.GetPackageManager()
-		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+		total := 0
+		for _, set := range sets {
+			total += set.Count()
+		}
+		if total == 0 {
+			fmt.Println("No packages configured. Run 'dotfiles add <package>' first.")
+			if !noScripts && runPendingScripts(dryRun) > 0 {
+				os.Exit(1)
+			}
+			return
 		}
 
-		// Check if package manager is available
-		if !pm.IsAvailable() {
-			fmt.Printf("⚠️  %s not found. Please install it first.\n", pm.GetName())
-			if pm.GetName() == "homebrew" {
-				fmt.Println("   /bin/bash -c \"$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)\"")
+		// Skip package managers that are unknown or not installed
+		var usable []managerSet
+		for _, set := range sets {
+			if set.Count() == 0 {
+				continue
+			}
+			if set.Manager == nil {
+				fmt.Printf("⚠️  Unknown package manager %q, skipping %d package(s)\n", set.Name, set.Count())
+				continue
+			}
+			if !set.Available() {
+				fmt.Printf("⚠️  %s not found. Please install it first.\n", set.Name)
+				if set.Name == "homebrew" {
+					fmt.Println("   /bin/bash -c \"$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)\"")
+				}
+				continue
 			}
+			usable = append(usable, set)
+		}
+		if len(usable) == 0 {
 			return
 		}
 
+		// --frozen installs the versions recorded by 'dotfiles lock'
+		var lock *config.Lock
+		if frozen, _ := cmd.Flags().GetBool("frozen"); frozen {
+			lock, err = config.LoadLock(appCtx.LockPath())
+			if err != nil {
+				if os.IsNotExist(err) {
+					fmt.Println("❌ No dotfiles.lock found. Run 'dotfiles lock' first.")
+				} else {
+					fmt.Printf("❌ Error loading lock file: %v\n", err)
+				}
+				os.Exit(1)
+			}
+		}
+
 		// Create auto-snapshot before installation (unless --no-snapshot flag)
 		noSnapshot, _ := cmd.Flags().GetBool("no-snapshot")
 		if !noSnapshot {
 			fmt.Println("📸 Creating snapshot before installation...")
-			timestamp, err := snapshot.CreateAutoSnapshot("Before package installation")
+			timestamp, err := snapshot.CreateAutoSnapshot(appCtx.Root, "Before package installation")
 			if err != nil {
 				fmt.Printf("⚠️  Warning: Could not create snapshot: %v\n", err)
 			} else {
@@ -57,97 +106,106 @@ var installCmd = &cobra.Command{
 			}
 		}
 
-		// Run pre-install hooks
-		if cfg.Hooks != nil && len(cfg.Hooks.PreInstall) > 0 {
-			if err := RunHooks(cfg.Hooks.PreInstall, "pre-install"); err != nil {
-				fmt.Printf("⚠️  Pre-install hook failed: %v\n", err)
-				os.Exit(1)
+		// Write the package file (Brewfile or packages.txt) for the system package manager
+		for _, set := range usable {
+			if set.Primary {
+				if err := writePackageFile(cmd, set); err != nil {
+					fmt.Printf("Error writing package file: %v\n", err)
+					os.Exit(1)
+				}
 			}
 		}
 
-		// Generate package list file
-		fileContent, err := pm.GenerateInstallFile(cfg.Brews, cfg.Casks, cfg.Taps)
-		if err != nil {
-			fmt.Printf("Error generating package file: %v\n", err)
-			os.Exit(1)
-		}
-
-		if fileContent == "" {
-			fmt.Println("No packages configured. Run 'dotfiles add <package>' first.")
+		jobs := installJobs(usable, cfg, lock)
+		if dryRun {
+			for _, set := range usable {
+				fmt.Printf("🔍 Dry run - would install %d package(s) using %s\n", set.Count(), set.Name)
+			}
+			if !noScripts {
+				runPendingScripts(true)
+			}
 			return
 		}
 
-		// Write package file
-		fileName := "Brewfile"
-		if pm.GetName() == "pacman" {
-			fileName = "packages.txt"
-		} else if pm.GetName() == "apt" {
-			fileName = "packages.txt"
-		} else if pm.GetName() == "yum" {
-			fileName = "packages.txt"
-		}
-
-		filePath := "./" + fileName
-		if output, _ := cmd.Flags().GetString("output"); output != "" {
-			filePath = output
+		// Run pre-install hooks
+		payload := hooks.Payload{Event: hooks.Install, Phase: hooks.Pre, Packages: jobPackages(jobs)}
+		if err := fireHooks(cfg, payload); err != nil {
+			fmt.Printf("❌ Pre-install hook failed: %v\n", err)
+			os.Exit(1)
 		}
 
-		if err := os.WriteFile(filePath, []byte(fileContent), 0644); err != nil {
-			fmt.Printf("Error writing package file: %v\n", err)
+		// Record per-package results so a failed run can be resumed
+		if err := appCtx.EnsureStateDir(); err != nil {
+			fmt.Printf("❌ %v\n", err)
 			os.Exit(1)
 		}
-
-		fmt.Printf("✓ Generated package list at: %s\n", filePath)
-
-		// Run install unless --dry-run is specified
-		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
-			fmt.Println("🔍 Dry run - would install packages using " + pm.GetName())
-			return
+		resume, _ := cmd.Flags().GetBool("resume")
+		journal, err := installer.LoadJournal(appCtx.InstallJournalPath())
+		if err != nil {
+			if resume {
+				fmt.Printf("❌ Error loading install journal: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("⚠️  Could not read the install journal, starting a new one: %v\n", err)
+			journal = installer.NewJournal(appCtx.InstallJournalPath())
+		}
+		if !resume {
+			journal.Restart()
 		}
 
-		fmt.Printf("📦 Installing packages with %s...\n", pm.GetName())
+		concurrency, _ := cmd.Flags().GetInt("jobs")
+		fmt.Printf("📦 Installing %d package(s) with %d worker(s)...\n", len(jobs), concurrency)
 
-		// Install packages by type
-		if len(cfg.Taps) > 0 {
-			fmt.Println("Installing taps...")
-			if err := pm.Install(cfg.Taps, "tap"); err != nil {
-				fmt.Printf("⚠️  Error installing taps: %v\n", err)
-			}
-		}
+		results := installer.Run(jobs, installer.Options{
+			Concurrency: concurrency,
+			Journal:     journal,
+			Resume:      resume,
+			OnEvent: func(event installer.Event) {
+				fmt.Println(formatInstallEvent(event))
+			},
+		})
 
-		if len(cfg.Brews) > 0 {
-			fmt.Println("Installing packages...")
-			if err := pm.Install(cfg.Brews, "brew"); err != nil {
-				fmt.Printf("Error installing packages: %v\n", err)
-				os.Exit(1)
-			}
+		if printInstallSummary(usable, results) > 0 {
+			fmt.Println("💡 Fix the failures above and run 'dotfiles install --resume' to retry only what is left.")
+			os.Exit(1)
 		}
 
-		if len(cfg.Casks) > 0 {
-			fmt.Println("Installing casks/applications...")
-			if err := pm.Install(cfg.Casks, "cask"); err != nil {
-				fmt.Printf("⚠️  Error installing casks: %v\n", err)
-			}
+		// Report packages whose version differs from the lock or their constraint
+		differences := 0
+		for _, set := range usable {
+			differences += checkVersions(set, cfg, lock)
+		}
+		if differences > 0 && lock != nil {
+			fmt.Printf("❌ %d package(s) differ from dotfiles.lock\n", differences)
+			os.Exit(1)
 		}
 
 		fmt.Println("✅ Installation complete!")
 
+		// Run bootstrap scripts once the packages they may need are installed
+		failedScripts := 0
+		if !noScripts {
+			failedScripts = runPendingScripts(false)
+		}
+
 		// Run post-install hooks
-		if cfg.Hooks != nil && len(cfg.Hooks.PostInstall) > 0 {
-			if err := RunHooks(cfg.Hooks.PostInstall, "post-install"); err != nil {
-				fmt.Printf("⚠️  Post-install hook failed: %v\n", err)
-			}
+		payload.Phase = hooks.Post
+		if err := fireHooks(cfg, payload); err != nil {
+			fmt.Printf("⚠️  Post-install hook failed: %v\n", err)
 		}
 
-		// Run package-specific hooks (pre-install and post-install)
+		// Run package-specific hooks (pre-install and post-install) for every
+		// package the installer was given, whichever manager installed it
 		if cfg.PackageConfigs != nil {
-			allPackages := append(append([]string{}, cfg.Brews...), cfg.Casks...)
-			for _, pkg := range allPackages {
+			for _, pkg := range jobPackages(jobs) {
 				if pkgConfig, exists := cfg.PackageConfigs[pkg]; exists {
+					pkgPayload := hooks.Payload{Event: hooks.Install, Packages: []string{pkg}}
+
 					// Run pre-install hooks for this package
 					if len(pkgConfig.PreInstall) > 0 {
 						fmt.Printf("🔧 Running pre-install hooks for package: %s\n", pkg)
-						if err := RunHooks(pkgConfig.PreInstall, fmt.Sprintf("%s pre-install", pkg)); err != nil {
+						pkgPayload.Phase = hooks.Pre
+						if err := RunHooks(pkgConfig.PreInstall, pkgPayload); err != nil {
 							fmt.Printf("⚠️  Package pre-install hook failed for %s: %v\n", pkg, err)
 						}
 					}
@@ -155,19 +213,172 @@ var installCmd = &cobra.Command{
 					// Run post-install hooks for this package
 					if len(pkgConfig.PostInstall) > 0 {
 						fmt.Printf("🔧 Running post-install hooks for package: %s\n", pkg)
-						if err := RunHooks(pkgConfig.PostInstall, fmt.Sprintf("%s post-install", pkg)); err != nil {
+						pkgPayload.Phase = hooks.Post
+						if err := RunHooks(pkgConfig.PostInstall, pkgPayload); err != nil {
 							fmt.Printf("⚠️  Package post-install hook failed for %s: %v\n", pkg, err)
 						}
 					}
 				}
 			}
 		}
+
+		if failedScripts > 0 {
+			fmt.Printf("❌ %d script(s) failed and will run again next time\n", failedScripts)
+			os.Exit(1)
+		}
 	},
 }
 
+// writePackageFile writes the system package manager's package file
+// (Brewfile or packages.txt)
+func writePackageFile(cmd *cobra.Command, set managerSet) error {
+	pm := set.Manager
+
+	fileContent, err := pm.GenerateInstallFile(set.Brews, set.Casks, set.Taps)
+	if err != nil || fileContent == "" {
+		return err
+	}
+
+	fileName := "Brewfile"
+	if pm.GetName() != "homebrew" {
+		fileName = "packages.txt"
+	}
+
+	filePath := "./" + fileName
+	if output, _ := cmd.Flags().GetString("file"); output != "" {
+		filePath = output
+	}
+
+	if err := os.WriteFile(filePath, []byte(fileContent), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Generated package list at: %s\n", filePath)
+	return nil
+}
+
+// installJobs turns the configured packages into one install job per
+// package, pinned to the locked or exact configured version
+func installJobs(sets []managerSet, cfg *config.Config, lock *config.Lock) []installer.Job {
+	var jobs []installer.Job
+	for _, set := range sets {
+		pins := versionPins(set, cfg, lock)
+
+		for _, tap := range set.Taps {
+			if set.Name == "homebrew" {
+				jobs = append(jobs, installer.Job{Manager: set.Manager, Package: tap, Type: "tap"})
+			}
+		}
+		for _, pkgType := range []string{"brew", "cask"} {
+			for _, pkg := range managedPackages(set, pkgType) {
+				jobs = append(jobs, installer.Job{
+					Manager: set.Manager,
+					Package: pkg,
+					Type:    pkgType,
+					Version: pins[pkgType][pkg],
+				})
+			}
+		}
+	}
+	return jobs
+}
+
+// jobPackages returns the package names of the jobs, without duplicates
+func jobPackages(jobs []installer.Job) []string {
+	var packages []string
+	for _, job := range jobs {
+		packages = mergeUnique(packages, []string{job.Package})
+	}
+	return packages
+}
+
+// formatInstallEvent renders an install event as a line of progress, plus a
+// warning when its result could not be saved to the install journal
+func formatInstallEvent(event installer.Event) string {
+	line := formatInstallProgress(event)
+	if event.JournalErr != nil {
+		line += fmt.Sprintf("\n  ⚠️  Could not save to the install journal, --resume may repeat it: %v", event.JournalErr)
+	}
+	return line
+}
+
+// formatInstallProgress renders an install event as one line of progress
+func formatInstallProgress(event installer.Event) string {
+	name := event.Job.Package
+	if event.Job.Version != "" {
+		name += " " + event.Job.Version
+	}
+	if event.Job.Type == "cask" || event.Job.Type == "tap" {
+		name += " (" + event.Job.Type + ")"
+	}
+	progress := fmt.Sprintf("[%d/%d] %s", event.Completed, event.Total, event.Job.Manager.GetName())
+
+	switch event.Type {
+	case installer.EventStarted:
+		return fmt.Sprintf("  ⏳ %s %s", event.Job.Manager.GetName(), name)
+	case installer.EventSucceeded:
+		return fmt.Sprintf("  ✅ %s %s (%s)", progress, name, event.Duration.Round(100*time.Millisecond))
+	case installer.EventSkipped:
+		return fmt.Sprintf("  ⏭️  %s %s (installed by previous run)", progress, name)
+	default:
+		return fmt.Sprintf("  ❌ %s %s: %v", progress, name, event.Err)
+	}
+}
+
+// printInstallSummary prints per-manager counts and every failure with its
+// reason, returning the number of failed packages
+func printInstallSummary(sets []managerSet, results []installer.Result) int {
+	fmt.Println("\n📊 Install summary:")
+
+	for _, set := range sets {
+		installed, skipped, failed := 0, 0, 0
+		for _, result := range results {
+			if result.Job.Manager != set.Manager {
+				continue
+			}
+			switch {
+			case result.Err != nil:
+				failed++
+			case result.Skipped:
+				skipped++
+			default:
+				installed++
+			}
+		}
+
+		icon := "✅"
+		if failed > 0 {
+			icon = "❌"
+		}
+		line := fmt.Sprintf("  %s %s: %d installed", icon, set.Name, installed)
+		if skipped > 0 {
+			line += fmt.Sprintf(", %d already done", skipped)
+		}
+		if failed > 0 {
+			line += fmt.Sprintf(", %d failed", failed)
+		}
+		fmt.Println(line)
+	}
+
+	failed := installer.Failed(results)
+	if len(failed) > 0 {
+		fmt.Printf("\n❌ Failed packages (%d):\n", len(failed))
+		for _, result := range failed {
+			fmt.Printf("   • %s %s: %v\n", result.Job.Manager.GetName(), result.Job.Package, result.Err)
+		}
+		fmt.Println()
+	}
+	return len(failed)
+}
+
 func init() {
-	installCmd.Flags().StringP("output", "o", "", "Output path for the package file (default: ./Brewfile or ./packages.txt)")
+	installCmd.Flags().StringP("file", "o", "", "Output path for the package file (default: ./Brewfile or ./packages.txt)")
+	installCmd.Annotations = map[string]string{legacyOutputPath: "file"} // --output used to be the path
 	installCmd.Flags().Bool("no-snapshot", false, "Skip creating automatic snapshot before installation")
 	installCmd.Flags().Bool("dry-run", false, "Show what would be installed without executing")
+	installCmd.Flags().Bool("frozen", false, "Install the versions recorded in dotfiles.lock and fail if any differ")
+	installCmd.Flags().IntP("jobs", "j", installer.DefaultConcurrency, "Number of packages to install in parallel")
+	installCmd.Flags().Bool("resume", false, "Continue the last install, skipping packages it already installed")
+	installCmd.Flags().Bool("no-scripts", false, "Don't run pending run_once_/run_onchange_ scripts")
 	rootCmd.AddCommand(installCmd)
-}
\ No newline at end of file
+}
@@ -1,41 +1,31 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 
-	"dotfiles/internal/config"
 	"github.com/spf13/cobra"
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all packages in your configuration",
-	Long:  `Shows all brews, casks, and taps in your config.json`,
+	Long:  `Shows all brews, casks, and taps in your config.json, with the OS, host and profile overlays merged`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
+		// --json is kept as a shorthand for --output json
+		if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
+			outputFlag = outputJSON
 		}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
-		cfg, err := config.Load(configPath)
+		cfg, err := loadMergedConfig()
 		if err != nil {
-			fmt.Printf("Error loading configuration: %v\n", err)
-			os.Exit(1)
+			fail("Error loading configuration", err)
 		}
 
-		// Handle JSON output
-		if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
-			data, err := json.MarshalIndent(cfg, "", "  ")
-			if err != nil {
-				fmt.Printf("Error marshaling JSON: %v\n", err)
-				os.Exit(1)
+		// Structured output is the merged configuration
+		if structuredOutput() {
+			if err := printResult(cfg); err != nil {
+				fail("Error printing configuration", err)
 			}
-			fmt.Println(string(data))
 			return
 		}
 
@@ -116,8 +106,8 @@ var listCmd = &cobra.Command{
 }
 
 func init() {
-	listCmd.Flags().Bool("json", false, "Output as JSON")
+	listCmd.Flags().Bool("json", false, "Output as JSON (same as --output json)")
 	listCmd.Flags().Bool("count", false, "Show only package counts")
 	listCmd.Flags().StringP("type", "t", "", "Filter by package type (brew, cask, tap, stow)")
 	rootCmd.AddCommand(listCmd)
-}
\ No newline at end of file
+}
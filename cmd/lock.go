@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"dotfiles/internal/config"
+	"dotfiles/internal/pkgmanager"
+	"github.com/spf13/cobra"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "🔒 Record installed package versions in dotfiles.lock",
+	Long: `🔒 Lock Package Versions
+
+Writes dotfiles.lock with the exact version of every configured package as
+installed through each package manager. Commit it so 'dotfiles install --frozen'
+installs the same versions on other machines.
+
+Entries for package managers not used on this machine are kept, so one lock
+file can serve both macOS and Linux.
+
+Version constraints from "package_versions" in config.json are checked while
+locking:
+
+  "package_versions": {
+    "node": "20.*",
+    "git": ">=2.40,<3",
+    "ripgrep": "14.1.0"
+  }
+
+Examples:
+  dotfiles lock                    # Write dotfiles.lock
+  dotfiles install --frozen        # Install the locked versions`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := loadMergedConfig()
+		if err != nil {
+			fmt.Printf("❌ Error loading configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		sets, err := configuredManagers(cfg)
+		if err != nil {
+			if len(sets) == 0 {
+				fmt.Printf("❌ Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("⚠️  %v\n", err)
+		}
+
+		// Keep entries for package managers that are not on this machine
+		lock, err := config.LoadLock(appCtx.LockPath())
+		if err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Printf("❌ Error loading lock file: %v\n", err)
+				os.Exit(1)
+			}
+			lock = config.NewLock()
+		}
+
+		locked, problems := 0, 0
+		for _, set := range sets {
+			if set.Count() == 0 {
+				continue
+			}
+			if !set.Available() {
+				fmt.Printf("⚠️  %s not available, keeping its existing lock entries\n", set.Name)
+				continue
+			}
+
+			lister, ok := set.Manager.(pkgmanager.VersionLister)
+			if !ok {
+				fmt.Printf("⚠️  %s cannot report package versions, skipping\n", set.Name)
+				continue
+			}
+
+			fmt.Printf("\n🔒 %s:\n", set.Name)
+			delete(lock.Managers, set.Name)
+
+			for _, pkgType := range []string{"brew", "cask"} {
+				packages := managedPackages(set, pkgType)
+				if len(packages) == 0 {
+					continue
+				}
+
+				versions, err := lister.InstalledVersions(pkgType)
+				if err != nil {
+					fmt.Printf("  ⚠️  Could not list installed versions: %v\n", err)
+					problems += len(packages)
+					continue
+				}
+
+				for _, pkg := range packages {
+					version, installed := versions[pkg]
+					if !installed {
+						fmt.Printf("  ❌ %s (not installed)\n", pkg)
+						problems++
+						continue
+					}
+
+					if constraint, ok := cfg.PackageVersions[pkg]; ok {
+						if match, err := pkgmanager.MatchesConstraint(version, constraint); err != nil || !match {
+							fmt.Printf("  ⚠️  %s %s (does not satisfy %s)\n", pkg, version, constraint)
+							problems++
+						} else {
+							fmt.Printf("  ✅ %s %s\n", pkg, version)
+						}
+					} else {
+						fmt.Printf("  ✅ %s %s\n", pkg, version)
+					}
+
+					lock.Set(set.Name, pkgType, pkg, version)
+					locked++
+				}
+			}
+		}
+
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			fmt.Printf("\n🔍 Dry run - would lock %d package(s) in %s\n", locked, appCtx.LockPath())
+			return
+		}
+
+		lock.GeneratedAt = time.Now()
+		if err := lock.Save(appCtx.LockPath()); err != nil {
+			fmt.Printf("❌ Error writing lock file: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("\n✅ Locked %d package(s) in %s\n", locked, appCtx.LockPath())
+		if problems > 0 {
+			fmt.Printf("⚠️  %d package(s) are missing or outside their constraints. Run 'dotfiles install' and lock again.\n", problems)
+		}
+	},
+}
+
+// managedPackages returns the packages of a type that the set's package
+// manager actually installs: Linux package managers skip unmapped casks and
+// packages mapped to "-"
+func managedPackages(set managerSet, pkgType string) []string {
+	packages := set.Brews
+	if pkgType == "cask" {
+		packages = set.Casks
+	}
+	if set.Name == "homebrew" {
+		return packages
+	}
+
+	var result []string
+	for _, pkg := range packages {
+		if pkgType == "cask" && !pkgmanager.HasMapping(set.Name, pkg) {
+			continue
+		}
+		if pkgmanager.ResolveName(set.Name, pkg) == pkgmanager.SkipPackage {
+			continue
+		}
+		result = append(result, pkg)
+	}
+	return result
+}
+
+// versionPins returns the exact versions to install per package type and
+// package: the locked versions when lock is set (--frozen), otherwise the
+// constraints in package_versions that name a single version
+func versionPins(set managerSet, cfg *config.Config, lock *config.Lock) map[string]map[string]string {
+	pins := map[string]map[string]string{"brew": {}, "cask": {}}
+	for _, pkgType := range []string{"brew", "cask"} {
+		for _, pkg := range managedPackages(set, pkgType) {
+			if lock != nil {
+				if version, ok := lock.Get(set.Name, pkgType, pkg); ok {
+					pins[pkgType][pkg] = version
+				}
+			} else if version, ok := pkgmanager.ExactVersion(cfg.PackageVersions[pkg]); ok {
+				pins[pkgType][pkg] = version
+			}
+		}
+	}
+	return pins
+}
+
+// checkVersions compares installed versions with the lock (when set) or the
+// configured constraints, printing every package that differs. It returns
+// the number of differences.
+func checkVersions(set managerSet, cfg *config.Config, lock *config.Lock) int {
+	if lock == nil && len(cfg.PackageVersions) == 0 {
+		return 0
+	}
+
+	lister, ok := set.Manager.(pkgmanager.VersionLister)
+	if !ok {
+		if lock != nil {
+			fmt.Printf("⚠️  %s cannot report package versions, not checked against the lock\n", set.Name)
+		}
+		return 0
+	}
+
+	differences := 0
+	for _, pkgType := range []string{"brew", "cask"} {
+		packages := managedPackages(set, pkgType)
+		if len(packages) == 0 {
+			continue
+		}
+
+		versions, err := lister.InstalledVersions(pkgType)
+		if err != nil {
+			fmt.Printf("⚠️  Could not list %s versions: %v\n", set.Name, err)
+			continue
+		}
+
+		for _, pkg := range packages {
+			installed, ok := versions[pkg]
+
+			if lock != nil {
+				locked, inLock := lock.Get(set.Name, pkgType, pkg)
+				switch {
+				case !inLock:
+					fmt.Printf("  ⚠️  %s is not in dotfiles.lock\n", pkg)
+				case !ok:
+					fmt.Printf("  ❌ %s: locked %s, not installed\n", pkg, locked)
+					differences++
+				case installed != locked:
+					fmt.Printf("  ❌ %s: locked %s, installed %s\n", pkg, locked, installed)
+					differences++
+				}
+				continue
+			}
+
+			constraint, constrained := cfg.PackageVersions[pkg]
+			if !constrained || !ok {
+				continue
+			}
+			if match, err := pkgmanager.MatchesConstraint(installed, constraint); err != nil {
+				fmt.Printf("  ⚠️  %s: %v\n", pkg, err)
+				differences++
+			} else if !match {
+				fmt.Printf("  ⚠️  %s: installed %s does not satisfy %s\n", pkg, installed, constraint)
+				differences++
+			}
+		}
+	}
+	return differences
+}
+
+func init() {
+	lockCmd.Flags().Bool("dry-run", false, "Show the versions that would be locked without writing dotfiles.lock")
+	rootCmd.AddCommand(lockCmd)
+}
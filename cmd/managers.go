@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"sort"
+
+	"dotfiles/internal/config"
+	"dotfiles/internal/pkgmanager"
+)
+
+// managerSet holds the packages one package manager is responsible for
+type managerSet struct {
+	Name    string                    // Package manager name, or the config key when unknown
+	Manager pkgmanager.PackageManager // nil when Name is not a known package manager
+	Brews   []string
+	Casks   []string
+	Taps    []string
+	Primary bool // Detected system package manager, handles the top-level brews/casks/taps
+}
+
+// Available reports whether the package manager is known and installed
+func (s managerSet) Available() bool {
+	return s.Manager != nil && s.Manager.IsAvailable()
+}
+
+// Count returns the number of configured packages
+func (s managerSet) Count() int {
+	return len(s.Brews) + len(s.Casks) + len(s.Taps)
+}
+
+// configuredManagers groups the configured packages by package manager. The
+// detected system package manager comes first and owns the top-level
+// brews, casks and taps; entries under "packages" follow in ManagerNames
+// order, merged into the primary when they name the same manager. The error
+// is from detecting the system package manager; the other sets are still
+// returned when it fails.
+func configuredManagers(cfg *config.Config) ([]managerSet, error) {
+	var sets []managerSet
+
+	primary, primaryErr := pkgmanager.GetPackageManager()
+	if primaryErr == nil {
+		sets = append(sets, managerSet{
+			Name:    primary.GetName(),
+			Manager: primary,
+			Brews:   cfg.Brews,
+			Casks:   cfg.Casks,
+			Taps:    cfg.Taps,
+			Primary: true,
+		})
+	}
+
+	keys := make([]string, 0, len(cfg.Packages))
+	for key := range cfg.Packages {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return managerOrder(keys[i]) < managerOrder(keys[j]) ||
+			(managerOrder(keys[i]) == managerOrder(keys[j]) && keys[i] < keys[j])
+	})
+
+	for _, key := range keys {
+		packages := cfg.Packages[key]
+		pm, err := pkgmanager.GetPackageManagerByName(key)
+		if err != nil {
+			sets = append(sets, managerSet{Name: key, Brews: packages})
+			continue
+		}
+
+		merged := false
+		for i := range sets {
+			if sets[i].Manager != nil && sets[i].Manager.GetName() == pm.GetName() {
+				sets[i].Brews = mergeUnique(sets[i].Brews, packages)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			sets = append(sets, managerSet{Name: pm.GetName(), Manager: pm, Brews: packages})
+		}
+	}
+
+	return sets, primaryErr
+}
+
+// managerOrder sorts config keys by their position in ManagerNames, unknown names last
+func managerOrder(key string) int {
+	pm, err := pkgmanager.GetPackageManagerByName(key)
+	if err != nil {
+		return len(pkgmanager.ManagerNames)
+	}
+	for i, name := range pkgmanager.ManagerNames {
+		if name == pm.GetName() {
+			return i
+		}
+	}
+	return len(pkgmanager.ManagerNames)
+}
+
+// addManagerPackages adds packages to the config's "packages" entry for a
+// package manager, reusing an existing key such as "brew" for "homebrew"
+func addManagerPackages(cfg *config.Config, name string, packages []string) {
+	if len(packages) == 0 {
+		return
+	}
+
+	key := name
+	for existing := range cfg.Packages {
+		if pm, err := pkgmanager.GetPackageManagerByName(existing); err == nil && pm.GetName() == name {
+			key = existing
+			break
+		}
+	}
+
+	if cfg.Packages == nil {
+		cfg.Packages = make(map[string][]string)
+	}
+	cfg.Packages[key] = mergeUnique(cfg.Packages[key], packages)
+	sort.Strings(cfg.Packages[key])
+}
@@ -100,8 +100,7 @@ Examples:
 
 		// Set up complete environment (private dir + shell packages + stow)
 		fmt.Println("🔒 Setting up dotfiles environment...")
-		home, _ := os.UserHomeDir()
-		dotfilesDir := filepath.Join(home, ".dotfiles")
+		dotfilesDir := appCtx.Root
 		if err := setupCompleteEnvironment(dotfilesDir, true); err != nil {
 			fmt.Printf("⚠️  Environment setup had issues: %v\n", err)
 		} else {
@@ -170,12 +169,7 @@ Examples:
 }
 
 func initializeConfig() error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	configPath := appCtx.ConfigPath()
 
 	// Check if config already exists
 	if _, err := os.Stat(configPath); err == nil {
@@ -274,12 +268,7 @@ func installEssentialPackages(skipInteractive bool) error {
 
 	// Show packages that will be installed
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	configPath := appCtx.ConfigPath()
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %v", err)
@@ -362,12 +351,14 @@ func showNextSteps() {
 	fmt.Println("   • Check status anytime with: dotfiles status")
 }
 
-func askConfirmation(prompt string, defaultYes bool) bool {
-	reader := bufio.NewReader(os.Stdin)
+// stdinReader is shared by prompts so that answers piped in together are
+// not lost to a previous prompt's buffer
+var stdinReader = bufio.NewReader(os.Stdin)
 
+func askConfirmation(prompt string, defaultYes bool) bool {
 	for {
 		fmt.Print(prompt)
-		response, _ := reader.ReadString('\n')
+		response, _ := stdinReader.ReadString('\n')
 		response = strings.TrimSpace(strings.ToLower(response))
 
 		if response == "" {
@@ -417,7 +408,7 @@ func offerDotfilesImport(dotfiles []string, skipInteractive bool) error {
 		return err
 	}
 
-	dotfilesDir := filepath.Join(home, ".dotfiles")
+	dotfilesDir := appCtx.Root
 	stowDir := filepath.Join(dotfilesDir, "stow")
 
 	// Ensure directories exist
@@ -464,23 +455,23 @@ func offerDotfilesImport(dotfiles []string, skipInteractive bool) error {
 func suggestPackageName(dotfile string) string {
 	// Suggest logical package names based on dotfile
 	packageMap := map[string]string{
-		".zshrc":         "zsh",
-		".bashrc":        "bash",
-		".bash_profile":  "bash",
-		".profile":       "shell",
-		".vimrc":         "vim",
-		".vim":           "vim",
-		".nvim":          "nvim",
-		".config/nvim":   "nvim",
-		".tmux.conf":     "tmux",
-		".gitconfig":     "git",
+		".zshrc":            "zsh",
+		".bashrc":           "bash",
+		".bash_profile":     "bash",
+		".profile":          "shell",
+		".vimrc":            "vim",
+		".vim":              "vim",
+		".nvim":             "nvim",
+		".config/nvim":      "nvim",
+		".tmux.conf":        "tmux",
+		".gitconfig":        "git",
 		".gitignore_global": "git",
-		".aliases":       "shell",
-		".functions":     "shell",
-		".exports":       "shell",
-		".ssh/config":    "ssh",
-		".aws":           "aws",
-		".docker":        "docker",
+		".aliases":          "shell",
+		".functions":        "shell",
+		".exports":          "shell",
+		".ssh/config":       "ssh",
+		".aws":              "aws",
+		".docker":           "docker",
 	}
 
 	if pkg, exists := packageMap[dotfile]; exists {
@@ -720,12 +711,7 @@ func scanAndOfferPackages(skipInteractive bool) error {
 		return nil
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	configPath := appCtx.ConfigPath()
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %v", err)
@@ -813,4 +799,3 @@ func init() {
 
 	rootCmd.AddCommand(onboardCmd)
 }
-
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Exit codes. Provisioning scripts and dashboards rely on them, so they
+// mean the same for every command and output format.
+const (
+	ExitOK    = 0 // Success, and nothing differs from the configuration
+	ExitError = 1 // The command failed, e.g. the configuration can't be read
+	ExitDrift = 2 // The command ran and found differences or problems (status, diff, doctor, snapshot diff, validate and secrets scan, apply --detailed-exitcode)
+	ExitUsage = 3 // Invalid command line: unknown command or flag, wrong arguments or --output value
+)
+
+// Formats of the global --output flag
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFlag holds the value of the global --output flag
+var outputFlag string
+
+// legacyOutputPath annotates commands whose --output used to be the path of
+// the file they write, naming the flag that took it over
+const legacyOutputPath = "legacy_output_path"
+
+// takeLegacyOutputPath treats an --output value that is not a format as the
+// file path it used to be for the command, moving it to the new flag
+func takeLegacyOutputPath(cmd *cobra.Command) {
+	name, ok := cmd.Annotations[legacyOutputPath]
+	if !ok || validateOutputFlag() == nil {
+		return
+	}
+	if !cmd.Flags().Changed(name) {
+		cmd.Flags().Set(name, outputFlag)
+	}
+	fmt.Fprintf(os.Stderr, "⚠️  --output %s is deprecated for '%s', use --%s %s\n", outputFlag, cmd.CommandPath(), name, outputFlag)
+	outputFlag = outputTable
+}
+
+// validateOutputFlag checks the --output value
+func validateOutputFlag() error {
+	switch outputFlag {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid --output %q: use table, json or yaml", outputFlag)
+}
+
+// structuredOutput reports whether results are printed as JSON or YAML
+// rather than for people
+func structuredOutput() bool {
+	return outputFlag == outputJSON || outputFlag == outputYAML
+}
+
+// ErrorResult is printed instead of a command's result when it fails with
+// structured output
+type ErrorResult struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// PackageCounts summarizes the packages of a configuration
+type PackageCounts struct {
+	Brews int `json:"brews"`
+	Casks int `json:"casks"`
+	Taps  int `json:"taps"`
+	Stow  int `json:"stow"`
+}
+
+// printReport prints a command's result: as JSON or YAML when --output asks for
+// it, otherwise with table. It then exits with code unless that is ExitOK.
+func printReport(result interface{}, code int, table func()) {
+	if structuredOutput() {
+		if err := printResult(result); err != nil {
+			fail("Error printing result", err)
+		}
+	} else {
+		table()
+	}
+	if code != ExitOK {
+		os.Exit(code)
+	}
+}
+
+// fail reports an error and exits with ExitError. With structured output
+// the error is an ErrorResult on stdout, so consumers always get a document.
+func fail(message string, err error) {
+	if structuredOutput() {
+		printResult(ErrorResult{Error: fmt.Sprintf("%s: %v", message, err), ExitCode: ExitError})
+	} else {
+		fmt.Printf("❌ %s: %v\n", message, err)
+	}
+	os.Exit(ExitError)
+}
+
+// humanOutputToStderr runs fn with its output on stderr when results are
+// structured, so stdout only holds the result
+func humanOutputToStderr(fn func()) {
+	if !structuredOutput() {
+		fn()
+		return
+	}
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+	fn()
+}
+
+// printResult prints v as JSON or YAML. YAML is converted from the JSON
+// encoding, so both use the json tags and field order.
+func printResult(v interface{}) error {
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	if outputFlag != outputYAML {
+		_, err := os.Stdout.Write(data.Bytes())
+		return err
+	}
+
+	// JSON is YAML; decoding it keeps key order, and clearing the flow
+	// style prints it as block YAML
+	var node yaml.Node
+	if err := yaml.Unmarshal(data.Bytes(), &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	out := yaml.NewEncoder(os.Stdout)
+	out.SetIndent(2)
+	if err := out.Encode(&node); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
@@ -3,10 +3,31 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"dotfiles/internal/config"
+	"dotfiles/internal/pkgmanager"
 	"github.com/spf13/cobra"
 )
 
+// appContext holds the locations shared by every subcommand. It is resolved
+// once before any command runs so that commands, snapshots and the TUIs all
+// read and write the same dotfiles root.
+type appContext struct {
+	Root string // Dotfiles root directory (config.json, stow/, private/, ...)
+	Home string // User home directory, the default stow target
+}
+
+// appCtx is the resolved context for the current invocation
+var appCtx = &appContext{}
+
+// rootFlag holds the value of the global --root flag
+var rootFlag string
+
+// profileFlag holds the value of the global --profile flag
+var profileFlag string
+
 var rootCmd = &cobra.Command{
 	Use:   "dotfiles",
 	Short: "🚀 Complete developer environment management toolkit",
@@ -14,7 +35,7 @@ var rootCmd = &cobra.Command{
 
 A comprehensive command-line tool that manages your entire development environment:
 • Homebrew packages with smart curation
-• Dotfiles management with GNU Stow
+• Dotfiles management with Stow-style symlinks
 • GitHub SSH setup and configuration
 • Complete developer onboarding automation
 • Configuration sharing and templates
@@ -27,12 +48,249 @@ Quick Start:
   dotfiles templates discover         # Browse community templates
   dotfiles add git curl tmux          # Add essential packages
 
+Dotfiles Location:
+  The dotfiles root is resolved in this order:
+  1. --root flag
+  2. DOTFILES_HOME environment variable
+  3. ~/.dotfiles (if it exists)
+  4. $XDG_CONFIG_HOME/dotfiles (if it exists, default ~/.config/dotfiles)
+  5. ~/.dotfiles
+
+Output and Exit Codes:
+  status, diff, doctor, apply, history, undo, list, list-profiles and
+  groups, hooks and snapshot list print JSON or YAML with --output json|yaml.
+  Every command exits with 0 on success, 1 on error, 2 when differences or
+  problems were found and 3 on invalid usage.
+
 Get started: https://github.com/wsoule/dotfiles-cli`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		takeLegacyOutputPath(cmd)
+		if err := validateOutputFlag(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(ExitUsage)
+		}
+		recordConfigChanges(cmd)
+	},
 }
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
+		os.Exit(ExitUsage)
+	}
+}
+
+func init() {
+	cobra.OnInitialize(initAppContext)
+	rootCmd.PersistentFlags().StringVar(&rootFlag, "root", "", "Dotfiles root directory (default: $DOTFILES_HOME or ~/.dotfiles)")
+	rootCmd.PersistentFlags().StringVar(&outputFlag, "output", outputTable, "Output format of reports: table, json or yaml")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "Configuration profile layered on config.json (default: $DOTFILES_PROFILE or 'dotfiles config profile')")
+}
+
+// initAppContext resolves the shared context from flags and environment
+func initAppContext() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Printf("❌ Error getting home directory: %v\n", err)
 		os.Exit(1)
 	}
+
+	appCtx.Home = home
+	appCtx.Root = resolveDotfilesRoot(rootFlag, home)
+
+	// Package name overrides apply to every package manager call
+	if cfg, err := loadMergedConfig(); err == nil {
+		pkgmanager.SetMappings(cfg.PackageMappings)
+	}
+}
+
+// resolveDotfilesRoot determines the dotfiles root directory.
+// Precedence: --root flag, DOTFILES_HOME, an existing ~/.dotfiles,
+// an existing $XDG_CONFIG_HOME/dotfiles, and finally ~/.dotfiles.
+func resolveDotfilesRoot(flagValue, home string) string {
+	if flagValue != "" {
+		return absPath(expandHome(flagValue, home))
+	}
+
+	if env := os.Getenv("DOTFILES_HOME"); env != "" {
+		return absPath(expandHome(env, home))
+	}
+
+	legacy := filepath.Join(home, ".dotfiles")
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy
+	}
+
+	xdgConfig := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfig == "" {
+		xdgConfig = filepath.Join(home, ".config")
+	}
+	xdgRoot := filepath.Join(expandHome(xdgConfig, home), "dotfiles")
+	if _, err := os.Stat(xdgRoot); err == nil {
+		return absPath(xdgRoot)
+	}
+
+	return legacy
+}
+
+// expandHome replaces a leading ~ with the user's home directory
+func expandHome(path, home string) string {
+	if path == "~" {
+		return home
+	}
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(home, path[2:])
+	}
+	return path
+}
+
+// absPath returns the absolute form of path, or path itself on error
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// ConfigPath returns the path to the configuration file: config.json, or
+// config.yaml or config.toml when that is what the dotfiles root holds
+func (c *appContext) ConfigPath() string {
+	return config.FindFile(c.Root, "config")
+}
+
+// StowDir returns the directory containing stow packages
+func (c *appContext) StowDir() string {
+	return filepath.Join(c.Root, "stow")
+}
+
+// PrivateDir returns the directory holding private (git-ignored) files
+func (c *appContext) PrivateDir() string {
+	return filepath.Join(c.Root, "private")
+}
+
+// SnapshotsDir returns the directory holding configuration snapshots
+func (c *appContext) SnapshotsDir() string {
+	return filepath.Join(c.Root, "snapshots")
+}
+
+// ProfilesDir returns the directory holding exported machine profiles
+func (c *appContext) ProfilesDir() string {
+	return filepath.Join(c.Root, "profiles")
+}
+
+// TemplatesDir returns the directory holding custom templates
+func (c *appContext) TemplatesDir() string {
+	return filepath.Join(c.Root, "templates")
+}
+
+// BackupsDir returns the directory holding conflict backups
+func (c *appContext) BackupsDir() string {
+	return filepath.Join(c.Root, "backups")
+}
+
+// StateDir returns the directory holding machine-local state (git-ignored)
+func (c *appContext) StateDir() string {
+	return filepath.Join(c.Root, "state")
+}
+
+// stateGitignore keeps everything in state/ out of git, whatever the
+// dotfiles .gitignore says; repositories created before state/ existed lack it
+const stateGitignore = "# Machine-local state, including plaintext copies of private/ files\n*\n"
+
+// EnsureStateDir creates state/ with the .gitignore that keeps it out of
+// git. Call it before writing anything below state/.
+func (c *appContext) EnsureStateDir() error {
+	path := filepath.Join(c.StateDir(), ".gitignore")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.StateDir(), 0755); err != nil {
+		return fmt.Errorf("error creating state directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(stateGitignore), 0644); err != nil {
+		return fmt.Errorf("error creating %s: %v", path, err)
+	}
+	return nil
+}
+
+// LinkManifestPath returns the path to the manifest of links created by stow
+func (c *appContext) LinkManifestPath() string {
+	return filepath.Join(c.StateDir(), "links.json")
+}
+
+// LockPath returns the path to dotfiles.lock, which records installed package versions
+func (c *appContext) LockPath() string {
+	return filepath.Join(c.Root, "dotfiles.lock")
+}
+
+// JournalPath returns the path to the history of configuration changes, see 'dotfiles history'
+func (c *appContext) JournalPath() string {
+	return filepath.Join(c.StateDir(), "journal.jsonl")
+}
+
+// InstallJournalPath returns the path to the per-package results of the last install
+func (c *appContext) InstallJournalPath() string {
+	return filepath.Join(c.StateDir(), "install-journal.json")
+}
+
+// ScriptsDir returns the directory holding run_once_ and run_onchange_ scripts
+func (c *appContext) ScriptsDir() string {
+	return filepath.Join(c.Root, "scripts")
+}
+
+// ScriptsStatePath returns the path to the record of scripts run on this machine
+func (c *appContext) ScriptsStatePath() string {
+	return filepath.Join(c.StateDir(), "scripts.json")
+}
+
+// EncryptedDir returns the directory holding the committed, encrypted copy of private/
+func (c *appContext) EncryptedDir() string {
+	return filepath.Join(c.Root, "private.enc")
+}
+
+// RecipientsPath returns the path to the age public keys private files are encrypted to
+func (c *appContext) RecipientsPath() string {
+	return filepath.Join(c.Root, ".age-recipients")
+}
+
+// IdentityPath returns the path to this machine's age secret key. It lives
+// outside the dotfiles root so it is never committed: $DOTFILES_AGE_IDENTITY,
+// or $XDG_CONFIG_HOME/age/dotfiles.txt (default ~/.config/age/dotfiles.txt).
+func (c *appContext) IdentityPath() string {
+	if env := os.Getenv("DOTFILES_AGE_IDENTITY"); env != "" {
+		return absPath(expandHome(env, c.Home))
+	}
+
+	xdgConfig := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfig == "" {
+		xdgConfig = filepath.Join(c.Home, ".config")
+	}
+	return filepath.Join(expandHome(xdgConfig, c.Home), "age", "dotfiles.txt")
+}
+
+// SecretsAllowlistPath returns the path to the list of accepted secret scanner findings
+func (c *appContext) SecretsAllowlistPath() string {
+	return filepath.Join(c.Root, ".secrets-allowlist")
+}
+
+// ActiveProfilePath returns the path to the name of the profile this machine layers on config.json
+func (c *appContext) ActiveProfilePath() string {
+	return filepath.Join(c.StateDir(), "profile")
+}
+
+// Profile returns the active configuration profile: the --profile flag,
+// $DOTFILES_PROFILE, or the one set with 'dotfiles config profile'. Empty
+// when no profile is active.
+func (c *appContext) Profile() string {
+	if profileFlag != "" {
+		return profileFlag
+	}
+	if env := os.Getenv("DOTFILES_PROFILE"); env != "" {
+		return env
+	}
+	data, err := os.ReadFile(c.ActiveProfilePath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
 }
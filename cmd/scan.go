@@ -4,11 +4,11 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
 	"dotfiles/internal/config"
+	"dotfiles/internal/pkgmanager"
 	"github.com/spf13/cobra"
 )
 
@@ -17,31 +17,42 @@ var scanCmd = &cobra.Command{
 	Short: "🔍 Scan system for installed packages and add them to config",
 	Long: `🔍 System Package Scanner
 
-Scans your system for packages already installed via Homebrew and helps you
-add them to your dotfiles configuration. Perfect for when you're setting up
+Scans your system for packages already installed via Homebrew (or your
+system package manager) and any other available package manager such as
+flatpak, cargo or nix, and helps you add them to your dotfiles configuration. Perfect for when you're setting up
 dotfiles on an existing machine with packages already installed.
 
 Examples:
   dotfiles scan                    # Scan and interactively select packages
   dotfiles scan --auto             # Automatically add all installed packages
   dotfiles scan --brews-only       # Only scan Homebrew formulas
-  dotfiles scan --casks-only       # Only scan Homebrew casks`,
+  dotfiles scan --casks-only       # Only scan Homebrew casks
+  dotfiles scan --manager=flatpak  # Only scan one package manager`,
 	Run: func(cmd *cobra.Command, args []string) {
 		auto, _ := cmd.Flags().GetBool("auto")
 		brewsOnly, _ := cmd.Flags().GetBool("brews-only")
 		casksOnly, _ := cmd.Flags().GetBool("casks-only")
+		managerFilter, _ := cmd.Flags().GetString("manager")
+
+		primaryName := ""
+		if pm, err := pkgmanager.GetPackageManager(); err == nil {
+			primaryName = pm.GetName()
+		}
+		if managerFilter != "" {
+			pm, err := pkgmanager.GetPackageManagerByName(managerFilter)
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				os.Exit(1)
+			}
+			managerFilter = pm.GetName()
+		}
+		scanPrimary := managerFilter == "" || managerFilter == primaryName
 
 		fmt.Println("🔍 Scanning system for installed packages...")
 		fmt.Println()
 
 		// Load current config
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("⚠️  Could not load config (will create new): %v\n", err)
@@ -55,13 +66,22 @@ Examples:
 
 		var newBrews, newCasks []string
 
+		// Packages already configured per package manager, including "packages" entries
+		sets, _ := configuredManagers(cfg)
+		configuredBrews := cfg.Brews
+		for _, set := range sets {
+			if set.Primary {
+				configuredBrews = set.Brews
+			}
+		}
+
 		// Scan brews
-		if !casksOnly {
+		if scanPrimary && !casksOnly {
 			installedBrews, err := getInstalledBrews()
 			if err != nil {
 				fmt.Printf("⚠️  Could not scan Homebrew formulas: %v\n", err)
 			} else {
-				newBrews = filterNewPackages(installedBrews, cfg.Brews)
+				newBrews = filterNewPackages(installedBrews, configuredBrews)
 				if len(newBrews) > 0 {
 					fmt.Printf("📋 Found %d Homebrew formulas not in config\n", len(newBrews))
 				}
@@ -69,7 +89,7 @@ Examples:
 		}
 
 		// Scan casks
-		if !brewsOnly {
+		if scanPrimary && !brewsOnly {
 			installedCasks, err := getInstalledCasks()
 			if err != nil {
 				fmt.Printf("⚠️  Could not scan Homebrew casks: %v\n", err)
@@ -81,7 +101,38 @@ Examples:
 			}
 		}
 
-		if len(newBrews) == 0 && len(newCasks) == 0 {
+		// Scan other package managers (flatpak, cargo, Linuxbrew, ...)
+		newOther := make(map[string][]string)
+		var otherNames []string
+		if !brewsOnly && !casksOnly {
+			for _, pm := range pkgmanager.AvailableManagers() {
+				name := pm.GetName()
+				if name == primaryName || (managerFilter != "" && managerFilter != name) {
+					continue
+				}
+
+				installed, err := pm.ListInstalled("brew")
+				if err != nil {
+					fmt.Printf("⚠️  Could not scan %s packages: %v\n", name, err)
+					continue
+				}
+
+				var configured []string
+				for _, set := range sets {
+					if set.Name == name {
+						configured = set.Brews
+					}
+				}
+
+				if pkgs := filterNewPackages(installed, configured); len(pkgs) > 0 {
+					newOther[name] = pkgs
+					otherNames = append(otherNames, name)
+					fmt.Printf("📦 Found %d %s packages not in config\n", len(pkgs), name)
+				}
+			}
+		}
+
+		if len(newBrews) == 0 && len(newCasks) == 0 && len(otherNames) == 0 {
 			fmt.Println("✅ All installed packages are already in your config!")
 			return
 		}
@@ -92,6 +143,9 @@ Examples:
 		if auto {
 			cfg.Brews = append(cfg.Brews, newBrews...)
 			cfg.Casks = append(cfg.Casks, newCasks...)
+			for _, name := range otherNames {
+				addManagerPackages(cfg, name, newOther[name])
+			}
 
 			// Sort for cleaner config
 			sort.Strings(cfg.Brews)
@@ -103,6 +157,9 @@ Examples:
 			}
 
 			fmt.Printf("✅ Added %d brews and %d casks to config\n", len(newBrews), len(newCasks))
+			for _, name := range otherNames {
+				fmt.Printf("✅ Added %d %s packages to config\n", len(newOther[name]), name)
+			}
 			return
 		}
 
@@ -123,7 +180,15 @@ Examples:
 			selectedCasks = selectPackages(newCasks)
 		}
 
-		if len(selectedBrews) == 0 && len(selectedCasks) == 0 {
+		selectedOther := make(map[string][]string)
+		selectedCount := 0
+		for _, name := range otherNames {
+			fmt.Printf("📦 %s Packages:\n", name)
+			selectedOther[name] = selectPackages(newOther[name])
+			selectedCount += len(selectedOther[name])
+		}
+
+		if len(selectedBrews) == 0 && len(selectedCasks) == 0 && selectedCount == 0 {
 			fmt.Println("No packages selected. Exiting.")
 			return
 		}
@@ -131,6 +196,9 @@ Examples:
 		// Add selected packages to config
 		cfg.Brews = append(cfg.Brews, selectedBrews...)
 		cfg.Casks = append(cfg.Casks, selectedCasks...)
+		for _, name := range otherNames {
+			addManagerPackages(cfg, name, selectedOther[name])
+		}
 
 		// Sort for cleaner config
 		sort.Strings(cfg.Brews)
@@ -143,6 +211,11 @@ Examples:
 
 		fmt.Println()
 		fmt.Printf("✅ Added %d brews and %d casks to config\n", len(selectedBrews), len(selectedCasks))
+		for _, name := range otherNames {
+			if len(selectedOther[name]) > 0 {
+				fmt.Printf("✅ Added %d %s packages to config\n", len(selectedOther[name]), name)
+			}
+		}
 		fmt.Println()
 		fmt.Println("💡 Next steps:")
 		fmt.Println("   • View your config: dotfiles list")
@@ -213,6 +286,7 @@ func init() {
 	scanCmd.Flags().Bool("auto", false, "Automatically add all installed packages without prompting")
 	scanCmd.Flags().Bool("brews-only", false, "Only scan Homebrew formulas")
 	scanCmd.Flags().Bool("casks-only", false, "Only scan Homebrew casks")
+	scanCmd.Flags().String("manager", "", "Only scan one package manager (e.g. flatpak, cargo)")
 
 	rootCmd.AddCommand(scanCmd)
 }
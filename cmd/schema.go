@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"dotfiles/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// schemaKind is a kind of file 'dotfiles schema' describes
+type schemaKind struct {
+	name        string
+	description string
+	schema      func() *config.Schema
+}
+
+var schemaKinds = []schemaKind{
+	{"config", "config.json, its overlays and profiles/<name>.json", configSchema},
+	{"template", "Templates created with 'dotfiles templates create'", templateSchema},
+	{"profile", "Profiles exported with 'dotfiles export'", profileSchema},
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema <config|template|profile>",
+	Short: "📐 Print the JSON Schema of a configuration file",
+	Long: `📐 JSON Schema
+
+Print the JSON Schema of config.json, templates or exported profiles, for
+editor autocompletion and validation. The schema is generated from the types
+dotfiles reads, so it always matches this version.
+
+Reference it from config.json:
+
+  {"$schema": "./schema/config.json", "brews": [...]}
+
+or from config.yaml with a comment for the YAML language server:
+
+  # yaml-language-server: $schema=./schema/config.json
+
+'dotfiles config validate' and 'dotfiles templates validate' check files
+against the same schema.
+
+Examples:
+  dotfiles schema config > schema/config.json
+  dotfiles schema template > schema/template.json`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"config", "template", "profile"},
+	Run: func(cmd *cobra.Command, args []string) {
+		kind, ok := findSchemaKind(args[0])
+		if !ok {
+			fmt.Printf("❌ Unknown schema '%s'. Available:\n", args[0])
+			for _, k := range schemaKinds {
+				fmt.Printf("   %-10s %s\n", k.name, k.description)
+			}
+			os.Exit(1)
+		}
+
+		data, err := json.MarshalIndent(kind.schema(), "", "  ")
+		if err != nil {
+			fmt.Printf("❌ Error generating schema: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	},
+}
+
+func findSchemaKind(name string) (schemaKind, bool) {
+	for _, kind := range schemaKinds {
+		if kind.name == name {
+			return kind, true
+		}
+	}
+	return schemaKind{}, false
+}
+
+func configSchema() *config.Schema {
+	return config.RootSchema(config.Config{}, "dotfiles configuration")
+}
+
+func templateSchema() *config.Schema {
+	s := config.RootSchema(ExtendedTemplate{}, "dotfiles template")
+	s.Required = []string{"metadata"}
+	metadata := s.Properties["metadata"]
+	metadata.Required = []string{"name", "description", "author"}
+	for _, field := range metadata.Required {
+		metadata.Properties[field].MinLength = 1
+	}
+	return s
+}
+
+func profileSchema() *config.Schema {
+	s := config.RootSchema(MachineProfile{}, "dotfiles machine profile")
+	s.Required = []string{"name", "config"}
+	return s
+}
+
+// printProblems prints the problems of a failed validation one per line,
+// or the error itself when it isn't a validation error
+func printProblems(err error) {
+	var invalid *config.ValidationError
+	if !errors.As(err, &invalid) {
+		fmt.Printf("   %v\n", err)
+		return
+	}
+	for _, problem := range invalid.Problems {
+		fmt.Printf("   • %s\n", problem)
+	}
+}
+
+// isUnreadable reports whether a validation failed because the file couldn't
+// be read, rather than because of what it holds
+func isUnreadable(err error) bool {
+	var pathErr *fs.PathError
+	return errors.As(err, &pathErr)
+}
+
+// problemCount returns how many problems a validation error holds
+func problemCount(err error) int {
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		return len(invalid.Problems)
+	}
+	return 1
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
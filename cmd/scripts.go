@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"dotfiles/internal/scripts"
+	"github.com/spf13/cobra"
+)
+
+var scriptsCmd = &cobra.Command{
+	Use:   "scripts",
+	Short: "📜 Manage run-once and run-on-change bootstrap scripts",
+	Long: `📜 Bootstrap Scripts
+
+Scripts in the scripts/ directory of your dotfiles run during 'dotfiles install'
+and 'dotfiles apply', in name order, from the dotfiles root:
+
+• run_once_*     - Run once per machine, never again
+• run_onchange_* - Run again whenever the script's content changes
+
+Other files in scripts/ are ignored. Executable scripts run directly (so their
+shebang is used), others run with sh. A script that fails is not recorded and
+runs again next time. Which scripts ran is kept in state/scripts.json.
+
+Examples:
+  scripts/run_once_10-default-shell.sh
+  scripts/run_onchange_20-rustup.sh
+
+  dotfiles scripts status      # Show pending and completed scripts`,
+}
+
+var scriptsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show pending and completed scripts",
+	Run: func(cmd *cobra.Command, args []string) {
+		found, state, err := loadScripts()
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(found) == 0 {
+			fmt.Printf("📜 No scripts in %s\n", appCtx.ScriptsDir())
+			fmt.Println()
+			fmt.Println("💡 Add run_once_* or run_onchange_* scripts there to run them during install")
+			return
+		}
+
+		fmt.Println("📜 Scripts:")
+		fmt.Println("=" + strings.Repeat("=", 10))
+		fmt.Println()
+
+		pending := 0
+		for _, script := range found {
+			switch state.Status(script) {
+			case "pending":
+				pending++
+				fmt.Printf("  ⏳ %s (pending)\n", script.Name)
+			case "changed":
+				pending++
+				fmt.Printf("  🔄 %s (changed since %s)\n", script.Name, state.Scripts[script.Name].RanAt.Format("2006-01-02 15:04"))
+			default:
+				fmt.Printf("  ✅ %s (ran %s)\n", script.Name, state.Scripts[script.Name].RanAt.Format("2006-01-02 15:04"))
+			}
+		}
+
+		fmt.Println()
+		fmt.Printf("📊 %d pending, %d completed\n", pending, len(found)-pending)
+		if pending > 0 {
+			fmt.Println("💡 Run 'dotfiles install' or 'dotfiles apply' to run pending scripts")
+		}
+	},
+}
+
+// loadScripts discovers the scripts and loads their state
+func loadScripts() ([]scripts.Script, *scripts.State, error) {
+	found, err := scripts.Discover(appCtx.ScriptsDir())
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading scripts: %v", err)
+	}
+	state, err := scripts.LoadState(appCtx.ScriptsStatePath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading script state: %v", err)
+	}
+	return found, state, nil
+}
+
+// pendingScripts returns the scripts that have to run on this machine
+func pendingScripts() ([]scripts.Script, *scripts.State, error) {
+	found, state, err := loadScripts()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pending []scripts.Script
+	for _, script := range found {
+		if state.Pending(script) {
+			pending = append(pending, script)
+		}
+	}
+	return pending, state, nil
+}
+
+// runScript runs a script and records it in the state
+func runScript(script scripts.Script, state *scripts.State) error {
+	env := []string{"DOTFILES_ROOT=" + appCtx.Root, "DOTFILES_SCRIPT=" + script.Name}
+	if err := scripts.Run(script, appCtx.Root, env); err != nil {
+		return err
+	}
+	if err := appCtx.EnsureStateDir(); err != nil {
+		return err
+	}
+	return state.Record(script)
+}
+
+// runPendingScripts runs the pending scripts in order and returns the number
+// that failed. Later scripts still run after a failure.
+func runPendingScripts(dryRun bool) int {
+	pending, state, err := pendingScripts()
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
+	if len(pending) == 0 {
+		return 0
+	}
+
+	if dryRun {
+		fmt.Printf("🔍 Dry run - would run %d script(s):\n", len(pending))
+		for _, script := range pending {
+			fmt.Printf("   • %s\n", script.Name)
+		}
+		return 0
+	}
+
+	fmt.Printf("📜 Running %d script(s)...\n", len(pending))
+	failed := 0
+	for i, script := range pending {
+		fmt.Printf("   [%d/%d] %s\n", i+1, len(pending), script.Name)
+		if err := runScript(script, state); err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			failed++
+		}
+	}
+	fmt.Println()
+	return failed
+}
+
+func init() {
+	scriptsCmd.AddCommand(scriptsStatusCmd)
+	rootCmd.AddCommand(scriptsCmd)
+}
@@ -0,0 +1,515 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"dotfiles/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "🔐 Encrypt private files so they can be committed",
+	Long: `🔐 Encrypted Private Files
+
+private/ is git-ignored, so SSH keys and .env-private never reach another machine
+through 'dotfiles sync'. 'dotfiles secrets' encrypts them with age (X25519) into
+private.enc/, which is committed, and decrypts them again on other machines.
+
+• private.enc/<file>.age  - Encrypted copy of private/<file>
+• .age-recipients         - Public keys files are encrypted to (committed)
+• ~/.config/age/dotfiles.txt - This machine's secret key (never committed,
+                            override with $DOTFILES_AGE_IDENTITY)
+
+'dotfiles setup' and 'dotfiles apply' decrypt private.enc/ automatically when
+this machine's key is a recipient. Local files that differ are never overwritten.
+
+Examples:
+  dotfiles secrets keygen                 # Create this machine's key and add it as a recipient
+  dotfiles secrets encrypt                # Encrypt all of private/ into private.enc/
+  dotfiles secrets encrypt .ssh/id_ed25519
+  dotfiles secrets status                 # Show which files are encrypted
+  dotfiles secrets add-recipient age1...  # Let another machine decrypt
+  dotfiles secrets rekey                  # Re-encrypt after editing .age-recipients
+  dotfiles secrets decrypt                # Decrypt private.enc/ into private/`,
+}
+
+var secretsKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Create this machine's age key and add it as a recipient",
+	Run: func(cmd *cobra.Command, args []string) {
+		store := secretStore()
+
+		if _, err := os.Stat(store.IdentityPath); err == nil {
+			fmt.Printf("🔑 Identity already exists at %s\n", store.IdentityPath)
+		} else {
+			if _, err := secrets.GenerateIdentity(store.IdentityPath); err != nil {
+				fmt.Printf("❌ Error creating identity: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("🔑 Created identity at %s\n", store.IdentityPath)
+			fmt.Println("   Back it up somewhere safe: without it this machine cannot decrypt private.enc/")
+		}
+
+		keys, err := store.PublicKeys()
+		if err != nil {
+			fmt.Printf("❌ Error reading identity: %v\n", err)
+			os.Exit(1)
+		}
+
+		hostname, _ := os.Hostname()
+		for _, key := range keys {
+			fmt.Printf("   Public key: %s\n", key)
+			added, err := store.AddRecipient(key, hostname)
+			if err != nil {
+				fmt.Printf("❌ Error adding recipient: %v\n", err)
+				os.Exit(1)
+			}
+			if added {
+				fmt.Printf("✅ Added to %s\n", store.RecipientsPath)
+			}
+		}
+
+		statuses, _ := store.Status()
+		for _, status := range statuses {
+			if status.State == secrets.Unreadable {
+				fmt.Println()
+				fmt.Println("💡 This key cannot decrypt private.enc/ yet. On a machine that can, run:")
+				fmt.Printf("   dotfiles secrets add-recipient %s\n", strings.Join(keys, " "))
+				break
+			}
+		}
+	},
+}
+
+var secretsAddRecipientCmd = &cobra.Command{
+	Use:   "add-recipient <public-key>...",
+	Short: "Add age public keys and re-encrypt private.enc/ to them",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store := secretStore()
+		comment, _ := cmd.Flags().GetString("comment")
+		noRekey, _ := cmd.Flags().GetBool("no-rekey")
+
+		added := 0
+		for _, key := range args {
+			ok, err := store.AddRecipient(key, comment)
+			if err != nil {
+				fmt.Printf("❌ Invalid recipient %s: %v\n", key, err)
+				os.Exit(1)
+			}
+			if ok {
+				fmt.Printf("✅ Added recipient %s\n", key)
+				added++
+			} else {
+				fmt.Printf("⚠️  %s is already a recipient\n", key)
+			}
+		}
+
+		if added == 0 || noRekey {
+			return
+		}
+		if encrypted, _ := store.EncryptedFiles(); len(encrypted) == 0 {
+			return
+		}
+		rekeySecrets(store)
+	},
+}
+
+var secretsRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-encrypt private.enc/ to the current recipients",
+	Long: `Re-encrypt every file in private.enc/ to the keys in .age-recipients.
+
+Run it after removing a key from .age-recipients so that machine can no longer
+decrypt new commits. Files it already has stay readable to it, so rotate any
+secret the removed machine had access to.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rekeySecrets(secretStore())
+	},
+}
+
+var secretsEncryptCmd = &cobra.Command{
+	Use:   "encrypt [file]...",
+	Short: "Encrypt private files into private.enc/",
+	Long: `Encrypt files from private/ into private.enc/. Without arguments every file in
+private/ is encrypted. Files whose encrypted copy is already up to date are left
+alone, so unchanged secrets don't show up as changes in git.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := secretStore()
+		result, err := store.Encrypt(args)
+		if err != nil {
+			fmt.Printf("❌ Error encrypting: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, path := range result.Written {
+			fmt.Printf("🔐 %s\n", path)
+		}
+		fmt.Printf("✅ Encrypted %d file(s), %d unchanged\n", len(result.Written), len(result.Unchanged))
+		if len(result.Written) > 0 {
+			fmt.Println("💡 Run 'dotfiles sync' to commit private.enc/")
+		}
+	},
+}
+
+var secretsDecryptCmd = &cobra.Command{
+	Use:   "decrypt [file]...",
+	Short: "Decrypt private.enc/ into private/",
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		if err := decryptSecrets(args, force); err != nil {
+			fmt.Printf("❌ Error decrypting: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var secretsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which private files are encrypted",
+	Run: func(cmd *cobra.Command, args []string) {
+		store := secretStore()
+
+		recipients, err := store.Recipients()
+		if err != nil {
+			fmt.Printf("❌ Error reading recipients: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("🔐 Secrets:")
+		fmt.Println("=" + strings.Repeat("=", 10))
+		fmt.Println()
+		if _, err := store.Identities(); err != nil {
+			fmt.Printf("🔑 Identity: none (%v)\n", err)
+		} else {
+			fmt.Printf("🔑 Identity: %s\n", store.IdentityPath)
+		}
+		fmt.Printf("👥 Recipients: %d in %s\n", len(recipients), store.RecipientsPath)
+		fmt.Println()
+
+		statuses, err := store.Status()
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(statuses) == 0 {
+			fmt.Printf("📭 No files in %s\n", store.PrivateDir)
+			return
+		}
+
+		pending := 0
+		for _, status := range statuses {
+			switch status.State {
+			case secrets.Encrypted:
+				fmt.Printf("  ✅ %s\n", status.Path)
+			case secrets.Unencrypted:
+				pending++
+				fmt.Printf("  ⚠️  %s (not encrypted)\n", status.Path)
+			case secrets.Modified:
+				pending++
+				fmt.Printf("  🔄 %s (changed since encrypted)\n", status.Path)
+			case secrets.Missing:
+				fmt.Printf("  📥 %s (not decrypted on this machine)\n", status.Path)
+			default:
+				fmt.Printf("  ❌ %s (cannot decrypt: %v)\n", status.Path, status.Err)
+			}
+		}
+
+		if pending > 0 {
+			fmt.Println()
+			fmt.Printf("💡 Run 'dotfiles secrets encrypt' to encrypt %d file(s)\n", pending)
+		}
+	},
+}
+
+var secretsScanCmd = &cobra.Command{
+	Use:   "scan [path]...",
+	Short: "Scan the dotfiles repository for secrets",
+	Long: `Scan for AWS keys, GitHub and Slack tokens, private keys, passwords assigned
+to variables and other high-entropy strings. Exits with status 2 when anything
+is found, so it can run in CI, and 1 when the scan itself fails.
+
+Without paths, every file git would commit is scanned: tracked files and
+untracked files that are not ignored. 'dotfiles sync --auto' and
+'dotfiles share gist' run the same scan and stop when it finds something.
+
+Accept findings in .secrets-allowlist, one entry per line:
+  stow/vim/.vim/spell/        # A path or glob; directories cover everything below
+  sha256:3f9a0c1b2d4e5f60     # A single finding's fingerprint
+or add "dotfiles:allow" in a comment on the line itself.
+
+Examples:
+  dotfiles secrets scan               # Scan the dotfiles repository
+  dotfiles secrets scan --changed     # Only uncommitted changes
+  dotfiles secrets scan ~/.zshrc      # Scan other files`,
+	Run: func(cmd *cobra.Command, args []string) {
+		changed, _ := cmd.Flags().GetBool("changed")
+
+		var findings []secrets.Finding
+		var err error
+		if len(args) > 0 {
+			findings, err = scanPaths(args)
+		} else {
+			findings, err = scanRepository(changed)
+		}
+		if err != nil {
+			fail("Error scanning", err)
+		}
+
+		if len(findings) == 0 {
+			fmt.Println("✅ No secrets found")
+			return
+		}
+		printFindings(findings)
+		os.Exit(ExitDrift)
+	},
+}
+
+// secretStore returns the store for the dotfiles root
+func secretStore() *secrets.Store {
+	return &secrets.Store{
+		PrivateDir:     appCtx.PrivateDir(),
+		EncryptedDir:   appCtx.EncryptedDir(),
+		RecipientsPath: appCtx.RecipientsPath(),
+		IdentityPath:   appCtx.IdentityPath(),
+	}
+}
+
+// rekeySecrets re-encrypts private.enc/ to the current recipients, exiting on error
+func rekeySecrets(store *secrets.Store) {
+	result, err := store.Rekey()
+	if err != nil {
+		fmt.Printf("❌ Error re-encrypting: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("🔐 Re-encrypted %d file(s) to the current recipients\n", len(result.Written))
+	if len(result.Written) > 0 {
+		fmt.Println("💡 Run 'dotfiles sync' to commit private.enc/")
+	}
+}
+
+// decryptSecrets decrypts private.enc/ into private/ and reports what it did.
+// Nothing happens when there are no encrypted files.
+func decryptSecrets(paths []string, force bool) error {
+	store := secretStore()
+	if encrypted, err := store.EncryptedFiles(); err != nil || len(encrypted) == 0 {
+		return err
+	}
+
+	result, err := store.Decrypt(paths, force)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range result.Written {
+		fmt.Printf("🔓 %s\n", path)
+	}
+	for _, path := range result.Conflicts {
+		fmt.Printf("⚠️  Kept local %s, it differs from private.enc/ (use --force to overwrite)\n", path)
+	}
+	if len(result.Written) > 0 {
+		fmt.Printf("✅ Decrypted %d file(s) into %s\n", len(result.Written), store.PrivateDir)
+	}
+	return nil
+}
+
+// setupSecrets decrypts private.enc/ after cloning, explaining how to get
+// access when this machine has no key yet
+func setupSecrets() {
+	err := decryptSecrets(nil, false)
+	if err == nil {
+		return
+	}
+
+	if errors.Is(err, secrets.ErrNoIdentity) {
+		fmt.Println("🔐 This repository has encrypted private files in private.enc/")
+		fmt.Println("   Run 'dotfiles secrets keygen', then 'dotfiles secrets add-recipient <key>' on a")
+		fmt.Println("   machine that can decrypt them, sync, and run 'dotfiles secrets decrypt' here")
+		return
+	}
+	fmt.Printf("⚠️  Could not decrypt private.enc/: %v\n", err)
+}
+
+// secretScanner returns a scanner using the dotfiles allowlist
+func secretScanner() (*secrets.Scanner, error) {
+	allow, err := secrets.LoadAllowlist(appCtx.SecretsAllowlistPath())
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", appCtx.SecretsAllowlistPath(), err)
+	}
+	return &secrets.Scanner{Allowlist: allow}, nil
+}
+
+// scanRepository scans the files git would commit in the dotfiles root, or
+// only the uncommitted ones when changedOnly is set, including anything in
+// state/ that is not ignored. Without a git repository every file except
+// private/ is scanned, and state/ too unless its .gitignore keeps it out.
+func scanRepository(changedOnly bool) ([]secrets.Finding, error) {
+	scanner, err := secretScanner()
+	if err != nil {
+		return nil, err
+	}
+	files, err := repositoryFiles(changedOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []secrets.Finding
+	for _, file := range files {
+		found, err := scanner.ScanFile(appCtx.Root, file)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, found...)
+	}
+	return findings, nil
+}
+
+// repositoryFiles lists the files in the dotfiles root that git would commit
+func repositoryFiles(changedOnly bool) ([]string, error) {
+	root := appCtx.Root
+	if _, err := os.Stat(filepath.Join(root, ".git")); err != nil {
+		skip := []string{".git", "private"}
+		if _, err := os.Stat(filepath.Join(root, "state", ".gitignore")); err == nil {
+			skip = append(skip, "state")
+		}
+		return walkFiles(root, skip)
+	}
+
+	var lists [][]string
+	if changedOnly {
+		lists = append(lists,
+			[]string{"ls-files", "-z", "--modified", "--others", "--exclude-standard"},
+			[]string{"diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR"})
+	} else {
+		lists = append(lists, []string{"ls-files", "-z", "--cached", "--others", "--exclude-standard"})
+	}
+
+	var files []string
+	for _, args := range lists {
+		output, err := exec.Command("git", append([]string{"-C", root}, args...)...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("git %s: %v", args[0], err)
+		}
+		for _, file := range strings.Split(string(output), "\x00") {
+			if file != "" && !contains(files, file) {
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// walkFiles lists the files below root, skipping the named top-level directories
+func walkFiles(root string, skip []string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if d.IsDir() && contains(skip, rel) {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return files, err
+}
+
+// scanPaths scans files and directories given on the command line
+func scanPaths(paths []string) ([]secrets.Finding, error) {
+	scanner, err := secretScanner()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []secrets.Finding
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		files := []string{filepath.Base(path)}
+		root := filepath.Dir(path)
+		if info.IsDir() {
+			root = path
+			if files, err = walkFiles(path, []string{".git"}); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, file := range files {
+			found, err := scanner.ScanFile(root, file)
+			if err != nil {
+				return nil, err
+			}
+			for i := range found {
+				found[i].Path = filepath.ToSlash(filepath.Join(root, file))
+			}
+			findings = append(findings, found...)
+		}
+	}
+	return findings, nil
+}
+
+// printFindings lists findings with how to accept them
+func printFindings(findings []secrets.Finding) {
+	fmt.Printf("🚨 Found %d possible secret(s):\n", len(findings))
+	for _, finding := range findings {
+		fmt.Printf("   • %s\n", finding)
+	}
+	fmt.Println()
+	fmt.Println("💡 Remove them (private/ and 'dotfiles secrets encrypt' keep secrets out of git),")
+	fmt.Printf("   or accept false positives by adding the path or fingerprint to %s\n", appCtx.SecretsAllowlistPath())
+}
+
+// checkSecrets blocks an operation when the scan found secrets
+func checkSecrets(findings []secrets.Finding, err error) error {
+	if err != nil {
+		return fmt.Errorf("secret scan failed: %v", err)
+	}
+	if len(findings) > 0 {
+		printFindings(findings)
+		return fmt.Errorf("%d possible secret(s) found", len(findings))
+	}
+	return nil
+}
+
+// scanShareable checks a configuration for secrets before it is uploaded,
+// reporting findings against name
+func scanShareable(name string, shareable ShareableConfig) error {
+	scanner, err := secretScanner()
+	if err != nil {
+		return checkSecrets(nil, err)
+	}
+	data, err := json.MarshalIndent(shareable, "", "  ")
+	if err != nil {
+		return checkSecrets(nil, err)
+	}
+	return checkSecrets(scanner.Scan(name, data), nil)
+}
+
+func init() {
+	secretsScanCmd.Flags().Bool("changed", false, "Only scan uncommitted changes")
+	secretsAddRecipientCmd.Flags().String("comment", "", "Comment written above the key, e.g. the machine's name")
+	secretsAddRecipientCmd.Flags().Bool("no-rekey", false, "Don't re-encrypt existing files to the new recipients")
+	secretsDecryptCmd.Flags().BoolP("force", "f", false, "Overwrite private files that differ from private.enc/")
+
+	secretsCmd.AddCommand(secretsKeygenCmd)
+	secretsCmd.AddCommand(secretsAddRecipientCmd)
+	secretsCmd.AddCommand(secretsRekeyCmd)
+	secretsCmd.AddCommand(secretsEncryptCmd)
+	secretsCmd.AddCommand(secretsDecryptCmd)
+	secretsCmd.AddCommand(secretsStatusCmd)
+	secretsCmd.AddCommand(secretsScanCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
@@ -12,18 +12,12 @@ import (
 var setupCmd = &cobra.Command{
 	Use:   "setup [repo-url]",
 	Short: "Set up dotfiles repository and directory structure",
-	Long:  `Fork and clone a dotfiles repository to ~/.dotfiles/, create private directory structure, and set up stow packages.`,
+	Long:  `Fork and clone a dotfiles repository to ~/.dotfiles/, decrypt private.enc/ when this machine has a key, create private directory structure, and set up stow packages.`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		repoURL := args[0]
 
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			return
-		}
-
-		dotfilesDir := filepath.Join(homeDir, ".dotfiles")
+		dotfilesDir := appCtx.Root
 
 		// Check if directory already exists
 		if _, err := os.Stat(dotfilesDir); !os.IsNotExist(err) {
@@ -49,6 +43,9 @@ var setupCmd = &cobra.Command{
 			return
 		}
 
+		// Decrypt committed private files before placeholders are created
+		setupSecrets()
+
 		// Create stow directory
 		stowDir := filepath.Join(dotfilesDir, "stow")
 		if err := os.MkdirAll(stowDir, 0755); err != nil {
@@ -91,4 +88,4 @@ var setupCmd = &cobra.Command{
 func init() {
 	setupCmd.Flags().BoolP("force", "f", false, "Force overwrite existing directory")
 	rootCmd.AddCommand(setupCmd)
-}
\ No newline at end of file
+}
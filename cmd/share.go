@@ -21,6 +21,22 @@ type ShareableConfig struct {
 	Metadata ShareMetadata `json:"metadata"`
 }
 
+// UnmarshalJSON decodes the metadata alongside the configuration, whose own
+// UnmarshalJSON (which migrates older files) would otherwise drop it
+func (s *ShareableConfig) UnmarshalJSON(data []byte) error {
+	var meta struct {
+		Metadata ShareMetadata `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+	if err := s.Config.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	s.Metadata = meta.Metadata
+	return nil
+}
+
 type ShareMetadata struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
@@ -31,11 +47,11 @@ type ShareMetadata struct {
 }
 
 type GistResponse struct {
-	ID          string            `json:"id"`
-	HTMLURL     string            `json:"html_url"`
+	ID          string              `json:"id"`
+	HTMLURL     string              `json:"html_url"`
 	Files       map[string]GistFile `json:"files"`
-	Description string            `json:"description"`
-	Public      bool              `json:"public"`
+	Description string              `json:"description"`
+	Public      bool                `json:"public"`
 }
 
 type GistFile struct {
@@ -43,8 +59,8 @@ type GistFile struct {
 }
 
 type GistRequest struct {
-	Description string            `json:"description"`
-	Public      bool              `json:"public"`
+	Description string              `json:"description"`
+	Public      bool                `json:"public"`
 	Files       map[string]GistFile `json:"files"`
 }
 
@@ -72,13 +88,7 @@ var shareGistCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -98,6 +108,12 @@ var shareGistCmd = &cobra.Command{
 			},
 		}
 
+		// Hook commands and variables can hold tokens
+		if err := scanShareable("config.json", shareableConfig); err != nil {
+			fmt.Printf("❌ Not sharing: %v\n", err)
+			os.Exit(1)
+		}
+
 		fmt.Printf("📤 Sharing config '%s'...\n", name)
 
 		// Try uploading to web app first
@@ -126,8 +142,7 @@ var shareGistCmd = &cobra.Command{
 			fmt.Printf("📤 Pushing to template API...\n")
 
 			// Create a temporary template file for the API push
-			home, _ := os.UserHomeDir()
-			templatesDir := filepath.Join(home, ".dotfiles", "templates")
+			templatesDir := appCtx.TemplatesDir()
 			os.MkdirAll(templatesDir, 0755)
 
 			tempTemplateFile := filepath.Join(templatesDir, "temp_"+name+".json")
@@ -195,13 +210,7 @@ var shareFileCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			fmt.Printf("❌ Error loading configuration: %v\n", err)
@@ -263,15 +272,20 @@ Examples:
   dotfiles clone <source> --preview                 # Preview before applying
   dotfiles clone <source> --merge                   # Merge with existing config
 
+Hooks in the shared configuration are listed before importing. Unless the
+source is in "trusted_sources" or you trust them when asked (or pass --trust),
+they are quarantined until approved with 'dotfiles hooks trust'.
+
 Popular templates:
 • template:web-dev - Web development with Node.js, Python, Docker
 • template:minimal - Essential tools only
 • template:data-science - Python, R, Jupyter, analytics tools`,
-	Args:  cobra.ExactArgs(1),
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		source := args[0]
 		merge, _ := cmd.Flags().GetBool("merge")
 		preview, _ := cmd.Flags().GetBool("preview")
+		trust, _ := cmd.Flags().GetBool("trust")
 
 		// Handle template:name format
 		if strings.HasPrefix(source, "template:") {
@@ -335,6 +349,23 @@ Popular templates:
 		}
 		fmt.Println()
 
+		// Hooks are compared with what they would be added to. The remote
+		// config's own trusted_sources are never used.
+		configPath := appCtx.ConfigPath()
+		currentConfig, err := config.Load(configPath)
+		if err != nil {
+			currentConfig = &config.Config{}
+		}
+		base := &config.Config{TrustedSources: currentConfig.TrustedSources}
+		if merge {
+			base = currentConfig
+		}
+		hookSource := source
+		if !strings.HasPrefix(source, "http") {
+			hookSource = absPath(source)
+		}
+		hasHooks := printIncomingHooks(base, &shareableConfig.Config, hookSource, trust)
+
 		if preview {
 			fmt.Println("📋 Full package list:")
 			if len(shareableConfig.Taps) > 0 {
@@ -357,14 +388,10 @@ Popular templates:
 			return
 		}
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
+		if hasHooks && !trust && !base.IsTrustedSource(hookSource) {
+			trust = askConfirmation("Trust these hooks so they run on the next operation? (y/N): ", false)
 		}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
-
 		if merge {
 			// Load existing config and merge
 			existingConfig, err := config.Load(configPath)
@@ -378,6 +405,7 @@ Popular templates:
 			existingConfig.Brews = mergeSlices(existingConfig.Brews, shareableConfig.Brews)
 			existingConfig.Casks = mergeSlices(existingConfig.Casks, shareableConfig.Casks)
 			existingConfig.Stow = mergeSlices(existingConfig.Stow, shareableConfig.Stow)
+			importHooks(existingConfig, &shareableConfig.Config, hookSource, trust)
 
 			if err := existingConfig.Save(configPath); err != nil {
 				fmt.Printf("❌ Error saving merged config: %v\n", err)
@@ -387,11 +415,13 @@ Popular templates:
 		} else {
 			// Replace existing config
 			newConfig := &config.Config{
-				Taps:  shareableConfig.Taps,
-				Brews: shareableConfig.Brews,
-				Casks: shareableConfig.Casks,
-				Stow:  shareableConfig.Stow,
+				Taps:           shareableConfig.Taps,
+				Brews:          shareableConfig.Brews,
+				Casks:          shareableConfig.Casks,
+				Stow:           shareableConfig.Stow,
+				TrustedSources: currentConfig.TrustedSources,
 			}
+			importHooks(newConfig, &shareableConfig.Config, hookSource, trust)
 
 			if err := newConfig.Save(configPath); err != nil {
 				fmt.Printf("❌ Error saving config: %v\n", err)
@@ -655,6 +685,7 @@ func init() {
 	// Clone flags
 	cloneCmd.Flags().Bool("merge", false, "Merge with existing config instead of replacing")
 	cloneCmd.Flags().Bool("preview", false, "Preview config without importing")
+	cloneCmd.Flags().Bool("trust", false, "Trust the configuration's hooks instead of quarantining them")
 
 	shareCmd.AddCommand(shareGistCmd)
 	shareCmd.AddCommand(shareFileCmd)
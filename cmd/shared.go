@@ -3,11 +3,10 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
 
 	"dotfiles/internal/config"
+	"dotfiles/internal/linker"
 )
 
 // createPrivateDirectoryStructure creates the private directory with all necessary env files
@@ -66,7 +65,7 @@ export DOTFILES_PRIVATE="true"
 
 	// Update .gitignore to exclude private directory
 	gitignoreFile := filepath.Join(dotfilesDir, ".gitignore")
-	gitignoreContent := "\n# Private files\nprivate/\n"
+	gitignoreContent := "\n# Private files\nprivate/\n\n# Machine-local state\nstate/\n"
 
 	f, err := os.OpenFile(gitignoreFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err == nil {
@@ -114,6 +113,9 @@ func createShellStowPackages(stowDir string) error {
 		return fmt.Errorf("failed to create shell stow directory: %v", err)
 	}
 
+	// The stow directory lives directly under the dotfiles root
+	dotfilesDir := filepath.Dir(stowDir)
+
 	// Create public shell files directly in stow package
 	publicShellFiles := map[string]string{
 		".zshrc": `# Base zsh configuration
@@ -141,7 +143,7 @@ SAVEHIST=10000
 # Common environment variables for all shells
 export EDITOR="vim"
 export BROWSER="open"
-export DOTFILES_DIR="$HOME/.dotfiles"
+export DOTFILES_DIR="` + dotfilesDir + `"
 
 # Path additions
 export PATH="$HOME/.local/bin:$PATH"
@@ -227,7 +229,7 @@ alias reload="source ~/.zshrc"
 	// Create a base .gitconfig that includes the private config
 	gitConfigContent := `# Main git configuration
 [include]
-	path = ~/.dotfiles/private/.gitconfig.local
+	path = ` + filepath.Join(dotfilesDir, "private", ".gitconfig.local") + `
 
 [core]
 	editor = vim
@@ -274,15 +276,65 @@ func addStowPackagesToConfig(configPath string) error {
 	return nil
 }
 
-// stowPackages stows the given packages using GNU stow
+// stowPackages stows the given packages with the built-in linker
 func stowPackages(packages []string, stowDir, target string) error {
+	opts := packageStowOptions(stowDir, target)
+	lnk, err := linker.New(opts)
+	if err != nil {
+		return err
+	}
+
+	manifest := loadLinkManifest()
+	defer saveLinkManifest(manifest)
+
 	for _, pkg := range packages {
-		stowArgs := []string{"-d", stowDir, "-t", target, pkg}
-		stowCmd := exec.Command("stow", stowArgs...)
+		ops, err := lnk.Stow(pkg)
+		if err != nil {
+			return fmt.Errorf("error stowing %s: %v", pkg, err)
+		}
+		if manifest != nil {
+			manifest.Apply(pkg, opts, ops)
+		}
+	}
+	return nil
+}
+
+// packageStowOptions returns the linker options stowPackages links with
+func packageStowOptions(stowDir, target string) linker.Options {
+	return withTemplates(linker.Options{Dir: stowDir, Target: target})
+}
 
-		output, err := stowCmd.CombinedOutput()
+// unstowPackages removes the links of dotfile packages, with the options
+// they were stowed with, including recorded links the package no longer has
+func unstowPackages(packages []string, stowDir, target string) error {
+	manifest := loadLinkManifest()
+	defer saveLinkManifest(manifest)
+
+	for _, pkg := range packages {
+		opts := withTemplates(linker.Options{Dir: stowDir, Target: target})
+		if manifest != nil {
+			if record, ok := manifest.Packages[pkg]; ok {
+				opts.Dotfiles = record.Dotfiles
+			}
+		}
+		lnk, err := linker.New(opts)
 		if err != nil {
-			return fmt.Errorf("error stowing %s: %v (output: %s)", pkg, err, strings.TrimSpace(string(output)))
+			return err
+		}
+
+		var ops []linker.Operation
+		recorded := manifest != nil && len(manifest.Links(pkg)) > 0
+		if _, err := os.Stat(filepath.Join(stowDir, pkg)); err == nil || !recorded {
+			if ops, err = lnk.Unstow(pkg); err != nil {
+				return fmt.Errorf("error unstowing %s: %v", pkg, err)
+			}
+		}
+		if recorded {
+			manifest.Apply(pkg, opts, ops)
+			if _, err := lnk.Prune(manifest.Links(pkg)); err != nil {
+				return fmt.Errorf("error removing recorded links for %s: %v", pkg, err)
+			}
+			manifest.Forget(pkg)
 		}
 	}
 	return nil
@@ -291,7 +343,7 @@ func stowPackages(packages []string, stowDir, target string) error {
 // setupCompleteEnvironment sets up the complete dotfiles environment (private dir + shell packages + config)
 func setupCompleteEnvironment(dotfilesDir string, shouldStow bool) error {
 	stowDir := filepath.Join(dotfilesDir, "stow")
-	configPath := filepath.Join(dotfilesDir, "config.json")
+	configPath := config.FindFile(dotfilesDir, "config")
 
 	// Create stow directory if it doesn't exist
 	if err := os.MkdirAll(stowDir, 0755); err != nil {
@@ -320,15 +372,10 @@ func setupCompleteEnvironment(dotfilesDir string, shouldStow bool) error {
 
 	// Optionally stow the packages
 	if shouldStow {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %v", err)
-		}
-
-		if err := stowPackages([]string{"shell", "git"}, stowDir, home); err != nil {
+		if err := stowPackages([]string{"shell", "git"}, stowDir, appCtx.Home); err != nil {
 			return fmt.Errorf("failed to stow packages: %v", err)
 		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
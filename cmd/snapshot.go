@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,15 +9,15 @@ import (
 	"time"
 
 	"dotfiles/internal/config"
+	"dotfiles/internal/hooks"
+	"dotfiles/internal/installer"
+	"dotfiles/internal/linker"
+	"dotfiles/internal/pkgmanager"
+	"dotfiles/internal/snapshot"
 	"github.com/spf13/cobra"
 )
 
-type Snapshot struct {
-	Timestamp   string         `json:"timestamp"`
-	Description string         `json:"description"`
-	Config      *config.Config `json:"config"`
-	Metadata    map[string]string `json:"metadata"`
-}
+type Snapshot = snapshot.Snapshot
 
 var snapshotCmd = &cobra.Command{
 	Use:   "snapshot",
@@ -31,68 +30,50 @@ Snapshots are stored in ~/.dotfiles/snapshots/ with timestamps.
 Examples:
   dotfiles snapshot create                       # Create snapshot with auto description
   dotfiles snapshot create -m "Before update"    # Create with custom message
+  dotfiles snapshot create --files               # Also capture stow packages and private/
   dotfiles snapshot list                         # List all snapshots
   dotfiles snapshot restore <timestamp>          # Restore from snapshot
+  dotfiles snapshot diff <timestamp>             # Compare with the current configuration
+  dotfiles snapshot tag <timestamp> known-good   # Name a snapshot and keep it from pruning
+  dotfiles snapshot prune --keep-last 10         # Remove old snapshots
   dotfiles snapshot delete <timestamp>           # Delete a snapshot
-  dotfiles snapshot auto                         # Create before major operations`,
+  dotfiles snapshot auto                         # Create before major operations
+
+Commands taking a snapshot accept its timestamp or one of its tags.`,
 }
 
 var snapshotCreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a new snapshot",
 	Long: `Create a timestamped snapshot of your current configuration.
-Useful before making major changes to enable easy rollback.`,
+Useful before making major changes to enable easy rollback.
+
+With --files the snapshot also captures every file of your stow packages
+and private/. Contents are stored once in state/objects/ (which is not
+committed), so files that didn't change between snapshots take no extra
+space.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		message, _ := cmd.Flags().GetString("message")
+		withFiles, _ := cmd.Flags().GetBool("files")
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
-		cfg, err := config.Load(configPath)
-		if err != nil {
-			fmt.Printf("❌ Error loading configuration: %v\n", err)
-			os.Exit(1)
-		}
-
-		// Create snapshots directory
-		snapshotsDir := filepath.Join(home, ".dotfiles", "snapshots")
-		if err := os.MkdirAll(snapshotsDir, 0755); err != nil {
-			fmt.Printf("❌ Error creating snapshots directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		// Create snapshot
-		timestamp := time.Now().Format("20060102-150405")
 		if message == "" {
 			message = "Manual snapshot"
 		}
-
-		snapshot := Snapshot{
-			Timestamp:   timestamp,
-			Description: message,
-			Config:      cfg,
-			Metadata: map[string]string{
-				"created_by": "dotfiles snapshot",
-				"platform":   "darwin",
-			},
-		}
-
-		// Save snapshot
-		snapshotPath := filepath.Join(snapshotsDir, timestamp+".json")
-		data, err := json.MarshalIndent(snapshot, "", "  ")
-		if err != nil {
-			fmt.Printf("❌ Error marshaling snapshot: %v\n", err)
+		if err := appCtx.EnsureStateDir(); err != nil {
+			fmt.Printf("❌ %v\n", err)
 			os.Exit(1)
 		}
 
-		if err := os.WriteFile(snapshotPath, data, 0644); err != nil {
-			fmt.Printf("❌ Error saving snapshot: %v\n", err)
+		snap, err := snapshot.Create(appCtx.Root, message, snapshot.Options{
+			Files:    withFiles,
+			Home:     appCtx.Home,
+			Metadata: map[string]string{"created_by": "dotfiles snapshot"},
+		})
+		if err != nil {
+			fmt.Printf("❌ Error creating snapshot: %v\n", err)
 			os.Exit(1)
 		}
+		timestamp, cfg := snap.Timestamp, snap.Config
 
 		fmt.Println("📸 Snapshot created successfully!")
 		fmt.Println()
@@ -102,9 +83,16 @@ Useful before making major changes to enable easy rollback.`,
 		fmt.Println()
 		fmt.Printf("   Packages: %d brews, %d casks, %d taps, %d stow\n",
 			len(cfg.Brews), len(cfg.Casks), len(cfg.Taps), len(cfg.Stow))
+		if withFiles {
+			fmt.Printf("   Files: %s\n", describeSnapshotFiles(snap.Files))
+		}
 		fmt.Println()
 		fmt.Println("💡 To restore this snapshot:")
-		fmt.Printf("   dotfiles snapshot restore %s\n", timestamp)
+		if withFiles {
+			fmt.Printf("   dotfiles snapshot restore %s --files\n", timestamp)
+		} else {
+			fmt.Printf("   dotfiles snapshot restore %s\n", timestamp)
+		}
 	},
 }
 
@@ -112,164 +100,324 @@ var snapshotListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all snapshots",
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		snapshotsDir := filepath.Join(home, ".dotfiles", "snapshots")
-		if _, err := os.Stat(snapshotsDir); os.IsNotExist(err) {
-			fmt.Println("📸 No snapshots found")
-			fmt.Println()
-			fmt.Println("💡 Create your first snapshot:")
-			fmt.Println("   dotfiles snapshot create -m 'Initial snapshot'")
-			return
-		}
-
-		entries, err := os.ReadDir(snapshotsDir)
+		snapshots, err := snapshot.ListSnapshots(appCtx.Root)
 		if err != nil {
-			fmt.Printf("❌ Error reading snapshots: %v\n", err)
-			os.Exit(1)
+			fail("Error reading snapshots", err)
 		}
 
-		snapshots := []Snapshot{}
-		for _, entry := range entries {
-			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
-				snapshotPath := filepath.Join(snapshotsDir, entry.Name())
-				data, err := os.ReadFile(snapshotPath)
-				if err != nil {
-					continue
-				}
+		// Sort by timestamp (newest first)
+		sort.Slice(snapshots, func(i, j int) bool {
+			return snapshots[i].Timestamp > snapshots[j].Timestamp
+		})
 
-				var snapshot Snapshot
-				if err := json.Unmarshal(data, &snapshot); err != nil {
-					continue
+		result := SnapshotList{Snapshots: []SnapshotInfo{}}
+		for _, snap := range snapshots {
+			info := SnapshotInfo{
+				Timestamp:   snap.Timestamp,
+				Description: snap.Description,
+				Tags:        snap.Tags,
+				Files:       len(snap.Files),
+			}
+			if t, err := time.ParseInLocation("20060102-150405", snap.Timestamp, time.Local); err == nil {
+				info.Created = &t
+			}
+			if snap.Config != nil {
+				info.Packages = PackageCounts{
+					Brews: len(snap.Config.Brews),
+					Casks: len(snap.Config.Casks),
+					Taps:  len(snap.Config.Taps),
+					Stow:  len(snap.Config.Stow),
 				}
-
-				snapshots = append(snapshots, snapshot)
 			}
+			for _, file := range snap.Files {
+				info.FileBytes += file.Size
+			}
+			result.Snapshots = append(result.Snapshots, info)
 		}
 
-		if len(snapshots) == 0 {
-			fmt.Println("📸 No snapshots found")
-			return
-		}
+		printReport(result, ExitOK, func() { printSnapshotList(result) })
+	},
+}
 
-		// Sort by timestamp (newest first)
-		sort.Slice(snapshots, func(i, j int) bool {
-			return snapshots[i].Timestamp > snapshots[j].Timestamp
-		})
+// SnapshotList is the result of 'dotfiles snapshot list'
+type SnapshotList struct {
+	Snapshots []SnapshotInfo `json:"snapshots"` // Newest first
+}
 
-		fmt.Printf("📸 Found %d snapshot(s):\n", len(snapshots))
-		fmt.Println("=" + strings.Repeat("=", 25))
-		fmt.Println()
+// SnapshotInfo summarizes a snapshot
+type SnapshotInfo struct {
+	Timestamp   string        `json:"timestamp"`
+	Description string        `json:"description,omitempty"`
+	Created     *time.Time    `json:"created,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	Packages    PackageCounts `json:"packages"`
+	Files       int           `json:"files"`      // Captured dotfiles
+	FileBytes   int64         `json:"file_bytes"` // Total size of the captured dotfiles
+}
 
-		for i, snapshot := range snapshots {
-			// Parse timestamp for display
-			t, err := time.Parse("20060102-150405", snapshot.Timestamp)
-			displayTime := snapshot.Timestamp
-			if err == nil {
-				displayTime = t.Format("Jan 02, 2006 at 3:04 PM")
-			}
+func printSnapshotList(result SnapshotList) {
+	if len(result.Snapshots) == 0 {
+		fmt.Println("📸 No snapshots found")
+		fmt.Println()
+		fmt.Println("💡 Create your first snapshot:")
+		fmt.Println("   dotfiles snapshot create -m 'Initial snapshot'")
+		return
+	}
+
+	fmt.Printf("📸 Found %d snapshot(s):\n", len(result.Snapshots))
+	fmt.Println("=" + strings.Repeat("=", 25))
+	fmt.Println()
+
+	for i, snap := range result.Snapshots {
+		displayTime := snap.Timestamp
+		if snap.Created != nil {
+			displayTime = snap.Created.Format("Jan 02, 2006 at 3:04 PM")
+		}
 
-			fmt.Printf("%d. %s\n", i+1, snapshot.Timestamp)
-			fmt.Printf("   Created: %s\n", displayTime)
-			if snapshot.Description != "" {
-				fmt.Printf("   Message: %s\n", snapshot.Description)
-			}
-			fmt.Printf("   Packages: %d brews, %d casks, %d taps, %d stow\n",
-				len(snapshot.Config.Brews),
-				len(snapshot.Config.Casks),
-				len(snapshot.Config.Taps),
-				len(snapshot.Config.Stow))
-			fmt.Println()
+		fmt.Printf("%d. %s\n", i+1, snap.Timestamp)
+		fmt.Printf("   Created: %s\n", displayTime)
+		if snap.Description != "" {
+			fmt.Printf("   Message: %s\n", snap.Description)
 		}
+		if len(snap.Tags) > 0 {
+			fmt.Printf("   Tags: %s\n", strings.Join(snap.Tags, ", "))
+		}
+		fmt.Printf("   Packages: %d brews, %d casks, %d taps, %d stow\n",
+			snap.Packages.Brews, snap.Packages.Casks, snap.Packages.Taps, snap.Packages.Stow)
+		if snap.Files > 0 {
+			fmt.Printf("   Files: %d (%.1f KB)\n", snap.Files, float64(snap.FileBytes)/1024)
+		}
+		fmt.Println()
+	}
 
-		fmt.Println("💡 To restore a snapshot:")
-		fmt.Println("   dotfiles snapshot restore <timestamp>")
-	},
+	fmt.Println("💡 To restore a snapshot:")
+	fmt.Println("   dotfiles snapshot restore <timestamp>")
 }
 
 var snapshotRestoreCmd = &cobra.Command{
-	Use:   "restore <timestamp>",
+	Use:   "restore <timestamp|tag>",
 	Short: "Restore configuration from a snapshot",
-	Args:  cobra.ExactArgs(1),
+	Long: `Restore the configuration saved in a snapshot.
+
+Snapshots created with --files, and those taken before stow removed
+conflicting files, also hold dotfiles. --files puts those back too, and
+--file restores just the given files (without the configuration). A stow
+link where a captured file was is replaced by the file.
+
+Restoring the configuration leaves installed packages and links alone.
+--reconcile rolls the machine back too: packages and stow packages only the
+current configuration has are uninstalled and unlinked, and those only the
+snapshot has are installed and linked, including those listed per package
+manager under "packages". Both sides include the overlays for this machine.
+Only packages 'dotfiles install' installed are uninstalled; the plan lists
+the others as left installed unless --remove-untracked is given. Changed
+package_versions are left for 'dotfiles install'; the plan notes them. The
+plan is shown before anything is changed.
+
+Examples:
+  dotfiles snapshot restore 20240101-120000
+  dotfiles snapshot restore 20240101-120000 --files
+  dotfiles snapshot restore 20240101-120000 --file ~/.zshrc
+  dotfiles snapshot restore 20240101-120000 --reconcile
+  dotfiles snapshot restore known-good`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		timestamp := args[0]
 		noBackup, _ := cmd.Flags().GetBool("no-backup")
-
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
+		withFiles, _ := cmd.Flags().GetBool("files")
+		only, _ := cmd.Flags().GetStringSlice("file")
+		reconcile, _ := cmd.Flags().GetBool("reconcile")
+		removeUntracked, _ := cmd.Flags().GetBool("remove-untracked")
+		yes, _ := cmd.Flags().GetBool("yes")
+		restoreConfig := len(only) == 0
+		restoreFiles := withFiles || len(only) > 0
+
+		if reconcile && !restoreConfig {
+			fmt.Println("❌ --reconcile restores the configuration and can't be used with --file")
 			os.Exit(1)
 		}
-
-		// Load snapshot
-		snapshotPath := filepath.Join(home, ".dotfiles", "snapshots", timestamp+".json")
-		data, err := os.ReadFile(snapshotPath)
-		if err != nil {
-			fmt.Printf("❌ Snapshot not found: %s\n", timestamp)
-			fmt.Println()
-			fmt.Println("💡 List available snapshots:")
-			fmt.Println("   dotfiles snapshot list")
+		if removeUntracked && !reconcile {
+			fmt.Println("❌ --remove-untracked only applies with --reconcile")
 			os.Exit(1)
 		}
 
-		var snapshot Snapshot
-		if err := json.Unmarshal(data, &snapshot); err != nil {
-			fmt.Printf("❌ Error reading snapshot: %v\n", err)
-			os.Exit(1)
+		snap := loadSnapshot(args[0])
+		timestamp := snap.Timestamp
+
+		var files []snapshot.File
+		if restoreFiles {
+			files = selectSnapshotFiles(snap.Files, only)
+			if len(files) == 0 {
+				if len(only) > 0 {
+					fmt.Printf("❌ Snapshot %s holds none of: %s\n", timestamp, strings.Join(only, ", "))
+				} else {
+					fmt.Printf("❌ Snapshot %s holds no files (create one with 'dotfiles snapshot create --files')\n", timestamp)
+				}
+				os.Exit(1)
+			}
 		}
 
 		fmt.Println("📸 Restoring snapshot...")
 		fmt.Println()
-		fmt.Printf("   Timestamp: %s\n", snapshot.Timestamp)
-		if snapshot.Description != "" {
-			fmt.Printf("   Description: %s\n", snapshot.Description)
+		fmt.Printf("   Timestamp: %s\n", snap.Timestamp)
+		if snap.Description != "" {
+			fmt.Printf("   Description: %s\n", snap.Description)
 		}
 		fmt.Println()
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
+
+		// Hooks come from the configuration being replaced, every layer of it
+		currentCfg := loadHookConfig()
+
+		var plan ApplyPlan
+		var pm pkgmanager.PackageManager
+		var err error
+		if reconcile {
+			pm, err = pkgmanager.GetPackageManager()
+			if err != nil {
+				fmt.Printf("❌ Error: %v\n", err)
+				os.Exit(1)
+			}
+			if !pm.IsAvailable() {
+				fmt.Printf("⚠️  %s not found. Please install it first.\n", pm.GetName())
+				os.Exit(1)
+			}
+
+			// Restoring replaces config.json, the overlays stay
+			stack, err := loadConfigStack()
+			if err != nil {
+				fmt.Printf("❌ Error loading configuration: %v\n", err)
+				os.Exit(1)
+			}
+			tracked := func(manager, pkgType, pkg string) bool { return true }
+			if !removeUntracked {
+				journal, err := installer.LoadJournal(appCtx.InstallJournalPath())
+				if err != nil {
+					fmt.Printf("⚠️  Could not read the install journal, no package will be uninstalled: %v\n", err)
+					journal = installer.NewJournal(appCtx.InstallJournalPath())
+				}
+				tracked = journal.Installed
+			}
+
+			plan = buildReconcilePlan(stack.Config, stack.Rebase(snap.Config), pm, tracked, func(pkg string) bool {
+				if _, err := os.Stat(filepath.Join(appCtx.StowDir(), pkg)); err == nil {
+					return true
+				}
+				// The package may come back with the snapshot's files
+				return restoreFiles && holdsStowPackage(files, pkg)
+			})
+			printReconcilePlan(plan)
+			fmt.Println()
+
+			if !plan.Converged && !yes {
+				if !askConfirmation("Restore and apply this plan? (y/N): ", false) {
+					fmt.Println("❌ Restore cancelled.")
+					return
+				}
+				fmt.Println()
+			}
+		}
+
+		payload := hooks.Payload{Event: hooks.SnapshotRestore, Phase: hooks.Pre, Snapshot: timestamp}
+		if err := fireHooks(currentCfg, payload); err != nil {
+			fmt.Printf("❌ Pre-snapshot-restore hook failed: %v\n", err)
+			os.Exit(1)
+		}
 
 		// Create backup of current config before restoring (unless --no-backup)
 		if !noBackup {
 			fmt.Println("💾 Creating backup of current configuration...")
-			currentCfg, err := config.Load(configPath)
-			if err == nil {
-				backupSnapshot := Snapshot{
-					Timestamp:   time.Now().Format("20060102-150405"),
-					Description: "Auto-backup before restore",
-					Config:      currentCfg,
-					Metadata: map[string]string{
-						"created_by":   "auto-backup",
-						"restore_from": timestamp,
-					},
-				}
-
-				snapshotsDir := filepath.Join(home, ".dotfiles", "snapshots")
-				backupPath := filepath.Join(snapshotsDir, backupSnapshot.Timestamp+".json")
-				backupData, _ := json.MarshalIndent(backupSnapshot, "", "  ")
-				if err := os.WriteFile(backupPath, backupData, 0644); err == nil {
-					fmt.Printf("   ✅ Backup created: %s\n", backupSnapshot.Timestamp)
+			opts := snapshot.Options{
+				Home: appCtx.Home,
+				Metadata: map[string]string{
+					"created_by":   "auto-backup",
+					"restore_from": timestamp,
+				},
+			}
+			// The backup holds the files about to be overwritten too
+			for _, file := range files {
+				if file.Kind == snapshot.KindTarget {
+					path, _ := file.Location(appCtx.Root, appCtx.Home)
+					opts.Targets = append(opts.Targets, path)
+				} else {
+					opts.Files = true
 				}
 			}
+			err := appCtx.EnsureStateDir()
+			var backup *snapshot.Snapshot
+			if err == nil {
+				backup, err = snapshot.Create(appCtx.Root, "Auto-backup before restore", opts)
+			}
+			if err != nil {
+				fmt.Printf("   ⚠️  Could not create backup: %v\n", err)
+			} else {
+				fmt.Printf("   ✅ Backup created: %s\n", backup.Timestamp)
+			}
 			fmt.Println()
 		}
 
 		// Restore snapshot
-		if err := snapshot.Config.Save(configPath); err != nil {
-			fmt.Printf("❌ Error restoring configuration: %v\n", err)
-			os.Exit(1)
+		if restoreConfig {
+			if err := snap.Config.Save(configPath); err != nil {
+				fmt.Printf("❌ Error restoring configuration: %v\n", err)
+				os.Exit(1)
+			}
 		}
 
-		fmt.Println("✅ Configuration restored successfully!")
-		fmt.Println()
-		fmt.Printf("   Restored: %d brews, %d casks, %d taps, %d stow\n",
-			len(snapshot.Config.Brews),
-			len(snapshot.Config.Casks),
-			len(snapshot.Config.Taps),
-			len(snapshot.Config.Stow))
+		var restored []snapshot.File
+		if restoreFiles {
+			selected := make(map[snapshot.File]bool)
+			for _, file := range files {
+				selected[file] = true
+			}
+			restored, err = snapshot.RestoreFiles(appCtx.Root, appCtx.Home, snap, func(file snapshot.File) bool {
+				return selected[file]
+			})
+			for _, file := range restored {
+				path, _ := file.Location(appCtx.Root, appCtx.Home)
+				fmt.Printf("   📄 Restored %s\n", path)
+			}
+			if err != nil {
+				fmt.Printf("❌ Error restoring files: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		failed := 0
+		if reconcile && !plan.Converged {
+			failed = executeApplyPlan(plan, pm)
+			fmt.Println()
+		}
+
+		payload.Phase = hooks.Post
+		if err := fireHooks(currentCfg, payload); err != nil {
+			fmt.Printf("⚠️  Post-snapshot-restore hook failed: %v\n", err)
+		}
+
+		if restoreConfig {
+			fmt.Println("✅ Configuration restored successfully!")
+			fmt.Println()
+			fmt.Printf("   Restored: %d brews, %d casks, %d taps, %d stow\n",
+				len(snap.Config.Brews),
+				len(snap.Config.Casks),
+				len(snap.Config.Taps),
+				len(snap.Config.Stow))
+		}
+		if restoreFiles {
+			fmt.Printf("✅ Restored %d file(s), %d already matched the snapshot\n", len(restored), len(files)-len(restored))
+		}
+		if failed > 0 {
+			fmt.Printf("⚠️  Reconcile finished with %d failed action(s)\n", failed)
+			os.Exit(1)
+		}
+		if reconcile {
+			if len(plan.Notes) > 0 {
+				fmt.Println("✅ Reconciled packages and links with the snapshot, except as noted in the plan")
+			} else {
+				fmt.Println("✅ Installed packages and links match the snapshot")
+			}
+			return
+		}
 		fmt.Println()
 		fmt.Println("💡 Next steps:")
 		fmt.Println("   • View config: dotfiles list")
@@ -278,30 +426,313 @@ var snapshotRestoreCmd = &cobra.Command{
 	},
 }
 
-var snapshotDeleteCmd = &cobra.Command{
-	Use:   "delete <timestamp>",
-	Short: "Delete a snapshot",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		timestamp := args[0]
+// buildReconcilePlan works out the actions rolling the machine back from
+// the current configuration to a snapshot's: stow packages and packages only
+// the current configuration has are unlinked and uninstalled, then those
+// only the snapshot has are installed and linked. Packages under "packages"
+// are reconciled with their own package manager. Only packages tracked
+// reports as installed by dotfiles are uninstalled, and changed
+// package_versions are not reconciled; the plan notes both. stowable
+// reports whether a stow package will be in stow/ to link.
+func buildReconcilePlan(current, target *config.Config, pm pkgmanager.PackageManager, tracked func(manager, pkgType, pkg string) bool, stowable func(string) bool) ApplyPlan {
+	plan := ApplyPlan{
+		PackageManager: pm.GetName(),
+		Actions:        []PlanAction{},
+	}
+	if current == nil {
+		current = &config.Config{}
+	}
+	if target == nil {
+		target = &config.Config{}
+	}
+	stowDir := appCtx.StowDir()
+
+	// Anything stowed is unlinked, whether the configuration lists it or
+	// only the link manifest remembers it
+	stowed := append([]string{}, current.Stow...)
+	recorded := make(map[string]bool)
+	if manifest, err := linker.LoadManifest(appCtx.LinkManifestPath()); err == nil {
+		for _, pkg := range manifest.PackageNames() {
+			recorded[pkg] = len(manifest.Links(pkg)) > 0
+			stowed = append(stowed, pkg)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, pkg := range stowed {
+		if seen[pkg] || contains(target.Stow, pkg) {
+			continue
+		}
+		seen[pkg] = true
+		if recorded[pkg] || checkIfStowed(pkg, stowDir, appCtx.Home) {
+			plan.Actions = append(plan.Actions, PlanAction{Kind: "stow", Name: pkg, Action: "unlink"})
+		}
+	}
+
+	// Group both configurations by package manager, as install does; the
+	// system one also gets the "packages" entries naming it
+	currentSets, _ := configuredManagers(current)
+	targetSets, _ := configuredManagers(target)
+	from, to := primarySet(currentSets), primarySet(targetSets)
+
+	// Casks and brews go before the taps they come from, which are added first
+	var untracked []string
+	uninstall := func(packages []string, pkgType string, pm pkgmanager.PackageManager) []PlanAction {
+		var actions []PlanAction
+		for _, action := range installedPackages(packages, pkgType, pm) {
+			if tracked(pm.GetName(), pkgType, action.Name) {
+				actions = append(actions, action)
+			} else {
+				untracked = append(untracked, action.Name)
+			}
+		}
+		return actions
+	}
+
+	isHomebrew := pm.GetName() == "homebrew"
+	if isHomebrew {
+		plan.Actions = append(plan.Actions, uninstall(subtract(from.Casks, to.Casks), "cask", pm)...)
+	}
+	plan.Actions = append(plan.Actions, uninstall(subtract(from.Brews, to.Brews), "brew", pm)...)
+	if isHomebrew {
+		plan.Actions = append(plan.Actions, uninstall(subtract(from.Taps, to.Taps), "tap", pm)...)
+		plan.Actions = append(plan.Actions, missingPackages(to.Taps, "tap", pm)...)
+	}
+	plan.Actions = append(plan.Actions, missingPackages(to.Brews, "brew", pm)...)
+	if isHomebrew {
+		plan.Actions = append(plan.Actions, missingPackages(to.Casks, "cask", pm)...)
+	}
+
+	for _, name := range otherManagerNames(currentSets, targetSets) {
+		from, to := findSet(currentSets, name), findSet(targetSets, name)
+		removed := subtract(from.Brews, to.Brews)
+		set := to
+		if set.Manager == nil {
+			set = from
+		}
+		if len(removed) == 0 && len(to.Brews) == 0 {
+			continue
+		}
+		if set.Manager == nil || !set.Available() {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("packages.%s is left untouched: %s is not available", name, name))
+			continue
+		}
+
+		actions := uninstall(removed, "brew", set.Manager)
+		actions = append(actions, missingPackages(to.Brews, "brew", set.Manager)...)
+		for _, action := range actions {
+			action.Manager = name
+			action.pm = set.Manager
+			plan.Actions = append(plan.Actions, action)
+		}
+	}
+
+	if len(untracked) > 0 {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("packages 'dotfiles install' didn't install are left installed: %s (uninstall them too with --remove-untracked)", strings.Join(untracked, ", ")))
+	}
+	if changed := changedVersions(current.PackageVersions, target.PackageVersions); len(changed) > 0 {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("package_versions of %s differ from the snapshot and are left untouched: run 'dotfiles install' after restoring", strings.Join(changed, ", ")))
+	}
+
+	for _, pkg := range target.Stow {
+		if !stowable(pkg) || checkIfStowed(pkg, stowDir, appCtx.Home) {
+			continue
+		}
+		action := PlanAction{Kind: "stow", Name: pkg, Action: "link"}
+		if conflicts := findStowConflicts(packageStowOptions(stowDir, appCtx.Home), pkg); len(conflicts) > 0 {
+			action.Detail = fmt.Sprintf("%d conflict(s)", len(conflicts))
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	plan.Converged = len(plan.Actions) == 0
+	return plan
+}
+
+// primarySet returns the set of the system package manager, empty if there is none
+func primarySet(sets []managerSet) managerSet {
+	for _, set := range sets {
+		if set.Primary {
+			return set
+		}
+	}
+	return managerSet{}
+}
+
+// findSet returns the non-primary set named name, empty if there is none
+func findSet(sets []managerSet, name string) managerSet {
+	for _, set := range sets {
+		if !set.Primary && set.Name == name {
+			return set
+		}
+	}
+	return managerSet{Name: name}
+}
+
+// otherManagerNames returns the names of the non-primary sets of both
+// configurations, in the order configuredManagers returns them
+func otherManagerNames(current, target []managerSet) []string {
+	var names []string
+	for _, set := range append(append([]managerSet{}, target...), current...) {
+		if !set.Primary && !contains(names, set.Name) {
+			names = append(names, set.Name)
+		}
+	}
+	return names
+}
 
-		home, err := os.UserHomeDir()
+// changedVersions returns the sorted packages whose version constraint
+// differs between two package_versions maps
+func changedVersions(current, target map[string]string) []string {
+	var changed []string
+	for pkg, version := range current {
+		if target[pkg] != version {
+			changed = append(changed, pkg)
+		}
+	}
+	for pkg := range target {
+		if _, ok := current[pkg]; !ok {
+			changed = append(changed, pkg)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// subtract returns the items of list that aren't in other
+func subtract(list, other []string) []string {
+	var result []string
+	for _, item := range list {
+		if !contains(other, item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// holdsStowPackage reports whether files include those of a stow package
+func holdsStowPackage(files []snapshot.File, pkg string) bool {
+	for _, file := range files {
+		if file.Kind == snapshot.KindStow && strings.HasPrefix(file.Path, pkg+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func printReconcilePlan(plan ApplyPlan) {
+	fmt.Println("📋 Reconcile Plan")
+	fmt.Println(strings.Repeat("=", 17))
+	fmt.Printf("Package Manager: %s\n", plan.PackageManager)
+	fmt.Println()
+
+	defer printPlanNotes(plan)
+	if plan.Converged && len(plan.Notes) > 0 {
+		fmt.Println("✅ No changes to make, but not everything is reconciled:")
+		return
+	}
+	if plan.Converged {
+		fmt.Println("✅ No changes. Installed packages and links already match the snapshot.")
+		return
+	}
+
+	installs, uninstalls, links, unlinks := 0, 0, 0, 0
+	for _, action := range plan.Actions {
+		switch action.Action {
+		case "uninstall":
+			uninstalls++
+			fmt.Println(describePackageAction("-", action))
+		case "unlink":
+			unlinks++
+			fmt.Printf("  - %-6s %s\n", action.Kind, action.Name)
+		case "link":
+			links++
+			line := fmt.Sprintf("  ~ %-6s %s", action.Kind, action.Name)
+			if action.Detail != "" {
+				line += fmt.Sprintf(" (%s)", action.Detail)
+			}
+			fmt.Println(line)
+		default:
+			installs++
+			fmt.Println(describePackageAction("+", action))
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Plan: %d to install, %d to uninstall, %d to link, %d to unlink.\n", installs, uninstalls, links, unlinks)
+}
+
+// printPlanNotes lists what a plan leaves untouched
+func printPlanNotes(plan ApplyPlan) {
+	if len(plan.Notes) == 0 {
+		return
+	}
+	fmt.Println()
+	for _, note := range plan.Notes {
+		fmt.Printf("⚠️  %s\n", note)
+	}
+}
+
+// loadSnapshot reads a snapshot by timestamp or tag, exiting when it can't
+func loadSnapshot(ref string) *Snapshot {
+	snap, err := snapshot.Load(appCtx.Root, ref)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println()
+		fmt.Println("💡 List available snapshots:")
+		fmt.Println("   dotfiles snapshot list")
+		os.Exit(1)
+	}
+	return snap
+}
+
+// selectSnapshotFiles returns the files of a snapshot at or under one of
+// paths (e.g. ~/.zshrc or ~/.config/nvim), or all of them when paths is empty
+func selectSnapshotFiles(files []snapshot.File, paths []string) []snapshot.File {
+	if len(paths) == 0 {
+		return files
+	}
+	var selected []snapshot.File
+	for _, file := range files {
+		location, err := file.Location(appCtx.Root, appCtx.Home)
 		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
+			continue
+		}
+		for _, path := range paths {
+			path = absPath(expandHome(path, appCtx.Home))
+			if location == path || strings.HasPrefix(location, path+string(filepath.Separator)) {
+				selected = append(selected, file)
+				break
+			}
 		}
+	}
+	return selected
+}
 
-		snapshotPath := filepath.Join(home, ".dotfiles", "snapshots", timestamp+".json")
-		if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
-			fmt.Printf("❌ Snapshot not found: %s\n", timestamp)
+// describeSnapshotFiles summarizes captured files, e.g. "12 (34.5 KB)"
+func describeSnapshotFiles(files []snapshot.File) string {
+	var size int64
+	for _, file := range files {
+		size += file.Size
+	}
+	return fmt.Sprintf("%d (%.1f KB)", len(files), float64(size)/1024)
+}
+
+var snapshotDeleteCmd = &cobra.Command{
+	Use:   "delete <timestamp|tag>",
+	Short: "Delete a snapshot",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		snapshot := loadSnapshot(args[0])
+		timestamp := snapshot.Timestamp
+		snapshotPath := filepath.Join(appCtx.SnapshotsDir(), timestamp+".json")
+
+		if len(snapshot.Tags) > 0 && !force {
+			fmt.Printf("❌ Snapshot %s is tagged %s\n", timestamp, strings.Join(snapshot.Tags, ", "))
+			fmt.Println("   Use --force to delete it anyway")
 			os.Exit(1)
 		}
 
-		// Read snapshot to show info
-		data, _ := os.ReadFile(snapshotPath)
-		var snapshot Snapshot
-		json.Unmarshal(data, &snapshot)
-
 		fmt.Printf("🗑️  Deleting snapshot: %s\n", timestamp)
 		if snapshot.Description != "" {
 			fmt.Printf("   Description: %s\n", snapshot.Description)
@@ -313,54 +744,257 @@ var snapshotDeleteCmd = &cobra.Command{
 		}
 
 		fmt.Println("✅ Snapshot deleted")
+		if len(snapshot.Files) > 0 {
+			pruneSnapshotObjects()
+		}
 	},
 }
 
-var snapshotAutoCmd = &cobra.Command{
-	Use:   "auto",
-	Short: "Create automatic snapshot before operations",
-	Long: `Create an automatic snapshot with timestamp.
-This is meant to be called before major operations like update, install, etc.`,
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff <snapshot> [snapshot]",
+	Short: "Compare a snapshot with another or the current configuration",
+	Long: `Show what changed between two snapshots, or between a snapshot and the
+current configuration: packages, packages per package manager, hooks,
+groups and package tags. Snapshots are given by timestamp or tag.
+
+Both sides of a comparison with the current configuration include the
+overlays for this machine. Exits with code 2 when there are differences.
+
+Examples:
+  dotfiles snapshot diff known-good                         # Snapshot → current
+  dotfiles snapshot diff 20240101-120000 20240201-090000    # Older → newer
+  dotfiles snapshot diff known-good --output json`,
+	Args: cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
+		from := loadSnapshot(args[0])
+		result := SnapshotDiff{From: snapshotLabel(from), To: "current"}
+
+		var fromCfg, toCfg *config.Config
+		if len(args) == 2 {
+			snap := loadSnapshot(args[1])
+			fromCfg, toCfg, result.To = from.Config, snap.Config, snapshotLabel(snap)
+		} else {
+			// The snapshot holds config.json, so it gets this machine's overlays too
+			stack, err := loadConfigStack()
+			if err != nil {
+				fail("Error loading configuration", err)
+			}
+			fromCfg, toCfg = stack.Rebase(from.Config), stack.Config
+		}
+
+		result.Changes = config.Diff(fromCfg, toCfg)
+		if result.Changes == nil {
+			result.Changes = []config.Change{}
+		}
+		for _, change := range result.Changes {
+			result.Added += len(change.Added)
+			result.Removed += len(change.Removed)
+		}
+
+		code := ExitOK
+		if len(result.Changes) > 0 {
+			code = ExitDrift
+		}
+		printReport(result, code, func() { printSnapshotDiff(result) })
+	},
+}
+
+// SnapshotDiff is the result of 'dotfiles snapshot diff'
+type SnapshotDiff struct {
+	From    string          `json:"from"`
+	To      string          `json:"to"` // "current" for the configuration of this machine
+	Changes []config.Change `json:"changes"`
+	Added   int             `json:"added"`
+	Removed int             `json:"removed"`
+}
+
+func printSnapshotDiff(result SnapshotDiff) {
+	fmt.Printf("🔍 %s → %s\n", result.From, result.To)
+	fmt.Println()
+
+	if len(result.Changes) == 0 {
+		fmt.Println("✅ No differences")
+		return
+	}
+
+	for _, change := range result.Changes {
+		fmt.Printf("%s:\n", change.Section)
+		for _, entry := range change.Added {
+			fmt.Printf("  + %s\n", entry)
+		}
+		for _, entry := range change.Removed {
+			fmt.Printf("  - %s\n", entry)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("%d added, %d removed\n", result.Added, result.Removed)
+}
+
+// snapshotLabel names a snapshot by timestamp and tags, e.g.
+// "20240101-120000 (known-good)"
+func snapshotLabel(snap *Snapshot) string {
+	if len(snap.Tags) == 0 {
+		return snap.Timestamp
+	}
+	return fmt.Sprintf("%s (%s)", snap.Timestamp, strings.Join(snap.Tags, ", "))
+}
+
+var snapshotTagCmd = &cobra.Command{
+	Use:   "tag <snapshot> <tag>",
+	Short: "Name a snapshot so it is never pruned",
+	Long: `Tag a snapshot, e.g. as known-good. Tagged snapshots are never removed by
+pruning or retention, and the tag can be used wherever a timestamp is
+expected. A tag names one snapshot, so tagging another moves it.
+
+Examples:
+  dotfiles snapshot tag 20240101-120000 known-good
+  dotfiles snapshot restore known-good
+  dotfiles snapshot tag --delete known-good`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if remove, _ := cmd.Flags().GetBool("delete"); remove {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if remove, _ := cmd.Flags().GetBool("delete"); remove {
+			timestamp, err := snapshot.Untag(appCtx.Root, args[0])
+			if err != nil {
+				fmt.Printf("❌ Error removing tag: %v\n", err)
+				os.Exit(1)
+			}
+			if timestamp == "" {
+				fmt.Printf("❌ No snapshot is tagged %s\n", args[0])
+				os.Exit(1)
+			}
+			fmt.Printf("✅ Removed tag %s from %s\n", args[0], timestamp)
+			return
+		}
+
+		snap := loadSnapshot(args[0])
+		previous, err := snapshot.Tag(appCtx.Root, snap.Timestamp, args[1])
 		if err != nil {
+			fmt.Printf("❌ Error tagging snapshot: %v\n", err)
 			os.Exit(1)
 		}
+		if previous != "" {
+			fmt.Printf("🏷️  Moved tag %s from %s\n", args[1], previous)
+		}
+		fmt.Printf("✅ Tagged %s as %s\n", snap.Timestamp, args[1])
+	},
+}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
-		cfg, err := config.Load(configPath)
-		if err != nil {
+var snapshotPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove snapshots the retention policy doesn't keep",
+	Long: `Remove old snapshots, keeping the newest ones, the newest of each recent
+day and week, and every tagged snapshot. The policy comes from
+snapshot_retention in config.json, and flags override it:
+
+  "snapshot_retention": {"keep_last": 10, "keep_daily": 7, "keep_weekly": 4}
+
+With a policy configured, automatic snapshots prune after they are taken.
+
+Examples:
+  dotfiles snapshot prune --dry-run                 # Show what would be removed
+  dotfiles snapshot prune --keep-last 5 --keep-weekly 8`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		var policy config.SnapshotRetention
+		if cfg, err := config.Load(appCtx.ConfigPath()); err == nil && cfg.SnapshotRetention != nil {
+			policy = *cfg.SnapshotRetention
+		}
+		if cmd.Flags().Changed("keep-last") {
+			policy.KeepLast, _ = cmd.Flags().GetInt("keep-last")
+		}
+		if cmd.Flags().Changed("keep-daily") {
+			policy.KeepDaily, _ = cmd.Flags().GetInt("keep-daily")
+		}
+		if cmd.Flags().Changed("keep-weekly") {
+			policy.KeepWeekly, _ = cmd.Flags().GetInt("keep-weekly")
+		}
+		if policy.IsEmpty() {
+			fmt.Println("❌ No retention policy. Set snapshot_retention in config.json or pass")
+			fmt.Println("   --keep-last, --keep-daily or --keep-weekly")
 			os.Exit(1)
 		}
 
-		snapshotsDir := filepath.Join(home, ".dotfiles", "snapshots")
-		os.MkdirAll(snapshotsDir, 0755)
+		removed, err := snapshot.Prune(appCtx.Root, policy, dryRun)
+		if err != nil {
+			fmt.Printf("❌ Error pruning snapshots: %v\n", err)
+			os.Exit(1)
+		}
+		if len(removed) == 0 {
+			fmt.Println("✅ Nothing to prune")
+			return
+		}
 
-		timestamp := time.Now().Format("20060102-150405")
-		snapshot := Snapshot{
-			Timestamp:   timestamp,
-			Description: "Auto-snapshot before operation",
-			Config:      cfg,
-			Metadata: map[string]string{
-				"created_by": "auto-snapshot",
-			},
+		sort.Slice(removed, func(i, j int) bool { return removed[i].Timestamp > removed[j].Timestamp })
+		if dryRun {
+			fmt.Printf("🔍 Would remove %d snapshot(s):\n", len(removed))
+		} else {
+			fmt.Printf("🗑️  Removed %d snapshot(s):\n", len(removed))
+		}
+		for _, snap := range removed {
+			fmt.Printf("   %s  %s\n", snap.Timestamp, snap.Description)
 		}
+	},
+}
 
-		snapshotPath := filepath.Join(snapshotsDir, timestamp+".json")
-		data, _ := json.MarshalIndent(snapshot, "", "  ")
-		os.WriteFile(snapshotPath, data, 0644)
+var snapshotAutoCmd = &cobra.Command{
+	Use:   "auto",
+	Short: "Create automatic snapshot before operations",
+	Long: `Create an automatic snapshot with timestamp.
+This is meant to be called before major operations like update, install, etc.
+Old snapshots are pruned afterwards when snapshot_retention is set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		timestamp, err := snapshot.CreateAutoSnapshot(appCtx.Root, "Auto-snapshot before operation")
+		if timestamp == "" {
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
 
 		fmt.Printf("📸 Auto-snapshot created: %s\n", timestamp)
 	},
 }
 
+// pruneSnapshotObjects removes file contents no snapshot holds any more
+func pruneSnapshotObjects() {
+	removed, err := snapshot.PruneObjects(appCtx.Root)
+	if err != nil {
+		fmt.Printf("⚠️  Could not remove unused snapshot files: %v\n", err)
+		return
+	}
+	if removed > 0 {
+		fmt.Printf("🧹 Removed %d file(s) no other snapshot holds\n", removed)
+	}
+}
+
 func init() {
 	snapshotCreateCmd.Flags().StringP("message", "m", "", "Snapshot description")
+	snapshotCreateCmd.Flags().Bool("files", false, "Also capture the files of stow packages and private/")
 	snapshotRestoreCmd.Flags().Bool("no-backup", false, "Don't create backup before restoring")
+	snapshotRestoreCmd.Flags().Bool("files", false, "Also restore the files the snapshot holds")
+	snapshotRestoreCmd.Flags().StringSlice("file", nil, "Restore only these files or directories (e.g. ~/.zshrc), not the configuration")
+	snapshotRestoreCmd.Flags().Bool("reconcile", false, "Also install, uninstall, link and unlink packages to match the snapshot")
+	snapshotRestoreCmd.Flags().Bool("remove-untracked", false, "With --reconcile, also uninstall packages 'dotfiles install' didn't install")
+	snapshotRestoreCmd.Flags().BoolP("yes", "y", false, "Reconcile without asking for confirmation")
+	snapshotDeleteCmd.Flags().Bool("force", false, "Delete the snapshot even if it is tagged")
+	snapshotTagCmd.Flags().BoolP("delete", "d", false, "Remove the tag instead")
+	snapshotPruneCmd.Flags().Int("keep-last", 0, "Keep the newest N snapshots")
+	snapshotPruneCmd.Flags().Int("keep-daily", 0, "Keep the newest snapshot of each of the last N days")
+	snapshotPruneCmd.Flags().Int("keep-weekly", 0, "Keep the newest snapshot of each of the last N weeks")
+	snapshotPruneCmd.Flags().Bool("dry-run", false, "Show what would be removed without removing it")
 
 	snapshotCmd.AddCommand(snapshotCreateCmd)
 	snapshotCmd.AddCommand(snapshotListCmd)
 	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	snapshotCmd.AddCommand(snapshotDiffCmd)
+	snapshotCmd.AddCommand(snapshotTagCmd)
+	snapshotCmd.AddCommand(snapshotPruneCmd)
 	snapshotCmd.AddCommand(snapshotDeleteCmd)
 	snapshotCmd.AddCommand(snapshotAutoCmd)
 
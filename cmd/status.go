@@ -7,143 +7,255 @@ import (
 	"path/filepath"
 	"strings"
 
-	"dotfiles/internal/config"
-	"dotfiles/internal/pkgmanager"
+	"dotfiles/internal/linker"
 	"github.com/spf13/cobra"
 )
 
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Check package installation status",
-	Long:  `Compare configured packages with what's actually installed on the system`,
+	Long: `Compare configured packages with what's actually installed on the system
+
+Exits with code 2 when a package is missing, a stow package is not stowed
+or its links drifted, or links remain for a stow package no longer in the
+configuration. Use --output json or yaml for a machine-readable report.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
+		cfg, err := loadMergedConfig()
 		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
+			fail("Error loading configuration", err)
 		}
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
-		cfg, err := config.Load(configPath)
-		if err != nil {
-			fmt.Printf("Error loading configuration: %v\n", err)
-			os.Exit(1)
+		// Group packages by package manager: the system one plus any listed under "packages"
+		sets, err := configuredManagers(cfg)
+		if err != nil && len(sets) == 0 {
+			fail("Error", err)
 		}
 
-		// Get the appropriate package manager for this OS
-		pm, err := pkgmanager.GetPackageManager()
+		report := StatusReport{Packages: []PackageStatus{}}
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			report.Warnings = append(report.Warnings, err.Error())
 		}
 
-		// Check if package manager is available
-		if !pm.IsAvailable() {
-			fmt.Printf("⚠️  %s not found. Cannot check package status.\n", pm.GetName())
-			return
+		for _, set := range sets {
+			if set.Primary {
+				report.PackageManager = set.Name
+			}
+			checkManagerPackages(&report, set)
 		}
 
-		fmt.Println("📊 Package Status Report")
-		fmt.Println("=" + strings.Repeat("=", 23))
-		fmt.Printf("Package Manager: %s\n", pm.GetName())
-		fmt.Println()
-
-		// Check taps (only relevant for Homebrew)
-		if len(cfg.Taps) > 0 {
-			checkTapsWithPM(cfg.Taps, pm)
+		// Check stow packages
+		if len(cfg.Stow) > 0 {
+			checkStowPackages(&report, cfg.Stow)
 		}
 
-		// Check brews
-		if len(cfg.Brews) > 0 {
-			checkPackagesWithPM("Packages", cfg.Brews, "brew", pm)
+		report.InSync = len(report.Orphaned) == 0
+		for _, pkg := range report.Packages {
+			if pkg.Drifted() {
+				report.InSync = false
+			}
 		}
 
-		// Check casks (only relevant for Homebrew)
-		if len(cfg.Casks) > 0 {
-			checkPackagesWithPM("Applications/Casks", cfg.Casks, "cask", pm)
+		code := ExitOK
+		if !report.InSync {
+			code = ExitDrift
 		}
+		printReport(report, code, func() { printStatusReport(report) })
+	},
+}
 
-		// Check stow packages
-		if len(cfg.Stow) > 0 {
-			checkStowPackages(cfg.Stow)
-		}
+// StatusReport is the result of 'dotfiles status'
+type StatusReport struct {
+	PackageManager string          `json:"package_manager"`
+	Packages       []PackageStatus `json:"packages"`
+	Orphaned       []string        `json:"orphaned,omitempty"` // Stow packages still linked but no longer configured
+	Warnings       []string        `json:"warnings,omitempty"`
+	InSync         bool            `json:"in_sync"`
+}
 
-		if len(cfg.Taps)+len(cfg.Brews)+len(cfg.Casks)+len(cfg.Stow) == 0 {
-			fmt.Println("No packages configured. Run 'dotfiles add <package>' to get started.")
-		}
-	},
+// PackageStatus is the state of one configured package
+type PackageStatus struct {
+	Name    string         `json:"name"`
+	Type    string         `json:"type"` // tap, brew, cask, package (other package managers) or stow
+	Manager string         `json:"manager,omitempty"`
+	State   string         `json:"state"` // installed, missing, stowed, not_stowed, drifted, not_found, error or unchecked
+	Error   string         `json:"error,omitempty"`
+	Links   int            `json:"links,omitempty"` // Links recorded for a stow package
+	Drift   []linker.Drift `json:"drift,omitempty"`
 }
 
-func checkTapsWithPM(configuredTaps []string, pm pkgmanager.PackageManager) {
-	// Only show taps section for Homebrew
-	if pm.GetName() != "homebrew" {
+// Drifted reports whether the package differs from the configuration
+func (p PackageStatus) Drifted() bool {
+	switch p.State {
+	case "missing", "not_stowed", "drifted", "not_found":
+		return true
+	}
+	return false
+}
+
+// checkManagerPackages adds the status of the packages one package manager
+// is responsible for
+func checkManagerPackages(report *StatusReport, set managerSet) {
+	if set.Count() == 0 {
 		return
 	}
 
-	fmt.Println("\n📋 Taps:")
+	type group struct {
+		pkgType  string
+		packages []string
+	}
+	groups := []group{{"package", set.Brews}}
+	if set.Primary {
+		groups = []group{{"brew", set.Brews}}
+		// Taps and casks are only relevant for Homebrew
+		if set.Name == "homebrew" {
+			groups = []group{{"tap", set.Taps}, {"brew", set.Brews}, {"cask", set.Casks}}
+		}
+	}
 
-	missing := []string{}
-	for _, tap := range configuredTaps {
-		installed, err := pm.IsInstalled(tap, "tap")
-		if err != nil {
-			fmt.Printf("  ⚠️  %s (error checking: %v)\n", tap, err)
-			continue
+	var skipped string
+	if set.Manager == nil {
+		skipped = fmt.Sprintf("Unknown package manager %q (%d package(s))", set.Name, set.Count())
+	} else if !set.Available() {
+		skipped = fmt.Sprintf("%s not found. Cannot check %d package(s).", set.Name, set.Count())
+	}
+	if skipped != "" {
+		report.Warnings = append(report.Warnings, skipped)
+	}
+
+	for _, g := range groups {
+		// Other package managers list their packages like brews
+		checkType := g.pkgType
+		if checkType == "package" {
+			checkType = "brew"
 		}
 
-		if installed {
-			fmt.Printf("  ✅ %s\n", tap)
-		} else {
-			fmt.Printf("  ❌ %s (not tapped)\n", tap)
-			missing = append(missing, tap)
+		for _, pkg := range g.packages {
+			status := PackageStatus{Name: pkg, Type: g.pkgType, Manager: set.Name, State: "unchecked"}
+			if skipped == "" {
+				installed, err := set.Manager.IsInstalled(pkg, checkType)
+				switch {
+				case err != nil:
+					status.State, status.Error = "error", err.Error()
+				case installed:
+					status.State = "installed"
+				default:
+					status.State = "missing"
+				}
+			}
+			report.Packages = append(report.Packages, status)
 		}
 	}
+}
 
-	if len(missing) > 0 {
-		fmt.Printf("  → Run: dotfiles add --type=tap %s\n", strings.Join(missing, " "))
+// printStatusReport prints a status report for people, a section per
+// package manager and package type
+func printStatusReport(report StatusReport) {
+	fmt.Println("📊 Package Status Report")
+	fmt.Println("=" + strings.Repeat("=", 23))
+
+	if report.PackageManager != "" {
+		fmt.Printf("Package Manager: %s\n", report.PackageManager)
+		fmt.Println()
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+
+	if len(report.Packages) == 0 {
+		fmt.Println("No packages configured. Run 'dotfiles add <package>' to get started.")
+		return
 	}
-}
 
-func checkPackagesWithPM(label string, packages []string, pkgType string, pm pkgmanager.PackageManager) {
-	icon := "🍺"
-	if pkgType == "cask" {
-		icon = "📦"
-		// Skip casks on non-macOS systems
-		if pm.GetName() != "homebrew" {
-			return
+	// Packages come grouped by manager and type
+	for start := 0; start < len(report.Packages); {
+		end := start + 1
+		for end < len(report.Packages) && report.Packages[end].Type == report.Packages[start].Type &&
+			report.Packages[end].Manager == report.Packages[start].Manager {
+			end++
 		}
+		printStatusSection(report.Packages[start:end], report.PackageManager)
+		start = end
 	}
 
-	fmt.Printf("\n%s %s:\n", icon, label)
+	if len(report.Orphaned) > 0 {
+		fmt.Printf("  ⚠️  Linked but not in config: %s\n", strings.Join(report.Orphaned, ", "))
+		fmt.Printf("  → Run: dotfiles unstow %s\n", strings.Join(report.Orphaned, " "))
+	}
+}
 
-	missing := []string{}
-	for _, pkg := range packages {
-		installed, err := pm.IsInstalled(pkg, pkgType)
-		if err != nil {
-			fmt.Printf("  ⚠️  %s (error checking: %v)\n", pkg, err)
-			continue
-		}
+// printStatusSection prints packages of one manager and type, with the
+// command that fixes the ones that differ
+func printStatusSection(packages []PackageStatus, primary string) {
+	first := packages[0]
+	if first.State == "unchecked" {
+		// Reported in the warnings
+		return
+	}
 
-		if installed {
-			fmt.Printf("  ✅ %s\n", pkg)
-		} else {
-			fmt.Printf("  ❌ %s (not installed)\n", pkg)
-			missing = append(missing, pkg)
-		}
+	switch {
+	case first.Type == "stow":
+		fmt.Println("\n🔗 Stow Packages:")
+	case first.Type == "tap":
+		fmt.Println("\n📋 Taps:")
+	case first.Type == "cask":
+		fmt.Println("\n📦 Applications/Casks:")
+	case first.Manager != primary:
+		fmt.Printf("\n📦 %s Packages:\n", first.Manager)
+	default:
+		fmt.Println("\n🍺 Packages:")
 	}
 
-	if len(missing) > 0 {
-		if pm.GetName() == "homebrew" {
-			if pkgType == "cask" {
-				fmt.Printf("  → Run: brew install --cask %s\n", strings.Join(missing, " "))
+	var missing, drifted []string
+	for _, pkg := range packages {
+		switch pkg.State {
+		case "installed":
+			fmt.Printf("  ✅ %s\n", pkg.Name)
+		case "stowed":
+			fmt.Printf("  ✅ %s (stowed)\n", pkg.Name)
+		case "error":
+			fmt.Printf("  ⚠️  %s (error checking: %s)\n", pkg.Name, pkg.Error)
+		case "missing":
+			if pkg.Type == "tap" {
+				fmt.Printf("  ❌ %s (not tapped)\n", pkg.Name)
 			} else {
-				fmt.Printf("  → Run: brew install %s\n", strings.Join(missing, " "))
+				fmt.Printf("  ❌ %s (not installed)\n", pkg.Name)
 			}
-		} else if pm.GetName() == "pacman" {
-			fmt.Printf("  → Run: yay -S %s\n", strings.Join(missing, " "))
-		} else {
+			missing = append(missing, pkg.Name)
+		case "not_found":
+			fmt.Printf("  ❌ %s (directory not found: %s)\n", pkg.Name, filepath.Join(appCtx.StowDir(), pkg.Name))
+			missing = append(missing, pkg.Name)
+		case "not_stowed":
+			fmt.Printf("  ⚠️  %s (not stowed)\n", pkg.Name)
+			missing = append(missing, pkg.Name)
+		case "drifted":
+			fmt.Printf("  ⚠️  %s (%d of %d links drifted)\n", pkg.Name, len(pkg.Drift), pkg.Links)
+			for _, d := range pkg.Drift {
+				fmt.Printf("     • %s (%s)\n", d.Target, d.Problem)
+			}
+			drifted = append(drifted, pkg.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		names := strings.Join(missing, " ")
+		switch {
+		case first.Type == "stow":
+			fmt.Printf("  → Run: dotfiles stow %s\n", names)
+		case first.Type == "tap":
+			fmt.Printf("  → Run: dotfiles add --type=tap %s\n", names)
+		case first.Manager == "homebrew" && first.Type == "cask":
+			fmt.Printf("  → Run: brew install --cask %s\n", names)
+		case first.Manager == "homebrew":
+			fmt.Printf("  → Run: brew install %s\n", names)
+		case first.Manager == "pacman":
+			fmt.Printf("  → Run: yay -S %s\n", names)
+		default:
 			fmt.Printf("  → Run: dotfiles install\n")
 		}
 	}
+	if len(drifted) > 0 {
+		fmt.Printf("  → Run: dotfiles restow %s\n", strings.Join(drifted, " "))
+	}
 }
 
 func checkTaps(configuredTaps []string) {
@@ -246,100 +358,75 @@ func getInstalledTaps() []string {
 	return taps
 }
 
-func checkStowPackages(configuredStow []string) {
-	fmt.Println("\n🔗 Stow Packages:")
-
-	home, err := os.UserHomeDir()
-	if err != nil {
-		fmt.Printf("  ❌ Error getting home directory: %v\n", err)
-		return
-	}
-
-	stowDir := filepath.Join(home, ".dotfiles", "stow")
-
-	// Check if stow is available
-	if _, err := exec.LookPath("stow"); err != nil {
-		fmt.Println("  ⚠️  GNU Stow not found. Install with: brew install stow")
-		return
-	}
+// checkStowPackages adds the status of the configured stow packages and
+// the stow packages linked but no longer configured
+func checkStowPackages(report *StatusReport, configuredStow []string) {
+	stowDir := appCtx.StowDir()
+	manifest := loadLinkManifest()
+	renderer := templateRenderer()
 
-	missing := []string{}
 	for _, pkg := range configuredStow {
+		status := PackageStatus{Name: pkg, Type: "stow"}
+
 		// Check if package directory exists
-		pkgPath := filepath.Join(stowDir, pkg)
-		if _, err := os.Stat(pkgPath); os.IsNotExist(err) {
-			fmt.Printf("  ❌ %s (directory not found: %s)\n", pkg, pkgPath)
-			missing = append(missing, pkg)
+		if _, err := os.Stat(filepath.Join(stowDir, pkg)); os.IsNotExist(err) {
+			status.State = "not_found"
+			report.Packages = append(report.Packages, status)
 			continue
 		}
 
+		// Recorded links tell us exactly what was created
+		if manifest != nil && len(manifest.Links(pkg)) > 0 {
+			status.Links = len(manifest.Links(pkg))
+			// Rendered templates are stale when their template or data changed
+			status.Drift = append(manifest.Verify(pkg), manifest.VerifyRendered(pkg, renderer)...)
+			if len(status.Drift) > 0 {
+				status.State = "drifted"
+				report.Packages = append(report.Packages, status)
+				continue
+			}
+		}
+
 		// Check if package is stowed (has symlinks in home)
-		isStowed := checkIfStowed(pkg, stowDir, home)
-		if isStowed {
-			fmt.Printf("  ✅ %s (stowed)\n", pkg)
-		} else {
-			fmt.Printf("  ⚠️  %s (not stowed)\n", pkg)
-			missing = append(missing, pkg)
+		status.State = "not_stowed"
+		if checkIfStowed(pkg, stowDir, appCtx.Home) {
+			status.State = "stowed"
 		}
+		report.Packages = append(report.Packages, status)
 	}
 
-	if len(missing) > 0 {
-		fmt.Printf("  → Run: dotfiles stow %s\n", strings.Join(missing, " "))
+	// Links recorded for packages that are no longer configured
+	if manifest != nil {
+		for _, pkg := range manifest.PackageNames() {
+			if !contains(configuredStow, pkg) {
+				report.Orphaned = append(report.Orphaned, pkg)
+			}
+		}
 	}
 }
 
 func checkIfStowed(pkg, stowDir, target string) bool {
-	// Run stow in simulation mode to check if package is already stowed
-	cmd := exec.Command("stow", "-d", stowDir, "-t", target, "-n", "-v", pkg)
-	output, err := cmd.CombinedOutput()
-
-	// If stow reports "LINK" operations, it means the package is not stowed
-	// If it reports no operations or only "UNLINK/LINK" pairs, it's already stowed
-	if err == nil && !strings.Contains(string(output), "LINK") {
-		return true
-	}
-
-	// Alternative check: look for existing symlinks
-	pkgPath := filepath.Join(stowDir, pkg)
-	return hasSymlinksInTarget(pkgPath, target, "")
-}
-
-func hasSymlinksInTarget(pkgPath, target, subPath string) bool {
-	currentPkgPath := filepath.Join(pkgPath, subPath)
-	currentTargetPath := filepath.Join(target, subPath)
-
-	entries, err := os.ReadDir(currentPkgPath)
-	if err != nil {
-		return false
+	// Use the options the package was stowed with when they are recorded,
+	// otherwise it may have been stowed with or without --dotfiles
+	modes := []bool{false, true}
+	if manifest, err := linker.LoadManifest(appCtx.LinkManifestPath()); err == nil {
+		if record, ok := manifest.Packages[pkg]; ok {
+			modes = []bool{record.Dotfiles}
+		}
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			// Recursively check subdirectories
-			if hasSymlinksInTarget(pkgPath, target, filepath.Join(subPath, entry.Name())) {
-				return true
-			}
-		} else {
-			// Check if file exists as symlink in target
-			targetFile := filepath.Join(currentTargetPath, entry.Name())
-			if info, err := os.Lstat(targetFile); err == nil && info.Mode()&os.ModeSymlink != 0 {
-				if link, err := os.Readlink(targetFile); err == nil {
-					expectedPath := filepath.Join(currentPkgPath, entry.Name())
-					if absLink, err := filepath.Abs(link); err == nil {
-						if absExpected, err := filepath.Abs(expectedPath); err == nil {
-							if absLink == absExpected {
-								return true
-							}
-						}
-					}
-				}
-			}
+	for _, dotfiles := range modes {
+		lnk, err := linker.New(withTemplates(linker.Options{Dir: stowDir, Target: target, Dotfiles: dotfiles}))
+		if err != nil {
+			return false
+		}
+		if stowed, err := lnk.IsStowed(pkg); err == nil && stowed {
+			return true
 		}
 	}
-
 	return false
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
-}
\ No newline at end of file
+}
@@ -1,36 +1,47 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"dotfiles/internal/config"
+	"dotfiles/internal/hooks"
+	"dotfiles/internal/linker"
+	"dotfiles/internal/pkgmanager"
+	"dotfiles/internal/render"
+	"dotfiles/internal/snapshot"
 	"github.com/spf13/cobra"
 )
 
 var stowCmd = &cobra.Command{
 	Use:   "stow <packages>",
-	Short: "🔗 Create dotfile symlinks using GNU Stow",
+	Short: "🔗 Create dotfile symlinks",
 	Long: `🔗 Stow Dotfiles - Manage Configuration Symlinks
 
-Create symlinks for dotfile packages using GNU Stow. This allows you to
-keep your dotfiles organized in ~/.dotfiles/stow/ while having them
-appear in their expected locations in your home directory.
+Create symlinks for dotfile packages with a built-in linker that follows
+GNU Stow's layout, so GNU Stow itself does not need to be installed. This
+allows you to keep your dotfiles organized in ~/.dotfiles/stow/ while
+having them appear in their expected locations in your home directory.
 
 How it works:
 1. Files in ~/.dotfiles/stow/vim/.vimrc become ~/.vimrc
 2. Directories in ~/.dotfiles/stow/zsh/.zsh become ~/.zsh
 3. Config files in ~/.dotfiles/stow/config/.config/app become ~/.config/app
+4. Directories are linked whole until another package needs them
+   (tree folding), then split into real directories automatically
+5. With --dotfiles, names like dot-vimrc become .vimrc
+6. Entries matching .stow-local-ignore (or the built-in list) are skipped
 
 Examples:
   dotfiles stow vim zsh tmux                 # Stow multiple packages
   dotfiles stow --dir=/custom/path vim       # Use custom stow directory
   dotfiles stow --target=/tmp vim            # Stow to custom target
   dotfiles stow --dry-run --verbose vim      # Preview what would happen
+  dotfiles stow --dotfiles vim               # Link dot-vimrc as .vimrc
   dotfiles stow config                       # Stow .config applications
 
 Common packages to stow:
@@ -39,13 +50,9 @@ Common packages to stow:
 • config - Applications that use ~/.config/
 • shell - Shell environment and aliases`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
+		home := appCtx.Home
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 
 		// Load existing config
 		cfg, err := config.Load(configPath)
@@ -55,16 +62,17 @@ Common packages to stow:
 			os.Exit(1)
 		}
 
-		// Check if stow is available
-		if _, err := exec.LookPath("stow"); err != nil {
-			fmt.Println("⚠️  GNU Stow not found. Install with: brew install stow")
+		// Hooks come from every layer, config.json is what gets saved
+		hookCfg, err := loadMergedConfig()
+		if err != nil {
+			fmt.Printf("Error loading configuration: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Get stow directory from flag or default to ~/.dotfiles/stow
+		// Get stow directory from flag or default to stow/ in the dotfiles root
 		stowDir, _ := cmd.Flags().GetString("dir")
 		if stowDir == "" {
-			stowDir = filepath.Join(home, ".dotfiles", "stow")
+			stowDir = appCtx.StowDir()
 		}
 
 		// Get target directory from flag or default to home
@@ -95,10 +103,25 @@ Common packages to stow:
 
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		opts := stowLinkerOptions(cmd, stowDir, target)
+		lnk, err := linker.New(opts)
+		if err != nil {
+			fmt.Printf("Error creating linker: %v\n", err)
+			os.Exit(1)
+		}
 		backup, _ := cmd.Flags().GetBool("backup")
 		autoResolve, _ := cmd.Flags().GetBool("auto-resolve")
+		manifest := loadLinkManifest()
 		added := 0
 
+		payload := hooks.Payload{Event: hooks.Stow, Phase: hooks.Pre, Packages: packages}
+		if !dryRun {
+			if err := fireHooks(hookCfg, payload); err != nil {
+				fmt.Printf("❌ Pre-stow hook failed: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
 		for _, pkg := range packages {
 			pkg = strings.TrimSpace(pkg)
 			if pkg == "" {
@@ -124,25 +147,9 @@ Common packages to stow:
 				}
 			}
 
-			// Build stow command
-			stowArgs := []string{
-				"-d", stowDir,
-				"-t", target,
-			}
-
-			if verbose {
-				stowArgs = append(stowArgs, "-v")
-			}
-
-			if dryRun {
-				stowArgs = append(stowArgs, "-n")
-			}
-
-			stowArgs = append(stowArgs, pkg)
-
 			// Check for conflicts before stowing
 			if !dryRun {
-				conflicts := findStowConflicts(pkgPath, target)
+				conflicts := findStowConflicts(opts, pkg)
 				if len(conflicts) > 0 {
 					fmt.Printf("⚠️  Found %d conflicts for package '%s':\n", len(conflicts), pkg)
 					for _, conflict := range conflicts {
@@ -163,23 +170,19 @@ Common packages to stow:
 				}
 			}
 
-			// Execute stow command
-			stowCmd := exec.Command("stow", stowArgs...)
-			if verbose || dryRun {
-				fmt.Printf("Running: %s\n", strings.Join(stowCmd.Args, " "))
-			}
-
-			output, err := stowCmd.CombinedOutput()
+			ops, err := lnk.Stow(pkg)
 			if err != nil {
 				fmt.Printf("❌ Error stowing %s: %v\n", pkg, err)
-				if len(output) > 0 {
-					fmt.Printf("   Output: %s\n", strings.TrimSpace(string(output)))
-				}
+				printLinkConflicts(err)
 				continue
 			}
 
-			if verbose && len(output) > 0 {
-				fmt.Printf("   %s\n", strings.TrimSpace(string(output)))
+			if verbose || dryRun {
+				printLinkOperations(ops)
+			}
+
+			if !dryRun && manifest != nil {
+				manifest.Apply(pkg, opts, ops)
 			}
 
 			if !dryRun {
@@ -196,6 +199,10 @@ Common packages to stow:
 			}
 		}
 
+		if !dryRun {
+			saveLinkManifest(manifest)
+		}
+
 		if !dryRun && added > 0 {
 			if err := cfg.Save(configPath); err != nil {
 				fmt.Printf("Error saving configuration: %v\n", err)
@@ -203,21 +210,24 @@ Common packages to stow:
 			}
 			fmt.Printf("\n📊 Added %d new stow packages to config\n", added)
 		}
+
+		if !dryRun {
+			payload.Phase = hooks.Post
+			if err := fireHooks(hookCfg, payload); err != nil {
+				fmt.Printf("⚠️  Post-stow hook failed: %v\n", err)
+			}
+		}
 	},
 }
 
 var unstowCmd = &cobra.Command{
 	Use:   "unstow <packages>",
-	Short: "Unstow dotfile packages using GNU Stow",
-	Long:  `Remove symlinks for dotfile packages using GNU Stow`,
+	Short: "Unstow dotfile packages",
+	Long:  `Remove the symlinks a dotfile package created in the target directory`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
+		home := appCtx.Home
 
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		configPath := appCtx.ConfigPath()
 
 		// Load existing config
 		cfg, err := config.Load(configPath)
@@ -226,16 +236,17 @@ var unstowCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		// Check if stow is available
-		if _, err := exec.LookPath("stow"); err != nil {
-			fmt.Println("⚠️  GNU Stow not found. Install with: brew install stow")
+		// Hooks come from every layer, config.json is what gets saved
+		hookCfg, err := loadMergedConfig()
+		if err != nil {
+			fmt.Printf("Error loading configuration: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Get stow directory from flag or default to ~/.dotfiles/stow
+		// Get stow directory from flag or default to stow/ in the dotfiles root
 		stowDir, _ := cmd.Flags().GetString("dir")
 		if stowDir == "" {
-			stowDir = filepath.Join(home, ".dotfiles", "stow")
+			stowDir = appCtx.StowDir()
 		}
 
 		// Get target directory from flag or default to home
@@ -271,49 +282,55 @@ var unstowCmd = &cobra.Command{
 
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		opts := stowLinkerOptions(cmd, stowDir, target)
+		lnk, err := linker.New(opts)
+		if err != nil {
+			fmt.Printf("Error creating linker: %v\n", err)
+			os.Exit(1)
+		}
 		keepConfig, _ := cmd.Flags().GetBool("keep-config")
+		manifest := loadLinkManifest()
 		removed := 0
 
+		payload := hooks.Payload{Event: hooks.Unstow, Phase: hooks.Pre, Packages: packages}
+		if !dryRun {
+			if err := fireHooks(hookCfg, payload); err != nil {
+				fmt.Printf("❌ Pre-unstow hook failed: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
 		for _, pkg := range packages {
 			pkg = strings.TrimSpace(pkg)
 			if pkg == "" {
 				continue
 			}
 
-			// Build stow command
-			stowArgs := []string{
-				"-d", stowDir,
-				"-t", target,
-				"-D", // Delete (unstow)
-			}
-
-			if verbose {
-				stowArgs = append(stowArgs, "-v")
-			}
-
-			if dryRun {
-				stowArgs = append(stowArgs, "-n")
-			}
-
-			stowArgs = append(stowArgs, pkg)
-
-			// Execute stow command
-			stowCmd := exec.Command("stow", stowArgs...)
-			if verbose || dryRun {
-				fmt.Printf("Running: %s\n", strings.Join(stowCmd.Args, " "))
+			var ops []linker.Operation
+			recorded := manifest != nil && len(manifest.Links(pkg)) > 0
+			if _, err := os.Stat(filepath.Join(stowDir, pkg)); err == nil || !recorded {
+				ops, err = lnk.Unstow(pkg)
+				if err != nil {
+					fmt.Printf("❌ Error unstowing %s: %v\n", pkg, err)
+					continue
+				}
 			}
 
-			output, err := stowCmd.CombinedOutput()
-			if err != nil {
-				fmt.Printf("❌ Error unstowing %s: %v\n", pkg, err)
-				if len(output) > 0 {
-					fmt.Printf("   Output: %s\n", strings.TrimSpace(string(output)))
+			// Remove recorded links the package no longer knows about,
+			// such as files deleted from the package after stowing
+			if recorded {
+				manifest.Apply(pkg, opts, ops)
+				pruned, err := lnk.Prune(manifest.Links(pkg))
+				if err != nil {
+					fmt.Printf("❌ Error removing recorded links for %s: %v\n", pkg, err)
+					continue
 				}
-				continue
+				ops = append(ops, pruned...)
+				manifest.Forget(pkg)
 			}
 
-			if verbose && len(output) > 0 {
-				fmt.Printf("   %s\n", strings.TrimSpace(string(output)))
+			if verbose || dryRun {
+				printLinkOperations(ops)
 			}
 
 			if !dryRun && !keepConfig {
@@ -332,6 +349,10 @@ var unstowCmd = &cobra.Command{
 			}
 		}
 
+		if !dryRun {
+			saveLinkManifest(manifest)
+		}
+
 		if !dryRun && removed > 0 {
 			if err := cfg.Save(configPath); err != nil {
 				fmt.Printf("Error saving configuration: %v\n", err)
@@ -339,39 +360,34 @@ var unstowCmd = &cobra.Command{
 			}
 			fmt.Printf("\n📊 Removed %d stow packages from config\n", removed)
 		}
+
+		if !dryRun {
+			payload.Phase = hooks.Post
+			if err := fireHooks(hookCfg, payload); err != nil {
+				fmt.Printf("⚠️  Post-unstow hook failed: %v\n", err)
+			}
+		}
 	},
 }
 
 var restowCmd = &cobra.Command{
 	Use:   "restow <packages>",
 	Short: "Restow dotfile packages (unstow then stow)",
-	Long:  `Remove and recreate symlinks for dotfile packages using GNU Stow`,
+	Long:  `Remove and recreate symlinks for dotfile packages in a single pass`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		configPath := filepath.Join(home, ".dotfiles", "config.json")
+		home := appCtx.Home
 
 		// Load existing config
-		cfg, err := config.Load(configPath)
+		cfg, err := loadMergedConfig()
 		if err != nil {
 			fmt.Printf("Error loading configuration: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Check if stow is available
-		if _, err := exec.LookPath("stow"); err != nil {
-			fmt.Println("⚠️  GNU Stow not found. Install with: brew install stow")
-			os.Exit(1)
-		}
-
-		// Get stow directory from flag or default to ~/.dotfiles/stow
+		// Get stow directory from flag or default to stow/ in the dotfiles root
 		stowDir, _ := cmd.Flags().GetString("dir")
 		if stowDir == "" {
-			stowDir = filepath.Join(home, ".dotfiles", "stow")
+			stowDir = appCtx.StowDir()
 		}
 
 		// Get target directory from flag or default to home
@@ -407,6 +423,22 @@ var restowCmd = &cobra.Command{
 
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		opts := stowLinkerOptions(cmd, stowDir, target)
+		lnk, err := linker.New(opts)
+		if err != nil {
+			fmt.Printf("Error creating linker: %v\n", err)
+			os.Exit(1)
+		}
+		manifest := loadLinkManifest()
+
+		// Restowing relinks packages, so it runs the stow hooks
+		payload := hooks.Payload{Event: hooks.Stow, Phase: hooks.Pre, Packages: packages}
+		if !dryRun {
+			if err := fireHooks(cfg, payload); err != nil {
+				fmt.Printf("❌ Pre-stow hook failed: %v\n", err)
+				os.Exit(1)
+			}
+		}
 
 		for _, pkg := range packages {
 			pkg = strings.TrimSpace(pkg)
@@ -421,44 +453,46 @@ var restowCmd = &cobra.Command{
 				continue
 			}
 
-			// Build restow command
-			stowArgs := []string{
-				"-d", stowDir,
-				"-t", target,
-				"-R", // Restow
-			}
-
-			if verbose {
-				stowArgs = append(stowArgs, "-v")
+			ops, err := lnk.Restow(pkg)
+			if err != nil {
+				fmt.Printf("❌ Error restowing %s: %v\n", pkg, err)
+				printLinkConflicts(err)
+				continue
 			}
 
-			if dryRun {
-				stowArgs = append(stowArgs, "-n")
+			// Drop links to files that were removed from the package
+			if manifest != nil {
+				pruned, err := lnk.Prune(manifest.Orphans(pkg))
+				if err != nil {
+					fmt.Printf("❌ Error removing orphaned links for %s: %v\n", pkg, err)
+					continue
+				}
+				ops = append(ops, pruned...)
+				if !dryRun {
+					manifest.Apply(pkg, opts, ops)
+				}
 			}
 
-			stowArgs = append(stowArgs, pkg)
-
-			// Execute stow command
-			stowCmd := exec.Command("stow", stowArgs...)
 			if verbose || dryRun {
-				fmt.Printf("Running: %s\n", strings.Join(stowCmd.Args, " "))
+				printLinkOperations(ops)
 			}
 
-			output, err := stowCmd.CombinedOutput()
-			if err != nil {
-				fmt.Printf("❌ Error restowing %s: %v\n", pkg, err)
-				if len(output) > 0 {
-					fmt.Printf("   Output: %s\n", strings.TrimSpace(string(output)))
-				}
+			if dryRun {
+				fmt.Printf("✓ Would restow: %s\n", pkg)
 				continue
 			}
 
-			if verbose && len(output) > 0 {
-				fmt.Printf("   %s\n", strings.TrimSpace(string(output)))
-			}
-
 			fmt.Printf("✓ Restowed: %s\n", pkg)
 		}
+
+		if !dryRun {
+			saveLinkManifest(manifest)
+
+			payload.Phase = hooks.Post
+			if err := fireHooks(cfg, payload); err != nil {
+				fmt.Printf("⚠️  Post-stow hook failed: %v\n", err)
+			}
+		}
 	},
 }
 
@@ -468,17 +502,11 @@ var privateCmd = &cobra.Command{
 	Long:  `Create a symlink in a stow package that points to a file in the private directory`,
 	Args:  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
 		pkg := args[0]
 		filename := args[1]
 
-		stowDir := filepath.Join(home, ".dotfiles", "stow")
-		privateDir := filepath.Join(home, ".dotfiles", "private")
+		stowDir := appCtx.StowDir()
+		privateDir := appCtx.PrivateDir()
 
 		// Ensure the private directory exists
 		if err := os.MkdirAll(privateDir, 0755); err != nil {
@@ -538,29 +566,38 @@ var privateCmd = &cobra.Command{
 
 func init() {
 	// Stow command flags
-	stowCmd.Flags().StringP("dir", "d", "", "Stow directory (default: ~/.dotfiles/stow)")
+	stowCmd.Flags().StringP("dir", "d", "", "Stow directory (default: stow/ in the dotfiles root)")
 	stowCmd.Flags().StringP("target", "t", "", "Target directory (default: ~)")
 	stowCmd.Flags().StringP("file", "f", "", "Read packages from file (one per line)")
 	stowCmd.Flags().BoolP("dry-run", "n", false, "Show what would be done without executing")
 	stowCmd.Flags().BoolP("verbose", "v", false, "Verbose output")
+	stowCmd.Flags().Bool("dotfiles", false, "Translate dot- prefixes (dot-vimrc becomes .vimrc)")
+	stowCmd.Flags().Bool("no-folding", false, "Create directories instead of linking whole trees")
+	stowCmd.Flags().StringSlice("ignore", nil, "Extra ignore patterns (regular expressions)")
 	stowCmd.Flags().Bool("backup", false, "Backup existing files before stowing")
 	stowCmd.Flags().Bool("auto-resolve", false, "Automatically resolve conflicts")
 
 	// Unstow command flags
-	unstowCmd.Flags().StringP("dir", "d", "", "Stow directory (default: ~/.dotfiles/stow)")
+	unstowCmd.Flags().StringP("dir", "d", "", "Stow directory (default: stow/ in the dotfiles root)")
 	unstowCmd.Flags().StringP("target", "t", "", "Target directory (default: ~)")
 	unstowCmd.Flags().StringP("file", "f", "", "Read packages from file (one per line)")
 	unstowCmd.Flags().BoolP("dry-run", "n", false, "Show what would be done without executing")
 	unstowCmd.Flags().BoolP("verbose", "v", false, "Verbose output")
+	unstowCmd.Flags().Bool("dotfiles", false, "Translate dot- prefixes (dot-vimrc becomes .vimrc)")
+	unstowCmd.Flags().Bool("no-folding", false, "Create directories instead of linking whole trees")
+	unstowCmd.Flags().StringSlice("ignore", nil, "Extra ignore patterns (regular expressions)")
 	unstowCmd.Flags().Bool("all", false, "Unstow all configured stow packages")
 	unstowCmd.Flags().Bool("keep-config", false, "Don't remove packages from config")
 
 	// Restow command flags
-	restowCmd.Flags().StringP("dir", "d", "", "Stow directory (default: ~/.dotfiles/stow)")
+	restowCmd.Flags().StringP("dir", "d", "", "Stow directory (default: stow/ in the dotfiles root)")
 	restowCmd.Flags().StringP("target", "t", "", "Target directory (default: ~)")
 	restowCmd.Flags().StringP("file", "f", "", "Read packages from file (one per line)")
 	restowCmd.Flags().BoolP("dry-run", "n", false, "Show what would be done without executing")
 	restowCmd.Flags().BoolP("verbose", "v", false, "Verbose output")
+	restowCmd.Flags().Bool("dotfiles", false, "Translate dot- prefixes (dot-vimrc becomes .vimrc)")
+	restowCmd.Flags().Bool("no-folding", false, "Create directories instead of linking whole trees")
+	restowCmd.Flags().StringSlice("ignore", nil, "Extra ignore patterns (regular expressions)")
 	restowCmd.Flags().Bool("all", false, "Restow all configured stow packages")
 
 	// Private command flags
@@ -600,77 +637,164 @@ func importDotfileDirectory(pkgName, sourcePath, destPath string) error {
 	return nil
 }
 
-func findStowConflicts(pkgPath, target string) []string {
+// findStowConflicts returns target paths that would block stowing pkg with
+// opts, the options it is then stowed with
+func findStowConflicts(opts linker.Options, pkg string) []string {
 	var conflicts []string
 
-	err := filepath.Walk(pkgPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	opts.DryRun = true
+	lnk, err := linker.New(opts)
+	if err != nil {
+		fmt.Printf("Warning: Error scanning for conflicts: %v\n", err)
+		return conflicts
+	}
 
-		// Skip the package directory itself
-		if path == pkgPath {
-			return nil
-		}
+	found, err := lnk.Conflicts(pkg)
+	if err != nil {
+		fmt.Printf("Warning: Error scanning for conflicts: %v\n", err)
+		return conflicts
+	}
 
-		// Get relative path from package directory
-		relPath, err := filepath.Rel(pkgPath, path)
-		if err != nil {
-			return err
-		}
+	for _, conflict := range found {
+		conflicts = append(conflicts, conflict.Path)
+	}
 
-		// Calculate target path
-		targetPath := filepath.Join(target, relPath)
-
-		// Check if target exists and is not a symlink to our package
-		if info, err := os.Lstat(targetPath); err == nil {
-			if info.Mode()&os.ModeSymlink != 0 {
-				// It's a symlink, check if it points to our package
-				linkTarget, err := os.Readlink(targetPath)
-				if err == nil {
-					if absLink, err := filepath.Abs(filepath.Join(filepath.Dir(targetPath), linkTarget)); err == nil {
-						if absPackage, err := filepath.Abs(path); err == nil {
-							if absLink == absPackage {
-								// It's already linked to our package, not a conflict
-								return nil
-							}
-						}
-					}
-				}
-			}
-			// File exists and is not a proper symlink to our package
-			conflicts = append(conflicts, targetPath)
-		}
+	return conflicts
+}
 
+// loadLinkManifest loads the link manifest, warning and returning nil if it cannot be read
+func loadLinkManifest() *linker.Manifest {
+	manifest, err := linker.LoadManifest(appCtx.LinkManifestPath())
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Could not read link manifest: %v\n", err)
 		return nil
+	}
+	return manifest
+}
+
+func saveLinkManifest(manifest *linker.Manifest) {
+	if manifest == nil {
+		return
+	}
+	if err := appCtx.EnsureStateDir(); err != nil {
+		fmt.Printf("⚠️  Warning: Could not save link manifest: %v\n", err)
+		return
+	}
+	if err := manifest.Save(); err != nil {
+		fmt.Printf("⚠️  Warning: Could not save link manifest: %v\n", err)
+	}
+}
+
+// stowLinkerOptions builds linker options from the shared stow flags
+func stowLinkerOptions(cmd *cobra.Command, stowDir, target string) linker.Options {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	dotfiles, _ := cmd.Flags().GetBool("dotfiles")
+	noFolding, _ := cmd.Flags().GetBool("no-folding")
+	ignore, _ := cmd.Flags().GetStringSlice("ignore")
+
+	return withTemplates(linker.Options{
+		Dir:       stowDir,
+		Target:    target,
+		Dotfiles:  dotfiles,
+		NoFolding: noFolding,
+		DryRun:    dryRun,
+		Ignore:    ignore,
 	})
+}
 
+// withTemplates makes the linker render .tmpl files and lets it update the
+// files it rendered before
+func withTemplates(opts linker.Options) linker.Options {
+	opts.Render = templateRenderer()
+	if manifest, err := linker.LoadManifest(appCtx.LinkManifestPath()); err == nil {
+		opts.Rendered = manifest.RenderedHashes()
+	}
+	return opts
+}
+
+// templateRenderer returns a function rendering .tmpl files with this
+// machine's data. The data is collected on first use.
+func templateRenderer() func(source string) ([]byte, error) {
+	var data *render.Data
+	return func(source string) ([]byte, error) {
+		if data == nil {
+			collected, err := templateData()
+			if err != nil {
+				return nil, err
+			}
+			data = &collected
+		}
+		return render.File(source, *data)
+	}
+}
+
+// templateData collects the data available to .tmpl files
+func templateData() (render.Data, error) {
+	cfg, err := loadMergedConfig()
 	if err != nil {
-		fmt.Printf("Warning: Error scanning for conflicts: %v\n", err)
+		return render.Data{}, fmt.Errorf("loading configuration: %v", err)
 	}
 
-	return conflicts
+	pmName := ""
+	if pm, err := pkgmanager.GetPackageManager(); err == nil {
+		pmName = pm.GetName()
+	}
+
+	data, err := render.NewData(appCtx.Root, appCtx.Home, appCtx.PrivateDir(), pmName, cfg.Variables, cfg.HostVariables)
+	if err != nil {
+		return data, fmt.Errorf("reading private files: %v", err)
+	}
+	return data, nil
+}
+
+func printLinkOperations(ops []linker.Operation) {
+	if len(ops) == 0 {
+		fmt.Println("   (nothing to do)")
+		return
+	}
+	for _, op := range ops {
+		fmt.Printf("   %s\n", op)
+	}
+}
+
+func printLinkConflicts(err error) {
+	var conflictErr *linker.ConflictError
+	if errors.As(err, &conflictErr) {
+		for _, conflict := range conflictErr.Conflicts {
+			fmt.Printf("   %s: %s\n", conflict.Path, conflict.Reason)
+		}
+	}
 }
 
 func resolveStowConflicts(conflicts []string, backup, verbose bool) error {
 	backupDir := ""
 	if backup {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("error getting home directory: %v", err)
-		}
-
-		backupDir = filepath.Join(home, ".dotfiles", "backups", fmt.Sprintf("backup-%d", time.Now().Unix()))
+		backupDir = filepath.Join(appCtx.BackupsDir(), fmt.Sprintf("backup-%d", time.Now().Unix()))
 		if err := os.MkdirAll(backupDir, 0755); err != nil {
 			return fmt.Errorf("error creating backup directory: %v", err)
 		}
 		fmt.Printf("📦 Created backup directory: %s\n", backupDir)
+	} else if len(conflicts) > 0 {
+		// Removed files can still be restored from a snapshot
+		if err := appCtx.EnsureStateDir(); err != nil {
+			return err
+		}
+		snap, err := snapshot.Create(appCtx.Root, "Before removing stow conflicts", snapshot.Options{
+			Home:     appCtx.Home,
+			Targets:  conflicts,
+			Metadata: map[string]string{"created_by": "auto-snapshot"},
+		})
+		if err != nil {
+			return fmt.Errorf("error saving conflicting files to a snapshot: %v", err)
+		}
+		fmt.Printf("📸 Saved the conflicting files in snapshot %s\n", snap.Timestamp)
+		fmt.Printf("   Restore them with: dotfiles snapshot restore %s --files\n", snap.Timestamp)
 	}
 
 	for _, conflictPath := range conflicts {
 		if backup {
 			// Backup the existing file
-			relPath, err := filepath.Rel(os.Getenv("HOME"), conflictPath)
+			relPath, err := filepath.Rel(appCtx.Home, conflictPath)
 			if err != nil {
 				relPath = filepath.Base(conflictPath)
 			}
@@ -706,4 +830,4 @@ func resolveStowConflicts(conflicts []string, backup, verbose bool) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"dotfiles/internal/hooks"
 	"github.com/spf13/cobra"
 )
 
@@ -22,24 +23,21 @@ Examples:
   dotfiles sync              # Pull and push changes (full sync)
   dotfiles sync --pull       # Only pull changes from remote
   dotfiles sync --push       # Only push changes to remote
-  dotfiles sync --auto       # Auto-commit and sync all changes`,
+  dotfiles sync --auto       # Auto-commit and sync all changes
+
+Changes are scanned for secrets before --auto commits them; see
+'dotfiles secrets scan'.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		pullOnly, _ := cmd.Flags().GetBool("pull")
 		pushOnly, _ := cmd.Flags().GetBool("push")
 		autoCommit, _ := cmd.Flags().GetBool("auto")
 		message, _ := cmd.Flags().GetString("message")
 
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Printf("❌ Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-
-		dotfilesDir := filepath.Join(home, ".dotfiles")
+		dotfilesDir := appCtx.Root
 
 		// Check if .dotfiles directory exists
 		if _, err := os.Stat(dotfilesDir); os.IsNotExist(err) {
-			fmt.Println("❌ Dotfiles directory not found at ~/.dotfiles")
+			fmt.Printf("❌ Dotfiles directory not found at %s\n", dotfilesDir)
 			fmt.Println("💡 Run 'dotfiles setup <repo-url>' first")
 			os.Exit(1)
 		}
@@ -61,6 +59,18 @@ Examples:
 			os.Exit(1)
 		}
 
+		// Pre-sync hooks run first so files they generate are committed
+		cfg, err := loadMergedConfig()
+		if err != nil {
+			fmt.Printf("⚠️  Could not load configuration, skipping hooks: %v\n", err)
+			cfg = nil
+		}
+		payload := hooks.Payload{Event: hooks.Sync, Phase: hooks.Pre}
+		if err := fireHooks(cfg, payload); err != nil {
+			fmt.Printf("❌ Pre-sync hook failed: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Check for uncommitted changes
 		hasChanges := checkGitStatus()
 
@@ -109,6 +119,11 @@ Examples:
 			fmt.Println()
 		}
 
+		payload.Phase = hooks.Post
+		if err := fireHooks(cfg, payload); err != nil {
+			fmt.Printf("⚠️  Post-sync hook failed: %v\n", err)
+		}
+
 		fmt.Println("🎉 Sync complete!")
 		fmt.Println()
 		fmt.Println("💡 Next steps:")
@@ -127,6 +142,12 @@ func checkGitStatus() bool {
 }
 
 func gitCommit(message string) error {
+	// Refuse to commit secrets, including plaintext copies of private/
+	// files in a state/ that predates its .gitignore
+	if err := checkSecrets(scanRepository(true)); err != nil {
+		return err
+	}
+
 	// Add all changes
 	addCmd := exec.Command("git", "add", ".")
 	if err := addCmd.Run(); err != nil {
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"dotfiles/internal/config"
+	"dotfiles/internal/hooks"
 	"github.com/spf13/cobra"
 )
 
@@ -24,11 +25,32 @@ var templatesFS embed.FS
 // Template with inheritance support
 type ExtendedTemplate struct {
 	ShareableConfig
-	Extends    string   `json:"extends,omitempty"`    // Base template to inherit from
-	Overrides  []string `json:"overrides,omitempty"`  // Fields to override from base
-	AddOnly    bool     `json:"addOnly,omitempty"`    // Only add packages, don't remove any
-	Public     bool     `json:"public,omitempty"`     // Whether template is publicly visible
-	Featured   bool     `json:"featured,omitempty"`   // Whether template is featured
+	Extends   string   `json:"extends,omitempty"`   // Base template to inherit from
+	Overrides []string `json:"overrides,omitempty"` // Fields to override from base
+	AddOnly   bool     `json:"addOnly,omitempty"`   // Only add packages, don't remove any
+	Public    bool     `json:"public,omitempty"`    // Whether template is publicly visible
+	Featured  bool     `json:"featured,omitempty"`  // Whether template is featured
+}
+
+// UnmarshalJSON decodes the inheritance fields alongside the shared
+// configuration, whose UnmarshalJSON would otherwise drop them
+func (t *ExtendedTemplate) UnmarshalJSON(data []byte) error {
+	var extra struct {
+		Extends   string   `json:"extends"`
+		Overrides []string `json:"overrides"`
+		AddOnly   bool     `json:"addOnly"`
+		Public    bool     `json:"public"`
+		Featured  bool     `json:"featured"`
+	}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return err
+	}
+	if err := t.ShareableConfig.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	t.Extends, t.Overrides, t.AddOnly = extra.Extends, extra.Overrides, extra.AddOnly
+	t.Public, t.Featured = extra.Public, extra.Featured
+	return nil
 }
 
 // Template structure for JSON files
@@ -113,47 +135,47 @@ var configTemplates = func() map[string]ShareableConfig {
 				},
 				Stow: []string{"vim", "zsh", "tmux", "starship", "git"},
 				Hooks: &config.Hooks{
-					PreInstall: []string{
+					PreInstall: config.Commands(
 						"brew update",
-					},
-					PostInstall: []string{
+					),
+					PostInstall: config.Commands(
 						"echo '✅ Installation complete! Run dotfiles stow to symlink your config files.'",
-					},
-					PreStow: []string{
+					),
+					PreStow: config.Commands(
 						"echo '🔗 Creating symlinks...'",
-					},
-					PostStow: []string{
+					),
+					PostStow: config.Commands(
 						"echo '✅ Dotfiles stowed successfully!'",
-					},
+					),
 				},
 				PackageConfigs: map[string]config.PackageConfig{
 					"starship": {
-						PostInstall: []string{
+						PostInstall: config.Commands(
 							"echo 'eval \"$(starship init bash)\"' >> ~/.bashrc",
 							"echo 'eval \"$(starship init zsh)\"' >> ~/.zshrc",
-						},
+						),
 					},
 					"zoxide": {
-						PostInstall: []string{
+						PostInstall: config.Commands(
 							"echo 'eval \"$(zoxide init bash)\"' >> ~/.bashrc",
 							"echo 'eval \"$(zoxide init zsh)\"' >> ~/.zshrc",
-						},
+						),
 					},
 					"fzf": {
-						PostInstall: []string{
+						PostInstall: config.Commands(
 							"$(brew --prefix)/opt/fzf/install --key-bindings --completion --no-update-rc",
-						},
+						),
 					},
 					"neovim": {
-						PostInstall: []string{
+						PostInstall: config.Commands(
 							"mkdir -p ~/.config/nvim",
 							"echo '-- Neovim configuration will be managed via stow' > ~/.config/nvim/init.lua",
-						},
+						),
 					},
 					"tmux": {
-						PostInstall: []string{
+						PostInstall: config.Commands(
 							"git clone https://github.com/tmux-plugins/tpm ~/.tmux/plugins/tpm || echo 'TPM already installed'",
-						},
+						),
 					},
 				},
 			},
@@ -322,14 +344,21 @@ var templatesCreateCmd = &cobra.Command{
 var templatesValidateCmd = &cobra.Command{
 	Use:   "validate <file>",
 	Short: "Validate a template file",
-	Long:  `Check if a template file is valid and can be applied`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Check if a template file is valid and can be applied. The file is checked
+against the schema printed by 'dotfiles schema template', and each problem is
+reported with its JSON path. Exits with status 2 when the template has problems
+and 1 when it can't be read.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		templateFile := args[0]
 
 		if err := validateTemplate(templateFile); err != nil {
-			fmt.Printf("❌ Template validation failed: %v\n", err)
-			os.Exit(1)
+			if isUnreadable(err) {
+				fail("Error reading template", err)
+			}
+			fmt.Println("❌ Template validation failed:")
+			printProblems(err)
+			os.Exit(ExitDrift)
 		}
 
 		fmt.Printf("✅ Template '%s' is valid!\n", templateFile)
@@ -412,16 +441,32 @@ func handleTemplateClone(templateName string, merge bool) error {
 	fmt.Printf("📝 Description: %s\n", template.Metadata.Description)
 	fmt.Println()
 
-	if !askConfirmation("Apply this template? (y/N): ", false) {
-		return fmt.Errorf("template application cancelled")
-	}
+	configPath := appCtx.ConfigPath()
 
-	home, err := os.UserHomeDir()
+	// Hooks come from the configuration the template is applied to
+	currentCfg, err := config.Load(configPath)
 	if err != nil {
-		return fmt.Errorf("error getting home directory: %v", err)
+		fmt.Printf("⚠️  Could not load current configuration, skipping hooks: %v\n", err)
+		currentCfg = nil
 	}
 
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	// Built-in templates ship with dotfiles, so their hooks are trusted
+	hookSource := "template:" + templateName
+	base := &config.Config{}
+	if merge && currentCfg != nil {
+		base = currentCfg
+	}
+	printIncomingHooks(base, &template.Config, hookSource, true)
+
+	if !askConfirmation("Apply this template? (y/N): ", false) {
+		return fmt.Errorf("template application cancelled")
+	}
+	// Hooks fire from every layer of the configuration being changed
+	hookCfg := loadHookConfig()
+	payload := hooks.Payload{Event: hooks.TemplateApply, Phase: hooks.Pre, Template: templateName}
+	if err := fireHooks(hookCfg, payload); err != nil {
+		return fmt.Errorf("pre-template-apply hook failed: %v", err)
+	}
 
 	if merge {
 		// Load existing config and merge
@@ -436,6 +481,7 @@ func handleTemplateClone(templateName string, merge bool) error {
 		existingConfig.Brews = mergeSlices(existingConfig.Brews, template.Brews)
 		existingConfig.Casks = mergeSlices(existingConfig.Casks, template.Casks)
 		existingConfig.Stow = mergeSlices(existingConfig.Stow, template.Stow)
+		importHooks(existingConfig, &template.Config, hookSource, true)
 
 		if err := existingConfig.Save(configPath); err != nil {
 			return fmt.Errorf("error saving merged config: %v", err)
@@ -449,6 +495,10 @@ func handleTemplateClone(templateName string, merge bool) error {
 			Casks: template.Casks,
 			Stow:  template.Stow,
 		}
+		if currentCfg != nil {
+			newConfig.TrustedSources = currentCfg.TrustedSources
+		}
+		importHooks(newConfig, &template.Config, hookSource, true)
 
 		if err := newConfig.Save(configPath); err != nil {
 			return fmt.Errorf("error saving config: %v", err)
@@ -456,6 +506,11 @@ func handleTemplateClone(templateName string, merge bool) error {
 		fmt.Println("✅ Template applied successfully!")
 	}
 
+	payload.Phase = hooks.Post
+	if err := fireHooks(hookCfg, payload); err != nil {
+		fmt.Printf("⚠️  Post-template-apply hook failed: %v\n", err)
+	}
+
 	fmt.Println("💡 Next steps:")
 	fmt.Println("  dotfiles status    # Check what needs to be installed")
 	fmt.Println("  dotfiles install   # Install all packages")
@@ -464,13 +519,8 @@ func handleTemplateClone(templateName string, merge bool) error {
 }
 
 func createCustomTemplate(name, description, author, tagsList, baseTemplate string, addOnly bool) (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("error getting home directory: %v", err)
-	}
-
 	// Load current configuration
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	configPath := appCtx.ConfigPath()
 	currentConfig, err := config.Load(configPath)
 	if err != nil {
 		return "", fmt.Errorf("error loading current config: %v", err)
@@ -523,7 +573,7 @@ func createCustomTemplate(name, description, author, tagsList, baseTemplate stri
 	}
 
 	// Save template to ~/.dotfiles/templates/
-	templatesDir := filepath.Join(home, ".dotfiles", "templates")
+	templatesDir := appCtx.TemplatesDir()
 	if err := os.MkdirAll(templatesDir, 0755); err != nil {
 		return "", fmt.Errorf("error creating templates directory: %v", err)
 	}
@@ -549,32 +599,28 @@ func createCustomTemplate(name, description, author, tagsList, baseTemplate stri
 
 func validateTemplate(templateFile string) error {
 	// Check if file exists
-	if _, err := os.Stat(templateFile); os.IsNotExist(err) {
-		return fmt.Errorf("template file does not exist: %s", templateFile)
+	if _, err := os.Stat(templateFile); err != nil {
+		return err
 	}
 
-	// Try to parse as extended template first
-	file, err := os.Open(templateFile)
-	if err != nil {
-		return fmt.Errorf("error opening template file: %v", err)
+	if err := config.ValidateFile(templateFile, templateSchema()); err != nil {
+		return err
 	}
-	defer file.Close()
 
+	data, err := os.ReadFile(templateFile)
+	if err != nil {
+		return err
+	}
 	var extTemplate ExtendedTemplate
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&extTemplate); err != nil {
-		// Try parsing as regular ShareableConfig
-		file.Seek(0, 0)
-		var template ShareableConfig
-		if err := json.NewDecoder(file).Decode(&template); err != nil {
-			return fmt.Errorf("invalid template format: %v", err)
-		}
-		return validateShareableConfig(template)
+	if err := json.Unmarshal(data, &extTemplate); err != nil {
+		return fmt.Errorf("invalid template format: %v", err)
 	}
 
-	// Validate extended template
-	if err := validateExtendedTemplate(extTemplate); err != nil {
-		return err
+	// Validate inheritance
+	if extTemplate.Extends != "" {
+		if _, exists := configTemplates[extTemplate.Extends]; !exists {
+			return fmt.Errorf("$.extends: base template '%s' not found", extTemplate.Extends)
+		}
 	}
 
 	fmt.Println("📋 Template validation passed:")
@@ -589,50 +635,6 @@ func validateTemplate(templateFile string) error {
 	return nil
 }
 
-func validateExtendedTemplate(template ExtendedTemplate) error {
-	// Validate base ShareableConfig
-	if err := validateShareableConfig(template.ShareableConfig); err != nil {
-		return err
-	}
-
-	// Validate inheritance
-	if template.Extends != "" {
-		if _, exists := configTemplates[template.Extends]; !exists {
-			return fmt.Errorf("base template '%s' not found", template.Extends)
-		}
-	}
-
-	return nil
-}
-
-func validateShareableConfig(config ShareableConfig) error {
-	// Check required metadata
-	if config.Metadata.Name == "" {
-		return fmt.Errorf("template name is required")
-	}
-
-	if config.Metadata.Description == "" {
-		return fmt.Errorf("template description is required")
-	}
-
-	if config.Metadata.Author == "" {
-		return fmt.Errorf("template author is required")
-	}
-
-	// Validate package names (basic check)
-	allPackages := append(append(append(config.Brews, config.Casks...), config.Taps...), config.Stow...)
-	for _, pkg := range allPackages {
-		if strings.TrimSpace(pkg) == "" {
-			return fmt.Errorf("empty package name found")
-		}
-		if strings.Contains(pkg, " ") {
-			return fmt.Errorf("invalid package name (contains spaces): %s", pkg)
-		}
-	}
-
-	return nil
-}
-
 func resolveTemplateInheritance(templateName string) (*ShareableConfig, error) {
 	// Check if it's a built-in template
 	if template, exists := configTemplates[templateName]; exists {
@@ -640,12 +642,7 @@ func resolveTemplateInheritance(templateName string) (*ShareableConfig, error) {
 	}
 
 	// Try loading from custom templates
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("error getting home directory: %v", err)
-	}
-
-	templateFile := filepath.Join(home, ".dotfiles", "templates", templateName+".json")
+	templateFile := filepath.Join(appCtx.TemplatesDir(), templateName+".json")
 	file, err := os.Open(templateFile)
 	if err != nil {
 		return nil, fmt.Errorf("template not found: %s", templateName)
@@ -703,6 +700,11 @@ func pushTemplateToAPI(templateFile string, public, featured bool) error {
 		return fmt.Errorf("failed to parse template: %v", err)
 	}
 
+	// Template files are written by hand and can hold tokens in hooks or variables
+	if err := scanShareable(filepath.Base(templateFile), template.ShareableConfig); err != nil {
+		return fmt.Errorf("not pushing template: %v", err)
+	}
+
 	template.Public = public
 	template.Featured = featured
 
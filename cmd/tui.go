@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"dotfiles/internal/config"
 	tea "github.com/charmbracelet/bubbletea"
@@ -53,8 +52,7 @@ type model struct {
 }
 
 func initialModel() model {
-	home, _ := os.UserHomeDir()
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	configPath := appCtx.ConfigPath()
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		cfg = &config.Config{
@@ -188,8 +186,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case "s":
 			// Save and quit
-			home, _ := os.UserHomeDir()
-			configPath := filepath.Join(home, ".dotfiles", "config.json")
+			configPath := appCtx.ConfigPath()
 			if err := m.config.Save(configPath); err != nil {
 				m.message = fmt.Sprintf("Error saving: %v", err)
 			} else {
@@ -136,41 +136,41 @@ func (k keyMap) FullHelp() [][]key.Binding {
 }
 
 type enhancedModel struct {
-	cursor          int
-	selected        map[int]bool
-	packages        []packageItem
+	cursor           int
+	selected         map[int]bool
+	packages         []packageItem
 	filteredPackages []packageItem
-	snapshots       []Snapshot
-	profiles        []MachineProfile
-	config          *config.Config
-	currentTab      tab
-	sortMode        sortMode
-	searchMode      bool
-	searchInput     textinput.Model
-	commandMode     bool
-	commandInput    textinput.Model
-	message         string
-	messageType     string // "success", "error", "warning", "info"
-	quitting        bool
-	showHelp        bool
-	help            help.Model
-	spinner         spinner.Model
-	loading         bool
-	detailsVisible  bool
-	width           int
-	height          int
-	stats           Stats
+	snapshots        []Snapshot
+	profiles         []MachineProfile
+	config           *config.Config
+	currentTab       tab
+	sortMode         sortMode
+	searchMode       bool
+	searchInput      textinput.Model
+	commandMode      bool
+	commandInput     textinput.Model
+	message          string
+	messageType      string // "success", "error", "warning", "info"
+	quitting         bool
+	showHelp         bool
+	help             help.Model
+	spinner          spinner.Model
+	loading          bool
+	detailsVisible   bool
+	width            int
+	height           int
+	stats            Stats
 }
 
 type Stats struct {
-	TotalPackages     int
-	InstalledPackages int
+	TotalPackages      int
+	InstalledPackages  int
 	ConfiguredPackages int
-	DiskSpaceUsed     string
-	LastSync          string
-	DriftCount        int
-	PackageManager    string
-	OperatingSystem   string
+	DiskSpaceUsed      string
+	LastSync           string
+	DriftCount         int
+	PackageManager     string
+	OperatingSystem    string
 }
 
 type packageDetails struct {
@@ -184,8 +184,7 @@ type packageDetails struct {
 }
 
 func enhancedInitialModel() enhancedModel {
-	home, _ := os.UserHomeDir()
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	configPath := appCtx.ConfigPath()
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		cfg = &config.Config{
@@ -234,20 +233,20 @@ func enhancedInitialModel() enhancedModel {
 	s.Spinner = spinner.Dot
 
 	return enhancedModel{
-		cursor:          0,
-		selected:        make(map[int]bool),
-		packages:        packages,
+		cursor:           0,
+		selected:         make(map[int]bool),
+		packages:         packages,
 		filteredPackages: packages,
-		snapshots:       snapshots,
-		profiles:        profiles,
-		config:          cfg,
-		currentTab:      packagesTab,
-		sortMode:        sortByName,
-		searchInput:     searchInput,
-		commandInput:    commandInput,
-		help:            help.New(),
-		spinner:         s,
-		stats:           stats,
+		snapshots:        snapshots,
+		profiles:         profiles,
+		config:           cfg,
+		currentTab:       packagesTab,
+		sortMode:         sortByName,
+		searchInput:      searchInput,
+		commandInput:     commandInput,
+		help:             help.New(),
+		spinner:          s,
+		stats:            stats,
 	}
 }
 
@@ -304,8 +303,7 @@ func buildPackageList(cfg *config.Config, installedBrews, installedCasks []strin
 }
 
 func loadSnapshots() []Snapshot {
-	home, _ := os.UserHomeDir()
-	snapshotsDir := filepath.Join(home, ".dotfiles", "snapshots")
+	snapshotsDir := appCtx.SnapshotsDir()
 
 	snapshots := []Snapshot{}
 	if _, err := os.Stat(snapshotsDir); os.IsNotExist(err) {
@@ -339,8 +337,7 @@ func loadSnapshots() []Snapshot {
 }
 
 func loadProfiles() []MachineProfile {
-	home, _ := os.UserHomeDir()
-	profilesDir := filepath.Join(home, ".dotfiles", "profiles")
+	profilesDir := appCtx.ProfilesDir()
 
 	profiles := []MachineProfile{}
 	if _, err := os.Stat(profilesDir); os.IsNotExist(err) {
@@ -523,8 +520,7 @@ func (m enhancedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case key.Matches(msg, keys.Save):
-			home, _ := os.UserHomeDir()
-			configPath := filepath.Join(home, ".dotfiles", "config.json")
+			configPath := appCtx.ConfigPath()
 			if err := m.config.Save(configPath); err != nil {
 				m.setMessage(fmt.Sprintf("Error saving: %v", err), "error")
 			} else {
@@ -779,7 +775,7 @@ func (m enhancedModel) renderHooksView() string {
 
 	s.WriteString(headerStyle.Render("Configured Hooks") + "\n\n")
 
-	hasGlobalHooks := m.config.Hooks != nil && !isHooksEmpty(m.config.Hooks)
+	hasGlobalHooks := m.config.Hooks != nil && !m.config.Hooks.IsEmpty()
 	hasPackageHooks := m.config.PackageConfigs != nil && len(m.config.PackageConfigs) > 0
 
 	if !hasGlobalHooks && !hasPackageHooks {
@@ -793,23 +789,12 @@ func (m enhancedModel) renderHooksView() string {
 	if hasGlobalHooks {
 		s.WriteString(lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212")).Render("  Global Hooks:") + "\n\n")
 
-		hooks := []struct {
-			name  string
-			hooks []string
-		}{
-			{"Pre-Install", m.config.Hooks.PreInstall},
-			{"Post-Install", m.config.Hooks.PostInstall},
-			{"Pre-Sync", m.config.Hooks.PreSync},
-			{"Post-Sync", m.config.Hooks.PostSync},
-			{"Pre-Stow", m.config.Hooks.PreStow},
-			{"Post-Stow", m.config.Hooks.PostStow},
-		}
-
-		for _, h := range hooks {
-			if len(h.hooks) > 0 {
-				s.WriteString(fmt.Sprintf("  📌 %s:\n", h.name))
-				for i, hook := range h.hooks {
-					s.WriteString(fmt.Sprintf("     %d. %s\n", i, hook))
+		for _, hookType := range config.HookTypes() {
+			hooks := *m.config.Hooks.For(hookType)
+			if len(hooks) > 0 {
+				s.WriteString(fmt.Sprintf("  📌 %s:\n", hookType))
+				for i, hook := range hooks {
+					s.WriteString(fmt.Sprintf("     %d. %s\n", i, describeHook(hook)))
 				}
 				s.WriteString("\n")
 			}
@@ -827,14 +812,14 @@ func (m enhancedModel) renderHooksView() string {
 				if len(pkgConfig.PreInstall) > 0 {
 					s.WriteString("     Pre-Install:\n")
 					for i, hook := range pkgConfig.PreInstall {
-						s.WriteString(fmt.Sprintf("       %d. %s\n", i, hook))
+						s.WriteString(fmt.Sprintf("       %d. %s\n", i, describeHook(hook)))
 					}
 				}
 
 				if len(pkgConfig.PostInstall) > 0 {
 					s.WriteString("     Post-Install:\n")
 					for i, hook := range pkgConfig.PostInstall {
-						s.WriteString(fmt.Sprintf("       %d. %s\n", i, hook))
+						s.WriteString(fmt.Sprintf("       %d. %s\n", i, describeHook(hook)))
 					}
 				}
 				s.WriteString("\n")
@@ -1079,9 +1064,8 @@ func (m *enhancedModel) removeSelectedFromConfig() int {
 }
 
 func (m *enhancedModel) restoreSnapshot(timestamp string) {
-	home, _ := os.UserHomeDir()
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
-	snapshotPath := filepath.Join(home, ".dotfiles", "snapshots", timestamp+".json")
+	configPath := appCtx.ConfigPath()
+	snapshotPath := filepath.Join(appCtx.SnapshotsDir(), timestamp+".json")
 
 	data, err := os.ReadFile(snapshotPath)
 	if err != nil {
@@ -1105,8 +1089,7 @@ func (m *enhancedModel) restoreSnapshot(timestamp string) {
 }
 
 func (m *enhancedModel) importProfile(profile MachineProfile) {
-	home, _ := os.UserHomeDir()
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	configPath := appCtx.ConfigPath()
 
 	// Merge with existing config
 	m.config.Brews = mergeUnique(m.config.Brews, profile.Config.Brews)
@@ -1114,11 +1097,20 @@ func (m *enhancedModel) importProfile(profile MachineProfile) {
 	m.config.Taps = mergeUnique(m.config.Taps, profile.Config.Taps)
 	m.config.Stow = mergeUnique(m.config.Stow, profile.Config.Stow)
 
+	// Hooks from profiles are quarantined unless their source is trusted
+	source := "profile:" + profile.Name
+	quarantine := !m.config.IsTrustedSource(source)
+	added := m.config.ImportHooks(profile.Config, source, quarantine)
+
 	if err := m.config.Save(configPath); err != nil {
 		m.setMessage(fmt.Sprintf("Error importing: %v", err), "error")
 		return
 	}
 
+	if quarantine && len(added) > 0 {
+		m.setMessage(fmt.Sprintf("Imported profile: %s (%d hook(s) quarantined, run 'dotfiles hooks trust')", profile.Name, len(added)), "success")
+		return
+	}
 	m.setMessage(fmt.Sprintf("Imported profile: %s", profile.Name), "success")
 }
 
@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
 	"dotfiles/internal/config"
+	"dotfiles/internal/installer"
 	"dotfiles/internal/pkgmanager"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -46,18 +46,20 @@ const (
 // Advanced TUI model - lazygit inspired
 type advancedModel struct {
 	// Layout
-	width        int
-	height       int
-	activePanel  panel
-	currentView  view
+	width       int
+	height      int
+	activePanel panel
+	currentView view
 
 	// State
-	cursor       int
-	selected     map[int]bool
-	searchMode   bool
-	searchQuery  string
-	installing   bool
-	installLog   []string
+	cursor        int
+	selected      map[int]bool
+	searchMode    bool
+	searchQuery   string
+	installing    bool
+	installLog    []string
+	installEvents <-chan installer.Event
+	installFailed int
 
 	// Data
 	packages     []packageItem
@@ -70,15 +72,15 @@ type advancedModel struct {
 	pmName       string
 
 	// Status
-	message      string
-	messageType  string
-	quitting     bool
-	lastUpdate   time.Time
+	message     string
+	messageType string
+	quitting    bool
+	lastUpdate  time.Time
 }
 
 // Messages for async operations
 type installCompleteMsg struct{ err error }
-type installProgressMsg struct{ line string }
+type installEventMsg struct{ event installer.Event }
 
 // Styles - more defined than before
 var (
@@ -129,7 +131,7 @@ var (
 			Bold(true)
 
 	selectedStyleNew = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("212"))
+				Foreground(lipgloss.Color("212"))
 
 	installedStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("42"))
@@ -162,8 +164,7 @@ var (
 )
 
 func newAdvancedModel() advancedModel {
-	home, _ := os.UserHomeDir()
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	configPath := appCtx.ConfigPath()
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		cfg = &config.Config{
@@ -252,12 +253,16 @@ func (m advancedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case installProgressMsg:
-		m.installLog = append(m.installLog, msg.line)
+	case installEventMsg:
+		// Same events as 'dotfiles install' prints
+		if msg.event.Type == installer.EventFailed {
+			m.installFailed++
+		}
+		m.installLog = append(m.installLog, formatInstallEvent(msg.event))
 		if len(m.installLog) > 20 {
 			m.installLog = m.installLog[1:]
 		}
-		return m, nil
+		return m, waitForInstallEvent(m.installEvents, m.installFailed)
 
 	case tea.KeyMsg:
 		// Search mode
@@ -999,54 +1004,69 @@ func (m *advancedModel) applyTemplate(tmpl TemplateMetadata) tea.Cmd {
 func (m *advancedModel) runInstall() tea.Cmd {
 	m.installing = true
 	m.installLog = []string{"Starting installation..."}
+	m.installFailed = 0
 
-	return func() tea.Msg {
-		// Generate install file
-		if m.pm == nil {
-			return installCompleteMsg{err: fmt.Errorf("no package manager available")}
-		}
-
-		fileContent, err := m.pm.GenerateInstallFile(m.config.Brews, m.config.Casks, m.config.Taps)
-		if err != nil {
-			return installCompleteMsg{err: err}
+	sets, _ := configuredManagers(m.config)
+	var usable []managerSet
+	for _, set := range sets {
+		if set.Count() > 0 && set.Available() {
+			usable = append(usable, set)
 		}
-
-		fileName := "Brewfile"
-		if m.pmName != "homebrew" {
-			fileName = "packages.txt"
-		}
-
-		filePath := "./" + fileName
-		if err := os.WriteFile(filePath, []byte(fileContent), 0644); err != nil {
-			return installCompleteMsg{err: err}
+	}
+	if len(usable) == 0 {
+		return func() tea.Msg {
+			return installCompleteMsg{err: fmt.Errorf("no package manager available")}
 		}
+	}
 
-		// Install packages
-		var cmd *exec.Cmd
-		if m.pmName == "homebrew" {
-			cmd = exec.Command("brew", "bundle", "--file="+filePath)
-		} else if m.pmName == "pacman" {
-			// Read packages and install with yay/pacman
-			packages := m.config.Brews
-			packages = append(packages, m.config.Casks...)
-			if len(packages) > 0 {
-				args := append([]string{"-S", "--noconfirm"}, packages...)
-				if commandExists("yay") {
-					cmd = exec.Command("yay", args...)
-				} else {
-					cmd = exec.Command("sudo", append([]string{"pacman"}, args...)...)
+	for _, set := range usable {
+		if set.Primary {
+			if content, err := set.Manager.GenerateInstallFile(set.Brews, set.Casks, set.Taps); err == nil && content != "" {
+				fileName := "Brewfile"
+				if set.Name != "homebrew" {
+					fileName = "packages.txt"
 				}
+				os.WriteFile("./"+fileName, []byte(content), 0644)
 			}
 		}
+	}
 
-		if cmd != nil {
-			output, err := cmd.CombinedOutput()
-			if err != nil {
-				return installCompleteMsg{err: fmt.Errorf("%v: %s", err, string(output))}
+	// Run the installer in the background and feed its events to Update
+	events := make(chan installer.Event)
+	m.installEvents = events
+	jobs := installJobs(usable, m.config, nil)
+	// The install journal goes to state/, its save errors reach the UI as events
+	appCtx.EnsureStateDir()
+	journal, err := installer.LoadJournal(appCtx.InstallJournalPath())
+	if err != nil {
+		journal = installer.NewJournal(appCtx.InstallJournalPath())
+	}
+	journal.Restart()
+	go func() {
+		defer close(events)
+		installer.Run(jobs, installer.Options{
+			Journal: journal,
+			OnEvent: func(event installer.Event) {
+				events <- event
+			},
+		})
+	}()
+
+	return waitForInstallEvent(events, 0)
+}
+
+// waitForInstallEvent waits for the next install event, reporting completion
+// once the installer closes the channel
+func waitForInstallEvent(events <-chan installer.Event, failed int) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			if failed > 0 {
+				return installCompleteMsg{err: fmt.Errorf("%d package(s) failed, run 'dotfiles install --resume' to retry", failed)}
 			}
+			return installCompleteMsg{}
 		}
-
-		return installCompleteMsg{err: nil}
+		return installEventMsg{event: event}
 	}
 }
 
@@ -6,6 +6,8 @@ import (
 	"os/exec"
 	"strings"
 
+	"dotfiles/internal/hooks"
+	"dotfiles/internal/snapshot"
 	"github.com/spf13/cobra"
 )
 
@@ -30,8 +32,7 @@ Examples:
 
 		// Create snapshot before update (unless --no-snapshot or --dry-run)
 		if !dryRun && !noSnapshot {
-			snapshotCmd := exec.Command("dotfiles", "snapshot", "auto")
-			snapshotCmd.Run()
+			snapshot.CreateAutoSnapshot(appCtx.Root, "Before package update")
 		}
 
 		// Check if brew is available
@@ -45,6 +46,25 @@ Examples:
 		fmt.Println("=" + strings.Repeat("=", 22))
 		fmt.Println()
 
+		cfg := loadHookConfig()
+		payload := hooks.Payload{Event: hooks.Update, Phase: hooks.Pre, Packages: args}
+		if !dryRun {
+			if err := fireHooks(cfg, payload); err != nil {
+				fmt.Printf("❌ Pre-update hook failed: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		runPostHooks := func() {
+			if dryRun {
+				return
+			}
+			payload.Phase = hooks.Post
+			if err := fireHooks(cfg, payload); err != nil {
+				fmt.Printf("⚠️  Post-update hook failed: %v\n", err)
+			}
+		}
+
 		// Step 1: Update Homebrew itself
 		if !skipBrewUpdate {
 			fmt.Println("🍺 Updating Homebrew...")
@@ -64,6 +84,7 @@ Examples:
 		}
 
 		if brewOnly {
+			runPostHooks()
 			fmt.Println("🎉 Homebrew update complete!")
 			return
 		}
@@ -74,6 +95,7 @@ Examples:
 
 		if len(outdated) == 0 {
 			fmt.Println("✅ All packages are up to date!")
+			runPostHooks()
 			return
 		}
 
@@ -116,6 +138,8 @@ Examples:
 			}
 		}
 
+		runPostHooks()
+
 		fmt.Println()
 		fmt.Println("🎉 Update complete!")
 		fmt.Println()
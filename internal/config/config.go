@@ -1,40 +1,72 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
-	"path/filepath"
+	"reflect"
 )
 
 // Hooks represents pre/post commands for various operations
 type Hooks struct {
-	PreInstall  []string `json:"pre_install,omitempty"`
-	PostInstall []string `json:"post_install,omitempty"`
-	PreSync     []string `json:"pre_sync,omitempty"`
-	PostSync    []string `json:"post_sync,omitempty"`
-	PreStow     []string `json:"pre_stow,omitempty"`
-	PostStow    []string `json:"post_stow,omitempty"`
+	PreInstall          []Hook `json:"pre_install,omitempty"`
+	PostInstall         []Hook `json:"post_install,omitempty"`
+	PreSync             []Hook `json:"pre_sync,omitempty"`
+	PostSync            []Hook `json:"post_sync,omitempty"`
+	PreStow             []Hook `json:"pre_stow,omitempty"`
+	PostStow            []Hook `json:"post_stow,omitempty"`
+	PreUnstow           []Hook `json:"pre_unstow,omitempty"`
+	PostUnstow          []Hook `json:"post_unstow,omitempty"`
+	PreUpdate           []Hook `json:"pre_update,omitempty"`
+	PostUpdate          []Hook `json:"post_update,omitempty"`
+	PreSnapshotRestore  []Hook `json:"pre_snapshot_restore,omitempty"`
+	PostSnapshotRestore []Hook `json:"post_snapshot_restore,omitempty"`
+	PreTemplateApply    []Hook `json:"pre_template_apply,omitempty"`
+	PostTemplateApply   []Hook `json:"post_template_apply,omitempty"`
 }
 
 // PackageConfig represents configuration for a specific package
 type PackageConfig struct {
-	PostInstall []string `json:"post_install,omitempty"`
-	PreInstall  []string `json:"pre_install,omitempty"`
+	PostInstall []Hook `json:"post_install,omitempty"`
+	PreInstall  []Hook `json:"pre_install,omitempty"`
 }
 
 // Config represents the dotfiles configuration
 type Config struct {
-	Brews          []string                 `json:"brews"`
-	Casks          []string                 `json:"casks"`
-	Taps           []string                 `json:"taps"`
-	Stow           []string                 `json:"stow"`
-	Hooks          *Hooks                   `json:"hooks,omitempty"`
-	PackageConfigs map[string]PackageConfig `json:"package_configs,omitempty"`
-	Groups         map[string][]string      `json:"groups,omitempty"`         // Package groups/tags
-	PackageTags    map[string][]string      `json:"package_tags,omitempty"`   // Tags per package
+	Schema            string                            `json:"$schema,omitempty"` // JSON Schema for editors, see 'dotfiles schema config'
+	Version           int                               `json:"version,omitempty"` // Schema version, see CurrentVersion
+	Brews             []string                          `json:"brews"`
+	Casks             []string                          `json:"casks"`
+	Taps              []string                          `json:"taps"`
+	Stow              []string                          `json:"stow"`
+	Hooks             *Hooks                            `json:"hooks,omitempty"`
+	PackageConfigs    map[string]PackageConfig          `json:"package_configs,omitempty"`
+	Groups            map[string][]string               `json:"groups,omitempty"`             // Package groups/tags
+	PackageTags       map[string][]string               `json:"package_tags,omitempty"`       // Tags per package
+	PackageMappings   map[string]map[string]string      `json:"package_mappings,omitempty"`   // Package names per package manager
+	Packages          map[string][]string               `json:"packages,omitempty"`           // Extra packages per package manager, e.g. "flatpak"
+	PackageVersions   map[string]string                 `json:"package_versions,omitempty"`   // Version constraints per package, e.g. ">=2.40"
+	Variables         map[string]interface{}            `json:"variables,omitempty"`          // Data for .tmpl dotfiles, e.g. {{ .Vars.email }}
+	HostVariables     map[string]map[string]interface{} `json:"host_variables,omitempty"`     // Variable overrides per hostname pattern
+	TrustedSources    []string                          `json:"trusted_sources,omitempty"`    // Hook sources imported without quarantine, e.g. "https://gist.github.com/me/*"
+	SnapshotRetention *SnapshotRetention                `json:"snapshot_retention,omitempty"` // Which snapshots 'dotfiles snapshot prune' keeps
 }
 
-// Load reads configuration from JSON file
+// SnapshotRetention decides which snapshots pruning keeps. A snapshot any
+// rule keeps is kept, and tagged snapshots are never removed.
+type SnapshotRetention struct {
+	KeepLast   int `json:"keep_last,omitempty"`   // The newest snapshots
+	KeepDaily  int `json:"keep_daily,omitempty"`  // The newest snapshot of each of the last days with snapshots
+	KeepWeekly int `json:"keep_weekly,omitempty"` // The newest snapshot of each of the last weeks with snapshots
+}
+
+// IsEmpty reports whether the retention keeps nothing, i.e. sets no rule
+func (r SnapshotRetention) IsEmpty() bool {
+	return r.KeepLast <= 0 && r.KeepDaily <= 0 && r.KeepWeekly <= 0
+}
+
+// Load reads configuration from a JSON, YAML or TOML file, detected from
+// the extension
 func Load(configPath string) (*Config, error) {
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return &Config{
@@ -45,7 +77,7 @@ func Load(configPath string) (*Config, error) {
 		}, nil
 	}
 
-	data, err := os.ReadFile(configPath)
+	data, err := readFile(configPath)
 	if err != nil {
 		return nil, err
 	}
@@ -54,24 +86,58 @@ func Load(configPath string) (*Config, error) {
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
+	if err := checkFields(configPath, data, reflect.TypeOf(cfg)); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
 
-// Save writes configuration to JSON file
+// Save writes configuration in the format of the file's extension (.json,
+// .yaml or .toml). Comments and key order in an existing file are kept. A
+// file written by an older version is backed up to backups/ first.
 func (c *Config) Save(configPath string) error {
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(configPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := backupOutdated(configPath); err != nil {
+		return err
+	}
+	before, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	var previous *Config
+	if saveObserver != nil && before != nil {
+		previous, _ = Load(configPath)
+	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
-	if err != nil {
+	c.Version = CurrentVersion
+	if err := writeFile(configPath, c); err != nil {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	if saveObserver != nil {
+		after, err := os.ReadFile(configPath)
+		if err == nil && (before == nil || !bytes.Equal(before, after)) {
+			saveObserver(SaveEvent{Path: configPath, Before: before, After: after, Changes: Diff(previous, c)})
+		}
+	}
+	return nil
+}
+
+// SaveEvent describes a file Save changed
+type SaveEvent struct {
+	Path    string
+	Before  []byte // nil when the file didn't exist
+	After   []byte
+	Changes []Change
+}
+
+// saveObserver is called after every Save that changed a file
+var saveObserver func(SaveEvent)
+
+// ObserveSaves registers a function called after every Save that changed a
+// file, e.g. to record it in a journal
+func ObserveSaves(fn func(SaveEvent)) {
+	saveObserver = fn
 }
 
 // GenerateBrewfile creates a Brewfile from the configuration
@@ -103,7 +169,8 @@ func (c *Config) GenerateBrewfile() string {
 }
 
 // GetAllPackages returns all packages (brews + casks) as a single list
-// Useful for Linux package managers that don't distinguish between them
+// Useful for Linux package managers that don't distinguish between them.
+// Names are as configured; package managers resolve PackageMappings themselves.
 func (c *Config) GetAllPackages() []string {
 	all := make([]string, 0, len(c.Brews)+len(c.Casks))
 	all = append(all, c.Brews...)
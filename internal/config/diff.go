@@ -0,0 +1,83 @@
+package config
+
+// Change is what was added to and removed from one part of a
+// configuration, e.g. "brews", "groups.dev" or "hooks"
+type Change struct {
+	Section string   `json:"section"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Diff compares two configurations: package lists, packages per package
+// manager, hooks, groups and package tags. Either may be nil. Sections
+// that didn't change are left out.
+func Diff(from, to *Config) []Change {
+	if from == nil {
+		from = &Config{}
+	}
+	if to == nil {
+		to = &Config{}
+	}
+
+	var changes []Change
+	add := func(section string, before, after []string) {
+		change := Change{Section: section, Added: subtract(after, before), Removed: subtract(before, after)}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+
+	add("brews", from.Brews, to.Brews)
+	add("casks", from.Casks, to.Casks)
+	add("taps", from.Taps, to.Taps)
+	add("stow", from.Stow, to.Stow)
+	for _, manager := range unionKeys(from.Packages, to.Packages) {
+		add("packages."+manager, from.Packages[manager], to.Packages[manager])
+	}
+	add("hooks", hookEntries(from), hookEntries(to))
+	for _, group := range unionKeys(from.Groups, to.Groups) {
+		add("groups."+group, from.Groups[group], to.Groups[group])
+	}
+	for _, pkg := range unionKeys(from.PackageTags, to.PackageTags) {
+		add("package_tags."+pkg, from.PackageTags[pkg], to.PackageTags[pkg])
+	}
+	return changes
+}
+
+// hookEntries describes every hook as "<where>: <command>", e.g.
+// "post_install (git): git lfs install"
+func hookEntries(c *Config) []string {
+	var entries []string
+	for _, ref := range c.HookRefs() {
+		entries = append(entries, ref.String()+": "+ref.Hook.Command)
+	}
+	return entries
+}
+
+// subtract returns the entries of list that aren't in other, in list order
+func subtract(list, other []string) []string {
+	seen := make(map[string]bool, len(other))
+	for _, entry := range other {
+		seen[entry] = true
+	}
+	var result []string
+	for _, entry := range list {
+		if !seen[entry] {
+			result = append(result, entry)
+			seen[entry] = true
+		}
+	}
+	return result
+}
+
+// unionKeys returns the keys of both maps, sorted
+func unionKeys(a, b map[string][]string) []string {
+	set := make(map[string]bool)
+	for key := range a {
+		set[key] = true
+	}
+	for key := range b {
+		set[key] = true
+	}
+	return sortedKeys(set)
+}
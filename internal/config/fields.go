@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownField is a key in a configuration file that no field matches,
+// usually a typo such as "brew" for "brews"
+type UnknownField struct {
+	Path string // e.g. hooks.post_install[0].comand
+	Line int    // 0 when the line could not be found
+}
+
+// UnknownFieldsError lists the unknown fields of a file
+type UnknownFieldsError struct {
+	File   string
+	Fields []UnknownField
+}
+
+func (e *UnknownFieldsError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		if field.Line > 0 {
+			parts[i] = fmt.Sprintf("line %d: unknown field %q", field.Line, field.Path)
+		} else {
+			parts[i] = fmt.Sprintf("unknown field %q", field.Path)
+		}
+	}
+	return filepath.Base(e.File) + ": " + strings.Join(parts, "; ")
+}
+
+// checkFields reports the keys of a file that don't match a field of t.
+// data is the file as JSON (see readFile), after which the keys are looked
+// up in the original file for their line numbers. prefix is the key holding
+// the configuration, e.g. "config" in a profile.
+func checkFields(path string, data []byte, t reflect.Type, prefix ...string) error {
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return err
+	}
+	var v interface{} = doc
+	for _, key := range prefix {
+		v = doc[key]
+	}
+	if inner, ok := v.(map[string]interface{}); ok {
+		// Keys renamed by migrations are not unknown
+		if _, err := Migrate(inner); err != nil {
+			return err
+		}
+	}
+
+	paths := unknownPaths(v, t, prefix)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	lines := lineFinder(path)
+	fields := make([]UnknownField, len(paths))
+	for i, p := range paths {
+		fields[i] = UnknownField{Path: displayPath(p), Line: lines(p)}
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Line < fields[j].Line })
+	return &UnknownFieldsError{File: path, Fields: fields}
+}
+
+// unknownPaths walks a decoded document alongside the type it decodes into
+// and returns the path of every key no field matches. Slice indexes are
+// path elements such as "[2]".
+func unknownPaths(v interface{}, t reflect.Type, path []string) [][]string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var found [][]string
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return nil
+		}
+		// Anything else, e.g. a hook written as a plain command, is not a key
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(m) {
+			field, ok := jsonField(t, key)
+			if !ok {
+				found = append(found, childPath(path, key))
+				continue
+			}
+			found = append(found, unknownPaths(m[key], field.Type, childPath(path, key))...)
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(m) {
+			found = append(found, unknownPaths(m[key], t.Elem(), childPath(path, key))...)
+		}
+	case reflect.Slice:
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range items {
+			found = append(found, unknownPaths(item, t.Elem(), childPath(path, "["+strconv.Itoa(i)+"]"))...)
+		}
+	}
+	return found
+}
+
+// jsonField finds the struct field decoding key, matching case-insensitively
+// like encoding/json
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func childPath(path []string, key string) []string {
+	return append(append([]string{}, path...), key)
+}
+
+func displayPath(path []string) string {
+	var b strings.Builder
+	for _, part := range path {
+		if b.Len() > 0 && !strings.HasPrefix(part, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// lineFinder returns a function giving the line of a key path in the file
+func lineFinder(path string) func([]string) int {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return func([]string) int { return 0 }
+	}
+	data := string(raw)
+
+	if Format(path) == "toml" {
+		var lines []string
+		if strings.TrimSpace(data) != "" {
+			lines = strings.Split(data, "\n")
+		}
+		stmts, err := parseTOML(lines)
+		if err != nil {
+			return func([]string) int { return 0 }
+		}
+		return func(p []string) int { return tomlLine(stmts, p) }
+	}
+
+	// JSON is YAML, so both are located through yaml.v3 nodes
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil || len(doc.Content) == 0 {
+		return func([]string) int { return 0 }
+	}
+	return func(p []string) int { return nodeLine(doc.Content[0], p) }
+}
+
+// nodeLine returns the line of the deepest node along path
+func nodeLine(node *yaml.Node, path []string) int {
+	line := node.Line
+	for _, part := range path {
+		switch {
+		case node.Kind == yaml.SequenceNode && strings.HasPrefix(part, "["):
+			i, err := strconv.Atoi(strings.Trim(part, "[]"))
+			if err != nil || i >= len(node.Content) {
+				return line
+			}
+			node = node.Content[i]
+		case node.Kind == yaml.MappingNode:
+			found := false
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if strings.EqualFold(node.Content[i].Value, part) {
+					line = node.Content[i].Line
+					node = node.Content[i+1]
+					found = true
+					break
+				}
+			}
+			if !found {
+				return line
+			}
+			continue
+		default:
+			return line
+		}
+		line = node.Line
+	}
+	return line
+}
+
+// tomlLine returns the line of the statement that best matches path: the
+// key/value or table header sharing the longest prefix with it
+func tomlLine(stmts []tomlStmt, path []string) int {
+	var keys []string
+	for _, part := range path {
+		if !strings.HasPrefix(part, "[") {
+			keys = append(keys, part)
+		}
+	}
+
+	best, bestLen := 0, 0
+	for _, st := range stmts {
+		n := 0
+		for n < len(st.path) && n < len(keys) && st.path[n] == keys[n] {
+			n++
+		}
+		if n == len(st.path) && n > bestLen {
+			best, bestLen = st.start+1, n
+		}
+	}
+	return best
+}
@@ -0,0 +1,337 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions are the supported configuration file formats, in the order
+// FindFile looks for them
+var Extensions = []string{".json", ".yaml", ".yml", ".toml"}
+
+// FindFile returns the configuration file named stem in dir in whichever
+// supported format exists, e.g. config.yaml for stem "config". It returns
+// the .json path when none exists.
+func FindFile(dir, stem string) string {
+	for _, ext := range Extensions {
+		path := filepath.Join(dir, stem+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, stem+".json")
+}
+
+// Format returns the format of a configuration file from its extension:
+// "yaml", "toml" or "json"
+func Format(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return "json"
+}
+
+// readFile reads a configuration file in any supported format and returns
+// its content as JSON, so every format decodes through the json tags
+func readFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch Format(path) {
+	case "yaml":
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	case "toml":
+		var v map[string]interface{}
+		if err := toml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	}
+	return data, nil
+}
+
+// writeFile writes v to path in the format of its extension. When the file
+// exists its comments and key order are kept: changed values are updated in
+// place, new keys are appended and removed keys are dropped.
+func writeFile(path string, v interface{}) error {
+	node, err := orderedNode(v)
+	if err != nil {
+		return err
+	}
+	return writeNode(path, node)
+}
+
+// writeNode writes a node tree to path like writeFile
+func writeNode(path string, node *yaml.Node) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	data, err := encode(node, Format(path), existing)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// encode renders node in format, keeping the layout of existing
+func encode(node *yaml.Node, format string, existing []byte) ([]byte, error) {
+	switch format {
+	case "yaml":
+		return encodeYAML(node, existing)
+	case "toml":
+		return encodeTOML(node, existing)
+	}
+	return encodeJSON(node, existing)
+}
+
+// orderedNode converts v to a YAML node tree through its JSON encoding, so
+// keys keep the json tags and the struct field order
+func orderedNode(v interface{}) (*yaml.Node, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	root := doc.Content[0]
+	resetStyle(root)
+	return root, nil
+}
+
+// resetStyle drops the flow and quoting style a node got from JSON
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+// existingNode parses data as YAML (which includes JSON) and returns its
+// document node, or nil when data is empty or can't be parsed
+func existingNode(data []byte) *yaml.Node {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) != 1 {
+		return nil
+	}
+	return &doc
+}
+
+func encodeYAML(node *yaml.Node, existing []byte) ([]byte, error) {
+	out := node
+	if doc := existingNode(existing); doc != nil {
+		mergeNode(doc.Content[0], node)
+		out = doc
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeJSON writes node as indented JSON. JSON has no comments, but the
+// key order of an existing file is kept.
+func encodeJSON(node *yaml.Node, existing []byte) ([]byte, error) {
+	if doc := existingNode(existing); doc != nil {
+		mergeNode(doc.Content[0], node)
+		node = doc.Content[0]
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, node, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, node *yaml.Node, indent string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, _ := json.Marshal(node.Content[i].Value)
+			buf.WriteString(indent + "  ")
+			buf.Write(key)
+			buf.WriteString(": ")
+			if err := writeJSON(buf, node.Content[i+1], indent+"  "); err != nil {
+				return err
+			}
+			if i+2 < len(node.Content) {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, item := range node.Content {
+			buf.WriteString(indent + "  ")
+			if err := writeJSON(buf, item, indent+"  "); err != nil {
+				return err
+			}
+			if i+1 < len(node.Content) {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "]")
+	case yaml.AliasNode:
+		return writeJSON(buf, node.Alias, indent)
+	default:
+		var v interface{}
+		if err := node.Decode(&v); err != nil {
+			return err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	return nil
+}
+
+// mergeNode updates dst in place to hold the data of src, keeping the
+// comments, style and key order of dst. Keys missing from dst are inserted
+// after the key preceding them in src, keys missing from src are removed. Sequence items that are in both keep
+// their comments.
+func mergeNode(dst, src *yaml.Node) {
+	if dst.Kind != src.Kind {
+		replaceNode(dst, src)
+		return
+	}
+
+	switch dst.Kind {
+	case yaml.MappingNode:
+		content := make([]*yaml.Node, 0, len(src.Content))
+		kept := make(map[string]bool)
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			key, value := dst.Content[i], dst.Content[i+1]
+			if j := mappingIndex(src, key.Value); j >= 0 && !kept[key.Value] {
+				mergeNode(value, src.Content[j+1])
+				content = append(content, key, value)
+				kept[key.Value] = true
+			}
+		}
+		// New keys go after the key they follow in src, or first
+		at := 0
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key := src.Content[i].Value
+			if kept[key] {
+				at = mappingIndex(&yaml.Node{Content: content}, key) + 2
+				continue
+			}
+			// Unset values are not worth adding to a file that lacks them
+			if src.Content[i+1].ShortTag() == "!!null" {
+				continue
+			}
+			content = append(content[:at], append([]*yaml.Node{src.Content[i], src.Content[i+1]}, content[at:]...)...)
+			at += 2
+		}
+		dst.Content = content
+	case yaml.SequenceNode:
+		taken := make([]bool, len(dst.Content))
+		content := make([]*yaml.Node, 0, len(src.Content))
+		for _, item := range src.Content {
+			if i := matchItem(dst.Content, taken, item); i >= 0 {
+				taken[i] = true
+				mergeNode(dst.Content[i], item)
+				content = append(content, dst.Content[i])
+			} else {
+				content = append(content, item)
+			}
+		}
+		dst.Content = content
+	case yaml.ScalarNode:
+		if dst.Value != src.Value || dst.ShortTag() != src.ShortTag() {
+			replaceNode(dst, src)
+		}
+	default:
+		replaceNode(dst, src)
+	}
+}
+
+// replaceNode gives dst the value of src, keeping the comments of dst
+func replaceNode(dst, src *yaml.Node) {
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+}
+
+// mappingIndex returns the index of key in a mapping node, or -1
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchItem returns the first untaken item of items that is the same entry
+// as item: an equal scalar, or a hook with the same command
+func matchItem(items []*yaml.Node, taken []bool, item *yaml.Node) int {
+	id := itemID(item)
+	if id == "" {
+		return -1
+	}
+	for i, candidate := range items {
+		if !taken[i] && itemID(candidate) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func itemID(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return "scalar:" + node.Value
+	case yaml.MappingNode:
+		if i := mappingIndex(node, "command"); i >= 0 {
+			return "command:" + node.Content[i+1].Value
+		}
+	}
+	return ""
+}
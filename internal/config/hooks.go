@@ -0,0 +1,285 @@
+package config
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HookEvents lists the operations hooks can run around. Each event has a
+// pre_<event> and post_<event> hook type.
+var HookEvents = []string{"install", "sync", "stow", "unstow", "update", "snapshot_restore", "template_apply"}
+
+// Hook is a shell command run before or after an operation. In config.json a
+// hook is either a plain command string or an object with options:
+//
+//	"post_stow": [
+//	  "echo done",
+//	  {"command": "./reload.sh", "workdir": "scripts", "timeout": "30s",
+//	   "continue_on_error": true, "os": ["darwin"], "hosts": ["work-*"]}
+//	]
+type Hook struct {
+	Command         string      `json:"command"`
+	Timeout         string      `json:"timeout,omitempty"`           // Go duration such as "30s", defaults to 10m
+	WorkDir         string      `json:"workdir,omitempty"`           // Relative paths are resolved against the dotfiles root
+	ContinueOnError bool        `json:"continue_on_error,omitempty"` // Keep going when the hook fails
+	OS              []string    `json:"os,omitempty"`                // Only run on these operating systems (darwin/macos, linux)
+	Hosts           []string    `json:"hosts,omitempty"`             // Only run on hosts matching these glob patterns
+	Provenance      *Provenance `json:"provenance,omitempty"`        // Where the hook came from; hand-written hooks have none
+}
+
+// LocalSource is the provenance source of hooks added with 'dotfiles hooks add'
+const LocalSource = "local"
+
+// Provenance records where a hook came from. Hooks imported from a source
+// that is not trusted are quarantined and don't run until they are approved
+// with 'dotfiles hooks trust'.
+type Provenance struct {
+	Source      string     `json:"source"` // "local", "template:<name>", a URL or a file path
+	AddedAt     time.Time  `json:"added_at"`
+	Quarantined bool       `json:"quarantined,omitempty"`
+	TrustedAt   *time.Time `json:"trusted_at,omitempty"` // When a quarantined hook was approved
+}
+
+// Source returns where the hook came from, "local" for hand-written hooks
+func (h Hook) Source() string {
+	if h.Provenance == nil {
+		return LocalSource
+	}
+	return h.Provenance.Source
+}
+
+// Quarantined reports whether the hook is waiting to be trusted
+func (h Hook) Quarantined() bool {
+	return h.Provenance != nil && h.Provenance.Quarantined
+}
+
+// Trust lifts the hook's quarantine
+func (h *Hook) Trust() {
+	if h.Quarantined() {
+		now := time.Now()
+		h.Provenance.Quarantined = false
+		h.Provenance.TrustedAt = &now
+	}
+}
+
+// Commands turns plain commands into hooks
+func Commands(commands ...string) []Hook {
+	hooks := make([]Hook, 0, len(commands))
+	for _, command := range commands {
+		hooks = append(hooks, Hook{Command: command})
+	}
+	return hooks
+}
+
+// String returns the hook's command
+func (h Hook) String() string {
+	return h.Command
+}
+
+// IsPlain reports whether the hook only sets a command
+func (h Hook) IsPlain() bool {
+	return h.Timeout == "" && h.WorkDir == "" && !h.ContinueOnError && len(h.OS) == 0 && len(h.Hosts) == 0 && h.Provenance == nil
+}
+
+// UnmarshalJSON accepts a command string or a hook object
+func (h *Hook) UnmarshalJSON(data []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), `"`) {
+		*h = Hook{}
+		return json.Unmarshal(data, &h.Command)
+	}
+
+	type hook Hook
+	var decoded hook
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*h = Hook(decoded)
+	return nil
+}
+
+// MarshalJSON writes plain hooks as a command string
+func (h Hook) MarshalJSON() ([]byte, error) {
+	if h.IsPlain() {
+		return json.Marshal(h.Command)
+	}
+
+	type hook Hook
+	return json.Marshal(hook(h))
+}
+
+// For returns the hook list for a hook type such as "pre_stow", or nil when
+// the type is unknown
+func (h *Hooks) For(hookType string) *[]Hook {
+	switch hookType {
+	case "pre_install":
+		return &h.PreInstall
+	case "post_install":
+		return &h.PostInstall
+	case "pre_sync":
+		return &h.PreSync
+	case "post_sync":
+		return &h.PostSync
+	case "pre_stow":
+		return &h.PreStow
+	case "post_stow":
+		return &h.PostStow
+	case "pre_unstow":
+		return &h.PreUnstow
+	case "post_unstow":
+		return &h.PostUnstow
+	case "pre_update":
+		return &h.PreUpdate
+	case "post_update":
+		return &h.PostUpdate
+	case "pre_snapshot_restore":
+		return &h.PreSnapshotRestore
+	case "post_snapshot_restore":
+		return &h.PostSnapshotRestore
+	case "pre_template_apply":
+		return &h.PreTemplateApply
+	case "post_template_apply":
+		return &h.PostTemplateApply
+	}
+	return nil
+}
+
+// HookTypes returns every hook type in HookEvents order
+func HookTypes() []string {
+	var types []string
+	for _, event := range HookEvents {
+		types = append(types, "pre_"+event, "post_"+event)
+	}
+	return types
+}
+
+// IsEmpty reports whether no hooks are configured
+func (h *Hooks) IsEmpty() bool {
+	for _, hookType := range HookTypes() {
+		if len(*h.For(hookType)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// HookRef points at a hook in a configuration
+type HookRef struct {
+	Type    string // Hook type such as "post_install"
+	Package string // Set for package-specific hooks
+	Hook    *Hook
+}
+
+// String describes where the hook is configured, e.g. "post_install (git)"
+func (r HookRef) String() string {
+	if r.Package != "" {
+		return r.Type + " (" + r.Package + ")"
+	}
+	return r.Type
+}
+
+// HookRefs returns every configured hook: global hooks in HookTypes order,
+// then package hooks by package name
+func (c *Config) HookRefs() []HookRef {
+	var refs []HookRef
+	if c.Hooks != nil {
+		for _, hookType := range HookTypes() {
+			list := *c.Hooks.For(hookType)
+			for i := range list {
+				refs = append(refs, HookRef{Type: hookType, Hook: &list[i]})
+			}
+		}
+	}
+
+	packages := make([]string, 0, len(c.PackageConfigs))
+	for pkg := range c.PackageConfigs {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	for _, pkg := range packages {
+		pkgConfig := c.PackageConfigs[pkg]
+		for i := range pkgConfig.PreInstall {
+			refs = append(refs, HookRef{Type: "pre_install", Package: pkg, Hook: &pkgConfig.PreInstall[i]})
+		}
+		for i := range pkgConfig.PostInstall {
+			refs = append(refs, HookRef{Type: "post_install", Package: pkg, Hook: &pkgConfig.PostInstall[i]})
+		}
+	}
+	return refs
+}
+
+// NewHooks returns the hooks in src whose command c does not already have
+// for the same hook type and package
+func (c *Config) NewHooks(src *Config) []HookRef {
+	existing := make(map[string]bool)
+	for _, ref := range c.HookRefs() {
+		existing[ref.String()+"\x00"+ref.Hook.Command] = true
+	}
+
+	var refs []HookRef
+	for _, ref := range src.HookRefs() {
+		if ref.Hook.Command == "" || existing[ref.String()+"\x00"+ref.Hook.Command] {
+			continue
+		}
+		if ref.Package != "" && ref.Type != "pre_install" && ref.Type != "post_install" {
+			continue
+		}
+		existing[ref.String()+"\x00"+ref.Hook.Command] = true
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// ImportHooks adds the new hooks from src, recording source as their
+// provenance. Any provenance src claims is replaced. With quarantine set the
+// hooks won't run until trusted. It returns the hooks as added.
+func (c *Config) ImportHooks(src *Config, source string, quarantine bool) []Hook {
+	now := time.Now()
+
+	var added []Hook
+	for _, ref := range c.NewHooks(src) {
+		hook := *ref.Hook
+		hook.Provenance = &Provenance{Source: source, AddedAt: now, Quarantined: quarantine}
+
+		if ref.Package == "" {
+			if c.Hooks == nil {
+				c.Hooks = &Hooks{}
+			}
+			list := c.Hooks.For(ref.Type)
+			*list = append(*list, hook)
+		} else {
+			if c.PackageConfigs == nil {
+				c.PackageConfigs = make(map[string]PackageConfig)
+			}
+			pkgConfig := c.PackageConfigs[ref.Package]
+			if ref.Type == "pre_install" {
+				pkgConfig.PreInstall = append(pkgConfig.PreInstall, hook)
+			} else {
+				pkgConfig.PostInstall = append(pkgConfig.PostInstall, hook)
+			}
+			c.PackageConfigs[ref.Package] = pkgConfig
+		}
+		added = append(added, hook)
+	}
+	return added
+}
+
+// IsTrustedSource reports whether hooks from source are imported without
+// quarantine. Entries in trusted_sources match exactly, or as a prefix when
+// they end in *.
+func (c *Config) IsTrustedSource(source string) bool {
+	if source == LocalSource {
+		return true
+	}
+	for _, trusted := range c.TrustedSources {
+		if prefix, ok := strings.CutSuffix(trusted, "*"); ok {
+			if strings.HasPrefix(source, prefix) {
+				return true
+			}
+		} else if source == trusted {
+			return true
+		}
+	}
+	return false
+}
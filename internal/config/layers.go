@@ -0,0 +1,452 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// RemovePrefix marks an overlay entry that removes an entry inherited from
+// a lower layer, e.g. "!docker" in casks or "!ripgrep" in package_versions
+const RemovePrefix = "!"
+
+// Layer is one file of the configuration stack
+type Layer struct {
+	Name   string // base, os, host or profile
+	Path   string
+	Config *Config
+}
+
+// Label names the layer and its file, e.g. "os (config.darwin.json)"
+func (l Layer) Label() string {
+	return l.Name + " (" + filepath.Base(l.Path) + ")"
+}
+
+// Origin records what a layer did to an entry of the merged configuration
+type Origin struct {
+	Layer   Layer
+	Field   string // e.g. "casks", "packages.flatpak", "package_versions"
+	Value   string // Entry or map value as written in the layer
+	Removed bool
+}
+
+// Stack is the merged configuration of this machine: config.json, then
+// config.<os>.json, config.<hostname>.json and profiles/<profile>.json on top
+type Stack struct {
+	Layers []Layer // Layers that exist, lowest first
+	Config *Config // Merged configuration
+
+	origins map[string][]Origin // Keyed by entry name
+}
+
+// LayerPaths returns the files stacked on configPath for this machine,
+// lowest first. profile may be empty.
+func LayerPaths(configPath, profile string) []Layer {
+	dir := filepath.Dir(configPath)
+	layers := []Layer{
+		{Name: "base", Path: configPath},
+		{Name: "os", Path: FindFile(dir, "config."+runtime.GOOS)},
+	}
+
+	// The short hostname applies first, so config.<fqdn>.json can refine it
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		short := strings.SplitN(hostname, ".", 2)[0]
+		layers = append(layers, Layer{Name: "host", Path: FindFile(dir, "config."+short)})
+		if hostname != short {
+			layers = append(layers, Layer{Name: "host", Path: FindFile(dir, "config."+hostname)})
+		}
+	}
+
+	if profile != "" {
+		layers = append(layers, Layer{Name: "profile", Path: FindFile(filepath.Join(dir, "profiles"), profile)})
+	}
+	return layers
+}
+
+// LoadStack reads and merges the configuration stack. Missing overlay files
+// are skipped; a missing active profile is an error.
+func LoadStack(configPath, profile string) (*Stack, error) {
+	var layers []Layer
+	for _, layer := range LayerPaths(configPath, profile) {
+		if layer.Name == "base" {
+			cfg, err := Load(layer.Path)
+			if err != nil {
+				return nil, err
+			}
+			layer.Config = cfg
+		} else {
+			cfg, err := loadOverlay(layer.Path)
+			if os.IsNotExist(err) && layer.Name != "profile" {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			layer.Config = cfg
+		}
+		layers = append(layers, layer)
+	}
+	return mergeLayers(layers), nil
+}
+
+// mergeLayers stacks layers, lowest first
+func mergeLayers(layers []Layer) *Stack {
+	stack := &Stack{Layers: layers, origins: make(map[string][]Origin)}
+	stack.Config = &Config{Version: CurrentVersion, Brews: []string{}, Casks: []string{}, Taps: []string{}, Stow: []string{}}
+	for _, layer := range stack.Layers {
+		stack.apply(layer)
+	}
+	return stack
+}
+
+// Rebase returns the merged configuration with base in place of
+// config.json, e.g. what restoring a snapshot's config.json leads to on
+// this machine
+func (s *Stack) Rebase(base *Config) *Config {
+	layers := append([]Layer{}, s.Layers...)
+	layers[0].Config = base
+	return mergeLayers(layers).Config
+}
+
+// LoadMerged reads the configuration stack and returns the merged configuration
+func LoadMerged(configPath, profile string) (*Config, error) {
+	stack, err := LoadStack(configPath, profile)
+	if err != nil {
+		return nil, err
+	}
+	return stack.Config, nil
+}
+
+// loadOverlay reads an overlay file in any supported format. Profiles
+// exported with 'dotfiles export' wrap their configuration in a "config"
+// key; both forms are accepted.
+func loadOverlay(path string) (*Config, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var wrapped struct {
+		Config *Config `json:"config"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err == nil && wrapped.Config != nil {
+		if err := checkFields(path, data, reflect.TypeOf(Config{}), "config"); err != nil {
+			return nil, err
+		}
+		return wrapped.Config, nil
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if err := checkFields(path, data, reflect.TypeOf(cfg)); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// IsWrapped reports whether a file holds its configuration under a "config"
+// key, as profiles exported with 'dotfiles export' do
+func IsWrapped(path string) (bool, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return false, err
+	}
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return false, err
+	}
+	_, ok := doc["config"].(map[string]interface{})
+	return ok, nil
+}
+
+// Explain returns what each layer contributed for name: list entries, map
+// keys and groups, in layer order
+func (s *Stack) Explain(name string) []Origin {
+	return s.origins[name]
+}
+
+// Overlays reports whether any layer besides config.json is active
+func (s *Stack) Overlays() bool {
+	return len(s.Layers) > 1
+}
+
+// apply merges a layer into the stack's configuration
+func (s *Stack) apply(layer Layer) {
+	src, dst := layer.Config, s.Config
+
+	dst.Brews = s.mergeList(layer, "brews", dst.Brews, src.Brews)
+	dst.Casks = s.mergeList(layer, "casks", dst.Casks, src.Casks)
+	dst.Taps = s.mergeList(layer, "taps", dst.Taps, src.Taps)
+	dst.Stow = s.mergeList(layer, "stow", dst.Stow, src.Stow)
+
+	// Only config.json decides what is trusted, so an overlay such as an
+	// imported profile can't vouch for its own hooks
+	if layer.Name == "base" {
+		dst.TrustedSources = s.mergeList(layer, "trusted_sources", dst.TrustedSources, src.TrustedSources)
+	}
+
+	for _, manager := range sortedKeys(src.Packages) {
+		if dst.Packages == nil {
+			dst.Packages = make(map[string][]string)
+		}
+		if name, ok := removal(manager); ok {
+			delete(dst.Packages, name)
+			continue
+		}
+		dst.Packages[manager] = s.mergeList(layer, "packages."+manager, dst.Packages[manager], src.Packages[manager])
+	}
+
+	// Groups and tags are lists too; the entry explained is the package
+	for _, group := range sortedKeys(src.Groups) {
+		if dst.Groups == nil {
+			dst.Groups = make(map[string][]string)
+		}
+		if name, ok := removal(group); ok {
+			delete(dst.Groups, name)
+			continue
+		}
+		dst.Groups[group] = s.mergeList(layer, "groups."+group, dst.Groups[group], src.Groups[group])
+	}
+	for _, pkg := range sortedKeys(src.PackageTags) {
+		if dst.PackageTags == nil {
+			dst.PackageTags = make(map[string][]string)
+		}
+		if name, ok := removal(pkg); ok {
+			delete(dst.PackageTags, name)
+			s.record(name, Origin{Layer: layer, Field: "package_tags", Removed: true})
+			continue
+		}
+		dst.PackageTags[pkg] = mergeEntries(dst.PackageTags[pkg], src.PackageTags[pkg])
+		s.record(pkg, Origin{Layer: layer, Field: "package_tags", Value: strings.Join(src.PackageTags[pkg], ", ")})
+	}
+
+	for _, key := range sortedKeys(src.PackageVersions) {
+		if dst.PackageVersions == nil {
+			dst.PackageVersions = make(map[string]string)
+		}
+		if name, ok := removal(key); ok {
+			delete(dst.PackageVersions, name)
+			s.record(name, Origin{Layer: layer, Field: "package_versions", Removed: true})
+			continue
+		}
+		dst.PackageVersions[key] = src.PackageVersions[key]
+		s.record(key, Origin{Layer: layer, Field: "package_versions", Value: src.PackageVersions[key]})
+	}
+
+	for _, pkg := range sortedKeys(src.PackageMappings) {
+		if dst.PackageMappings == nil {
+			dst.PackageMappings = make(map[string]map[string]string)
+		}
+		if name, ok := removal(pkg); ok {
+			delete(dst.PackageMappings, name)
+			s.record(name, Origin{Layer: layer, Field: "package_mappings", Removed: true})
+			continue
+		}
+		if dst.PackageMappings[pkg] == nil {
+			dst.PackageMappings[pkg] = make(map[string]string)
+		}
+		for _, manager := range sortedKeys(src.PackageMappings[pkg]) {
+			field := "package_mappings." + strings.TrimPrefix(manager, RemovePrefix)
+			if name, ok := removal(manager); ok {
+				delete(dst.PackageMappings[pkg], name)
+				s.record(pkg, Origin{Layer: layer, Field: field, Removed: true})
+				continue
+			}
+			dst.PackageMappings[pkg][manager] = src.PackageMappings[pkg][manager]
+			s.record(pkg, Origin{Layer: layer, Field: field, Value: src.PackageMappings[pkg][manager]})
+		}
+	}
+
+	for _, pkg := range sortedKeys(src.PackageConfigs) {
+		if dst.PackageConfigs == nil {
+			dst.PackageConfigs = make(map[string]PackageConfig)
+		}
+		if name, ok := removal(pkg); ok {
+			delete(dst.PackageConfigs, name)
+			s.record(name, Origin{Layer: layer, Field: "package_configs", Removed: true})
+			continue
+		}
+		merged := dst.PackageConfigs[pkg]
+		merged.PreInstall = append(merged.PreInstall, s.layerHooks(layer, src.PackageConfigs[pkg].PreInstall)...)
+		merged.PostInstall = append(merged.PostInstall, s.layerHooks(layer, src.PackageConfigs[pkg].PostInstall)...)
+		dst.PackageConfigs[pkg] = merged
+		s.record(pkg, Origin{Layer: layer, Field: "package_configs"})
+	}
+
+	// Hooks from every layer run, lowest layer first, overlay hooks once trusted
+	if src.Hooks != nil {
+		if dst.Hooks == nil {
+			dst.Hooks = &Hooks{}
+		}
+		for _, ref := range src.HookRefs() {
+			if ref.Package != "" {
+				continue
+			}
+			if list := dst.Hooks.For(ref.Type); list != nil {
+				*list = append(*list, s.layerHooks(layer, []Hook{*ref.Hook})...)
+			}
+		}
+	}
+
+	// A retention is one policy, so the highest layer setting one wins
+	if src.SnapshotRetention != nil {
+		retention := *src.SnapshotRetention
+		dst.SnapshotRetention = &retention
+	}
+
+	dst.Variables = mergeValues(dst.Variables, src.Variables)
+	for _, pattern := range sortedKeys(src.HostVariables) {
+		if dst.HostVariables == nil {
+			dst.HostVariables = make(map[string]map[string]interface{})
+		}
+		if name, ok := removal(pattern); ok {
+			delete(dst.HostVariables, name)
+			continue
+		}
+		dst.HostVariables[pattern] = mergeValues(dst.HostVariables[pattern], src.HostVariables[pattern])
+	}
+}
+
+// layerHooks returns hooks as merged from layer. Hooks from an overlay get
+// the overlay file as their source, whatever provenance the file claims,
+// and are quarantined unless config.json trusts that file.
+func (s *Stack) layerHooks(layer Layer, list []Hook) []Hook {
+	if layer.Name == "base" || len(list) == 0 {
+		return list
+	}
+
+	source := s.layerSource(layer)
+	quarantine := !s.Config.IsTrustedSource(source)
+	hooks := make([]Hook, 0, len(list))
+	for _, hook := range list {
+		hook.Provenance = &Provenance{Source: source, Quarantined: quarantine}
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// OverlaySources returns the sources hooks from the overlay layers are
+// recorded with, lowest layer first
+func (s *Stack) OverlaySources() []string {
+	var sources []string
+	for _, layer := range s.Layers {
+		if layer.Name != "base" {
+			sources = append(sources, s.layerSource(layer))
+		}
+	}
+	return sources
+}
+
+// layerSource returns the provenance source of hooks from layer: its path
+// relative to config.json, e.g. "config.darwin.json" or "profiles/work.json"
+func (s *Stack) layerSource(layer Layer) string {
+	if len(s.Layers) > 0 {
+		if rel, err := filepath.Rel(filepath.Dir(s.Layers[0].Path), layer.Path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return layer.Path
+}
+
+// mergeList appends the entries of src missing from dst and removes the
+// entries src marks with RemovePrefix, recording each change
+func (s *Stack) mergeList(layer Layer, field string, dst, src []string) []string {
+	for _, entry := range src {
+		if name, ok := removal(entry); ok {
+			dst = without(dst, name)
+			s.record(name, Origin{Layer: layer, Field: field, Removed: true})
+			continue
+		}
+		if !containsEntry(dst, entry) {
+			dst = append(dst, entry)
+		}
+		s.record(entry, Origin{Layer: layer, Field: field})
+	}
+	return dst
+}
+
+func (s *Stack) record(name string, origin Origin) {
+	s.origins[name] = append(s.origins[name], origin)
+}
+
+// mergeEntries applies src to dst like mergeList, without recording
+func mergeEntries(dst, src []string) []string {
+	for _, entry := range src {
+		if name, ok := removal(entry); ok {
+			dst = without(dst, name)
+		} else if !containsEntry(dst, entry) {
+			dst = append(dst, entry)
+		}
+	}
+	return dst
+}
+
+// mergeValues copies dst with the keys of src set over it
+func mergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	if len(src) == 0 {
+		return dst
+	}
+	merged := make(map[string]interface{}, len(dst)+len(src))
+	for key, value := range dst {
+		merged[key] = value
+	}
+	for key, value := range src {
+		if name, ok := removal(key); ok {
+			delete(merged, name)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// removal reports whether entry removes an inherited entry, and which
+func removal(entry string) (string, bool) {
+	if strings.HasPrefix(entry, RemovePrefix) && len(entry) > len(RemovePrefix) {
+		return entry[len(RemovePrefix):], true
+	}
+	return "", false
+}
+
+func without(list []string, name string) []string {
+	kept := list[:0:0]
+	for _, entry := range list {
+		if entry != name {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+func containsEntry(list []string, name string) bool {
+	for _, entry := range list {
+		if entry == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in a stable order: removals before
+// additions, so a layer can replace an entry by removing and re-adding it
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		_, ri := removal(keys[i])
+		_, rj := removal(keys[j])
+		if ri != rj {
+			return ri
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
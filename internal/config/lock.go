@@ -0,0 +1,101 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockVersion is the current dotfiles.lock format version
+const LockVersion = 1
+
+// LockedManager records the exact package versions installed through one package manager
+type LockedManager struct {
+	Brews map[string]string `json:"brews,omitempty"`
+	Casks map[string]string `json:"casks,omitempty"`
+}
+
+// Lock is the contents of dotfiles.lock
+type Lock struct {
+	Version     int                       `json:"version"`
+	GeneratedAt time.Time                 `json:"generated_at"`
+	Managers    map[string]*LockedManager `json:"managers"`
+}
+
+// NewLock returns an empty lock
+func NewLock() *Lock {
+	return &Lock{
+		Version:     LockVersion,
+		GeneratedAt: time.Now(),
+		Managers:    make(map[string]*LockedManager),
+	}
+}
+
+// LoadLock reads dotfiles.lock
+func LoadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filepath.Base(path), err)
+	}
+	if lock.Version > LockVersion {
+		return nil, fmt.Errorf("%s has version %d, this dotfiles only supports up to %d", filepath.Base(path), lock.Version, LockVersion)
+	}
+	if lock.Managers == nil {
+		lock.Managers = make(map[string]*LockedManager)
+	}
+
+	return &lock, nil
+}
+
+// Save writes the lock as indented JSON
+func (l *Lock) Save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Set records the version of a package of the given type ("brew" or "cask")
+func (l *Lock) Set(manager, pkgType, pkg, version string) {
+	locked, ok := l.Managers[manager]
+	if !ok {
+		locked = &LockedManager{}
+		l.Managers[manager] = locked
+	}
+
+	if pkgType == "cask" {
+		if locked.Casks == nil {
+			locked.Casks = make(map[string]string)
+		}
+		locked.Casks[pkg] = version
+		return
+	}
+
+	if locked.Brews == nil {
+		locked.Brews = make(map[string]string)
+	}
+	locked.Brews[pkg] = version
+}
+
+// Get returns the locked version of a package
+func (l *Lock) Get(manager, pkgType, pkg string) (string, bool) {
+	locked, ok := l.Managers[manager]
+	if !ok {
+		return "", false
+	}
+
+	versions := locked.Brews
+	if pkgType == "cask" {
+		versions = locked.Casks
+	}
+	version, ok := versions[pkg]
+	return version, ok
+}
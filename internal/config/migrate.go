@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the configuration schema version this build writes.
+// Files without a version field are version 0.
+const CurrentVersion = 1
+
+// Migration upgrades a configuration document from version From to From+1.
+// Apply edits the decoded document (as produced by encoding/json) in place.
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(doc map[string]interface{})
+}
+
+// Migrations upgrade older documents, in version order. Add a migration and
+// bump CurrentVersion whenever a field is renamed, moved or changes meaning.
+var Migrations = []Migration{
+	{
+		From:        0,
+		Description: "Add the version field and replace null package lists with empty lists",
+		Apply: func(doc map[string]interface{}) {
+			for _, key := range []string{"brews", "casks", "taps", "stow"} {
+				if value, ok := doc[key]; ok && value == nil {
+					doc[key] = []interface{}{}
+				}
+			}
+		},
+	},
+}
+
+// DocumentVersion returns the version of a decoded configuration document
+func DocumentVersion(doc map[string]interface{}) (int, error) {
+	var version float64
+	switch v := doc["version"].(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid version %q", v)
+		}
+		version = f
+	case float64:
+		version = v
+	case int:
+		version = float64(v)
+	default:
+		return 0, fmt.Errorf("invalid version %v", v)
+	}
+	if version < 0 || version != math.Trunc(version) {
+		return 0, fmt.Errorf("invalid version %v", version)
+	}
+	return int(version), nil
+}
+
+// Migrate upgrades a decoded configuration document in place to
+// CurrentVersion and returns the migrations it applied
+func Migrate(doc map[string]interface{}) ([]Migration, error) {
+	version, err := DocumentVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("configuration version %d is newer than this dotfiles supports (%d); upgrade dotfiles", version, CurrentVersion)
+	}
+
+	var applied []Migration
+	for _, migration := range Migrations {
+		if migration.From >= version && migration.From < CurrentVersion {
+			migration.Apply(doc)
+			applied = append(applied, migration)
+		}
+	}
+	if len(applied) > 0 {
+		doc["version"] = CurrentVersion
+	}
+	return applied, nil
+}
+
+// UnmarshalJSON migrates configuration written by older versions before
+// decoding it, so snapshots, profiles and shared configurations that embed
+// a Config are upgraded too
+func (c *Config) UnmarshalJSON(data []byte) error {
+	doc, err := decodeDocument(data)
+	if err != nil || doc == nil {
+		return err
+	}
+	if _, err := Migrate(doc); err != nil {
+		return err
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	type plain Config
+	return json.Unmarshal(migrated, (*plain)(c))
+}
+
+// decodeDocument decodes a JSON object, keeping numbers exact
+func decodeDocument(data []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// MigrationPlan is the upgrade of one file: a configuration, an overlay, or
+// a snapshot or profile holding a configuration under "config"
+type MigrationPlan struct {
+	Path    string
+	From    int
+	Applied []Migration
+
+	doc map[string]interface{} // Migrated document
+}
+
+// PlanMigration reads a file and works out the migrations it needs
+func PlanMigration(path string) (*MigrationPlan, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+
+	target := doc
+	if inner, ok := doc["config"].(map[string]interface{}); ok {
+		target = inner
+	}
+	from, err := DocumentVersion(target)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := Migrate(target)
+	if err != nil {
+		return nil, err
+	}
+	return &MigrationPlan{Path: path, From: from, Applied: applied, doc: doc}, nil
+}
+
+// Write saves the migrated file in place, keeping its comments and key
+// order. A version field the file lacked is added first.
+func (p *MigrationPlan) Write() error {
+	node, err := orderedNode(p.doc)
+	if err != nil {
+		return err
+	}
+	target := node
+	if i := mappingIndex(node, "config"); i >= 0 && node.Content[i+1].Kind == yaml.MappingNode {
+		target = node.Content[i+1]
+	}
+	// Generic maps encode in key order, which would put version after most
+	// keys; it goes first, or after $schema
+	first := 0
+	if mappingIndex(target, "$schema") == 0 {
+		first = 2
+	}
+	if i := mappingIndex(target, "version"); i > first {
+		pair := []*yaml.Node{target.Content[i], target.Content[i+1]}
+		rest := append(target.Content[:i:i], target.Content[i+2:]...)
+		target.Content = append(append(rest[:first:first], pair...), rest[first:]...)
+	}
+	return writeNode(p.Path, node)
+}
+
+// Backup copies the file to dir before it is migrated, as <name>.v<version>
+func (p *MigrationPlan) Backup(dir string) (string, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	backup := filepath.Join(dir, filepath.Base(p.Path)+".v"+strconv.Itoa(p.From))
+	return backup, os.WriteFile(backup, data, 0644)
+}
+
+// backupOutdated backs up a configuration file written by an older version
+// before Save upgrades it, into backups/ next to the file
+func backupOutdated(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	plan, err := PlanMigration(path)
+	if err != nil || len(plan.Applied) == 0 {
+		return nil
+	}
+	_, err = plan.Backup(filepath.Join(filepath.Dir(path), "backups"))
+	return err
+}
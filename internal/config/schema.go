@@ -0,0 +1,302 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaDialect is the JSON Schema version of generated schemas
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema generated from the configuration
+// types and checked by ValidateFile
+type Schema struct {
+	Dialect              string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            int                `json:"minLength,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // false, or a *Schema for map values
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+// packageName is the pattern of brew, cask, tap and stow package names. An
+// overlay's "!name" removal matches too.
+const packageName = `^\S+$`
+
+// SchemaFor generates the schema of a type from its json tags. Structs
+// don't allow unknown keys, matching Load.
+func SchemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(Hook{}):
+		// A hook is a command string or an object
+		return &Schema{AnyOf: []*Schema{{Type: "string", MinLength: 1}, structSchema(t)}}
+	case reflect.TypeOf(Config{}):
+		s := structSchema(t)
+		for _, field := range []string{"brews", "casks", "taps", "stow"} {
+			list := s.Properties[field]
+			list.AnyOf[len(list.AnyOf)-1].Items.Pattern = packageName
+		}
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: SchemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: SchemaFor(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	// interface{} holds anything
+	return &Schema{}
+}
+
+func structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+	addFields(s, t)
+	return s
+}
+
+// addFields adds the fields of t to s, including those of embedded structs
+func addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for key, prop := range SchemaFor(field.Type).Properties {
+				s.Properties[key] = prop
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		prop := SchemaFor(field.Type)
+		// Unset lists, maps and pointers are written as null unless omitted
+		switch field.Type.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Ptr:
+			if !strings.Contains(field.Tag.Get("json"), ",omitempty") && len(prop.AnyOf) == 0 {
+				prop = &Schema{AnyOf: []*Schema{{Type: "null"}, prop}}
+			}
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// RootSchema generates the schema of a file holding v, e.g. Config{}
+func RootSchema(v interface{}, title string) *Schema {
+	s := SchemaFor(reflect.TypeOf(v))
+	s.Dialect = SchemaDialect
+	s.Title = title
+	return s
+}
+
+// Problem is a value that doesn't match a schema
+type Problem struct {
+	Path    string // JSON path, e.g. $.hooks.post_install[0].command
+	Line    int    // 0 when the line is not known
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", p.Line, p.Path, p.Message)
+	}
+	return p.Path + ": " + p.Message
+}
+
+// ValidationError lists the problems found in a file
+type ValidationError struct {
+	File     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		parts[i] = problem.String()
+	}
+	return fmt.Sprintf("%s: %d problem(s): %s", e.File, len(e.Problems), strings.Join(parts, "; "))
+}
+
+// ValidateFile checks a JSON, YAML or TOML file against a schema. Older
+// configuration is migrated first; prefix is the key holding it, e.g.
+// "config" in a profile. Problems are reported with their line numbers.
+func ValidateFile(path string, s *Schema, prefix ...string) error {
+	data, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return err
+	}
+
+	target := doc
+	for _, key := range prefix {
+		target, _ = target[key].(map[string]interface{})
+	}
+	if target != nil {
+		if _, err := Migrate(target); err != nil {
+			return err
+		}
+	}
+
+	var found []problemAt
+	validate(s, doc, nil, &found)
+	if len(found) == 0 {
+		return nil
+	}
+
+	lines := lineFinder(path)
+	problems := make([]Problem, len(found))
+	for i, p := range found {
+		problems[i] = Problem{Path: jsonPath(p.path), Line: lines(p.path), Message: p.message}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return &ValidationError{File: path, Problems: problems}
+}
+
+type problemAt struct {
+	path    []string
+	message string
+}
+
+func validate(s *Schema, v interface{}, path []string, found *[]problemAt) {
+	report := func(format string, args ...interface{}) {
+		*found = append(*found, problemAt{path: path, message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.AnyOf) > 0 {
+		validateAnyOf(s.AnyOf, v, path, found)
+		return
+	}
+	if s.Type != "" && !hasType(v, s.Type) {
+		report("expected %s, got %s", s.Type, typeName(v))
+		return
+	}
+
+	switch value := v.(type) {
+	case string:
+		if len(value) < s.MinLength {
+			report("must not be empty")
+		} else if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(value) {
+			report("%q does not match %s", value, s.Pattern)
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range value {
+				validate(s.Items, item, childPath(path, "["+strconv.Itoa(i)+"]"), found)
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := value[key]; !ok {
+				report("missing required field %q", key)
+			}
+		}
+		for _, key := range sortedKeys(value) {
+			if prop, ok := s.Properties[key]; ok {
+				validate(prop, value[key], childPath(path, key), found)
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case *Schema:
+				validate(extra, value[key], childPath(path, key), found)
+			case bool:
+				if !extra {
+					*found = append(*found, problemAt{path: childPath(path, key), message: "unknown field"})
+				}
+			}
+		}
+	}
+}
+
+// validateAnyOf reports the problems of the alternative of the value's type,
+// so a hook object with a typo is reported as such rather than "not a string"
+func validateAnyOf(alternatives []*Schema, v interface{}, path []string, found *[]problemAt) {
+	var types []string
+	for _, alt := range alternatives {
+		if alt.Type == "" || hasType(v, alt.Type) {
+			validate(alt, v, path, found)
+			return
+		}
+		types = append(types, alt.Type)
+	}
+	*found = append(*found, problemAt{path: path, message: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), typeName(v))})
+}
+
+func hasType(v interface{}, t string) bool {
+	switch t {
+	case "integer":
+		switch n := v.(type) {
+		case json.Number:
+			return !strings.ContainsAny(n.String(), ".eE")
+		case int:
+			return true
+		}
+		return false
+	case "number":
+		return typeName(v) == "number"
+	}
+	return typeName(v) == t
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number, float64, int:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonPath renders a key path as a JSON path, e.g. $.brews[2]
+func jsonPath(path []string) string {
+	if len(path) == 0 {
+		return "$"
+	}
+	return "$." + displayPath(path)
+}
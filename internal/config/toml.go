@@ -0,0 +1,689 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// The TOML library has no comment-preserving document model, so TOML files
+// are edited line by line: each value that changed is rewritten in place,
+// and multi-line arrays keep the lines (and comments) of the items that stay.
+
+// maxInlineArray is the longest array written on a single line
+const maxInlineArray = 80
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type tomlStmtKind int
+
+const (
+	tomlKeyValue tomlStmtKind = iota
+	tomlTable
+	tomlArrayTable
+)
+
+// tomlStmt is a statement of a TOML file spanning lines [start, end). head
+// is the first of the comment lines directly above it.
+type tomlStmt struct {
+	kind       tomlStmtKind
+	head       int
+	start, end int
+	path       []string // Table path of a header, full key path of a key/value
+	section    []string // Table a key/value is in
+	keyText    string   // Key as written, for rewriting the value
+	inArray    bool     // Key/value inside an [[array]] table
+}
+
+// tomlLeaf is a value of the new document and where it goes
+type tomlLeaf struct {
+	table []string
+	key   string
+	value *yaml.Node
+}
+
+func (l tomlLeaf) path() []string {
+	return append(append([]string{}, l.table...), l.key)
+}
+
+// encodeTOML writes node as TOML, keeping the comments and layout of existing
+func encodeTOML(node *yaml.Node, existing []byte) ([]byte, error) {
+	if out, err := mergeTOML(node, string(existing)); err == nil {
+		return []byte(out), nil
+	}
+	// The existing file can't be parsed; write it from scratch
+	out, err := mergeTOML(node, "")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+func mergeTOML(node *yaml.Node, existing string) (string, error) {
+	var lines []string
+	if strings.TrimSpace(existing) != "" {
+		lines = strings.Split(strings.TrimSuffix(existing, "\n"), "\n")
+	}
+
+	var old map[string]interface{}
+	if _, err := toml.Decode(existing, &old); err != nil {
+		return "", err
+	}
+	stmts, err := parseTOML(lines)
+	if err != nil {
+		return "", err
+	}
+
+	// Old values stay leaves in the new document, so an inline table is
+	// rewritten inline instead of becoming a [table]
+	stops := make(map[string]bool)
+	for _, st := range stmts {
+		if st.kind == tomlKeyValue && !st.inArray || st.kind == tomlArrayTable {
+			stops[pathKey(st.path)] = true
+		}
+	}
+	var leaves []tomlLeaf
+	tables := map[string]bool{"": true}
+	collectLeaves(node, nil, stops, tables, &leaves)
+	byPath := make(map[string]int)
+	for i, leaf := range leaves {
+		byPath[pathKey(leaf.path())] = i
+	}
+
+	deleted := make([]bool, len(lines))
+	replaced := make(map[int][]string)
+	handled := make([]bool, len(leaves))
+	remove := func(from, to int) {
+		for i := from; i < to; i++ {
+			deleted[i] = true
+		}
+	}
+
+	// Array tables are one value spread over several [[blocks]]
+	arrayTables := make(map[string]bool)
+	for i, st := range stmts {
+		switch {
+		case st.kind == tomlArrayTable:
+			key := pathKey(st.path)
+			j, ok := byPath[key]
+			same := ok && sameValue(lookup(old, st.path), leaves[j].value)
+			if !same {
+				remove(st.head, blockEnd(stmts, i, len(lines)))
+			} else if !arrayTables[key] {
+				handled[j] = true
+			}
+			arrayTables[key] = true
+		case st.kind == tomlTable:
+			if !tables[pathKey(st.path)] {
+				remove(st.head, st.end)
+			}
+		case st.kind == tomlKeyValue && !st.inArray:
+			j, ok := byPath[pathKey(st.path)]
+			if !ok {
+				remove(st.head, st.end)
+				continue
+			}
+			handled[j] = true
+			if sameValue(lookup(old, st.path), leaves[j].value) {
+				continue
+			}
+			replacement, ok := mergeTOMLArray(lines[st.start:st.end], leaves[j].value)
+			if !ok {
+				replacement = []string{st.keyText + " = " + tomlValue(leaves[j].value) + trailingComment(lines[st.start])}
+			}
+			replaced[st.start] = replacement
+			remove(st.start+1, st.end)
+		}
+	}
+
+	// New values go at the end of their table, or into a new table at the
+	// end of the file
+	insert := make(map[int][]string)
+	var newTables []string
+	newEntries := make(map[string][]string)
+	leading := true
+	for i, leaf := range leaves {
+		if len(leaf.table) > 0 || handled[i] {
+			leading = false
+		}
+		if handled[i] {
+			continue
+		}
+		entry := tomlEntry(leaf.key, leaf.value)
+		// Top-level keys that come before every existing one, such as
+		// version, go at the top of the file
+		if leading && len(stmts) > 0 {
+			insert[stmts[0].head] = append(insert[stmts[0].head], entry...)
+			continue
+		}
+		if at, ok := insertPoint(stmts, leaf.table, deleted, len(lines)); ok {
+			insert[at] = append(insert[at], entry...)
+			continue
+		}
+		key := pathKey(leaf.table)
+		if _, ok := newEntries[key]; !ok {
+			newTables = append(newTables, key)
+		}
+		newEntries[key] = append(newEntries[key], entry...)
+	}
+	// Tables that exist only to hold other tables still get a header when empty
+	for _, key := range emptyTables(node, nil, stops) {
+		if _, ok := newEntries[key]; !ok && !hasSection(stmts, key, deleted) {
+			newTables = append(newTables, key)
+			newEntries[key] = nil
+		}
+	}
+
+	var out []string
+	for i := 0; i <= len(lines); i++ {
+		out = append(out, insert[i]...)
+		if i == len(lines) {
+			break
+		}
+		if len(insert[i]) > 0 && strings.HasPrefix(strings.TrimSpace(lines[i]), "[") {
+			out = append(out, "")
+		}
+		if replacement, ok := replaced[i]; ok {
+			out = append(out, replacement...)
+		} else if !deleted[i] {
+			out = append(out, lines[i])
+		}
+	}
+	for _, key := range newTables {
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, "")
+		}
+		out = append(out, "["+key+"]")
+		out = append(out, newEntries[key]...)
+	}
+	return strings.Join(out, "\n") + "\n", nil
+}
+
+// collectLeaves lists the values of node to write as key = value, in
+// order. Mappings become tables unless an old key/value is at their path.
+func collectLeaves(node *yaml.Node, table []string, stops, tables map[string]bool, leaves *[]tomlLeaf) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if value.ShortTag() == "!!null" {
+			continue
+		}
+		path := append(append([]string{}, table...), key)
+		if value.Kind == yaml.MappingNode && !stops[pathKey(path)] {
+			continue
+		}
+		*leaves = append(*leaves, tomlLeaf{table: table, key: key, value: value})
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		path := append(append([]string{}, table...), key)
+		if value.Kind == yaml.MappingNode && !stops[pathKey(path)] {
+			tables[pathKey(path)] = true
+			collectLeaves(value, path, stops, tables, leaves)
+		}
+	}
+}
+
+// emptyTables returns the tables of node with no values and no subtables
+func emptyTables(node *yaml.Node, table []string, stops map[string]bool) []string {
+	var empty []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		value := node.Content[i+1]
+		path := append(append([]string{}, table...), node.Content[i].Value)
+		if value.Kind != yaml.MappingNode || stops[pathKey(path)] {
+			continue
+		}
+		if len(value.Content) == 0 {
+			empty = append(empty, pathKey(path))
+		}
+		empty = append(empty, emptyTables(value, path, stops)...)
+	}
+	return empty
+}
+
+// insertPoint returns the line before which new values of table go: after
+// its last key/value, or after its header
+func insertPoint(stmts []tomlStmt, table []string, deleted []bool, lines int) (int, bool) {
+	key := pathKey(table)
+	if key == "" {
+		at := -1
+		for _, st := range stmts {
+			if st.kind != tomlKeyValue {
+				if at < 0 {
+					return st.head, true
+				}
+				break
+			}
+			at = st.end
+		}
+		if at < 0 {
+			return lines, true
+		}
+		return at, true
+	}
+
+	at, found := -1, false
+	for _, st := range stmts {
+		switch {
+		case st.kind == tomlTable && pathKey(st.path) == key && !deleted[st.start]:
+			at, found = st.end, true
+		case st.kind == tomlKeyValue && found && pathKey(st.section) == key && !st.inArray:
+			at = st.end
+		case st.kind != tomlKeyValue && found:
+			return at, true
+		}
+	}
+	return at, found
+}
+
+func hasSection(stmts []tomlStmt, key string, deleted []bool) bool {
+	for _, st := range stmts {
+		if st.kind == tomlTable && pathKey(st.path) == key && !deleted[st.start] {
+			return true
+		}
+	}
+	return false
+}
+
+// blockEnd returns the line where the [[array]] block at stmts[i] ends
+func blockEnd(stmts []tomlStmt, i, lines int) int {
+	for _, st := range stmts[i+1:] {
+		if st.kind != tomlKeyValue {
+			return st.head
+		}
+	}
+	return lines
+}
+
+// mergeTOMLArray rewrites a multi-line array of scalars, keeping the lines
+// and comments of the items that are still in value. An array left empty is
+// written inline instead.
+func mergeTOMLArray(lines []string, value *yaml.Node) ([]string, bool) {
+	if value.Kind != yaml.SequenceNode || len(value.Content) == 0 || len(lines) < 2 {
+		return nil, false
+	}
+	for _, item := range value.Content {
+		if item.Kind != yaml.ScalarNode {
+			return nil, false
+		}
+	}
+	first := stripComment(lines[0])
+	last := strings.TrimSpace(stripComment(lines[len(lines)-1]))
+	if !strings.HasSuffix(strings.TrimSpace(first), "[") || !strings.HasPrefix(last, "]") {
+		return nil, false
+	}
+
+	type oldItem struct {
+		id    string
+		lines []string
+		taken bool
+	}
+	var items []*oldItem
+	var pending []string
+	indent := "  "
+	for _, line := range lines[1 : len(lines)-1] {
+		text := strings.TrimSpace(stripComment(line))
+		if text == "" {
+			pending = append(pending, line)
+			continue
+		}
+		var decoded map[string]interface{}
+		if _, err := toml.Decode("v = "+strings.TrimSuffix(text, ","), &decoded); err != nil {
+			return nil, false
+		}
+		if _, ok := decoded["v"].([]interface{}); ok {
+			return nil, false
+		}
+		id, _ := json.Marshal(decoded["v"])
+		if !strings.HasSuffix(text, ",") {
+			line = strings.Replace(line, text, text+",", 1)
+		}
+		if len(items) == 0 {
+			indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		}
+		items = append(items, &oldItem{id: string(id), lines: append(pending, line)})
+		pending = nil
+	}
+
+	out := []string{lines[0]}
+	for _, item := range value.Content {
+		id := jsonValue(item)
+		var match *oldItem
+		for _, candidate := range items {
+			if !candidate.taken && candidate.id == id {
+				match = candidate
+				break
+			}
+		}
+		if match != nil {
+			match.taken = true
+			out = append(out, match.lines...)
+		} else {
+			out = append(out, indent+tomlValue(item)+",")
+		}
+	}
+	out = append(out, pending...)
+	return append(out, lines[len(lines)-1]), true
+}
+
+// parseTOML splits lines into statements
+func parseTOML(lines []string) ([]tomlStmt, error) {
+	var stmts []tomlStmt
+	var section []string
+	inArray := false
+
+	for i := 0; i < len(lines); {
+		text := strings.TrimSpace(lines[i])
+		if text == "" || strings.HasPrefix(text, "#") {
+			i++
+			continue
+		}
+
+		head := i
+		for head > 0 && strings.HasPrefix(strings.TrimSpace(lines[head-1]), "#") {
+			head--
+		}
+		// Comments above a statement are its own only below the previous one
+		if len(stmts) > 0 && head < stmts[len(stmts)-1].end {
+			head = stmts[len(stmts)-1].end
+		}
+
+		switch {
+		case strings.HasPrefix(text, "[["):
+			end := strings.Index(text, "]]")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated table header", i+1)
+			}
+			section = parseTOMLKey(text[2:end])
+			inArray = true
+			stmts = append(stmts, tomlStmt{kind: tomlArrayTable, head: head, start: i, end: i + 1, path: section})
+			i++
+		case strings.HasPrefix(text, "["):
+			end := strings.Index(text, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated table header", i+1)
+			}
+			section = parseTOMLKey(text[1:end])
+			inArray = false
+			stmts = append(stmts, tomlStmt{kind: tomlTable, head: head, start: i, end: i + 1, path: section})
+			i++
+		default:
+			eq := keyEnd(lines[i])
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: expected key = value", i+1)
+			}
+			end, err := valueEnd(lines, i, eq+1)
+			if err != nil {
+				return nil, err
+			}
+			keyText := strings.TrimRight(lines[i][:eq], " \t")
+			path := append(append([]string{}, section...), parseTOMLKey(keyText)...)
+			stmts = append(stmts, tomlStmt{
+				kind: tomlKeyValue, head: head, start: i, end: end,
+				path: path, section: section, keyText: keyText, inArray: inArray,
+			})
+			i = end
+		}
+	}
+	return stmts, nil
+}
+
+// keyEnd returns the index of the = after the key on line, or -1
+func keyEnd(line string) int {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return i
+		case c == '#':
+			return -1
+		}
+	}
+	return -1
+}
+
+// valueEnd returns the line after the value starting at line i, column col
+func valueEnd(lines []string, i, col int) (int, error) {
+	depth := 0
+	delim := "" // Open string delimiter: ", ', """ or '''
+	for ; i < len(lines); i, col = i+1, 0 {
+		line := lines[i]
+		for j := col; j < len(line); j++ {
+			rest := line[j:]
+			if delim != "" {
+				switch {
+				case delim[0] == '"' && line[j] == '\\':
+					j++
+				case strings.HasPrefix(rest, delim):
+					j += len(delim) - 1
+					delim = ""
+				}
+				continue
+			}
+			switch {
+			case strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`):
+				delim = rest[:3]
+				j += 2
+			case line[j] == '"' || line[j] == '\'':
+				delim = rest[:1]
+			case line[j] == '[' || line[j] == '{':
+				depth++
+			case line[j] == ']' || line[j] == '}':
+				depth--
+			case line[j] == '#':
+				j = len(line)
+			}
+		}
+		if len(delim) == 1 {
+			return 0, fmt.Errorf("line %d: unterminated string", i+1)
+		}
+		if depth == 0 && delim == "" {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated value")
+}
+
+// parseTOMLKey splits a dotted key into its parts
+func parseTOMLKey(text string) []string {
+	var parts []string
+	var part strings.Builder
+	quote := byte(0)
+	flush := func() {
+		parts = append(parts, strings.TrimSpace(part.String()))
+		part.Reset()
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\' && i+1 < len(text):
+			raw := text[i : i+2]
+			if unquoted, err := strconv.Unquote(`"` + raw + `"`); err == nil {
+				part.WriteString(unquoted)
+			}
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			part.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			flush()
+		case c != ' ' && c != '\t':
+			part.WriteByte(c)
+		}
+	}
+	flush()
+	return parts
+}
+
+// stripComment removes a trailing # comment from a line
+func stripComment(line string) string {
+	if i := commentStart(line); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// trailingComment returns the comment at the end of a line with the space
+// before it, or ""
+func trailingComment(line string) string {
+	i := commentStart(line)
+	if i < 0 {
+		return ""
+	}
+	start := i
+	for start > 0 && (line[start-1] == ' ' || line[start-1] == '\t') {
+		start--
+	}
+	return line[start:]
+}
+
+func commentStart(line string) int {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return i
+		}
+	}
+	return -1
+}
+
+// tomlEntry formats key = value, breaking long arrays over several lines
+func tomlEntry(key string, value *yaml.Node) []string {
+	inline := tomlKey(key) + " = " + tomlValue(value)
+	if value.Kind != yaml.SequenceNode || len(inline) <= maxInlineArray {
+		return []string{inline}
+	}
+	lines := []string{tomlKey(key) + " = ["}
+	for _, item := range value.Content {
+		lines = append(lines, "  "+tomlValue(item)+",")
+	}
+	return append(lines, "]")
+}
+
+// tomlValue formats a value inline
+func tomlValue(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.ShortTag() != "!!null" {
+				items = append(items, tomlValue(item))
+			}
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case yaml.MappingNode:
+		var fields []string
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].ShortTag() != "!!null" {
+				fields = append(fields, tomlKey(node.Content[i].Value)+" = "+tomlValue(node.Content[i+1]))
+			}
+		}
+		if len(fields) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(fields, ", ") + " }"
+	case yaml.AliasNode:
+		return tomlValue(node.Alias)
+	}
+
+	switch node.ShortTag() {
+	case "!!int", "!!float", "!!bool":
+		return node.Value
+	}
+	return tomlString(node.Value)
+}
+
+func tomlKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlString quotes s as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// sameValue reports whether a decoded TOML value equals node
+func sameValue(old interface{}, node *yaml.Node) bool {
+	data, err := json.Marshal(old)
+	return err == nil && string(data) == jsonValue(node)
+}
+
+func jsonValue(node *yaml.Node) string {
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return ""
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// lookup returns the value at path in a decoded TOML document
+func lookup(doc map[string]interface{}, path []string) interface{} {
+	var v interface{} = doc
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// pathKey joins a table path as written in a header, e.g. package_mappings.fd
+func pathKey(path []string) string {
+	parts := make([]string, len(path))
+	for i, part := range path {
+		parts[i] = tomlKey(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tomlHeader is the part of a test file every case shares
+const tomlHeader = `version = 1
+taps = []
+stow = []
+`
+
+func TestSaveTOML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		edit    func(cfg *Config)
+		want    []string // Lines that must be in the output
+		missing []string // Text that must not be
+	}{
+		{
+			name: "comments above and beside keys",
+			input: tomlHeader + `# Command line tools
+brews = ["git", "fd"] # keep sorted
+
+# GUI apps
+casks = ["firefox"]
+`,
+			edit: func(cfg *Config) {
+				cfg.Brews = append(cfg.Brews, "ripgrep")
+			},
+			want: []string{
+				"# Command line tools",
+				`brews = ["git", "fd", "ripgrep"] # keep sorted`,
+				"# GUI apps",
+				`casks = ["firefox"]`,
+			},
+		},
+		{
+			name: "multiline array",
+			input: tomlHeader + `casks = []
+brews = [
+  "git", # version control
+  "fd",
+  # search
+  "ripgrep",
+]
+`,
+			edit: func(cfg *Config) {
+				cfg.Brews = []string{"git", "ripgrep", "jq"}
+			},
+			want: []string{
+				`  "git", # version control`,
+				"  # search",
+				`  "ripgrep",`,
+				`  "jq",`,
+			},
+			missing: []string{`"fd"`},
+		},
+		{
+			name: "inline table",
+			input: tomlHeader + `brews = ["fd"]
+casks = []
+snapshot_retention = { keep_last = 5 } # a week of changes
+
+[package_mappings]
+fd = { apt = "fd-find", dnf = "fd-find" }
+`,
+			edit: func(cfg *Config) {
+				cfg.PackageMappings["fd"]["apt"] = "fdfind"
+				cfg.SnapshotRetention.KeepDaily = 7
+			},
+			want: []string{
+				"snapshot_retention = { keep_last = 5, keep_daily = 7 } # a week of changes",
+				"[package_mappings]",
+				`fd = { apt = "fdfind", dnf = "fd-find" }`,
+			},
+		},
+		{
+			name: "array tables",
+			input: tomlHeader + `brews = []
+casks = []
+
+# Reload tmux after stowing
+[[hooks.post_stow]]
+command = "tmux source-file ~/.tmux.conf"
+timeout = "30s"
+`,
+			edit: func(cfg *Config) {
+				cfg.Hooks.PreStow = Commands("echo stowing")
+			},
+			want: []string{
+				"# Reload tmux after stowing",
+				"[[hooks.post_stow]]",
+				`command = "tmux source-file ~/.tmux.conf"`,
+				`pre_stow = ["echo stowing"]`,
+			},
+		},
+		{
+			name: "changed array table",
+			input: tomlHeader + `brews = []
+casks = []
+
+[[hooks.post_stow]]
+command = "tmux source-file ~/.tmux.conf"
+timeout = "30s"
+`,
+			edit: func(cfg *Config) {
+				cfg.Hooks.PostStow = append(cfg.Hooks.PostStow, Hook{Command: "./reload.sh", ContinueOnError: true})
+			},
+			missing: []string{"[[hooks.post_stow]]"},
+		},
+		{
+			name: "quoted keys",
+			input: tomlHeader + `brews = ["python@3.12"]
+casks = []
+
+[package_versions]
+"python@3.12" = ">=3.12"
+git = ">=2.40"
+`,
+			edit: func(cfg *Config) {
+				cfg.PackageVersions["python@3.12"] = ">=3.12.4"
+				cfg.PackageVersions["node@20"] = "20.x"
+			},
+			want: []string{
+				`"python@3.12" = ">=3.12.4"`,
+				`git = ">=2.40"`,
+				`"node@20" = "20.x"`,
+			},
+		},
+		{
+			name: "remove last element",
+			input: tomlHeader + `brews = ["git"] # just git
+casks = [
+  "firefox", # browser
+]
+`,
+			edit: func(cfg *Config) {
+				cfg.Brews = []string{}
+				cfg.Casks = []string{}
+			},
+			want:    []string{"brews = [] # just git", "casks = []"},
+			missing: []string{`"git"`, "firefox"},
+		},
+		{
+			name: "new table",
+			input: tomlHeader + `# Packages
+brews = ["git"]
+casks = []
+`,
+			edit: func(cfg *Config) {
+				cfg.PackageVersions = map[string]string{"git": ">=2.40"}
+				cfg.HostVariables = map[string]map[string]interface{}{
+					"work-*": {"email": "me@work.example"},
+				}
+			},
+			want: []string{
+				"# Packages",
+				`brews = ["git"]`,
+				"[package_versions]",
+				`git = ">=2.40"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.edit(cfg)
+			if err := cfg.Save(path); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := string(data)
+			lines := strings.Split(out, "\n")
+			for _, want := range tt.want {
+				if !containsLine(lines, want) {
+					t.Errorf("output is missing line %q:\n%s", want, out)
+				}
+			}
+			for _, text := range tt.missing {
+				if strings.Contains(out, text) {
+					t.Errorf("output still contains %q:\n%s", text, out)
+				}
+			}
+
+			// What was written must read back as the configuration saved
+			reloaded, err := Load(path)
+			if err != nil {
+				t.Fatalf("output doesn't parse: %v\n%s", err, out)
+			}
+			if !reflect.DeepEqual(reloaded, cfg) {
+				t.Errorf("output reads back as\n%+v\nwant\n%+v\n%s", reloaded, cfg, out)
+			}
+		})
+	}
+}
+
+func TestSaveTOMLUnchanged(t *testing.T) {
+	input := tomlHeader + `# Command line tools
+brews = [
+  "git", # version control
+  "fd",
+]
+casks = [] # none yet
+
+[package_mappings]
+fd = { apt = "fd-find" }
+
+[[hooks.post_stow]]
+command = "tmux source-file ~/.tmux.conf"
+timeout = "30s"
+`
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != input {
+		t.Errorf("saving an unchanged config rewrote it:\n%s\nwant\n%s", data, input)
+	}
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+// Package hooks runs the user's pre/post hooks around dotfiles operations.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"dotfiles/internal/config"
+)
+
+// Event is an operation hooks can run around
+type Event string
+
+const (
+	Install         Event = "install"
+	Sync            Event = "sync"
+	Stow            Event = "stow"
+	Unstow          Event = "unstow"
+	Update          Event = "update"
+	SnapshotRestore Event = "snapshot_restore"
+	TemplateApply   Event = "template_apply"
+)
+
+// Phase is when a hook runs relative to its event
+type Phase string
+
+const (
+	Pre  Phase = "pre"
+	Post Phase = "post"
+)
+
+// DefaultTimeout applies to hooks that do not set a timeout
+const DefaultTimeout = 10 * time.Minute
+
+// Payload describes the operation a hook runs for. Hooks receive it as JSON
+// on stdin and as DOTFILES_* environment variables.
+type Payload struct {
+	Event    Event    `json:"event"`
+	Phase    Phase    `json:"phase"`
+	Root     string   `json:"root"`               // Dotfiles root directory
+	Packages []string `json:"packages,omitempty"` // Packages the operation works on
+	Snapshot string   `json:"snapshot,omitempty"` // Snapshot being restored
+	Template string   `json:"template,omitempty"` // Template being applied
+	OS       string   `json:"os"`
+	Hostname string   `json:"hostname"`
+}
+
+// HookType returns the config hook type, such as "pre_stow"
+func (p Payload) HookType() string {
+	return string(p.Phase) + "_" + string(p.Event)
+}
+
+// Environ returns the payload as environment variables
+func (p Payload) Environ() []string {
+	env := []string{
+		"DOTFILES_EVENT=" + string(p.Event),
+		"DOTFILES_PHASE=" + string(p.Phase),
+		"DOTFILES_HOOK=" + p.HookType(),
+		"DOTFILES_ROOT=" + p.Root,
+		"DOTFILES_OS=" + p.OS,
+		"DOTFILES_HOSTNAME=" + p.Hostname,
+	}
+	if len(p.Packages) > 0 {
+		env = append(env, "DOTFILES_PACKAGES="+strings.Join(p.Packages, " "))
+	}
+	if p.Snapshot != "" {
+		env = append(env, "DOTFILES_SNAPSHOT="+p.Snapshot)
+	}
+	if p.Template != "" {
+		env = append(env, "DOTFILES_TEMPLATE="+p.Template)
+	}
+	return env
+}
+
+// Status is the outcome of a single hook
+type Status string
+
+const (
+	Started   Status = "started"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	Skipped   Status = "skipped"
+)
+
+// Progress reports on one hook while the engine runs a list
+type Progress struct {
+	Status   Status
+	Hook     config.Hook
+	Index    int // 1-based position in the list
+	Total    int
+	Reason   string // Why a hook was skipped
+	Err      error
+	Duration time.Duration
+}
+
+// Engine runs hooks for an event
+type Engine struct {
+	Stdout     io.Writer
+	Stderr     io.Writer
+	GOOS       string
+	Hostname   string
+	OnProgress func(Progress)
+}
+
+// NewEngine returns an engine for this machine writing to the terminal
+func NewEngine() *Engine {
+	hostname, _ := os.Hostname()
+	return &Engine{
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		GOOS:     runtime.GOOS,
+		Hostname: hostname,
+	}
+}
+
+// Run executes the hooks in order. It stops at the first failing hook and
+// returns its error, unless that hook sets continue_on_error.
+func (e *Engine) Run(hooks []config.Hook, payload Payload) error {
+	payload.OS = e.GOOS
+	payload.Hostname = e.Hostname
+
+	for i, hook := range hooks {
+		progress := Progress{Hook: hook, Index: i + 1, Total: len(hooks)}
+
+		if reason := e.SkipReason(hook); reason != "" {
+			progress.Status, progress.Reason = Skipped, reason
+			e.report(progress)
+			continue
+		}
+
+		progress.Status = Started
+		e.report(progress)
+
+		start := time.Now()
+		err := e.Exec(hook, payload)
+		progress.Duration = time.Since(start)
+
+		if err != nil {
+			progress.Status, progress.Err = Failed, err
+			e.report(progress)
+			if !hook.ContinueOnError {
+				return fmt.Errorf("%q: %v", hook.Command, err)
+			}
+			continue
+		}
+
+		progress.Status = Succeeded
+		e.report(progress)
+	}
+	return nil
+}
+
+func (e *Engine) report(progress Progress) {
+	if e.OnProgress != nil {
+		e.OnProgress(progress)
+	}
+}
+
+// SkipReason returns why a hook is quarantined or does not apply to this
+// machine, or "" when it should run
+func (e *Engine) SkipReason(hook config.Hook) string {
+	if hook.Quarantined() {
+		return fmt.Sprintf("quarantined, from %s; run 'dotfiles hooks trust'", hook.Source())
+	}
+	if len(hook.OS) > 0 && !matchesOS(hook.OS, e.GOOS) {
+		return fmt.Sprintf("os is %s, hook is for %s", e.GOOS, strings.Join(hook.OS, ", "))
+	}
+	if len(hook.Hosts) > 0 && !matchesHost(hook.Hosts, e.Hostname) {
+		return fmt.Sprintf("host %s does not match %s", e.Hostname, strings.Join(hook.Hosts, ", "))
+	}
+	return ""
+}
+
+// Exec runs a single hook with the payload, ignoring its OS/host condition
+// and quarantine
+func (e *Engine) Exec(hook config.Hook, payload Payload) error {
+	timeout := DefaultTimeout
+	if hook.Timeout != "" {
+		parsed, err := time.ParseDuration(hook.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %v", hook.Timeout, err)
+		}
+		timeout = parsed
+	}
+
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	cmd.Env = append(os.Environ(), payload.Environ()...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = e.Stdout
+	cmd.Stderr = e.Stderr
+	// Don't wait forever on background processes holding the output open
+	cmd.WaitDelay = 5 * time.Second
+	if hook.WorkDir != "" {
+		cmd.Dir = resolveWorkDir(hook.WorkDir, payload.Root)
+	}
+
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// resolveWorkDir expands ~ and environment variables and resolves relative
+// directories against the dotfiles root
+func resolveWorkDir(dir, root string) string {
+	dir = os.ExpandEnv(dir)
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		}
+	}
+	if !filepath.IsAbs(dir) && root != "" {
+		dir = filepath.Join(root, dir)
+	}
+	return dir
+}
+
+func matchesOS(systems []string, goos string) bool {
+	for _, system := range systems {
+		system = strings.ToLower(system)
+		if system == "macos" || system == "osx" {
+			system = "darwin"
+		}
+		if system == goos {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesHost matches glob patterns against the full and short hostname
+func matchesHost(patterns []string, hostname string) bool {
+	short := strings.SplitN(hostname, ".", 2)[0]
+	for _, pattern := range patterns {
+		for _, name := range []string{hostname, short} {
+			if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+// Package installer installs packages one at a time through a worker pool,
+// reporting progress as events and recording results in a journal.
+package installer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"dotfiles/internal/pkgmanager"
+)
+
+// DefaultConcurrency is the number of workers used when none is configured
+const DefaultConcurrency = 4
+
+// Job is one package to install
+type Job struct {
+	Manager pkgmanager.PackageManager
+	Package string
+	Type    string // "brew", "cask" or "tap"
+	Version string // Exact version to install, empty for the latest
+}
+
+// Key identifies the job in the journal
+func (j Job) Key() string {
+	return journalKey(j.Manager.GetName(), j.Type, j.Package)
+}
+
+func journalKey(manager, pkgType, pkg string) string {
+	return fmt.Sprintf("%s/%s/%s", manager, pkgType, pkg)
+}
+
+// EventType describes what happened to a job
+type EventType string
+
+const (
+	EventStarted   EventType = "started"
+	EventSucceeded EventType = "succeeded"
+	EventFailed    EventType = "failed"
+	EventSkipped   EventType = "skipped" // Already installed by a previous run (--resume)
+)
+
+// Event reports progress on a job. Completed counts finished jobs, including
+// this one for everything but EventStarted.
+type Event struct {
+	Type       EventType
+	Job        Job
+	Err        error
+	JournalErr error // Saving the job's result to the journal failed, so --resume won't know about it
+	Duration   time.Duration
+	Completed  int
+	Total      int
+}
+
+// Result is the outcome of one job
+type Result struct {
+	Job      Job
+	Err      error
+	Skipped  bool
+	Duration time.Duration
+}
+
+// Options configures a run
+type Options struct {
+	Concurrency int         // Number of workers, DefaultConcurrency when zero
+	Journal     *Journal    // Records results when set
+	Resume      bool        // Skip jobs the journal records as succeeded
+	OnEvent     func(Event) // Called for every event, never concurrently
+}
+
+// Run installs every job and returns the results in job order. Taps are
+// installed before anything else since packages may come from them.
+// Packages of one package manager are installed one at a time unless it
+// supports concurrent installs, as system package managers hold a global
+// lock; different package managers run in parallel.
+func Run(jobs []Job, opts Options) []Result {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	results := make([]Result, len(jobs))
+	r := &run{opts: opts, total: len(jobs), locks: make(map[string]*sync.Mutex)}
+
+	var taps, rest []int
+	for i, job := range jobs {
+		if job.Type == "tap" {
+			taps = append(taps, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+
+	r.stage(jobs, taps, results)
+	r.stage(jobs, rest, results)
+
+	return results
+}
+
+type run struct {
+	opts      Options
+	total     int
+	completed int
+	locks     map[string]*sync.Mutex
+	locksMu   sync.Mutex
+}
+
+type message struct {
+	index  int
+	event  Event
+	result *Result
+}
+
+// stage installs the jobs at the given indexes and waits for them to finish
+func (r *run) stage(jobs []Job, indexes []int, results []Result) {
+	if len(indexes) == 0 {
+		return
+	}
+
+	queue := make(chan int)
+	messages := make(chan message)
+
+	var workers sync.WaitGroup
+	for w := 0; w < r.opts.Concurrency && w < len(indexes); w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range queue {
+				r.work(i, jobs[i], messages)
+			}
+		}()
+	}
+
+	go func() {
+		for _, i := range indexes {
+			queue <- i
+		}
+		close(queue)
+		workers.Wait()
+		close(messages)
+	}()
+
+	// Events are delivered and the journal written from this goroutine only
+	for msg := range messages {
+		if msg.result != nil {
+			results[msg.index] = *msg.result
+			r.completed++
+			if r.opts.Journal != nil && !msg.result.Skipped {
+				msg.event.JournalErr = r.opts.Journal.Record(msg.result.Job, msg.result.Err)
+			}
+		}
+
+		msg.event.Completed = r.completed
+		msg.event.Total = r.total
+		if r.opts.OnEvent != nil {
+			r.opts.OnEvent(msg.event)
+		}
+	}
+}
+
+// work installs one job, sending its events
+func (r *run) work(i int, job Job, messages chan<- message) {
+	if r.opts.Resume && r.opts.Journal != nil && r.opts.Journal.Succeeded(job) {
+		messages <- message{
+			index:  i,
+			event:  Event{Type: EventSkipped, Job: job},
+			result: &Result{Job: job, Skipped: true},
+		}
+		return
+	}
+
+	lock := r.lockFor(job.Manager)
+	lock.Lock()
+	defer lock.Unlock()
+
+	messages <- message{index: i, event: Event{Type: EventStarted, Job: job}}
+
+	start := time.Now()
+	err := install(job)
+	duration := time.Since(start)
+
+	event := Event{Type: EventSucceeded, Job: job, Duration: duration}
+	if err != nil {
+		event.Type = EventFailed
+		event.Err = err
+	}
+	messages <- message{
+		index:  i,
+		event:  event,
+		result: &Result{Job: job, Err: err, Duration: duration},
+	}
+}
+
+// lockFor returns the lock serializing installs for the job's package
+// manager, or a fresh lock when it supports concurrent installs
+func (r *run) lockFor(pm pkgmanager.PackageManager) *sync.Mutex {
+	if concurrent, ok := pm.(pkgmanager.ConcurrentInstaller); ok && concurrent.ConcurrentInstalls() {
+		return &sync.Mutex{}
+	}
+
+	r.locksMu.Lock()
+	defer r.locksMu.Unlock()
+
+	lock, ok := r.locks[pm.GetName()]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.locks[pm.GetName()] = lock
+	}
+	return lock
+}
+
+// install runs a single job, using the pinned version when the package
+// manager can install one
+func install(job Job) error {
+	if job.Version != "" {
+		if installer, ok := job.Manager.(pkgmanager.VersionInstaller); ok {
+			return installer.InstallVersion(job.Package, job.Version, job.Type)
+		}
+	}
+	return job.Manager.Install([]string{job.Package}, job.Type)
+}
+
+// Failed returns the results that failed
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
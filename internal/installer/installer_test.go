@@ -0,0 +1,190 @@
+package installer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"dotfiles/internal/pkgmanager"
+)
+
+// fakeManager records installs and tracks how many ran at the same time
+type fakeManager struct {
+	pkgmanager.PackageManager
+
+	name       string
+	fail       map[string]bool
+	concurrent bool
+
+	mu        sync.Mutex
+	installed []string
+	active    int
+	maxActive int
+}
+
+func (m *fakeManager) GetName() string { return m.name }
+
+func (m *fakeManager) ConcurrentInstalls() bool { return m.concurrent }
+
+func (m *fakeManager) Install(packages []string, packageType string) error {
+	m.mu.Lock()
+	m.active++
+	if m.active > m.maxActive {
+		m.maxActive = m.active
+	}
+	m.mu.Unlock()
+
+	// Give other workers a chance to overlap with this install
+	time.Sleep(5 * time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active--
+	for _, pkg := range packages {
+		if m.fail[pkg] {
+			return fmt.Errorf("%s: no such package", pkg)
+		}
+		m.installed = append(m.installed, pkg)
+	}
+	return nil
+}
+
+func jobsFor(pm pkgmanager.PackageManager, packages ...string) []Job {
+	var jobs []Job
+	for _, pkg := range packages {
+		jobs = append(jobs, Job{Manager: pm, Package: pkg, Type: "brew"})
+	}
+	return jobs
+}
+
+func TestRunResumeSkipsSucceeded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "install-journal.json")
+	pm := &fakeManager{name: "apt", fail: map[string]bool{"broken": true}}
+	jobs := jobsFor(pm, "git", "broken", "ripgrep")
+
+	first := Run(jobs, Options{Journal: NewJournal(path)})
+	if failed := Failed(first); len(failed) != 1 || failed[0].Job.Package != "broken" {
+		t.Fatalf("first run failed = %v, want only broken", failed)
+	}
+
+	journal, err := LoadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.installed = nil
+	delete(pm.fail, "broken")
+
+	var skipped []string
+	second := Run(jobs, Options{
+		Journal: journal,
+		Resume:  true,
+		OnEvent: func(event Event) {
+			if event.Type == EventSkipped {
+				skipped = append(skipped, event.Job.Package)
+			}
+		},
+	})
+
+	assertPackages(t, "installed on resume", pm.installed, []string{"broken"})
+	if len(skipped) != 2 {
+		t.Errorf("skipped = %v, want git and ripgrep", skipped)
+	}
+	for _, result := range second {
+		if result.Err != nil {
+			t.Errorf("%s failed on resume: %v", result.Job.Package, result.Err)
+		}
+		if result.Skipped != (result.Job.Package != "broken") {
+			t.Errorf("%s skipped = %v", result.Job.Package, result.Skipped)
+		}
+	}
+	if failed := journal.Failed(); len(failed) != 0 {
+		t.Errorf("journal still records failures after resume: %v", failed)
+	}
+}
+
+func TestRunFailureDoesNotAbortOtherManagers(t *testing.T) {
+	apt := &fakeManager{name: "apt", fail: map[string]bool{"missing": true}}
+	npm := &fakeManager{name: "npm"}
+
+	jobs := append(jobsFor(apt, "missing", "git"), jobsFor(npm, "typescript", "prettier")...)
+	results := Run(jobs, Options{Concurrency: 2})
+
+	if len(results) != len(jobs) {
+		t.Fatalf("got %d results, want %d", len(results), len(jobs))
+	}
+	for i, result := range results {
+		if result.Job.Package != jobs[i].Package {
+			t.Errorf("result %d is %s, want %s (job order)", i, result.Job.Package, jobs[i].Package)
+		}
+	}
+
+	failed := Failed(results)
+	if len(failed) != 1 || failed[0].Job.Package != "missing" {
+		t.Fatalf("failed = %v, want only missing", failed)
+	}
+	if failed[0].Err.Error() != "missing: no such package" {
+		t.Errorf("failed result error = %v", failed[0].Err)
+	}
+
+	sort.Strings(npm.installed)
+	assertPackages(t, "apt", apt.installed, []string{"git"})
+	assertPackages(t, "npm", npm.installed, []string{"prettier", "typescript"})
+}
+
+func TestRunSerializesEachManager(t *testing.T) {
+	tests := []struct {
+		name       string
+		concurrent bool
+		wantMax    func(int) bool
+	}{
+		{name: "global lock", concurrent: false, wantMax: func(n int) bool { return n == 1 }},
+		{name: "concurrent installs", concurrent: true, wantMax: func(n int) bool { return n > 1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &fakeManager{name: "dnf", concurrent: tt.concurrent}
+			other := &fakeManager{name: "cargo"}
+
+			jobs := append(jobsFor(pm, "a", "b", "c", "d", "e", "f"), jobsFor(other, "x", "y")...)
+			Run(jobs, Options{Concurrency: 4})
+
+			if !tt.wantMax(pm.maxActive) {
+				t.Errorf("dnf ran %d installs at once", pm.maxActive)
+			}
+			if other.maxActive != 1 {
+				t.Errorf("cargo ran %d installs at once, want 1", other.maxActive)
+			}
+			if len(pm.installed) != 6 || len(other.installed) != 2 {
+				t.Errorf("installed dnf=%v cargo=%v", pm.installed, other.installed)
+			}
+		})
+	}
+}
+
+func TestRunInstallsTapsFirst(t *testing.T) {
+	pm := &fakeManager{name: "homebrew", concurrent: true}
+	jobs := []Job{
+		{Manager: pm, Package: "fish", Type: "brew"},
+		{Manager: pm, Package: "homebrew/cask-fonts", Type: "tap"},
+	}
+
+	Run(jobs, Options{Concurrency: 2})
+
+	assertPackages(t, "homebrew", pm.installed, []string{"homebrew/cask-fonts", "fish"})
+}
+
+func assertPackages(t *testing.T, label string, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", label, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s = %v, want %v", label, got, want)
+		}
+	}
+}
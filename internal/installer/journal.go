@@ -0,0 +1,163 @@
+package installer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Journal statuses
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// JournalEntry is the outcome of installing one package
+type JournalEntry struct {
+	Manager    string    `json:"manager"`
+	Type       string    `json:"type"`
+	Package    string    `json:"package"`
+	Version    string    `json:"version,omitempty"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Journal records per-package install results so an interrupted or partly
+// failed install can be resumed, and what dotfiles has installed so far so
+// reconciling only uninstalls those. It is saved after every package.
+type Journal struct {
+	StartedAt time.Time                `json:"started_at"`
+	Entries   map[string]*JournalEntry `json:"entries"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// NewJournal starts an empty journal that will be written to path
+func NewJournal(path string) *Journal {
+	return &Journal{
+		StartedAt: time.Now(),
+		Entries:   make(map[string]*JournalEntry),
+		path:      path,
+	}
+}
+
+// LoadJournal reads the journal of the previous run, or starts an empty one
+// when there is none
+func LoadJournal(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewJournal(path), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &Journal{path: path}
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, err
+	}
+	if journal.Entries == nil {
+		journal.Entries = make(map[string]*JournalEntry)
+	}
+	return journal, nil
+}
+
+// Restart begins a new run: failures of earlier runs are dropped, the
+// packages they installed are kept
+func (j *Journal) Restart() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.StartedAt = time.Now()
+	for key, entry := range j.Entries {
+		if entry.Status != StatusSucceeded {
+			delete(j.Entries, key)
+		}
+	}
+}
+
+// Save writes the journal to disk
+func (j *Journal) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.save()
+}
+
+func (j *Journal) save() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(j.path, data, 0644)
+}
+
+// Record stores the result of a job and saves the journal
+func (j *Journal) Record(job Job, installErr error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := &JournalEntry{
+		Manager:    job.Manager.GetName(),
+		Type:       job.Type,
+		Package:    job.Package,
+		Version:    job.Version,
+		Status:     StatusSucceeded,
+		FinishedAt: time.Now(),
+	}
+	if installErr != nil {
+		entry.Status = StatusFailed
+		entry.Error = installErr.Error()
+	}
+	j.Entries[job.Key()] = entry
+
+	return j.save()
+}
+
+// Succeeded reports whether the journal records the job as installed. A
+// changed pinned version counts as not installed.
+func (j *Journal) Succeeded(job Job) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.Entries[job.Key()]
+	return ok && entry.Status == StatusSucceeded && entry.Version == job.Version
+}
+
+// Installed reports whether the journal records installing a package, in
+// any version
+func (j *Journal) Installed(manager, pkgType, pkg string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.Entries[journalKey(manager, pkgType, pkg)]
+	return ok && entry.Status == StatusSucceeded
+}
+
+// Failed returns the failed entries sorted by manager and package
+func (j *Journal) Failed() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var failed []JournalEntry
+	for _, entry := range j.Entries {
+		if entry.Status == StatusFailed {
+			failed = append(failed, *entry)
+		}
+	}
+	sort.Slice(failed, func(a, b int) bool {
+		if failed[a].Manager != failed[b].Manager {
+			return failed[a].Manager < failed[b].Manager
+		}
+		return failed[a].Package < failed[b].Package
+	})
+	return failed
+}
@@ -0,0 +1,215 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"dotfiles/internal/config"
+)
+
+// Entry is one change to a configuration file. The journal only grows:
+// undoing an entry appends another that reverses it.
+type Entry struct {
+	ID      int             `json:"id"`
+	Time    time.Time       `json:"time"`
+	Command string          `json:"command"` // e.g. "dotfiles add"
+	Args    []string        `json:"args,omitempty"`
+	File    string          `json:"file"` // Relative to the dotfiles root
+	Changes []config.Change `json:"changes,omitempty"`
+	Before  *string         `json:"before"` // Content before the change; nil when the file didn't exist
+	After   *string         `json:"after"`  // Content after the change; nil when the file was removed
+	Undoes  int             `json:"undoes,omitempty"`
+}
+
+// Read returns the entries of the journal at path, oldest first. A missing
+// journal has no entries. A last line cut short by an interrupted Append is
+// skipped.
+func Read(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("%s:%d: %v", filepath.Base(path), i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Append numbers an entry after the last one, adds it to the journal and
+// returns it
+func Append(path string, entry Entry) (Entry, error) {
+	entries, err := Read(path)
+	if err != nil {
+		return entry, err
+	}
+	entry.ID = 1
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return entry, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return entry, err
+	}
+	if err := repairTail(path); err != nil {
+		return entry, err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return entry, err
+}
+
+// repairTail makes the journal end with a complete line before appending:
+// a last line missing its newline gets one if it is a whole entry, and is
+// dropped if an interrupted Append cut it short
+func repairTail(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) || len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	start := bytes.LastIndexByte(data, '\n') + 1
+	var entry Entry
+	if json.Unmarshal(data[start:], &entry) == nil {
+		return os.WriteFile(path, append(data, '\n'), 0644)
+	}
+	return os.Truncate(path, int64(start))
+}
+
+// Content turns file content into an entry's Before or After
+func Content(data []byte) *string {
+	if data == nil {
+		return nil
+	}
+	content := string(data)
+	return &content
+}
+
+// Undone returns the IDs of entries that were undone, mapped to the undo
+func Undone(entries []Entry) map[int]int {
+	undone := make(map[int]int)
+	for _, entry := range entries {
+		if entry.Undoes != 0 {
+			undone[entry.Undoes] = entry.ID
+		}
+	}
+	return undone
+}
+
+// Undoable returns the last n changes that can be undone, newest first.
+// Undos themselves are not undone, so undoing again goes further back.
+func Undoable(entries []Entry, n int) []Entry {
+	undone := Undone(entries)
+	var result []Entry
+	for i := len(entries) - 1; i >= 0 && len(result) < n; i-- {
+		if entries[i].Undoes == 0 && undone[entries[i].ID] == 0 {
+			result = append(result, entries[i])
+		}
+	}
+	return result
+}
+
+// Reversal returns the entry recording that entry was undone by command
+func Reversal(entry Entry, command string, args []string) Entry {
+	changes := make([]config.Change, len(entry.Changes))
+	for i, change := range entry.Changes {
+		changes[i] = config.Change{Section: change.Section, Added: change.Removed, Removed: change.Added}
+	}
+	return Entry{
+		Command: command,
+		Args:    args,
+		File:    entry.File,
+		Changes: changes,
+		Before:  entry.After,
+		After:   entry.Before,
+		Undoes:  entry.ID,
+	}
+}
+
+// ConflictError is returned when a file changed after the entry being
+// undone, so putting back its old content would lose that change
+type ConflictError struct {
+	Entry Entry
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s changed after #%d (%s); undoing it would lose those changes", e.Entry.File, e.Entry.ID, e.Entry.Command)
+}
+
+// Check verifies that entries, newest first, can be undone in turn: each
+// file must still hold what the entry wrote, once the newer entries are
+// undone.
+func Check(root string, entries []Entry) error {
+	current := make(map[string]*string)
+	for _, entry := range entries {
+		content, seen := current[entry.File]
+		if !seen {
+			data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(entry.File)))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			content = Content(data)
+		}
+		if !sameContent(content, entry.After) {
+			return &ConflictError{Entry: entry}
+		}
+		current[entry.File] = entry.Before
+	}
+	return nil
+}
+
+// Revert puts back the content a file had before entry
+func Revert(root string, entry Entry) error {
+	path := filepath.Join(root, filepath.FromSlash(entry.File))
+	if entry.Before == nil {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(*entry.Before), 0644)
+}
+
+func sameContent(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,214 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// edit writes content to file under root, as a command would, and journals
+// the change
+func edit(t *testing.T, root, path, file string, content *string) Entry {
+	t.Helper()
+	full := filepath.Join(root, file)
+	before, err := os.ReadFile(full)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if content == nil {
+		err = os.Remove(full)
+	} else {
+		err = os.WriteFile(full, []byte(*content), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := Append(path, Entry{Command: "dotfiles add", File: file, Before: Content(before), After: content})
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	return entry
+}
+
+// undo checks and reverts the last n changes and journals the reversals
+func undo(t *testing.T, root, path string, n int) error {
+	t.Helper()
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	undoable := Undoable(entries, n)
+	if err := Check(root, undoable); err != nil {
+		return err
+	}
+	for _, entry := range undoable {
+		if err := Revert(root, entry); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Append(path, Reversal(entry, "dotfiles undo", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nil
+}
+
+func assertFile(t *testing.T, path string, want *string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if want == nil {
+		if !os.IsNotExist(err) {
+			t.Errorf("%s exists, want it removed", path)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != *want {
+		t.Errorf("%s = %q, want %q", path, data, *want)
+	}
+}
+
+func text(s string) *string { return &s }
+
+func TestUndo(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "state", "journal.jsonl")
+	target := filepath.Join(root, "config.toml")
+
+	edit(t, root, path, "config.toml", text("brews = []\n"))
+	edit(t, root, path, "config.toml", text("brews = [\"git\"]\n"))
+	edit(t, root, path, "config.toml", text("brews = [\"git\", \"fd\"]\n"))
+
+	if err := undo(t, root, path, 2); err != nil {
+		t.Fatalf("undo 2: %v", err)
+	}
+	assertFile(t, target, text("brews = []\n"))
+
+	// Undoing again goes past the undos to the first change
+	if err := undo(t, root, path, 1); err != nil {
+		t.Fatalf("undo again: %v", err)
+	}
+	assertFile(t, target, nil)
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 6 {
+		t.Fatalf("journal has %d entries, want 6", len(entries))
+	}
+	for i, want := range []int{3, 2, 1} {
+		if entries[3+i].Undoes != want {
+			t.Errorf("entry #%d undoes #%d, want #%d", entries[3+i].ID, entries[3+i].Undoes, want)
+		}
+	}
+	if left := Undoable(entries, 10); len(left) != 0 {
+		t.Errorf("Undoable() = %v after undoing everything", left)
+	}
+}
+
+func TestUndoAfterExternalEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		external *string // nil removes the file
+		n        int
+		conflict int // ID of the entry the conflict names
+	}{
+		{name: "edited", external: text("brews = [\"vim\"]\n"), n: 1, conflict: 2},
+		{name: "removed", n: 1, conflict: 2},
+		{name: "edited under several undos", external: text("brews = [\"vim\"]\n"), n: 2, conflict: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "state", "journal.jsonl")
+			target := filepath.Join(root, "config.toml")
+
+			edit(t, root, path, "config.toml", text("brews = []\n"))
+			edit(t, root, path, "config.toml", text("brews = [\"git\"]\n"))
+
+			if tt.external == nil {
+				if err := os.Remove(target); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := os.WriteFile(target, []byte(*tt.external), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := undo(t, root, path, tt.n)
+			var conflict *ConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("undo error = %v, want *ConflictError", err)
+			}
+			if conflict.Entry.ID != tt.conflict {
+				t.Errorf("conflict names #%d, want #%d", conflict.Entry.ID, tt.conflict)
+			}
+
+			// A refused undo leaves the file and the journal alone
+			assertFile(t, target, tt.external)
+			entries, err := Read(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 2 {
+				t.Errorf("journal has %d entries after a refused undo, want 2", len(entries))
+			}
+		})
+	}
+}
+
+func TestReadTruncatedLastLine(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "journal.jsonl")
+
+	edit(t, root, path, "config.toml", text("brews = []\n"))
+	edit(t, root, path, "config.toml", text("brews = [\"git\"]\n"))
+
+	// An append interrupted halfway through its line
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"id":3,"command":"dotfiles ad`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Read() returned %d entries, want the 2 complete ones", len(entries))
+	}
+
+	// The next append replaces the partial line
+	entry := edit(t, root, path, "config.toml", text("brews = [\"git\", \"fd\"]\n"))
+	if entry.ID != 3 {
+		t.Errorf("Append() numbered the entry #%d, want #3", entry.ID)
+	}
+	entries, err = Read(path)
+	if err != nil {
+		t.Fatalf("Read() after Append() error = %v", err)
+	}
+	if len(entries) != 3 || entries[2].After == nil || *entries[2].After != "brews = [\"git\", \"fd\"]\n" {
+		t.Errorf("Read() after Append() = %+v", entries)
+	}
+}
+
+func TestReadCorruptLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	data := "{\"id\":1,\"file\":\"config.toml\"}\nnot json\n{\"id\":2,\"file\":\"config.toml\"}\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only an unterminated last line can be an interrupted append
+	if _, err := Read(path); err == nil {
+		t.Error("Read() succeeded on a corrupt line in the middle of the journal")
+	}
+}
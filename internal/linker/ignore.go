@@ -0,0 +1,137 @@
+package linker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LocalIgnoreFile is the per-package ignore list, one regular expression per line
+const LocalIgnoreFile = ".stow-local-ignore"
+
+// GlobalIgnoreFile is the ignore list read from the home directory when a
+// package has no local ignore list
+const GlobalIgnoreFile = ".stow-global-ignore"
+
+// DefaultIgnore mirrors GNU Stow's built-in ignore list
+var DefaultIgnore = []string{
+	`RCS`,
+	`.+,v`,
+	`CVS`,
+	`\.\#.+`,
+	`\.cvsignore`,
+	`\.svn`,
+	`_darcs`,
+	`\.hg`,
+	`\.git`,
+	`\.gitignore`,
+	`\.gitmodules`,
+	`.+~`,
+	`\#.*\#`,
+	`^/README.*`,
+	`^/LICENSE.*`,
+	`^/COPYING`,
+}
+
+// ignoreList matches package-relative paths against Stow style patterns.
+// Patterns containing a slash match the end of the path (with a leading
+// slash), all others must match the base name exactly.
+type ignoreList struct {
+	pathPatterns []*regexp.Regexp
+	namePatterns []*regexp.Regexp
+}
+
+func newIgnoreList(patterns []string) (*ignoreList, error) {
+	list := &ignoreList{}
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "/") {
+			re, err := regexp.Compile("(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+			}
+			list.pathPatterns = append(list.pathPatterns, re)
+		} else {
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+			}
+			list.namePatterns = append(list.namePatterns, re)
+		}
+	}
+	return list, nil
+}
+
+// matches reports whether relPath (relative to the package root) is ignored
+func (i *ignoreList) matches(relPath string) bool {
+	name := filepath.Base(relPath)
+	if name == LocalIgnoreFile {
+		return true
+	}
+
+	for _, re := range i.namePatterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+
+	slashPath := "/" + filepath.ToSlash(relPath)
+	for _, re := range i.pathPatterns {
+		if re.MatchString(slashPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadIgnoreList builds the ignore list for a package: the package's local
+// list if present, otherwise the global list, otherwise DefaultIgnore.
+// Extra patterns are always added.
+func loadIgnoreList(pkgDir string, extra []string) (*ignoreList, error) {
+	patterns, err := readIgnoreFile(filepath.Join(pkgDir, LocalIgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if patterns == nil {
+		if home, err := os.UserHomeDir(); err == nil {
+			patterns, err = readIgnoreFile(filepath.Join(home, GlobalIgnoreFile))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if patterns == nil {
+		patterns = DefaultIgnore
+	}
+
+	return newIgnoreList(append(append([]string{}, patterns...), extra...))
+}
+
+// readIgnoreFile returns nil patterns when the file does not exist
+func readIgnoreFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	return patterns, scanner.Err()
+}
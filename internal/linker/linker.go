@@ -0,0 +1,698 @@
+package linker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"dotfiles/internal/render"
+)
+
+// OpType identifies a filesystem change planned by the linker
+type OpType string
+
+const (
+	OpLink   OpType = "LINK"
+	OpUnlink OpType = "UNLINK"
+	OpMkdir  OpType = "MKDIR"
+	OpRmdir  OpType = "RMDIR"
+	OpRender OpType = "RENDER"
+)
+
+// Operation is a single planned filesystem change
+type Operation struct {
+	Type   OpType `json:"type"`
+	Path   string `json:"path"`             // Path in the target directory
+	Source string `json:"source,omitempty"` // Absolute path in the stow directory (links and renders)
+
+	content []byte // Rendered template output
+}
+
+func (o Operation) String() string {
+	switch o.Type {
+	case OpLink:
+		return fmt.Sprintf("%s: %s => %s", o.Type, o.Path, o.Source)
+	case OpRender:
+		return fmt.Sprintf("%s: %s <= %s", o.Type, o.Path, o.Source)
+	}
+	return fmt.Sprintf("%s: %s", o.Type, o.Path)
+}
+
+// Conflict is a target path that cannot be linked without removing something
+type Conflict struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ConflictError is returned when a package cannot be stowed because of conflicts.
+// No changes are made when it is returned.
+type ConflictError struct {
+	Package   string
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%d conflict(s) stowing %s", len(e.Conflicts), e.Package)
+}
+
+// Options controls how packages are linked
+type Options struct {
+	Dir       string   // Stow directory containing packages
+	Target    string   // Directory the links are created in
+	Dotfiles  bool     // Translate "dot-" name prefixes to "."
+	NoFolding bool     // Always create directories instead of linking whole trees
+	DryRun    bool     // Plan operations without touching the filesystem
+	Ignore    []string // Extra ignore patterns added to every package
+
+	// Render renders .tmpl files, which are then written to the target
+	// (without the suffix) instead of linked. Templates are linked as-is
+	// when it is nil.
+	Render func(source string) ([]byte, error)
+	// Rendered maps target paths written from templates earlier to the hash
+	// of their content, so they can be updated or removed
+	Rendered map[string]string
+}
+
+// Linker creates and removes symlinks with GNU Stow compatible semantics
+type Linker struct {
+	opts      Options
+	dir       string
+	target    string
+	ignores   map[string]*ignoreList
+	overlay   map[string]node
+	ops       []Operation
+	conflicts []Conflict
+}
+
+type nodeKind int
+
+const (
+	nodeMissing nodeKind = iota
+	nodeFile
+	nodeDir
+	nodeLink
+)
+
+// node is the (possibly planned) state of a path in the target directory
+type node struct {
+	kind nodeKind
+	dest string // Absolute link destination for nodeLink
+}
+
+// New returns a Linker for the given options
+func New(opts Options) (*Linker, error) {
+	dir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stow directory: %v", err)
+	}
+	target, err := filepath.Abs(opts.Target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target directory: %v", err)
+	}
+
+	return &Linker{
+		opts:    opts,
+		dir:     dir,
+		target:  target,
+		ignores: make(map[string]*ignoreList),
+	}, nil
+}
+
+// Stow links a package into the target directory and returns the operations performed
+func (l *Linker) Stow(pkg string) ([]Operation, error) {
+	l.reset()
+	if err := l.planStow(pkg); err != nil {
+		return nil, err
+	}
+	return l.commit(pkg)
+}
+
+// Unstow removes a package's links from the target directory
+func (l *Linker) Unstow(pkg string) ([]Operation, error) {
+	l.reset()
+	if err := l.planUnstow(pkg); err != nil {
+		return nil, err
+	}
+	return l.commit(pkg)
+}
+
+// Restow unstows and then stows a package in a single plan
+func (l *Linker) Restow(pkg string) ([]Operation, error) {
+	l.reset()
+	if err := l.planUnstow(pkg); err != nil {
+		return nil, err
+	}
+	if err := l.planStow(pkg); err != nil {
+		return nil, err
+	}
+	return l.commit(pkg)
+}
+
+// Conflicts returns the target paths that would block stowing a package
+func (l *Linker) Conflicts(pkg string) ([]Conflict, error) {
+	l.reset()
+	if err := l.planStow(pkg); err != nil {
+		return nil, err
+	}
+	return l.conflicts, nil
+}
+
+// IsStowed reports whether every entry of a package is already linked
+func (l *Linker) IsStowed(pkg string) (bool, error) {
+	l.reset()
+	if err := l.planStow(pkg); err != nil {
+		return false, err
+	}
+	return len(l.ops) == 0 && len(l.conflicts) == 0, nil
+}
+
+func (l *Linker) reset() {
+	l.overlay = make(map[string]node)
+	l.ops = nil
+	l.conflicts = nil
+}
+
+// commit executes the planned operations unless running dry
+func (l *Linker) commit(pkg string) ([]Operation, error) {
+	if len(l.conflicts) > 0 {
+		return nil, &ConflictError{Package: pkg, Conflicts: l.conflicts}
+	}
+
+	if l.opts.DryRun {
+		return l.ops, nil
+	}
+
+	for i, op := range l.ops {
+		if err := execute(op); err != nil {
+			return l.ops[:i], fmt.Errorf("%s failed: %v", op, err)
+		}
+	}
+
+	return l.ops, nil
+}
+
+func execute(op Operation) error {
+	switch op.Type {
+	case OpLink:
+		linkText, err := filepath.Rel(filepath.Dir(op.Path), op.Source)
+		if err != nil {
+			linkText = op.Source
+		}
+		return os.Symlink(linkText, op.Path)
+	case OpUnlink, OpRmdir:
+		return os.Remove(op.Path)
+	case OpMkdir:
+		return os.Mkdir(op.Path, 0755)
+	case OpRender:
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(op.Source); err == nil {
+			mode = info.Mode().Perm()
+		}
+		return os.WriteFile(op.Path, op.content, mode)
+	}
+	return fmt.Errorf("unknown operation %s", op.Type)
+}
+
+func (l *Linker) packageDir(pkg string) (string, error) {
+	pkgDir := filepath.Join(l.dir, pkg)
+	info, err := os.Stat(pkgDir)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("package directory not found: %s", pkgDir)
+	}
+	return pkgDir, nil
+}
+
+func (l *Linker) ignoreFor(pkg string) (*ignoreList, error) {
+	if list, ok := l.ignores[pkg]; ok {
+		return list, nil
+	}
+	list, err := loadIgnoreList(filepath.Join(l.dir, pkg), l.opts.Ignore)
+	if err != nil {
+		return nil, err
+	}
+	l.ignores[pkg] = list
+	return list, nil
+}
+
+// isTemplate reports whether a package entry is rendered instead of linked
+func (l *Linker) isTemplate(entry os.DirEntry) bool {
+	return l.opts.Render != nil && !entry.IsDir() && render.IsTemplate(entry.Name())
+}
+
+// targetName maps a package entry to its name in the target directory
+func (l *Linker) targetName(entry os.DirEntry) string {
+	if l.isTemplate(entry) {
+		return l.translate(render.TargetName(entry.Name()))
+	}
+	return l.translate(entry.Name())
+}
+
+// translate maps a package entry name to its name in the target directory
+func (l *Linker) translate(name string) string {
+	if l.opts.Dotfiles && strings.HasPrefix(name, "dot-") && len(name) > len("dot-") {
+		return "." + strings.TrimPrefix(name, "dot-")
+	}
+	return name
+}
+
+// owner returns the package and package-relative path of a path inside the stow directory
+func (l *Linker) owner(path string) (string, string, bool) {
+	rel, err := filepath.Rel(l.dir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", false
+	}
+	parts := strings.SplitN(rel, string(filepath.Separator), 2)
+	if len(parts) == 1 {
+		return parts[0], "", true
+	}
+	return parts[0], parts[1], true
+}
+
+// lstat returns the state of a target path, taking planned operations into account
+func (l *Linker) lstat(path string) node {
+	if n, ok := l.overlay[path]; ok {
+		return n
+	}
+
+	// Anything below a path changed by the plan only exists in the plan
+	for p := filepath.Dir(path); ; p = filepath.Dir(p) {
+		if _, ok := l.overlay[p]; ok {
+			return node{kind: nodeMissing}
+		}
+		if p == filepath.Dir(p) {
+			break
+		}
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return node{kind: nodeMissing}
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		dest, err := os.Readlink(path)
+		if err != nil {
+			return node{kind: nodeFile}
+		}
+		if !filepath.IsAbs(dest) {
+			dest = filepath.Join(filepath.Dir(path), dest)
+		}
+		return node{kind: nodeLink, dest: filepath.Clean(dest)}
+	case info.IsDir():
+		return node{kind: nodeDir}
+	default:
+		return node{kind: nodeFile}
+	}
+}
+
+// readDir lists a target directory, taking planned operations into account
+func (l *Linker) readDir(dir string) []string {
+	names := make(map[string]bool)
+
+	if n, ok := l.overlay[dir]; !ok || n.kind != nodeDir {
+		if entries, err := os.ReadDir(dir); err == nil {
+			for _, entry := range entries {
+				names[entry.Name()] = true
+			}
+		}
+	}
+
+	for path, n := range l.overlay {
+		if filepath.Dir(path) != dir {
+			continue
+		}
+		if n.kind == nodeMissing {
+			delete(names, filepath.Base(path))
+		} else {
+			names[filepath.Base(path)] = true
+		}
+	}
+
+	var result []string
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (l *Linker) link(path, source string) {
+	l.ops = append(l.ops, Operation{Type: OpLink, Path: path, Source: source})
+	l.overlay[path] = node{kind: nodeLink, dest: source}
+}
+
+func (l *Linker) render(path, source string, content []byte) {
+	l.ops = append(l.ops, Operation{Type: OpRender, Path: path, Source: source, content: content})
+	l.overlay[path] = node{kind: nodeFile}
+}
+
+func (l *Linker) unlink(path string) {
+	l.ops = append(l.ops, Operation{Type: OpUnlink, Path: path})
+	l.overlay[path] = node{kind: nodeMissing}
+}
+
+func (l *Linker) mkdir(path string) {
+	l.ops = append(l.ops, Operation{Type: OpMkdir, Path: path})
+	l.overlay[path] = node{kind: nodeDir}
+}
+
+func (l *Linker) rmdir(path string) {
+	l.ops = append(l.ops, Operation{Type: OpRmdir, Path: path})
+	l.overlay[path] = node{kind: nodeMissing}
+}
+
+func (l *Linker) conflict(path, reason string) {
+	l.conflicts = append(l.conflicts, Conflict{Path: path, Reason: reason})
+}
+
+func (l *Linker) planStow(pkg string) error {
+	pkgDir, err := l.packageDir(pkg)
+	if err != nil {
+		return err
+	}
+	return l.stowContents(pkg, pkgDir, "", l.target)
+}
+
+// stowContents links every non-ignored entry of srcDir into targetDir
+func (l *Linker) stowContents(pkg, srcDir, rel, targetDir string) error {
+	ignore, err := l.ignoreFor(pkg)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", srcDir, err)
+	}
+
+	for _, entry := range entries {
+		entryRel := filepath.Join(rel, entry.Name())
+		if ignore.matches(entryRel) {
+			continue
+		}
+
+		source := filepath.Join(srcDir, entry.Name())
+		target := filepath.Join(targetDir, l.targetName(entry))
+		if l.isTemplate(entry) {
+			if err := l.stowTemplate(source, target); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := l.stowNode(pkg, source, entryRel, target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *Linker) stowNode(pkg, source, rel, target string) error {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	sourceIsDir := info.IsDir()
+
+	current := l.lstat(target)
+	switch current.kind {
+	case nodeMissing:
+		if sourceIsDir && !l.canFold(source) {
+			l.mkdir(target)
+			return l.stowContents(pkg, source, rel, target)
+		}
+		l.link(target, source)
+
+	case nodeLink:
+		if current.dest == source {
+			// Split a folded tree that may no longer be linked as a whole,
+			// such as one that gained templates
+			if sourceIsDir && !l.canFold(source) {
+				return l.unfold(target, current.dest)
+			}
+			return nil
+		}
+
+		owner, _, owned := l.owner(current.dest)
+		if !owned {
+			l.conflict(target, "existing symlink is not owned by stow")
+			return nil
+		}
+
+		destInfo, err := os.Stat(current.dest)
+		if err != nil {
+			// Stale link into the stow directory, replace it
+			l.unlink(target)
+			return l.stowNode(pkg, source, rel, target)
+		}
+
+		if sourceIsDir && destInfo.IsDir() {
+			// Another package folded this tree, split it into a real directory
+			if err := l.unfold(target, current.dest); err != nil {
+				return err
+			}
+			return l.stowContents(pkg, source, rel, target)
+		}
+
+		l.conflict(target, fmt.Sprintf("existing target is owned by package %s", owner))
+
+	case nodeDir:
+		if sourceIsDir {
+			return l.stowContents(pkg, source, rel, target)
+		}
+		l.conflict(target, "existing target is a directory")
+
+	default:
+		l.conflict(target, "existing target is not owned by stow")
+	}
+
+	return nil
+}
+
+// stowTemplate renders a .tmpl file to the target
+func (l *Linker) stowTemplate(source, target string) error {
+	content, err := l.opts.Render(source)
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %v", source, err)
+	}
+
+	current := l.lstat(target)
+	switch current.kind {
+	case nodeMissing:
+		l.render(target, source, content)
+
+	case nodeFile:
+		recorded, owned := l.opts.Rendered[target]
+		if !owned {
+			l.conflict(target, "existing target is not owned by stow")
+			return nil
+		}
+		existing, err := os.ReadFile(target)
+		if err == nil && bytes.Equal(existing, content) {
+			return nil
+		}
+		if err == nil && HashContent(existing) != recorded {
+			l.conflict(target, "rendered file was edited since it was written")
+			return nil
+		}
+		l.render(target, source, content)
+
+	case nodeLink:
+		owner, _, owned := l.owner(current.dest)
+		if !owned {
+			l.conflict(target, "existing symlink is not owned by stow")
+			return nil
+		}
+		// Replace a link to the template itself or a stale link
+		if _, err := os.Stat(current.dest); current.dest == source || err != nil {
+			l.unlink(target)
+			l.render(target, source, content)
+			return nil
+		}
+		l.conflict(target, fmt.Sprintf("existing target is owned by package %s", owner))
+
+	default:
+		l.conflict(target, "existing target is a directory")
+	}
+
+	return nil
+}
+
+// HashContent returns the hash recorded for rendered template output
+func HashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// canFold reports whether a source directory may be linked as a whole
+func (l *Linker) canFold(source string) bool {
+	if l.opts.NoFolding {
+		return false
+	}
+	if !l.opts.Dotfiles && l.opts.Render == nil {
+		return true
+	}
+
+	// Folding would hide "dot-" names from translation and templates from rendering
+	foldable := true
+	filepath.WalkDir(source, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !foldable {
+			return filepath.SkipDir
+		}
+		if path == source {
+			return nil
+		}
+		if (l.opts.Dotfiles && strings.HasPrefix(d.Name(), "dot-")) || l.isTemplate(d) {
+			foldable = false
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return foldable
+}
+
+// unfold replaces a folded directory link with a real directory of links
+func (l *Linker) unfold(target, dest string) error {
+	owner, rel, _ := l.owner(dest)
+	l.unlink(target)
+	l.mkdir(target)
+	return l.stowContents(owner, dest, rel, target)
+}
+
+func (l *Linker) planUnstow(pkg string) error {
+	pkgDir, err := l.packageDir(pkg)
+	if err != nil {
+		return err
+	}
+	return l.unstowContents(pkg, pkgDir, "", l.target)
+}
+
+// unstowContents removes links pointing at entries of srcDir from targetDir
+func (l *Linker) unstowContents(pkg, srcDir, rel, targetDir string) error {
+	ignore, err := l.ignoreFor(pkg)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", srcDir, err)
+	}
+
+	for _, entry := range entries {
+		entryRel := filepath.Join(rel, entry.Name())
+		if ignore.matches(entryRel) {
+			continue
+		}
+
+		source := filepath.Join(srcDir, entry.Name())
+		target := filepath.Join(targetDir, l.targetName(entry))
+
+		current := l.lstat(target)
+		switch current.kind {
+		case nodeLink:
+			if current.dest == source {
+				l.unlink(target)
+			}
+		case nodeFile:
+			// Rendered templates are removed unless they were edited since
+			if l.isTemplate(entry) && l.ownsRendered(target) {
+				l.unlink(target)
+			}
+		case nodeDir:
+			if entry.IsDir() {
+				if err := l.unstowContents(pkg, source, entryRel, target); err != nil {
+					return err
+				}
+				if err := l.refold(target); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ownsRendered reports whether target is a rendered template that still
+// holds the content written to it
+func (l *Linker) ownsRendered(target string) bool {
+	recorded, ok := l.opts.Rendered[target]
+	if !ok {
+		return false
+	}
+	content, err := os.ReadFile(target)
+	return err == nil && HashContent(content) == recorded
+}
+
+// refold turns a directory that only holds links into one package directory
+// back into a single link to that directory
+func (l *Linker) refold(dir string) error {
+	if l.opts.NoFolding {
+		return nil
+	}
+
+	names := l.readDir(dir)
+	if len(names) == 0 {
+		return nil
+	}
+
+	parent := ""
+	for _, name := range names {
+		current := l.lstat(filepath.Join(dir, name))
+		if current.kind != nodeLink {
+			return nil
+		}
+		if _, _, owned := l.owner(current.dest); !owned {
+			return nil
+		}
+		if l.translate(filepath.Base(current.dest)) != name {
+			return nil
+		}
+		if parent == "" {
+			parent = filepath.Dir(current.dest)
+		} else if parent != filepath.Dir(current.dest) {
+			return nil
+		}
+	}
+
+	if l.translate(filepath.Base(parent)) != filepath.Base(dir) || !l.canFold(parent) {
+		return nil
+	}
+
+	// Only fold when every visible entry of the package directory is linked
+	owner, rel, owned := l.owner(parent)
+	if !owned || rel == "" {
+		return nil
+	}
+	ignore, err := l.ignoreFor(owner)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return nil
+	}
+	visible := 0
+	for _, entry := range entries {
+		if !ignore.matches(filepath.Join(rel, entry.Name())) {
+			visible++
+		}
+	}
+	if visible != len(names) {
+		return nil
+	}
+
+	for _, name := range names {
+		l.unlink(filepath.Join(dir, name))
+	}
+	l.rmdir(dir)
+	l.link(dir, parent)
+	return nil
+}
@@ -0,0 +1,287 @@
+package linker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setup creates a stow directory holding the given package files and an
+// empty target directory. HOME points at a scratch directory so a global
+// ignore list on the machine running the tests is never read.
+func setup(t *testing.T, files map[string]string) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("HOME", filepath.Join(root, "home"))
+
+	stowDir := filepath.Join(root, "stow")
+	target := filepath.Join(root, "target")
+	for _, dir := range []string{stowDir, target} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		path := filepath.Join(stowDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return stowDir, target
+}
+
+func newLinker(t *testing.T, opts Options) *Linker {
+	t.Helper()
+	l, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// assertLink checks that path is a symlink resolving to want
+func assertLink(t *testing.T, path, want string) {
+	t.Helper()
+	dest, err := os.Readlink(path)
+	if err != nil {
+		t.Fatalf("%s is not a symlink: %v", path, err)
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(path), dest)
+	}
+	if filepath.Clean(dest) != want {
+		t.Errorf("%s -> %s, want %s", path, dest, want)
+	}
+}
+
+func assertDir(t *testing.T, path string) {
+	t.Helper()
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	if !info.IsDir() {
+		t.Errorf("%s is not a real directory (mode %s)", path, info.Mode())
+	}
+}
+
+func assertMissing(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("%s still exists", path)
+	}
+}
+
+func TestStowFolding(t *testing.T) {
+	stowDir, target := setup(t, map[string]string{
+		"nvim/.config/nvim/init.vim": "set number",
+		"git/.config/git/config":     "[user]",
+	})
+	l := newLinker(t, Options{Dir: stowDir, Target: target})
+
+	if _, err := l.Stow("nvim"); err != nil {
+		t.Fatal(err)
+	}
+	// A directory no other package uses is linked whole
+	assertLink(t, filepath.Join(target, ".config"), filepath.Join(stowDir, "nvim", ".config"))
+
+	if _, err := l.Stow("git"); err != nil {
+		t.Fatal(err)
+	}
+	// A second package sharing the directory unfolds it
+	assertDir(t, filepath.Join(target, ".config"))
+	assertLink(t, filepath.Join(target, ".config", "nvim"), filepath.Join(stowDir, "nvim", ".config", "nvim"))
+	assertLink(t, filepath.Join(target, ".config", "git"), filepath.Join(stowDir, "git", ".config", "git"))
+
+	for _, pkg := range []string{"nvim", "git"} {
+		stowed, err := l.IsStowed(pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stowed {
+			t.Errorf("IsStowed(%s) = false after stowing", pkg)
+		}
+	}
+}
+
+func TestStowNoFolding(t *testing.T) {
+	stowDir, target := setup(t, map[string]string{
+		"nvim/.config/nvim/init.vim": "set number",
+	})
+	l := newLinker(t, Options{Dir: stowDir, Target: target, NoFolding: true})
+
+	if _, err := l.Stow("nvim"); err != nil {
+		t.Fatal(err)
+	}
+	assertDir(t, filepath.Join(target, ".config"))
+	assertDir(t, filepath.Join(target, ".config", "nvim"))
+	assertLink(t, filepath.Join(target, ".config", "nvim", "init.vim"), filepath.Join(stowDir, "nvim", ".config", "nvim", "init.vim"))
+}
+
+func TestStowConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		prep   func(t *testing.T, target string)
+		reason string
+	}{
+		{
+			name: "regular file",
+			prep: func(t *testing.T, target string) {
+				if err := os.WriteFile(filepath.Join(target, ".bashrc"), []byte("local"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reason: "existing target is not owned by stow",
+		},
+		{
+			name: "foreign symlink",
+			prep: func(t *testing.T, target string) {
+				if err := os.Symlink("/etc/bash.bashrc", filepath.Join(target, ".bashrc")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reason: "existing symlink is not owned by stow",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stowDir, target := setup(t, map[string]string{
+				"bash/.bashrc":       "export EDITOR=vim",
+				"bash/.bash_profile": ". ~/.bashrc",
+			})
+			tt.prep(t, target)
+			l := newLinker(t, Options{Dir: stowDir, Target: target})
+
+			conflicts, err := l.Conflicts("bash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conflicts) != 1 {
+				t.Fatalf("Conflicts() = %v, want one conflict", conflicts)
+			}
+			if conflicts[0].Path != filepath.Join(target, ".bashrc") || conflicts[0].Reason != tt.reason {
+				t.Errorf("Conflicts() = %+v, want %s: %s", conflicts[0], ".bashrc", tt.reason)
+			}
+
+			_, err = l.Stow("bash")
+			var conflict *ConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("Stow() error = %v, want *ConflictError", err)
+			}
+			if conflict.Package != "bash" || len(conflict.Conflicts) != 1 {
+				t.Errorf("ConflictError = %+v", conflict)
+			}
+			// A conflicting stow changes nothing
+			assertMissing(t, filepath.Join(target, ".bash_profile"))
+		})
+	}
+}
+
+func TestUnstow(t *testing.T) {
+	tests := []struct {
+		name    string
+		stow    []string
+		unstow  string
+		missing []string
+		links   map[string]string
+	}{
+		{
+			name:    "folded tree",
+			stow:    []string{"nvim"},
+			unstow:  "nvim",
+			missing: []string{".config"},
+		},
+		{
+			name:    "shared tree refolds",
+			stow:    []string{"nvim", "git"},
+			unstow:  "git",
+			missing: []string{".config/git"},
+			links:   map[string]string{".config": "nvim/.config"},
+		},
+		{
+			name:   "package never stowed",
+			stow:   []string{"nvim"},
+			unstow: "git",
+			links:  map[string]string{".config": "nvim/.config"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stowDir, target := setup(t, map[string]string{
+				"nvim/.config/nvim/init.vim": "set number",
+				"git/.config/git/config":     "[user]",
+			})
+			l := newLinker(t, Options{Dir: stowDir, Target: target})
+
+			for _, pkg := range tt.stow {
+				if _, err := l.Stow(pkg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := l.Unstow(tt.unstow); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, path := range tt.missing {
+				assertMissing(t, filepath.Join(target, path))
+			}
+			for path, dest := range tt.links {
+				assertLink(t, filepath.Join(target, path), filepath.Join(stowDir, dest))
+			}
+			if _, err := os.Stat(filepath.Join(stowDir, "nvim", ".config", "nvim", "init.vim")); err != nil {
+				t.Errorf("unstow touched the stow directory: %v", err)
+			}
+		})
+	}
+}
+
+func TestDotfilesMode(t *testing.T) {
+	stowDir, target := setup(t, map[string]string{
+		"shell/dot-bashrc":             "export EDITOR=vim",
+		"shell/dot-config/dot-inputrc": "set editing-mode vi",
+		"shell/plain":                  "kept as is",
+		"shell/dot-local/bin/script":   "#!/bin/sh",
+	})
+	l := newLinker(t, Options{Dir: stowDir, Target: target, Dotfiles: true})
+
+	ops, err := l.Stow("shell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range ops {
+		if strings.Contains(op.Path, "dot-") {
+			t.Errorf("operation %s still uses the dot- prefix", op)
+		}
+	}
+
+	assertLink(t, filepath.Join(target, ".bashrc"), filepath.Join(stowDir, "shell", "dot-bashrc"))
+	assertLink(t, filepath.Join(target, "plain"), filepath.Join(stowDir, "shell", "plain"))
+	// A directory holding dot- names is not folded so they can be translated
+	assertDir(t, filepath.Join(target, ".config"))
+	assertLink(t, filepath.Join(target, ".config", ".inputrc"), filepath.Join(stowDir, "shell", "dot-config", "dot-inputrc"))
+	// One without them still folds
+	assertLink(t, filepath.Join(target, ".local"), filepath.Join(stowDir, "shell", "dot-local"))
+	assertMissing(t, filepath.Join(target, "dot-bashrc"))
+
+	stowed, err := l.IsStowed("shell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stowed {
+		t.Error("IsStowed() = false after stowing")
+	}
+
+	if _, err := l.Unstow("shell"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{".bashrc", ".config/.inputrc", "plain", ".local"} {
+		assertMissing(t, filepath.Join(target, name))
+	}
+}
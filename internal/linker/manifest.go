@@ -0,0 +1,305 @@
+package linker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestVersion is the current link manifest format
+const ManifestVersion = 1
+
+// LinkRecord is a symlink created in the target directory, or a file
+// rendered from a template
+type LinkRecord struct {
+	Source    string    `json:"source"`
+	Target    string    `json:"target"`
+	Hash      string    `json:"hash,omitempty"` // Content hash of a rendered template
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Rendered reports whether the record is a rendered template rather than a link
+func (r LinkRecord) Rendered() bool {
+	return r.Hash != ""
+}
+
+// PackageRecord holds the links created for one stow package
+type PackageRecord struct {
+	StowDir   string       `json:"stow_dir"`
+	TargetDir string       `json:"target_dir"`
+	Dotfiles  bool         `json:"dotfiles,omitempty"`
+	Links     []LinkRecord `json:"links"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Manifest records every link created per stow package so it can be
+// removed or verified later without re-walking the filesystem
+type Manifest struct {
+	Version  int                       `json:"version"`
+	Packages map[string]*PackageRecord `json:"packages"`
+
+	path string
+}
+
+// Drift describes a recorded link that no longer matches the filesystem
+type Drift struct {
+	Package string `json:"package"`
+	Target  string `json:"target"`
+	Source  string `json:"source"`
+	Problem string `json:"problem"` // missing, replaced, retargeted, broken, edited, stale
+}
+
+// LoadManifest reads the manifest at path, returning an empty one if it does not exist
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{
+		Version:  ManifestVersion,
+		Packages: make(map[string]*PackageRecord),
+		path:     path,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse link manifest %s: %v", path, err)
+	}
+	if m.Packages == nil {
+		m.Packages = make(map[string]*PackageRecord)
+	}
+	m.path = path
+
+	return m, nil
+}
+
+// Save writes the manifest back to the path it was loaded from
+func (m *Manifest) Save() error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(m.path, data, 0644)
+}
+
+// Apply updates the manifest with operations performed for pkg. Links are
+// attributed to the package owning their source, so links re-created while
+// unfolding another package's tree are recorded against that package.
+func (m *Manifest) Apply(pkg string, opts Options, ops []Operation) {
+	now := time.Now()
+	stowDir, _ := filepath.Abs(opts.Dir)
+	targetDir, _ := filepath.Abs(opts.Target)
+
+	for _, op := range ops {
+		switch op.Type {
+		case OpUnlink, OpRmdir:
+			m.Remove(op.Path)
+		case OpLink, OpRender:
+			m.Remove(op.Path)
+			owner := pkg
+			if rel, err := filepath.Rel(stowDir, op.Source); err == nil {
+				if first := strings.SplitN(rel, string(filepath.Separator), 2)[0]; first != ".." && first != "." {
+					owner = first
+				}
+			}
+			record := m.Packages[owner]
+			if record == nil {
+				record = &PackageRecord{StowDir: stowDir, TargetDir: targetDir, Dotfiles: opts.Dotfiles}
+				m.Packages[owner] = record
+			}
+			link := LinkRecord{Source: op.Source, Target: op.Path, CreatedAt: now}
+			if op.Type == OpRender {
+				link.Hash = HashContent(op.content)
+			}
+			record.Links = append(record.Links, link)
+			record.UpdatedAt = now
+		}
+	}
+
+	if record, ok := m.Packages[pkg]; ok {
+		record.StowDir = stowDir
+		record.TargetDir = targetDir
+		record.Dotfiles = opts.Dotfiles
+	}
+}
+
+// Links returns the links recorded for pkg
+func (m *Manifest) Links(pkg string) []LinkRecord {
+	if record, ok := m.Packages[pkg]; ok {
+		return record.Links
+	}
+	return nil
+}
+
+// Forget drops every record for pkg
+func (m *Manifest) Forget(pkg string) {
+	delete(m.Packages, pkg)
+}
+
+// PackageNames returns the recorded package names in sorted order
+func (m *Manifest) PackageNames() []string {
+	var names []string
+	for name := range m.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Verify compares the recorded links of pkg with the filesystem
+func (m *Manifest) Verify(pkg string) []Drift {
+	var drift []Drift
+
+	for _, link := range m.Links(pkg) {
+		if problem := checkLink(link); problem != "" {
+			drift = append(drift, Drift{Package: pkg, Target: link.Target, Source: link.Source, Problem: problem})
+		}
+	}
+
+	return drift
+}
+
+// VerifyAll compares every recorded link with the filesystem
+func (m *Manifest) VerifyAll() []Drift {
+	var drift []Drift
+	for _, name := range m.PackageNames() {
+		drift = append(drift, m.Verify(name)...)
+	}
+	return drift
+}
+
+// VerifyRendered re-renders the templates recorded for pkg and reports the
+// files that are out of date with their template or data as "stale"
+func (m *Manifest) VerifyRendered(pkg string, render func(source string) ([]byte, error)) []Drift {
+	var drift []Drift
+	for _, link := range m.Links(pkg) {
+		if !link.Rendered() || checkLink(link) != "" {
+			continue
+		}
+		content, err := render(link.Source)
+		if err != nil {
+			drift = append(drift, Drift{Package: pkg, Target: link.Target, Source: link.Source, Problem: fmt.Sprintf("render failed: %v", err)})
+		} else if HashContent(content) != link.Hash {
+			drift = append(drift, Drift{Package: pkg, Target: link.Target, Source: link.Source, Problem: "stale"})
+		}
+	}
+	return drift
+}
+
+// RenderedHashes maps the target of every rendered template to its content hash
+func (m *Manifest) RenderedHashes() map[string]string {
+	hashes := make(map[string]string)
+	for _, record := range m.Packages {
+		for _, link := range record.Links {
+			if link.Rendered() {
+				hashes[link.Target] = link.Hash
+			}
+		}
+	}
+	return hashes
+}
+
+// Orphans returns recorded links of pkg whose source no longer exists
+func (m *Manifest) Orphans(pkg string) []LinkRecord {
+	var orphans []LinkRecord
+	for _, link := range m.Links(pkg) {
+		if _, err := os.Lstat(link.Source); err != nil {
+			orphans = append(orphans, link)
+		}
+	}
+	return orphans
+}
+
+// Remove drops the record for a target path, whichever package owns it
+func (m *Manifest) Remove(target string) {
+	for name, record := range m.Packages {
+		kept := record.Links[:0]
+		for _, link := range record.Links {
+			if link.Target != target {
+				kept = append(kept, link)
+			}
+		}
+		record.Links = kept
+		if len(kept) == 0 {
+			delete(m.Packages, name)
+		}
+	}
+}
+
+// checkLink returns an empty string when the link is intact
+func checkLink(link LinkRecord) string {
+	info, err := os.Lstat(link.Target)
+	if err != nil {
+		return "missing"
+	}
+	if link.Rendered() {
+		return checkRendered(link, info)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return "replaced"
+	}
+
+	dest, err := os.Readlink(link.Target)
+	if err != nil {
+		return "replaced"
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(link.Target), dest)
+	}
+	if filepath.Clean(dest) != filepath.Clean(link.Source) {
+		return "retargeted"
+	}
+
+	if _, err := os.Stat(link.Source); err != nil {
+		return "broken"
+	}
+
+	return ""
+}
+
+// checkRendered returns an empty string when a rendered file still holds
+// the content written to it
+func checkRendered(link LinkRecord, info os.FileInfo) string {
+	if !info.Mode().IsRegular() {
+		return "replaced"
+	}
+	content, err := os.ReadFile(link.Target)
+	if err != nil || HashContent(content) != link.Hash {
+		return "edited"
+	}
+	if _, err := os.Stat(link.Source); err != nil {
+		return "broken"
+	}
+	return ""
+}
+
+// Prune removes recorded links that still point at their recorded source,
+// and rendered files that were not edited since they were written. It is
+// used to clean up targets whose source was removed from the package.
+func (l *Linker) Prune(links []LinkRecord) ([]Operation, error) {
+	l.reset()
+	for _, link := range links {
+		current := l.lstat(link.Target)
+		if current.kind == nodeLink && current.dest == filepath.Clean(link.Source) {
+			l.unlink(link.Target)
+		}
+		if current.kind == nodeFile && link.Rendered() {
+			if content, err := os.ReadFile(link.Target); err == nil && HashContent(content) == link.Hash {
+				l.unlink(link.Target)
+			}
+		}
+	}
+	return l.commit("")
+}
@@ -0,0 +1,104 @@
+package pkgmanager
+
+import "regexp"
+
+// ApkManager implements PackageManager for apk (Alpine Linux)
+type ApkManager struct {
+	Runner Runner
+}
+
+func (a *ApkManager) GetName() string {
+	return "apk"
+}
+
+func (a *ApkManager) IsAvailable() bool {
+	return runnerOrDefault(a.Runner).LookPath("apk")
+}
+
+func (a *ApkManager) Install(packages []string, packageType string) error {
+	packages = nativePackages(a.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(a.Runner), "apk", append([]string{"add"}, packages...)...)
+}
+
+func (a *ApkManager) IsInstalled(pkg string, packageType string) (bool, error) {
+	if packageType == "tap" || (packageType == "cask" && !HasMapping(a.GetName(), pkg)) {
+		return false, nil
+	}
+
+	name := ResolveName(a.GetName(), pkg)
+	if name == SkipPackage {
+		return true, nil
+	}
+
+	err := runnerOrDefault(a.Runner).Run("apk", "info", "-e", name)
+	return err == nil, nil
+}
+
+func (a *ApkManager) ListInstalled(packageType string) ([]string, error) {
+	if packageType == "cask" || packageType == "tap" {
+		return []string{}, nil
+	}
+
+	// Without arguments `apk info` prints one installed package per line
+	output, err := runnerOrDefault(a.Runner).Output("apk", "info")
+	if err != nil {
+		return nil, err
+	}
+
+	return canonicalNames(a.GetName(), parseLines(output)), nil
+}
+
+// GenerateInstallFile writes a list in the same format as /etc/apk/world
+func (a *ApkManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
+	allPackages := nativePackages(a.GetName(), brews, "brew")
+	allPackages = append(allPackages, nativePackages(a.GetName(), casks, "cask")...)
+	return packageListFile(allPackages), nil
+}
+
+func (a *ApkManager) InstallFromFile(filePath string) error {
+	packages, err := readPackageFile(filePath)
+	if err != nil {
+		return err
+	}
+	return a.Install(packages, "brew")
+}
+
+// InstalledVersions returns installed versions from `apk info -v`
+func (a *ApkManager) InstalledVersions(packageType string) (map[string]string, error) {
+	if packageType == "tap" {
+		return map[string]string{}, nil
+	}
+
+	output, err := runnerOrDefault(a.Runner).Output("apk", "info", "-v")
+	if err != nil {
+		return nil, err
+	}
+	return canonicalVersions(a.GetName(), ParseApkInfoVersions(output)), nil
+}
+
+var apkPackagePattern = regexp.MustCompile(`^(.+)-([0-9][^-]*-r[0-9]+)$`)
+
+// ParseApkInfoVersions splits the "name-version-rN" lines of `apk info -v`
+func ParseApkInfoVersions(output []byte) map[string]string {
+	versions := make(map[string]string)
+	for _, line := range parseLines(output) {
+		if match := apkPackagePattern.FindStringSubmatch(line); match != nil {
+			versions[match[1]] = match[2]
+		}
+	}
+	return versions
+}
+
+// InstallVersion installs name=version
+func (a *ApkManager) InstallVersion(pkg, version, packageType string) error {
+	packages := nativePackages(a.GetName(), []string{pkg}, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(a.Runner), "apk", "add", packages[0]+"="+version)
+}
@@ -0,0 +1,51 @@
+package pkgmanager
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApkInstall(t *testing.T) {
+	f := NewFakeRunner("apk")
+	a := &ApkManager{Runner: f}
+
+	if err := a.Install([]string{"git", "python", "awscli", "mas"}, "brew"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if err := a.Install([]string{"iterm2"}, "cask"); err != nil {
+		t.Fatalf("Install casks: %v", err)
+	}
+	assertCommands(t, f, "apk add git python3 aws-cli")
+}
+
+func TestApkIsInstalled(t *testing.T) {
+	f := NewFakeRunner("apk")
+	f.Errors["apk info -e tmux"] = errors.New("exit status 1")
+	a := &ApkManager{Runner: f}
+
+	for pkg, want := range map[string]bool{"openssh": true, "tmux": false} {
+		got, err := a.IsInstalled(pkg, "brew")
+		if err != nil {
+			t.Fatalf("IsInstalled(%q): %v", pkg, err)
+		}
+		if got != want {
+			t.Errorf("IsInstalled(%q) = %v, want %v", pkg, got, want)
+		}
+	}
+	if !f.Ran("apk info -e openssh-client") || !f.Ran("apk info -e tmux") {
+		t.Errorf("commands = %q, want apk info -e openssh-client and apk info -e tmux", f.Commands)
+	}
+}
+
+func TestApkListInstalled(t *testing.T) {
+	f := NewFakeRunner("apk")
+	f.Outputs["apk info"] = "musl\nbusybox\nopenssh-client\nnodejs\n"
+	a := &ApkManager{Runner: f}
+
+	got, err := a.ListInstalled("brew")
+	if err != nil {
+		t.Fatalf("ListInstalled: %v", err)
+	}
+	assertNames(t, got, []string{"musl", "busybox", "openssh", "node"})
+	assertCommands(t, f, "apk info")
+}
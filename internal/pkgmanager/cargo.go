@@ -0,0 +1,141 @@
+package pkgmanager
+
+import "strings"
+
+// CargoManager implements PackageManager for crates installed with `cargo install`.
+// It is never detected as the primary package manager and is only used for
+// packages listed under "cargo" in the configuration.
+type CargoManager struct {
+	Runner Runner
+}
+
+func (c *CargoManager) GetName() string {
+	return "cargo"
+}
+
+// ConcurrentInstalls is true since cargo builds each crate independently
+func (c *CargoManager) ConcurrentInstalls() bool {
+	return true
+}
+
+func (c *CargoManager) IsAvailable() bool {
+	return runnerOrDefault(c.Runner).LookPath("cargo")
+}
+
+func (c *CargoManager) Install(packages []string, packageType string) error {
+	packages = nativePackages(c.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runnerOrDefault(c.Runner).Run("cargo", append([]string{"install"}, packages...)...)
+}
+
+func (c *CargoManager) IsInstalled(pkg string, packageType string) (bool, error) {
+	if packageType == "cask" || packageType == "tap" {
+		return false, nil
+	}
+
+	name := ResolveName(c.GetName(), pkg)
+	if name == SkipPackage {
+		return true, nil
+	}
+
+	installed, err := c.listCrates()
+	if err != nil {
+		return false, err
+	}
+	for _, crate := range installed {
+		if crate == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *CargoManager) ListInstalled(packageType string) ([]string, error) {
+	if packageType == "cask" || packageType == "tap" {
+		return []string{}, nil
+	}
+
+	installed, err := c.listCrates()
+	if err != nil {
+		return nil, err
+	}
+	return canonicalNames(c.GetName(), installed), nil
+}
+
+func (c *CargoManager) listCrates() ([]string, error) {
+	output, err := runnerOrDefault(c.Runner).Output("cargo", "install", "--list")
+	if err != nil {
+		return nil, err
+	}
+	return ParseCargoInstallList(output), nil
+}
+
+// ParseCargoInstallList extracts crate names from `cargo install --list`:
+//
+//	ripgrep v14.1.0:
+//	    rg
+func ParseCargoInstallList(output []byte) []string {
+	var crates []string
+	for _, line := range strings.Split(string(output), "\n") {
+		// Binaries are indented below their crate
+		if line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		crates = append(crates, strings.Fields(line)[0])
+	}
+	return crates
+}
+
+// InstalledVersions returns installed crate versions from `cargo install --list`
+func (c *CargoManager) InstalledVersions(packageType string) (map[string]string, error) {
+	if packageType == "cask" || packageType == "tap" {
+		return map[string]string{}, nil
+	}
+
+	output, err := runnerOrDefault(c.Runner).Output("cargo", "install", "--list")
+	if err != nil {
+		return nil, err
+	}
+	return canonicalVersions(c.GetName(), ParseCargoInstallVersions(output)), nil
+}
+
+// ParseCargoInstallVersions extracts crate versions from `cargo install --list`
+func ParseCargoInstallVersions(output []byte) map[string]string {
+	versions := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		versions[fields[0]] = strings.TrimSuffix(strings.TrimPrefix(fields[1], "v"), ":")
+	}
+	return versions
+}
+
+// InstallVersion installs the given crate version
+func (c *CargoManager) InstallVersion(pkg, version, packageType string) error {
+	packages := nativePackages(c.GetName(), []string{pkg}, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runnerOrDefault(c.Runner).Run("cargo", "install", "--version", version, packages[0])
+}
+
+func (c *CargoManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
+	return packageListFile(nativePackages(c.GetName(), brews, "brew")), nil
+}
+
+func (c *CargoManager) InstallFromFile(filePath string) error {
+	packages, err := readPackageFile(filePath)
+	if err != nil {
+		return err
+	}
+	return c.Install(packages, "brew")
+}
@@ -0,0 +1,87 @@
+package pkgmanager
+
+// DnfManager implements PackageManager for dnf (Fedora, RHEL 8+)
+type DnfManager struct {
+	Runner Runner
+}
+
+func (d *DnfManager) GetName() string {
+	return "dnf"
+}
+
+func (d *DnfManager) IsAvailable() bool {
+	return runnerOrDefault(d.Runner).LookPath("dnf")
+}
+
+func (d *DnfManager) Install(packages []string, packageType string) error {
+	packages = nativePackages(d.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(d.Runner), "dnf", append([]string{"install", "-y"}, packages...)...)
+}
+
+func (d *DnfManager) IsInstalled(pkg string, packageType string) (bool, error) {
+	if packageType == "tap" || (packageType == "cask" && !HasMapping(d.GetName(), pkg)) {
+		return false, nil
+	}
+
+	name := ResolveName(d.GetName(), pkg)
+	if name == SkipPackage {
+		return true, nil
+	}
+
+	err := runnerOrDefault(d.Runner).Run("rpm", "-q", name)
+	return err == nil, nil
+}
+
+func (d *DnfManager) ListInstalled(packageType string) ([]string, error) {
+	if packageType == "cask" || packageType == "tap" {
+		return []string{}, nil
+	}
+
+	output, err := runnerOrDefault(d.Runner).Output("rpm", "-qa", "--queryformat", "%{NAME}\n")
+	if err != nil {
+		return nil, err
+	}
+
+	return canonicalNames(d.GetName(), parseLines(output)), nil
+}
+
+func (d *DnfManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
+	allPackages := nativePackages(d.GetName(), brews, "brew")
+	allPackages = append(allPackages, nativePackages(d.GetName(), casks, "cask")...)
+	return packageListFile(allPackages), nil
+}
+
+func (d *DnfManager) InstallFromFile(filePath string) error {
+	packages, err := readPackageFile(filePath)
+	if err != nil {
+		return err
+	}
+	return d.Install(packages, "brew")
+}
+
+// InstalledVersions returns installed versions from the rpm database
+func (d *DnfManager) InstalledVersions(packageType string) (map[string]string, error) {
+	if packageType == "tap" {
+		return map[string]string{}, nil
+	}
+
+	output, err := runnerOrDefault(d.Runner).Output("rpm", "-qa", "--queryformat", "%{NAME} %{VERSION}-%{RELEASE}\n")
+	if err != nil {
+		return nil, err
+	}
+	return canonicalVersions(d.GetName(), ParseNameVersionList(output)), nil
+}
+
+// InstallVersion installs name-version
+func (d *DnfManager) InstallVersion(pkg, version, packageType string) error {
+	packages := nativePackages(d.GetName(), []string{pkg}, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(d.Runner), "dnf", "install", "-y", packages[0]+"-"+version)
+}
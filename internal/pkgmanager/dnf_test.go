@@ -0,0 +1,70 @@
+package pkgmanager
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDnfInstall(t *testing.T) {
+	f := NewFakeRunner("dnf")
+	d := &DnfManager{Runner: f}
+
+	if err := d.Install([]string{"git", "fd", "mas"}, "brew"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if err := d.Install([]string{"visual-studio-code", "iterm2"}, "cask"); err != nil {
+		t.Fatalf("Install casks: %v", err)
+	}
+	if err := d.Install([]string{"homebrew/cask-fonts"}, "tap"); err != nil {
+		t.Fatalf("Install taps: %v", err)
+	}
+	assertCommands(t, f,
+		"dnf install -y git fd-find",
+		"dnf install -y code",
+	)
+}
+
+func TestDnfIsInstalled(t *testing.T) {
+	f := NewFakeRunner("dnf")
+	f.Errors["rpm -q ripgrep"] = errors.New("exit status 1")
+	d := &DnfManager{Runner: f}
+
+	for pkg, want := range map[string]bool{"fd": true, "ripgrep": false} {
+		got, err := d.IsInstalled(pkg, "brew")
+		if err != nil {
+			t.Fatalf("IsInstalled(%q): %v", pkg, err)
+		}
+		if got != want {
+			t.Errorf("IsInstalled(%q) = %v, want %v", pkg, got, want)
+		}
+	}
+	if !f.Ran("rpm -q fd-find") || !f.Ran("rpm -q ripgrep") {
+		t.Errorf("commands = %q, want rpm -q fd-find and rpm -q ripgrep", f.Commands)
+	}
+
+	f.Reset()
+	if installed, _ := d.IsInstalled("iterm2", "cask"); installed {
+		t.Error("unmapped cask reported as installed")
+	}
+	assertCommands(t, f)
+}
+
+func TestDnfListInstalled(t *testing.T) {
+	f := NewFakeRunner("dnf")
+	f.Outputs["rpm -qa --queryformat %{NAME}"] = "git\nfd-find\ncode\nbash\n"
+	d := &DnfManager{Runner: f}
+
+	got, err := d.ListInstalled("brew")
+	if err != nil {
+		t.Fatalf("ListInstalled: %v", err)
+	}
+	assertNames(t, got, []string{"git", "fd", "visual-studio-code", "bash"})
+	assertCommands(t, f, "rpm -qa --queryformat %{NAME}")
+
+	f.Reset()
+	taps, err := d.ListInstalled("tap")
+	if err != nil || len(taps) != 0 {
+		t.Errorf("ListInstalled(tap) = %q, %v; want none", taps, err)
+	}
+	assertCommands(t, f)
+}
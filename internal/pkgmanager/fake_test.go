@@ -0,0 +1,72 @@
+package pkgmanager
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FakeRunner is a Runner that records commands instead of executing them,
+// so package manager logic can be exercised without the real tools. Commands
+// are keyed by their full command line, e.g. "dpkg --get-selections".
+type FakeRunner struct {
+	Outputs  map[string]string // Output returned per command line
+	Errors   map[string]error  // Error returned per command line
+	Paths    map[string]bool   // Commands LookPath reports as installed
+	Commands []string          // Every command line run, in order
+}
+
+// NewFakeRunner returns a FakeRunner on which the given commands exist
+func NewFakeRunner(paths ...string) *FakeRunner {
+	f := &FakeRunner{
+		Outputs: make(map[string]string),
+		Errors:  make(map[string]error),
+		Paths:   make(map[string]bool),
+	}
+	for _, path := range paths {
+		f.Paths[path] = true
+	}
+	return f
+}
+
+// commandLine joins a command and its arguments with spaces
+func commandLine(name string, args ...string) string {
+	return strings.TrimSpace(name + " " + strings.Join(args, " "))
+}
+
+func (f *FakeRunner) Run(name string, args ...string) error {
+	line := commandLine(name, args...)
+	f.Commands = append(f.Commands, line)
+	return f.Errors[line]
+}
+
+func (f *FakeRunner) Output(name string, args ...string) ([]byte, error) {
+	line := commandLine(name, args...)
+	f.Commands = append(f.Commands, line)
+	if err, ok := f.Errors[line]; ok {
+		return nil, err
+	}
+	output, ok := f.Outputs[line]
+	if !ok {
+		return nil, fmt.Errorf("no output recorded for %q", line)
+	}
+	return []byte(output), nil
+}
+
+func (f *FakeRunner) LookPath(name string) bool {
+	return f.Paths[name]
+}
+
+// Ran reports whether the command line was run
+func (f *FakeRunner) Ran(line string) bool {
+	for _, command := range f.Commands {
+		if command == line {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset forgets the recorded commands
+func (f *FakeRunner) Reset() {
+	f.Commands = nil
+}
@@ -0,0 +1,116 @@
+package pkgmanager
+
+import "strings"
+
+// FlatpakRemote is the remote applications are installed from
+const FlatpakRemote = "flathub"
+
+// FlatpakManager implements PackageManager for Flatpak applications. Only
+// packages mapped to an application ID (or written as one, such as
+// "org.mozilla.firefox") are handled; command line tools are left to the
+// system package manager.
+type FlatpakManager struct {
+	Runner Runner
+}
+
+func (f *FlatpakManager) GetName() string {
+	return "flatpak"
+}
+
+func (f *FlatpakManager) IsAvailable() bool {
+	return runnerOrDefault(f.Runner).LookPath("flatpak")
+}
+
+// flatpakApps resolves packages to application IDs, dropping anything that is not an app
+func (f *FlatpakManager) flatpakApps(packages []string, packageType string) []string {
+	if packageType == "tap" {
+		return nil
+	}
+
+	var apps []string
+	for _, pkg := range packages {
+		name := ResolveName(f.GetName(), pkg)
+		if name == SkipPackage || !isFlatpakAppID(name) {
+			continue
+		}
+		apps = append(apps, name)
+	}
+	return apps
+}
+
+// isFlatpakAppID reports whether name looks like a reverse-DNS application ID
+func isFlatpakAppID(name string) bool {
+	return strings.Count(name, ".") >= 2 && !strings.ContainsAny(name, " /")
+}
+
+func (f *FlatpakManager) Install(packages []string, packageType string) error {
+	apps := f.flatpakApps(packages, packageType)
+	if len(apps) == 0 {
+		return nil
+	}
+
+	args := append([]string{"install", "-y", "--noninteractive", FlatpakRemote}, apps...)
+	return runnerOrDefault(f.Runner).Run("flatpak", args...)
+}
+
+func (f *FlatpakManager) IsInstalled(pkg string, packageType string) (bool, error) {
+	apps := f.flatpakApps([]string{pkg}, packageType)
+	if len(apps) == 0 {
+		return false, nil
+	}
+
+	err := runnerOrDefault(f.Runner).Run("flatpak", "info", apps[0])
+	return err == nil, nil
+}
+
+func (f *FlatpakManager) ListInstalled(packageType string) ([]string, error) {
+	if packageType == "tap" {
+		return []string{}, nil
+	}
+
+	output, err := runnerOrDefault(f.Runner).Output("flatpak", "list", "--app", "--columns=application")
+	if err != nil {
+		return nil, err
+	}
+
+	return canonicalNames(f.GetName(), ParseFlatpakList(output)), nil
+}
+
+// ParseFlatpakList extracts application IDs from `flatpak list --columns=application`
+func ParseFlatpakList(output []byte) []string {
+	var apps []string
+	for _, line := range parseLines(output) {
+		if line == "Application ID" {
+			continue
+		}
+		apps = append(apps, strings.Fields(line)[0])
+	}
+	return apps
+}
+
+// InstalledVersions returns application versions from `flatpak list`
+func (f *FlatpakManager) InstalledVersions(packageType string) (map[string]string, error) {
+	if packageType == "tap" {
+		return map[string]string{}, nil
+	}
+
+	output, err := runnerOrDefault(f.Runner).Output("flatpak", "list", "--app", "--columns=application,version")
+	if err != nil {
+		return nil, err
+	}
+	return canonicalVersions(f.GetName(), ParseNameVersionList(output)), nil
+}
+
+func (f *FlatpakManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
+	apps := f.flatpakApps(brews, "brew")
+	apps = append(apps, f.flatpakApps(casks, "cask")...)
+	return packageListFile(apps), nil
+}
+
+func (f *FlatpakManager) InstallFromFile(filePath string) error {
+	packages, err := readPackageFile(filePath)
+	if err != nil {
+		return err
+	}
+	return f.Install(packages, "cask")
+}
@@ -0,0 +1,54 @@
+package pkgmanager
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFlatpakInstall(t *testing.T) {
+	f := NewFakeRunner("flatpak")
+	fp := &FlatpakManager{Runner: f}
+
+	if err := fp.Install([]string{"visual-studio-code", "org.gimp.GIMP", "iterm2", "mas"}, "cask"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if err := fp.Install([]string{"git", "ripgrep"}, "brew"); err != nil {
+		t.Fatalf("Install brews: %v", err)
+	}
+	assertCommands(t, f, "flatpak install -y --noninteractive flathub com.visualstudio.code org.gimp.GIMP")
+}
+
+func TestFlatpakIsInstalled(t *testing.T) {
+	f := NewFakeRunner("flatpak")
+	f.Errors["flatpak info com.slack.Slack"] = errors.New("exit status 1")
+	fp := &FlatpakManager{Runner: f}
+
+	for pkg, want := range map[string]bool{"firefox": true, "slack": false, "git": false} {
+		got, err := fp.IsInstalled(pkg, "cask")
+		if err != nil {
+			t.Fatalf("IsInstalled(%q): %v", pkg, err)
+		}
+		if got != want {
+			t.Errorf("IsInstalled(%q) = %v, want %v", pkg, got, want)
+		}
+	}
+	if !f.Ran("flatpak info org.mozilla.firefox") || !f.Ran("flatpak info com.slack.Slack") {
+		t.Errorf("commands = %q, want flatpak info for firefox and slack", f.Commands)
+	}
+	if f.Ran("flatpak info git") {
+		t.Error("flatpak info run for a package that is not an app")
+	}
+}
+
+func TestFlatpakListInstalled(t *testing.T) {
+	f := NewFakeRunner("flatpak")
+	f.Outputs["flatpak list --app --columns=application"] = "Application ID\ncom.visualstudio.code\norg.gimp.GIMP\n"
+	fp := &FlatpakManager{Runner: f}
+
+	got, err := fp.ListInstalled("cask")
+	if err != nil {
+		t.Fatalf("ListInstalled: %v", err)
+	}
+	assertNames(t, got, []string{"visual-studio-code", "org.gimp.GIMP"})
+	assertCommands(t, f, "flatpak list --app --columns=application")
+}
@@ -27,24 +27,104 @@ type PackageManager interface {
 
 // GetPackageManager returns the appropriate package manager for the current OS
 func GetPackageManager() (PackageManager, error) {
-	switch runtime.GOOS {
+	return DetectPackageManager(runtime.GOOS, ExecRunner{})
+}
+
+// DetectPackageManager picks the package manager for goos, looking commands
+// up with r. The returned manager runs its commands through r as well.
+func DetectPackageManager(goos string, r Runner) (PackageManager, error) {
+	switch goos {
 	case "darwin":
-		return &HomebrewManager{}, nil
+		return &HomebrewManager{Runner: r}, nil
 	case "linux":
 		// Try to detect which Linux package manager is available
-		if commandExists("pacman") {
-			return &PacmanManager{}, nil
+		if r.LookPath("pacman") {
+			return &PacmanManager{Runner: r}, nil
+		}
+		if r.LookPath("apt-get") {
+			return &AptManager{Runner: r}, nil
+		}
+		if r.LookPath("dnf") {
+			return &DnfManager{Runner: r}, nil
+		}
+		if r.LookPath("yum") {
+			return &YumManager{Runner: r}, nil
 		}
-		if commandExists("apt-get") {
-			return &AptManager{}, nil
+		if r.LookPath("zypper") {
+			return &ZypperManager{Runner: r}, nil
 		}
-		if commandExists("yum") {
-			return &YumManager{}, nil
+		if r.LookPath("apk") {
+			return &ApkManager{Runner: r}, nil
+		}
+		// NixOS has no traditional package manager, use the user profile
+		if r.LookPath("nix") {
+			return &NixManager{Runner: r}, nil
 		}
 		return nil, fmt.Errorf("no supported package manager found on Linux")
 	default:
-		return nil, fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		return nil, fmt.Errorf("unsupported operating system: %s", goos)
+	}
+}
+
+// ManagerNames lists the package managers that can be named in the config's
+// "packages" section, in the order they are processed
+var ManagerNames = []string{"homebrew", "pacman", "apt", "dnf", "yum", "zypper", "apk", "nix", "flatpak", "cargo"}
+
+// GetPackageManagerByName returns the package manager with the given name.
+// "brew" and "linuxbrew" are accepted for Homebrew and "apt-get" for apt.
+func GetPackageManagerByName(name string) (PackageManager, error) {
+	return managerByName(name, nil)
+}
+
+// managerByName returns the package manager with the given name, running
+// its commands through r (os/exec when nil)
+func managerByName(name string, r Runner) (PackageManager, error) {
+	switch strings.ToLower(name) {
+	case "homebrew", "brew", "linuxbrew":
+		return &HomebrewManager{Runner: r}, nil
+	case "pacman":
+		return &PacmanManager{Runner: r}, nil
+	case "apt", "apt-get":
+		return &AptManager{Runner: r}, nil
+	case "dnf":
+		return &DnfManager{Runner: r}, nil
+	case "yum":
+		return &YumManager{Runner: r}, nil
+	case "zypper":
+		return &ZypperManager{Runner: r}, nil
+	case "apk":
+		return &ApkManager{Runner: r}, nil
+	case "nix":
+		return &NixManager{Runner: r}, nil
+	case "flatpak":
+		return &FlatpakManager{Runner: r}, nil
+	case "cargo":
+		return &CargoManager{Runner: r}, nil
+	default:
+		return nil, fmt.Errorf("unknown package manager: %s", name)
+	}
+}
+
+// AvailableManagers returns every known package manager installed on this
+// system. yum is left out when dnf is present since it is usually an alias.
+func AvailableManagers() []PackageManager {
+	return DetectAvailableManagers(ExecRunner{})
+}
+
+// DetectAvailableManagers is AvailableManagers looking commands up with r.
+// The returned managers run their commands through r as well.
+func DetectAvailableManagers(r Runner) []PackageManager {
+	var managers []PackageManager
+	for _, name := range ManagerNames {
+		if name == "yum" && r.LookPath("dnf") {
+			continue
+		}
+		pm, _ := managerByName(name, r)
+		if pm.IsAvailable() {
+			managers = append(managers, pm)
+		}
 	}
+	return managers
 }
 
 // commandExists checks if a command exists in PATH
@@ -54,14 +134,16 @@ func commandExists(cmd string) bool {
 }
 
 // HomebrewManager implements PackageManager for Homebrew (macOS)
-type HomebrewManager struct{}
+type HomebrewManager struct {
+	Runner Runner
+}
 
 func (h *HomebrewManager) GetName() string {
 	return "homebrew"
 }
 
 func (h *HomebrewManager) IsAvailable() bool {
-	return commandExists("brew")
+	return runnerOrDefault(h.Runner).LookPath("brew")
 }
 
 func (h *HomebrewManager) Install(packages []string, packageType string) error {
@@ -69,13 +151,14 @@ func (h *HomebrewManager) Install(packages []string, packageType string) error {
 		return nil
 	}
 
+	r := runnerOrDefault(h.Runner)
+
 	var args []string
 	if packageType == "cask" {
 		args = append([]string{"install", "--cask"}, packages...)
 	} else if packageType == "tap" {
 		for _, pkg := range packages {
-			cmd := exec.Command("brew", "tap", pkg)
-			if err := cmd.Run(); err != nil {
+			if err := r.Run("brew", "tap", pkg); err != nil {
 				return fmt.Errorf("failed to tap %s: %v", pkg, err)
 			}
 		}
@@ -84,62 +167,72 @@ func (h *HomebrewManager) Install(packages []string, packageType string) error {
 		args = append([]string{"install"}, packages...)
 	}
 
-	cmd := exec.Command("brew", args...)
-	return cmd.Run()
+	return r.Run("brew", args...)
 }
 
 func (h *HomebrewManager) IsInstalled(pkg string, packageType string) (bool, error) {
+	r := runnerOrDefault(h.Runner)
+
 	if packageType == "tap" {
-		cmd := exec.Command("brew", "tap")
-		output, err := cmd.Output()
+		output, err := r.Output("brew", "tap")
 		if err != nil {
 			return false, err
 		}
-		taps := strings.Split(strings.TrimSpace(string(output)), "\n")
-		for _, tap := range taps {
-			if strings.TrimSpace(tap) == pkg {
+		for _, tap := range parseLines(output) {
+			if tap == pkg {
 				return true, nil
 			}
 		}
 		return false, nil
 	}
 
-	var cmd *exec.Cmd
+	var err error
 	if packageType == "cask" {
-		cmd = exec.Command("brew", "list", "--cask", pkg)
+		err = r.Run("brew", "list", "--cask", pkg)
 	} else {
-		cmd = exec.Command("brew", "list", pkg)
+		err = r.Run("brew", "list", pkg)
 	}
-
-	err := cmd.Run()
 	return err == nil, nil
 }
 
 func (h *HomebrewManager) ListInstalled(packageType string) ([]string, error) {
-	var cmd *exec.Cmd
+	args := []string{"list", "--formula"}
 	if packageType == "cask" {
-		cmd = exec.Command("brew", "list", "--cask")
+		args = []string{"list", "--cask"}
 	} else if packageType == "tap" {
-		cmd = exec.Command("brew", "tap")
-	} else {
-		cmd = exec.Command("brew", "list", "--formula")
+		args = []string{"tap"}
 	}
 
-	output, err := cmd.Output()
+	output, err := runnerOrDefault(h.Runner).Output("brew", args...)
 	if err != nil {
 		return nil, err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var packages []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			packages = append(packages, line)
-		}
+	return ParseBrewList(output), nil
+}
+
+// ParseBrewList extracts names from `brew list --formula`, `brew list --cask`
+// or `brew tap`, which print one name per line
+func ParseBrewList(output []byte) []string {
+	return parseLines(output)
+}
+
+// InstalledVersions returns installed formula or cask versions from `brew list --versions`
+func (h *HomebrewManager) InstalledVersions(packageType string) (map[string]string, error) {
+	if packageType == "tap" {
+		return map[string]string{}, nil
 	}
 
-	return packages, nil
+	args := []string{"list", "--formula", "--versions"}
+	if packageType == "cask" {
+		args = []string{"list", "--cask", "--versions"}
+	}
+
+	output, err := runnerOrDefault(h.Runner).Output("brew", args...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNameVersionList(output), nil
 }
 
 func (h *HomebrewManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
@@ -170,53 +263,63 @@ func (h *HomebrewManager) GenerateInstallFile(brews, casks, taps []string) (stri
 }
 
 func (h *HomebrewManager) InstallFromFile(filePath string) error {
-	cmd := exec.Command("brew", "bundle", "--file="+filePath)
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	return cmd.Run()
+	return runnerOrDefault(h.Runner).Run("brew", "bundle", "--file="+filePath)
 }
 
 // PacmanManager implements PackageManager for pacman (Arch Linux)
-type PacmanManager struct{}
+type PacmanManager struct {
+	Runner Runner
+}
 
 func (p *PacmanManager) GetName() string {
 	return "pacman"
 }
 
 func (p *PacmanManager) IsAvailable() bool {
-	return commandExists("pacman")
+	return runnerOrDefault(p.Runner).LookPath("pacman")
 }
 
 func (p *PacmanManager) Install(packages []string, packageType string) error {
+	// Taps are a macOS concept, casks are only installed when mapped
+	packages = nativePackages(p.GetName(), packages, packageType)
 	if len(packages) == 0 {
 		return nil
 	}
 
-	// On Arch, we ignore "cask" and "tap" - they're macOS concepts
-	// All packages are treated the same
-	if packageType == "cask" || packageType == "tap" {
-		return nil
+	// AUR packages need an AUR helper, strip the marker for the command line
+	var names, aur []string
+	for _, pkg := range packages {
+		if strings.HasPrefix(pkg, AURPrefix) {
+			pkg = strings.TrimPrefix(pkg, AURPrefix)
+			aur = append(aur, pkg)
+		}
+		names = append(names, pkg)
 	}
 
 	// Check if yay (AUR helper) is available, prefer it over pacman
-	var cmd *exec.Cmd
-	if commandExists("yay") {
-		cmd = exec.Command("yay", append([]string{"-S", "--noconfirm"}, packages...)...)
-	} else {
-		cmd = exec.Command("sudo", append([]string{"pacman", "-S", "--noconfirm"}, packages...)...)
+	r := runnerOrDefault(p.Runner)
+	if r.LookPath("yay") {
+		return r.Run("yay", append([]string{"-S", "--noconfirm"}, names...)...)
 	}
-
-	return cmd.Run()
+	if len(aur) > 0 {
+		return fmt.Errorf("AUR packages need yay to install: %s", strings.Join(aur, ", "))
+	}
+	return runPrivileged(r, "pacman", append([]string{"-S", "--noconfirm"}, names...)...)
 }
 
 func (p *PacmanManager) IsInstalled(pkg string, packageType string) (bool, error) {
 	// Ignore macOS-specific package types
-	if packageType == "cask" || packageType == "tap" {
+	if packageType == "tap" || (packageType == "cask" && !HasMapping(p.GetName(), pkg)) {
 		return false, nil
 	}
 
-	cmd := exec.Command("pacman", "-Q", pkg)
-	err := cmd.Run()
+	name := ResolveName(p.GetName(), pkg)
+	if name == SkipPackage {
+		// Nothing to install on this platform
+		return true, nil
+	}
+
+	err := runnerOrDefault(p.Runner).Run("pacman", "-Q", strings.TrimPrefix(name, AURPrefix))
 	return err == nil, nil
 }
 
@@ -226,90 +329,90 @@ func (p *PacmanManager) ListInstalled(packageType string) ([]string, error) {
 		return []string{}, nil
 	}
 
-	cmd := exec.Command("pacman", "-Qq")
-	output, err := cmd.Output()
+	output, err := runnerOrDefault(p.Runner).Output("pacman", "-Qq")
 	if err != nil {
 		return nil, err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return canonicalNames(p.GetName(), ParsePacmanQuery(output)), nil
+}
+
+// ParsePacmanQuery extracts package names from `pacman -Qq` (one per line) or
+// `pacman -Q`, which adds the version after the name
+func ParsePacmanQuery(output []byte) []string {
 	var packages []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			packages = append(packages, line)
-		}
+	for _, line := range parseLines(output) {
+		packages = append(packages, strings.Fields(line)[0])
 	}
+	return packages
+}
 
-	return packages, nil
+// InstalledVersions returns installed versions from `pacman -Q`
+func (p *PacmanManager) InstalledVersions(packageType string) (map[string]string, error) {
+	if packageType == "tap" {
+		return map[string]string{}, nil
+	}
+
+	output, err := runnerOrDefault(p.Runner).Output("pacman", "-Q")
+	if err != nil {
+		return nil, err
+	}
+	return canonicalVersions(p.GetName(), ParseNameVersionList(output)), nil
 }
 
 func (p *PacmanManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
 	// Generate a simple package list for pacman
-	// Ignore taps (macOS concept), combine brews and casks into one list
-	var content string
-
-	allPackages := append([]string{}, brews...)
-	allPackages = append(allPackages, casks...)
-
-	for _, pkg := range allPackages {
-		content += pkg + "\n"
-	}
+	// Ignore taps (macOS concept), combine brews and mapped casks into one list
+	allPackages := nativePackages(p.GetName(), brews, "brew")
+	allPackages = append(allPackages, nativePackages(p.GetName(), casks, "cask")...)
 
-	return content, nil
+	return packageListFile(allPackages), nil
 }
 
 func (p *PacmanManager) InstallFromFile(filePath string) error {
 	// Read package list and install
-	content, err := exec.Command("cat", filePath).Output()
+	packages, err := readPackageFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
-	var packages []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && !strings.HasPrefix(line, "#") {
-			packages = append(packages, line)
-		}
-	}
-
 	return p.Install(packages, "brew")
 }
 
 // AptManager implements PackageManager for apt (Debian/Ubuntu)
-type AptManager struct{}
+type AptManager struct {
+	Runner Runner
+}
 
 func (a *AptManager) GetName() string {
 	return "apt"
 }
 
 func (a *AptManager) IsAvailable() bool {
-	return commandExists("apt-get")
+	return runnerOrDefault(a.Runner).LookPath("apt-get")
 }
 
 func (a *AptManager) Install(packages []string, packageType string) error {
+	// Taps are a macOS concept, casks are only installed when mapped
+	packages = nativePackages(a.GetName(), packages, packageType)
 	if len(packages) == 0 {
 		return nil
 	}
 
-	// Ignore macOS-specific package types
-	if packageType == "cask" || packageType == "tap" {
-		return nil
-	}
-
-	cmd := exec.Command("sudo", append([]string{"apt-get", "install", "-y"}, packages...)...)
-	return cmd.Run()
+	return runPrivileged(runnerOrDefault(a.Runner), "apt-get", append([]string{"install", "-y"}, packages...)...)
 }
 
 func (a *AptManager) IsInstalled(pkg string, packageType string) (bool, error) {
-	if packageType == "cask" || packageType == "tap" {
+	if packageType == "tap" || (packageType == "cask" && !HasMapping(a.GetName(), pkg)) {
 		return false, nil
 	}
 
-	cmd := exec.Command("dpkg", "-l", pkg)
-	err := cmd.Run()
+	name := ResolveName(a.GetName(), pkg)
+	if name == SkipPackage {
+		return true, nil
+	}
+
+	err := runnerOrDefault(a.Runner).Run("dpkg", "-l", name)
 	return err == nil, nil
 }
 
@@ -318,96 +421,132 @@ func (a *AptManager) ListInstalled(packageType string) ([]string, error) {
 		return []string{}, nil
 	}
 
-	cmd := exec.Command("dpkg", "--get-selections")
-	output, err := cmd.Output()
+	output, err := runnerOrDefault(a.Runner).Output("dpkg", "--get-selections")
 	if err != nil {
 		return nil, err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return canonicalNames(a.GetName(), ParseDpkgSelections(output)), nil
+}
+
+// ParseDpkgSelections extracts installed packages from `dpkg --get-selections`.
+// Packages marked for removal are skipped and architecture qualifiers such as
+// ":amd64" are stripped.
+//
+//	git            install
+//	libc6:amd64    install
+//	vim            deinstall
+func ParseDpkgSelections(output []byte) []string {
 	var packages []string
-	for _, line := range lines {
+	for _, line := range parseLines(output) {
 		fields := strings.Fields(line)
-		if len(fields) >= 2 && fields[1] == "install" {
-			packages = append(packages, fields[0])
+		if len(fields) < 2 || fields[1] != "install" {
+			continue
+		}
+		name := fields[0]
+		if i := strings.Index(name, ":"); i > 0 {
+			name = name[:i]
 		}
+		packages = append(packages, name)
 	}
-
-	return packages, nil
+	return packages
 }
 
-func (a *AptManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
-	var content string
+// InstalledVersions returns installed versions from dpkg-query
+func (a *AptManager) InstalledVersions(packageType string) (map[string]string, error) {
+	if packageType == "tap" {
+		return map[string]string{}, nil
+	}
 
-	allPackages := append([]string{}, brews...)
-	allPackages = append(allPackages, casks...)
+	output, err := runnerOrDefault(a.Runner).Output("dpkg-query", "-W", "-f=${db:Status-Abbrev} ${Package} ${Version}\n")
+	if err != nil {
+		return nil, err
+	}
+	return canonicalVersions(a.GetName(), ParseDpkgQuery(output)), nil
+}
 
-	for _, pkg := range allPackages {
-		content += pkg + "\n"
+// ParseDpkgQuery extracts versions of installed packages ("ii" status) from
+// `dpkg-query -W -f='${db:Status-Abbrev} ${Package} ${Version}\n'`
+func ParseDpkgQuery(output []byte) map[string]string {
+	versions := make(map[string]string)
+	for _, line := range parseLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "ii" {
+			continue
+		}
+		versions[fields[1]] = fields[2]
 	}
+	return versions
+}
 
-	return content, nil
+// InstallVersion installs pkg=version, allowing a downgrade
+func (a *AptManager) InstallVersion(pkg, version, packageType string) error {
+	packages := nativePackages(a.GetName(), []string{pkg}, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(a.Runner), "apt-get", "install", "-y", "--allow-downgrades", packages[0]+"="+version)
+}
+
+func (a *AptManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
+	allPackages := nativePackages(a.GetName(), brews, "brew")
+	allPackages = append(allPackages, nativePackages(a.GetName(), casks, "cask")...)
+
+	return packageListFile(allPackages), nil
 }
 
 func (a *AptManager) InstallFromFile(filePath string) error {
-	content, err := exec.Command("cat", filePath).Output()
+	packages, err := readPackageFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
-	var packages []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && !strings.HasPrefix(line, "#") {
-			packages = append(packages, line)
-		}
-	}
-
 	return a.Install(packages, "brew")
 }
 
 // YumManager implements PackageManager for yum/dnf (RHEL/Fedora)
-type YumManager struct{}
+type YumManager struct {
+	Runner Runner
+}
 
 func (y *YumManager) GetName() string {
 	return "yum"
 }
 
 func (y *YumManager) IsAvailable() bool {
-	return commandExists("yum") || commandExists("dnf")
+	r := runnerOrDefault(y.Runner)
+	return r.LookPath("yum") || r.LookPath("dnf")
 }
 
-func (y *YumManager) Install(packages []string, packageType string) error {
-	if len(packages) == 0 {
-		return nil
+// command returns dnf when it is installed, yum otherwise
+func (y *YumManager) command() string {
+	if runnerOrDefault(y.Runner).LookPath("dnf") {
+		return "dnf"
 	}
+	return "yum"
+}
 
-	if packageType == "cask" || packageType == "tap" {
+func (y *YumManager) Install(packages []string, packageType string) error {
+	packages = nativePackages(y.GetName(), packages, packageType)
+	if len(packages) == 0 {
 		return nil
 	}
 
-	cmdName := "yum"
-	if commandExists("dnf") {
-		cmdName = "dnf"
-	}
-
-	cmd := exec.Command("sudo", append([]string{cmdName, "install", "-y"}, packages...)...)
-	return cmd.Run()
+	return runPrivileged(runnerOrDefault(y.Runner), y.command(), append([]string{"install", "-y"}, packages...)...)
 }
 
 func (y *YumManager) IsInstalled(pkg string, packageType string) (bool, error) {
-	if packageType == "cask" || packageType == "tap" {
+	if packageType == "tap" || (packageType == "cask" && !HasMapping(y.GetName(), pkg)) {
 		return false, nil
 	}
 
-	cmdName := "yum"
-	if commandExists("dnf") {
-		cmdName = "dnf"
+	name := ResolveName(y.GetName(), pkg)
+	if name == SkipPackage {
+		return true, nil
 	}
 
-	cmd := exec.Command(cmdName, "list", "installed", pkg)
-	err := cmd.Run()
+	err := runnerOrDefault(y.Runner).Run(y.command(), "list", "installed", name)
 	return err == nil, nil
 }
 
@@ -416,56 +555,110 @@ func (y *YumManager) ListInstalled(packageType string) ([]string, error) {
 		return []string{}, nil
 	}
 
-	cmdName := "yum"
-	if commandExists("dnf") {
-		cmdName = "dnf"
-	}
-
-	cmd := exec.Command(cmdName, "list", "installed")
-	output, err := cmd.Output()
+	output, err := runnerOrDefault(y.Runner).Output(y.command(), "list", "installed")
 	if err != nil {
 		return nil, err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return canonicalNames(y.GetName(), ParseYumList(output)), nil
+}
+
+// ParseYumList extracts package names from `yum list installed` (or dnf),
+// dropping the ".arch" suffix. Header lines are skipped, as are the
+// continuation lines yum uses when a name is too long for its column.
+//
+//	Installed Packages
+//	bash.x86_64          5.1.8-6.el9      @anaconda
+//	python3.11.noarch    3.11.2-2.el9     @appstream
+func ParseYumList(output []byte) []string {
 	var packages []string
-	for _, line := range lines {
+	for _, line := range strings.Split(string(output), "\n") {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
 		fields := strings.Fields(line)
-		if len(fields) > 0 && !strings.HasPrefix(line, "Installed") {
-			packages = append(packages, fields[0])
+		name := fields[0]
+		if strings.HasSuffix(name, ":") || strings.HasPrefix(line, "Installed") ||
+			strings.HasPrefix(line, "Last metadata") || strings.HasPrefix(line, "Loaded plugins") {
+			continue
+		}
+		i := strings.LastIndex(name, ".")
+		if i <= 0 {
+			continue
 		}
+		packages = append(packages, name[:i])
 	}
+	return packages
+}
 
-	return packages, nil
+// InstalledVersions returns installed versions from `yum list installed`
+func (y *YumManager) InstalledVersions(packageType string) (map[string]string, error) {
+	if packageType == "tap" {
+		return map[string]string{}, nil
+	}
+
+	output, err := runnerOrDefault(y.Runner).Output(y.command(), "list", "installed")
+	if err != nil {
+		return nil, err
+	}
+	return canonicalVersions(y.GetName(), ParseYumListVersions(output)), nil
 }
 
-func (y *YumManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
-	var content string
+// ParseYumListVersions extracts versions from `yum list installed`, keyed by
+// package name without the ".arch" suffix. A name too long for its column is
+// followed by its version on the next, indented line.
+func ParseYumListVersions(output []byte) map[string]string {
+	versions := make(map[string]string)
+	pending := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
 
-	allPackages := append([]string{}, brews...)
-	allPackages = append(allPackages, casks...)
+		if line[0] == ' ' || line[0] == '\t' {
+			if pending != "" {
+				versions[pending] = fields[0]
+				pending = ""
+			}
+			continue
+		}
 
-	for _, pkg := range allPackages {
-		content += pkg + "\n"
+		names := ParseYumList([]byte(line))
+		if len(names) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			pending = names[0]
+			continue
+		}
+		versions[names[0]] = fields[1]
 	}
+	return versions
+}
 
-	return content, nil
+// InstallVersion installs name-version
+func (y *YumManager) InstallVersion(pkg, version, packageType string) error {
+	packages := nativePackages(y.GetName(), []string{pkg}, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(y.Runner), y.command(), "install", "-y", packages[0]+"-"+version)
+}
+
+func (y *YumManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
+	allPackages := nativePackages(y.GetName(), brews, "brew")
+	allPackages = append(allPackages, nativePackages(y.GetName(), casks, "cask")...)
+
+	return packageListFile(allPackages), nil
 }
 
 func (y *YumManager) InstallFromFile(filePath string) error {
-	content, err := exec.Command("cat", filePath).Output()
+	packages, err := readPackageFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
-	var packages []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && !strings.HasPrefix(line, "#") {
-			packages = append(packages, line)
-		}
-	}
-
 	return y.Install(packages, "brew")
 }
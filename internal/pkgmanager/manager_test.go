@@ -0,0 +1,51 @@
+package pkgmanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+// assertCommands checks that f ran exactly the given command lines, in order
+func assertCommands(t *testing.T, f *FakeRunner, want ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(f.Commands, want) {
+		t.Errorf("commands = %q, want %q", f.Commands, want)
+	}
+}
+
+func assertNames(t *testing.T, got, want []string) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func managerNames(managers []PackageManager) []string {
+	var names []string
+	for _, pm := range managers {
+		names = append(names, pm.GetName())
+	}
+	return names
+}
+
+func TestDetectAvailableManagers(t *testing.T) {
+	f := NewFakeRunner("dnf", "yum", "flatpak")
+	assertNames(t, managerNames(DetectAvailableManagers(f)), []string{"dnf", "flatpak"})
+
+	f = NewFakeRunner("yum", "nix")
+	assertNames(t, managerNames(DetectAvailableManagers(f)), []string{"yum", "nix"})
+}
+
+func TestDetectAvailableManagersUseRunner(t *testing.T) {
+	f := NewFakeRunner("apk")
+	managers := DetectAvailableManagers(f)
+	if len(managers) != 1 {
+		t.Fatalf("managers = %q, want [apk]", managerNames(managers))
+	}
+
+	f.Outputs["apk info"] = "git\n"
+	if _, err := managers[0].ListInstalled("brew"); err != nil {
+		t.Fatalf("ListInstalled: %v", err)
+	}
+	assertCommands(t, f, "apk info")
+}
@@ -0,0 +1,152 @@
+package pkgmanager
+
+import (
+	"sort"
+	"strings"
+)
+
+// SkipPackage as a mapped name marks a package as unavailable for that package manager
+const SkipPackage = "-"
+
+// AURPrefix marks an Arch package that has to be installed from the AUR
+const AURPrefix = "aur:"
+
+// BuiltinMappings maps common (Homebrew) package names to their names under
+// other package managers. Entries in config.json's package_mappings take
+// precedence over this table.
+var BuiltinMappings = map[string]map[string]string{
+	"fd":                  {"apt": "fd-find", "yum": "fd-find", "dnf": "fd-find"},
+	"the_silver_searcher": {"apt": "silversearcher-ag", "nix": "silver-searcher"},
+	"visual-studio-code":  {"apt": "code", "yum": "code", "dnf": "code", "zypper": "code", "pacman": AURPrefix + "visual-studio-code-bin", "nix": "vscode", "flatpak": "com.visualstudio.code"},
+	"google-chrome":       {"apt": "google-chrome-stable", "yum": "google-chrome-stable", "dnf": "google-chrome-stable", "zypper": "google-chrome-stable", "pacman": AURPrefix + "google-chrome", "flatpak": "com.google.Chrome"},
+	"slack":               {"pacman": AURPrefix + "slack-desktop", "flatpak": "com.slack.Slack"},
+	"spotify":             {"pacman": AURPrefix + "spotify", "flatpak": "com.spotify.Client"},
+	"discord":             {"flatpak": "com.discordapp.Discord"},
+	"obsidian":            {"flatpak": "md.obsidian.Obsidian"},
+	"zoom":                {"flatpak": "us.zoom.Zoom"},
+	"firefox":             {"apt": "firefox-esr", "flatpak": "org.mozilla.firefox"},
+	"python":              {"apt": "python3", "yum": "python3", "dnf": "python3", "zypper": "python3", "apk": "python3", "nix": "python3"},
+	"node":                {"apt": "nodejs", "yum": "nodejs", "dnf": "nodejs", "pacman": "nodejs", "apk": "nodejs", "nix": "nodejs"},
+	"go":                  {"apt": "golang-go", "yum": "golang", "dnf": "golang"},
+	"gnupg":               {"yum": "gnupg2", "dnf": "gnupg2", "zypper": "gpg2"},
+	"awscli":              {"pacman": "aws-cli", "apk": "aws-cli", "nix": "awscli2"},
+	"openssh":             {"apt": "openssh-client", "yum": "openssh-clients", "dnf": "openssh-clients", "apk": "openssh-client"},
+	"docker":              {"apt": "docker.io"},
+	"mas":                 {"apt": SkipPackage, "pacman": SkipPackage, "yum": SkipPackage, "dnf": SkipPackage, "zypper": SkipPackage, "apk": SkipPackage, "nix": SkipPackage, "flatpak": SkipPackage},
+	"coreutils":           {"apt": SkipPackage, "pacman": SkipPackage, "yum": SkipPackage, "dnf": SkipPackage, "zypper": SkipPackage},
+}
+
+// userMappings holds overrides from config.json, keyed by package then manager
+var userMappings map[string]map[string]string
+
+// SetMappings installs per-package overrides from the configuration
+func SetMappings(mappings map[string]map[string]string) {
+	userMappings = mappings
+}
+
+// mappingKeys returns the manager names to look up, most specific first.
+// yum and dnf share package names, so each falls back to the other.
+func mappingKeys(manager string) []string {
+	switch manager {
+	case "yum":
+		return []string{"yum", "dnf"}
+	case "dnf":
+		return []string{"dnf", "yum"}
+	}
+	return []string{manager}
+}
+
+func lookupMapping(manager, pkg string) (string, bool) {
+	for _, table := range []map[string]map[string]string{userMappings, BuiltinMappings} {
+		if names, ok := table[pkg]; ok {
+			for _, key := range mappingKeys(manager) {
+				if name, ok := names[key]; ok {
+					return name, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// ResolveName returns the name pkg has under the given package manager. The
+// result may be SkipPackage or carry the AURPrefix.
+func ResolveName(manager, pkg string) string {
+	if name, ok := lookupMapping(manager, pkg); ok {
+		return name
+	}
+	return pkg
+}
+
+// HasMapping reports whether pkg has an explicit mapping for the package manager
+func HasMapping(manager, pkg string) bool {
+	_, ok := lookupMapping(manager, pkg)
+	return ok
+}
+
+// nativePackages resolves configured packages to the names a Linux package
+// manager should install. Taps are dropped, and casks are only kept when
+// they are mapped for this manager since most are macOS applications.
+func nativePackages(manager string, packages []string, packageType string) []string {
+	if packageType == "tap" {
+		return nil
+	}
+
+	var result []string
+	for _, pkg := range packages {
+		if packageType == "cask" && !HasMapping(manager, pkg) {
+			continue
+		}
+		name := ResolveName(manager, pkg)
+		if name == SkipPackage || name == "" {
+			continue
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// canonicalNames maps installed package names back to the names used in the
+// configuration, so "fd-find" on apt is reported as "fd"
+func canonicalNames(manager string, installed []string) []string {
+	reverse := make(map[string][]string)
+	for _, table := range []map[string]map[string]string{BuiltinMappings, userMappings} {
+		for pkg := range table {
+			name := strings.TrimPrefix(ResolveName(manager, pkg), AURPrefix)
+			if name != pkg && name != SkipPackage && name != "" {
+				reverse[name] = append(reverse[name], pkg)
+			}
+		}
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+
+	for _, name := range installed {
+		aliases, ok := reverse[name]
+		if !ok {
+			add(name)
+			continue
+		}
+		sort.Strings(aliases)
+		for _, alias := range aliases {
+			add(alias)
+		}
+		// Keep the native name when it is also a configured name in its own right
+		if ResolveName(manager, name) == name {
+			if _, isKey := userMappings[name]; isKey {
+				add(name)
+			} else if _, isKey := BuiltinMappings[name]; isKey {
+				add(name)
+			}
+		}
+	}
+
+	return result
+}
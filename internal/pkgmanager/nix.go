@@ -0,0 +1,158 @@
+package pkgmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// nixFlags enables the nix command and flakes for installs that have not turned them on
+var nixFlags = []string{"--extra-experimental-features", "nix-command flakes"}
+
+// NixManager implements PackageManager for `nix profile` (NixOS or Nix on any distro)
+type NixManager struct {
+	Runner Runner
+}
+
+func (n *NixManager) GetName() string {
+	return "nix"
+}
+
+func (n *NixManager) IsAvailable() bool {
+	return runnerOrDefault(n.Runner).LookPath("nix")
+}
+
+func (n *NixManager) Install(packages []string, packageType string) error {
+	packages = nativePackages(n.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	args := append(append([]string{}, nixFlags...), "profile", "install")
+	for _, pkg := range packages {
+		args = append(args, nixInstallable(pkg))
+	}
+
+	return runnerOrDefault(n.Runner).Run("nix", args...)
+}
+
+// nixInstallable turns a package name into a flake reference, defaulting to nixpkgs
+func nixInstallable(pkg string) string {
+	if strings.Contains(pkg, "#") {
+		return pkg
+	}
+	return "nixpkgs#" + pkg
+}
+
+func (n *NixManager) IsInstalled(pkg string, packageType string) (bool, error) {
+	if packageType == "tap" || (packageType == "cask" && !HasMapping(n.GetName(), pkg)) {
+		return false, nil
+	}
+
+	name := ResolveName(n.GetName(), pkg)
+	if name == SkipPackage {
+		return true, nil
+	}
+
+	output, err := runnerOrDefault(n.Runner).Output("nix", append(append([]string{}, nixFlags...), "profile", "list", "--json")...)
+	if err != nil {
+		return false, err
+	}
+
+	installed, err := ParseNixProfileList(output)
+	if err != nil {
+		return false, err
+	}
+
+	name = name[strings.Index(name, "#")+1:]
+	for _, item := range installed {
+		if item == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (n *NixManager) ListInstalled(packageType string) ([]string, error) {
+	if packageType == "cask" || packageType == "tap" {
+		return []string{}, nil
+	}
+
+	output, err := runnerOrDefault(n.Runner).Output("nix", append(append([]string{}, nixFlags...), "profile", "list", "--json")...)
+	if err != nil {
+		return nil, err
+	}
+
+	installed, err := ParseNixProfileList(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return canonicalNames(n.GetName(), installed), nil
+}
+
+// ParseNixProfileList extracts package attribute names from `nix profile list --json`.
+// Nix 2.20+ keys elements by name, older versions use a list; both carry an
+// attrPath such as "legacyPackages.x86_64-linux.ripgrep".
+func ParseNixProfileList(output []byte) ([]string, error) {
+	var profile struct {
+		Elements json.RawMessage `json:"elements"`
+	}
+	if err := json.Unmarshal(output, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse nix profile list: %v", err)
+	}
+
+	type element struct {
+		AttrPath string `json:"attrPath"`
+	}
+
+	var names []string
+
+	var byName map[string]element
+	if err := json.Unmarshal(profile.Elements, &byName); err == nil {
+		for key, elem := range byName {
+			if name := nixAttrName(elem.AttrPath); name != "" {
+				names = append(names, name)
+			} else {
+				names = append(names, key)
+			}
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+
+	var list []element
+	if err := json.Unmarshal(profile.Elements, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse nix profile elements: %v", err)
+	}
+	for _, elem := range list {
+		if name := nixAttrName(elem.AttrPath); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// nixAttrName strips the "legacyPackages.<system>." or "packages.<system>." prefix
+func nixAttrName(attrPath string) string {
+	parts := strings.Split(attrPath, ".")
+	if len(parts) > 2 && (parts[0] == "legacyPackages" || parts[0] == "packages") {
+		return strings.Join(parts[2:], ".")
+	}
+	return attrPath
+}
+
+func (n *NixManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
+	allPackages := nativePackages(n.GetName(), brews, "brew")
+	allPackages = append(allPackages, nativePackages(n.GetName(), casks, "cask")...)
+	return packageListFile(allPackages), nil
+}
+
+func (n *NixManager) InstallFromFile(filePath string) error {
+	packages, err := readPackageFile(filePath)
+	if err != nil {
+		return err
+	}
+	return n.Install(packages, "brew")
+}
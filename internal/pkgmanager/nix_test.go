@@ -0,0 +1,62 @@
+package pkgmanager
+
+import "testing"
+
+const nixProfileList = `{
+  "elements": {
+    "ripgrep": {"active": true, "attrPath": "legacyPackages.x86_64-linux.ripgrep", "originalUrl": "flake:nixpkgs"},
+    "vscode": {"active": true, "attrPath": "legacyPackages.x86_64-linux.vscode", "originalUrl": "flake:nixpkgs"}
+  },
+  "version": 3
+}`
+
+func TestNixInstall(t *testing.T) {
+	f := NewFakeRunner("nix")
+	n := &NixManager{Runner: f}
+
+	if err := n.Install([]string{"ripgrep", "the_silver_searcher", "mas"}, "brew"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if err := n.Install([]string{"visual-studio-code", "iterm2"}, "cask"); err != nil {
+		t.Fatalf("Install casks: %v", err)
+	}
+	assertCommands(t, f,
+		"nix --extra-experimental-features nix-command flakes profile install nixpkgs#ripgrep nixpkgs#silver-searcher",
+		"nix --extra-experimental-features nix-command flakes profile install nixpkgs#vscode",
+	)
+}
+
+func TestNixIsInstalled(t *testing.T) {
+	f := NewFakeRunner("nix")
+	f.Outputs["nix --extra-experimental-features nix-command flakes profile list --json"] = nixProfileList
+	n := &NixManager{Runner: f}
+
+	for pkg, want := range map[string]bool{"ripgrep": true, "jq": false} {
+		got, err := n.IsInstalled(pkg, "brew")
+		if err != nil {
+			t.Fatalf("IsInstalled(%q): %v", pkg, err)
+		}
+		if got != want {
+			t.Errorf("IsInstalled(%q) = %v, want %v", pkg, got, want)
+		}
+	}
+	if installed, _ := n.IsInstalled("visual-studio-code", "cask"); !installed {
+		t.Error("visual-studio-code cask not reported as installed")
+	}
+	if !f.Ran("nix --extra-experimental-features nix-command flakes profile list --json") {
+		t.Errorf("commands = %q, want nix profile list --json", f.Commands)
+	}
+}
+
+func TestNixListInstalled(t *testing.T) {
+	f := NewFakeRunner("nix")
+	f.Outputs["nix --extra-experimental-features nix-command flakes profile list --json"] = nixProfileList
+	n := &NixManager{Runner: f}
+
+	got, err := n.ListInstalled("brew")
+	if err != nil {
+		t.Fatalf("ListInstalled: %v", err)
+	}
+	assertNames(t, got, []string{"ripgrep", "visual-studio-code"})
+	assertCommands(t, f, "nix --extra-experimental-features nix-command flakes profile list --json")
+}
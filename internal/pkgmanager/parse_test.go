@@ -0,0 +1,75 @@
+package pkgmanager
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files in testdata")
+
+// versionLines formats parsed versions as sorted "name version" lines
+func versionLines(versions map[string]string) []string {
+	lines := make([]string, 0, len(versions))
+	for name, version := range versions {
+		lines = append(lines, name+" "+version)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func nixLines(output []byte) []string {
+	names, err := ParseNixProfileList(output)
+	if err != nil {
+		return []string{"error: " + err.Error()}
+	}
+	return names
+}
+
+// TestParseGolden parses captured command output from testdata/<input>.txt
+// and compares the result, one entry per line, with testdata/<name>.golden.
+// Run with -update after changing a parser to rewrite the golden files.
+func TestParseGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		parse func([]byte) []string
+	}{
+		{"dpkg-selections", "dpkg-selections", ParseDpkgSelections},
+		{"dpkg-query", "dpkg-query", func(b []byte) []string { return versionLines(ParseDpkgQuery(b)) }},
+		{"yum-list", "yum-list", ParseYumList},
+		{"yum-list-versions", "yum-list", func(b []byte) []string { return versionLines(ParseYumListVersions(b)) }},
+		{"pacman-query", "pacman-query", ParsePacmanQuery},
+		{"nix-profile-list", "nix-profile-list", nixLines},
+		{"nix-profile-list-legacy", "nix-profile-list-legacy", nixLines},
+		{"flatpak-list", "flatpak-list", ParseFlatpakList},
+		{"brew-list", "brew-list", ParseBrewList},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := os.ReadFile(filepath.Join("testdata", tt.input+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Join(tt.parse(input), "\n") + "\n"
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("%s parsed as:\n%s\nwant:\n%s", tt.input, got, want)
+			}
+		})
+	}
+}
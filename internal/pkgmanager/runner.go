@@ -0,0 +1,108 @@
+package pkgmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Runner executes package manager commands. Backends take one so their
+// install, list and check logic can run without the real tools installed.
+type Runner interface {
+	// Run executes a command and waits for it to finish
+	Run(name string, args ...string) error
+	// Output executes a command and returns its standard output
+	Output(name string, args ...string) ([]byte, error)
+	// LookPath reports whether a command exists in PATH
+	LookPath(name string) bool
+}
+
+// ConcurrentInstaller is implemented by package managers that can run
+// several installs at once. System package managers hold a global lock, so
+// by default packages of one package manager are installed one at a time.
+type ConcurrentInstaller interface {
+	ConcurrentInstalls() bool
+}
+
+// ExecRunner runs commands with os/exec
+type ExecRunner struct{}
+
+// Run executes the command; a failure includes the last line the command
+// wrote to stderr, which usually says why
+func (ExecRunner) Run(name string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if lines := parseLines(stderr.Bytes()); len(lines) > 0 {
+			return fmt.Errorf("%v: %s", err, lines[len(lines)-1])
+		}
+		return err
+	}
+	return nil
+}
+
+func (ExecRunner) Output(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+func (ExecRunner) LookPath(name string) bool {
+	return commandExists(name)
+}
+
+// runnerOrDefault returns r, or an ExecRunner when r is nil
+func runnerOrDefault(r Runner) Runner {
+	if r == nil {
+		return ExecRunner{}
+	}
+	return r
+}
+
+// runPrivileged runs a command as root, using sudo unless already root or
+// sudo is missing (as in most containers)
+func runPrivileged(r Runner, name string, args ...string) error {
+	if os.Geteuid() == 0 || !r.LookPath("sudo") {
+		return r.Run(name, args...)
+	}
+	return r.Run("sudo", append([]string{name}, args...)...)
+}
+
+// parseLines returns the non-empty, trimmed lines of command output
+func parseLines(output []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// readPackageFile reads a package list file, skipping blank lines and comments
+func readPackageFile(filePath string) ([]string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []string
+	for _, line := range parseLines(content) {
+		if !strings.HasPrefix(line, "#") {
+			packages = append(packages, line)
+		}
+	}
+	return packages, nil
+}
+
+// packageListFile renders packages as a plain list, one per line
+func packageListFile(packages []string) string {
+	var content string
+	for _, pkg := range packages {
+		content += pkg + "\n"
+	}
+	return content
+}
@@ -0,0 +1,132 @@
+package pkgmanager
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Uninstaller is implemented by package managers that can remove packages
+type Uninstaller interface {
+	// Uninstall removes packages of the given type (brew, cask or tap)
+	Uninstall(packages []string, packageType string) error
+}
+
+func (h *HomebrewManager) Uninstall(packages []string, packageType string) error {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	r := runnerOrDefault(h.Runner)
+	switch packageType {
+	case "tap":
+		for _, pkg := range packages {
+			if err := r.Run("brew", "untap", pkg); err != nil {
+				return fmt.Errorf("failed to untap %s: %v", pkg, err)
+			}
+		}
+		return nil
+	case "cask":
+		return r.Run("brew", append([]string{"uninstall", "--cask"}, packages...)...)
+	}
+	return r.Run("brew", append([]string{"uninstall"}, packages...)...)
+}
+
+func (p *PacmanManager) Uninstall(packages []string, packageType string) error {
+	packages = nativePackages(p.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	// pacman removes AUR packages too, only installing them needs yay
+	names := make([]string, len(packages))
+	for i, pkg := range packages {
+		names[i] = strings.TrimPrefix(pkg, AURPrefix)
+	}
+	return runPrivileged(runnerOrDefault(p.Runner), "pacman", append([]string{"-R", "--noconfirm"}, names...)...)
+}
+
+func (a *AptManager) Uninstall(packages []string, packageType string) error {
+	packages = nativePackages(a.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(a.Runner), "apt-get", append([]string{"remove", "-y"}, packages...)...)
+}
+
+func (y *YumManager) Uninstall(packages []string, packageType string) error {
+	packages = nativePackages(y.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(y.Runner), y.command(), append([]string{"remove", "-y"}, packages...)...)
+}
+
+func (d *DnfManager) Uninstall(packages []string, packageType string) error {
+	packages = nativePackages(d.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(d.Runner), "dnf", append([]string{"remove", "-y"}, packages...)...)
+}
+
+func (z *ZypperManager) Uninstall(packages []string, packageType string) error {
+	packages = nativePackages(z.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(z.Runner), "zypper", append([]string{"--non-interactive", "remove"}, packages...)...)
+}
+
+func (a *ApkManager) Uninstall(packages []string, packageType string) error {
+	packages = nativePackages(a.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(a.Runner), "apk", append([]string{"del"}, packages...)...)
+}
+
+func (c *CargoManager) Uninstall(packages []string, packageType string) error {
+	packages = nativePackages(c.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runnerOrDefault(c.Runner).Run("cargo", append([]string{"uninstall"}, packages...)...)
+}
+
+func (f *FlatpakManager) Uninstall(packages []string, packageType string) error {
+	apps := f.flatpakApps(packages, packageType)
+	if len(apps) == 0 {
+		return nil
+	}
+
+	return runnerOrDefault(f.Runner).Run("flatpak", append([]string{"uninstall", "-y", "--noninteractive"}, apps...)...)
+}
+
+func (n *NixManager) Uninstall(packages []string, packageType string) error {
+	packages = nativePackages(n.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	// Profile elements are named after the attribute, e.g. "ripgrep" for
+	// nixpkgs#ripgrep
+	args := append(append([]string{}, nixFlags...), "profile", "remove")
+	for _, pkg := range packages {
+		args = append(args, nixElementName(pkg))
+	}
+	return runnerOrDefault(n.Runner).Run("nix", args...)
+}
+
+// nixElementName returns the profile element name of a package or flake reference
+func nixElementName(pkg string) string {
+	if i := strings.LastIndex(pkg, "#"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return pkg[strings.LastIndex(pkg, ".")+1:]
+}
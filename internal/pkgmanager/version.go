@@ -0,0 +1,200 @@
+package pkgmanager
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VersionLister is implemented by package managers that can report the
+// versions of installed packages
+type VersionLister interface {
+	// InstalledVersions returns installed versions keyed by configured package name
+	InstalledVersions(packageType string) (map[string]string, error)
+}
+
+// VersionInstaller is implemented by package managers that can install a
+// specific version of a package
+type VersionInstaller interface {
+	// InstallVersion installs the given version of pkg
+	InstallVersion(pkg, version, packageType string) error
+}
+
+// canonicalVersions re-keys native package versions by configured name, so
+// the version of "fd-find" on apt is reported for "fd"
+func canonicalVersions(manager string, versions map[string]string) map[string]string {
+	result := make(map[string]string, len(versions))
+	for name, version := range versions {
+		for _, alias := range canonicalNames(manager, []string{name}) {
+			result[alias] = version
+		}
+	}
+	return result
+}
+
+// ParseNameVersionList parses lines of a name followed by one or more
+// versions, as printed by `brew list --versions` and `pacman -Q`. When
+// several versions are installed the last one listed is used.
+func ParseNameVersionList(output []byte) map[string]string {
+	versions := make(map[string]string)
+	for _, line := range parseLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		versions[fields[0]] = fields[len(fields)-1]
+	}
+	return versions
+}
+
+// CompareVersions compares two version strings, returning -1, 0 or 1.
+// Epochs ("1:2.3") are compared first, then runs of digits numerically and
+// other runs lexically, so "2.10" sorts after "2.9".
+func CompareVersions(a, b string) int {
+	epochA, restA := splitEpoch(a)
+	epochB, restB := splitEpoch(b)
+	if epochA != epochB {
+		if epochA < epochB {
+			return -1
+		}
+		return 1
+	}
+
+	partsA := versionParts(restA)
+	partsB := versionParts(restB)
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		if c := compareVersionPart(partsA[i], partsB[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(partsA) < len(partsB):
+		return -1
+	case len(partsA) > len(partsB):
+		return 1
+	}
+	return 0
+}
+
+func splitEpoch(version string) (int, string) {
+	if i := strings.Index(version, ":"); i > 0 {
+		if epoch, err := strconv.Atoi(version[:i]); err == nil {
+			return epoch, version[i+1:]
+		}
+	}
+	return 0, version
+}
+
+var versionPartPattern = regexp.MustCompile(`[0-9]+|[A-Za-z]+`)
+
+func versionParts(version string) []string {
+	return versionPartPattern.FindAllString(version, -1)
+}
+
+func compareVersionPart(a, b string) int {
+	numA, errA := strconv.Atoi(a)
+	numB, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		if numA != numB {
+			if numA < numB {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	case errA == nil:
+		// A number sorts after letters at the same position
+		return 1
+	case errB == nil:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+// upstreamVersion strips the epoch and distribution release from a version,
+// e.g. "1:2.39.2-1.1" becomes "2.39.2"
+func upstreamVersion(version string) string {
+	_, version = splitEpoch(version)
+	if i := strings.LastIndex(version, "-"); i > 0 {
+		version = version[:i]
+	}
+	return version
+}
+
+// ExactVersion returns the version a constraint pins to, if it is an exact
+// version rather than a range or wildcard
+func ExactVersion(constraint string) (string, bool) {
+	constraint = strings.TrimSpace(constraint)
+	constraint = strings.TrimPrefix(constraint, "=")
+	if constraint == "" || strings.ContainsAny(constraint, "<>*,~^ ") {
+		return "", false
+	}
+	return constraint, true
+}
+
+// MatchesConstraint reports whether version satisfies a constraint. Supported
+// forms are an exact version ("2.44.0", also matching a distribution release
+// such as "1:2.44.0-1"), a wildcard ("2.44.*") and comparisons (">=2.40",
+// "<3"), which can be combined with commas (">=2.40,<3").
+func MatchesConstraint(version, constraint string) (bool, error) {
+	for _, part := range strings.Split(constraint, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		ok, err := matchesSingleConstraint(version, part)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchesSingleConstraint(version, constraint string) (bool, error) {
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if !strings.HasPrefix(constraint, op) {
+			continue
+		}
+
+		target := strings.TrimSpace(strings.TrimPrefix(constraint, op))
+		if target == "" {
+			return false, fmt.Errorf("invalid version constraint: %q", constraint)
+		}
+
+		// Compare upstream versions unless the target carries an epoch or release
+		installed := upstreamVersion(version)
+		if strings.ContainsAny(target, "-:") {
+			installed = version
+		}
+		c := CompareVersions(installed, target)
+
+		switch op {
+		case ">=":
+			return c >= 0, nil
+		case "<=":
+			return c <= 0, nil
+		case "!=":
+			return c != 0, nil
+		case ">":
+			return c > 0, nil
+		case "<":
+			return c < 0, nil
+		default:
+			return c == 0, nil
+		}
+	}
+
+	if strings.HasSuffix(constraint, "*") {
+		prefix := strings.TrimSuffix(constraint, "*")
+		return strings.HasPrefix(version, prefix) || strings.HasPrefix(upstreamVersion(version), prefix), nil
+	}
+
+	if strings.ContainsAny(constraint, "<>~^* ") {
+		return false, fmt.Errorf("invalid version constraint: %q", constraint)
+	}
+	return version == constraint || upstreamVersion(version) == constraint, nil
+}
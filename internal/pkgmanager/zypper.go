@@ -0,0 +1,110 @@
+package pkgmanager
+
+import "strings"
+
+// ZypperManager implements PackageManager for zypper (openSUSE, SLES)
+type ZypperManager struct {
+	Runner Runner
+}
+
+func (z *ZypperManager) GetName() string {
+	return "zypper"
+}
+
+func (z *ZypperManager) IsAvailable() bool {
+	return runnerOrDefault(z.Runner).LookPath("zypper")
+}
+
+func (z *ZypperManager) Install(packages []string, packageType string) error {
+	packages = nativePackages(z.GetName(), packages, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(z.Runner), "zypper", append([]string{"--non-interactive", "install"}, packages...)...)
+}
+
+func (z *ZypperManager) IsInstalled(pkg string, packageType string) (bool, error) {
+	if packageType == "tap" || (packageType == "cask" && !HasMapping(z.GetName(), pkg)) {
+		return false, nil
+	}
+
+	name := ResolveName(z.GetName(), pkg)
+	if name == SkipPackage {
+		return true, nil
+	}
+
+	err := runnerOrDefault(z.Runner).Run("rpm", "-q", name)
+	return err == nil, nil
+}
+
+func (z *ZypperManager) ListInstalled(packageType string) ([]string, error) {
+	if packageType == "cask" || packageType == "tap" {
+		return []string{}, nil
+	}
+
+	output, err := runnerOrDefault(z.Runner).Output("zypper", "--quiet", "--non-interactive", "search", "--installed-only", "--type", "package")
+	if err != nil {
+		return nil, err
+	}
+
+	return canonicalNames(z.GetName(), ParseZypperSearch(output)), nil
+}
+
+// ParseZypperSearch extracts package names from `zypper search` table output:
+//
+//	S  | Name | Summary          | Type
+//	---+------+------------------+--------
+//	i+ | git  | Fast, scalable.. | package
+func ParseZypperSearch(output []byte) []string {
+	var packages []string
+	for _, line := range parseLines(output) {
+		columns := strings.Split(line, "|")
+		if len(columns) < 2 {
+			continue
+		}
+		name := strings.TrimSpace(columns[1])
+		if name == "" || name == "Name" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		packages = append(packages, name)
+	}
+	return packages
+}
+
+func (z *ZypperManager) GenerateInstallFile(brews, casks, taps []string) (string, error) {
+	allPackages := nativePackages(z.GetName(), brews, "brew")
+	allPackages = append(allPackages, nativePackages(z.GetName(), casks, "cask")...)
+	return packageListFile(allPackages), nil
+}
+
+func (z *ZypperManager) InstallFromFile(filePath string) error {
+	packages, err := readPackageFile(filePath)
+	if err != nil {
+		return err
+	}
+	return z.Install(packages, "brew")
+}
+
+// InstalledVersions returns installed versions from the rpm database
+func (z *ZypperManager) InstalledVersions(packageType string) (map[string]string, error) {
+	if packageType == "tap" {
+		return map[string]string{}, nil
+	}
+
+	output, err := runnerOrDefault(z.Runner).Output("rpm", "-qa", "--queryformat", "%{NAME} %{VERSION}-%{RELEASE}\n")
+	if err != nil {
+		return nil, err
+	}
+	return canonicalVersions(z.GetName(), ParseNameVersionList(output)), nil
+}
+
+// InstallVersion installs name=version, allowing a downgrade
+func (z *ZypperManager) InstallVersion(pkg, version, packageType string) error {
+	packages := nativePackages(z.GetName(), []string{pkg}, packageType)
+	if len(packages) == 0 {
+		return nil
+	}
+
+	return runPrivileged(runnerOrDefault(z.Runner), "zypper", "--non-interactive", "install", "--oldpackage", packages[0]+"="+version)
+}
@@ -0,0 +1,60 @@
+package pkgmanager
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestZypperInstall(t *testing.T) {
+	f := NewFakeRunner("zypper")
+	z := &ZypperManager{Runner: f}
+
+	if err := z.Install([]string{"git", "gnupg", "coreutils"}, "brew"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if err := z.Install([]string{"google-chrome", "iterm2"}, "cask"); err != nil {
+		t.Fatalf("Install casks: %v", err)
+	}
+	assertCommands(t, f,
+		"zypper --non-interactive install git gpg2",
+		"zypper --non-interactive install google-chrome-stable",
+	)
+}
+
+func TestZypperIsInstalled(t *testing.T) {
+	f := NewFakeRunner("zypper")
+	f.Errors["rpm -q tmux"] = errors.New("exit status 1")
+	z := &ZypperManager{Runner: f}
+
+	for pkg, want := range map[string]bool{"gnupg": true, "tmux": false} {
+		got, err := z.IsInstalled(pkg, "brew")
+		if err != nil {
+			t.Fatalf("IsInstalled(%q): %v", pkg, err)
+		}
+		if got != want {
+			t.Errorf("IsInstalled(%q) = %v, want %v", pkg, got, want)
+		}
+	}
+	if !f.Ran("rpm -q gpg2") || !f.Ran("rpm -q tmux") {
+		t.Errorf("commands = %q, want rpm -q gpg2 and rpm -q tmux", f.Commands)
+	}
+}
+
+func TestZypperListInstalled(t *testing.T) {
+	f := NewFakeRunner("zypper")
+	f.Outputs["zypper --quiet --non-interactive search --installed-only --type package"] = `
+S  | Name | Summary                        | Type
+---+------+--------------------------------+--------
+i+ | git  | Fast, scalable, distributed... | package
+i  | gpg2 | File encryption, decryption... | package
+i+ | code | Visual Studio Code             | package
+`
+	z := &ZypperManager{Runner: f}
+
+	got, err := z.ListInstalled("brew")
+	if err != nil {
+		t.Fatalf("ListInstalled: %v", err)
+	}
+	assertNames(t, got, []string{"git", "gnupg", "visual-studio-code"})
+	assertCommands(t, f, "zypper --quiet --non-interactive search --installed-only --type package")
+}
@@ -0,0 +1,195 @@
+// Package render renders .tmpl dotfiles with Go text/template using data
+// about the current machine.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Suffix marks a file in a stow package as a template
+const Suffix = ".tmpl"
+
+// maxPrivateFile is the largest private file exposed to templates
+const maxPrivateFile = 1 << 20
+
+// Data is available to templates, e.g. {{ .Hostname }} or {{ .Vars.email }}
+type Data struct {
+	Hostname       string
+	OS             string // runtime.GOOS: darwin, linux
+	Arch           string // runtime.GOARCH: amd64, arm64
+	PackageManager string // Detected system package manager, e.g. homebrew
+	User           string
+	Home           string
+	Root           string                 // Dotfiles root directory
+	Vars           map[string]interface{} // "variables" from config.json, with matching "host_variables" applied
+	Private        map[string]string      // Files in private/ by relative path, e.g. {{ index .Private "email" }}
+}
+
+// NewData collects the data for this machine. home is the stow target,
+// vars are the configured variables and hostVars the per-host overrides
+// keyed by hostname pattern.
+func NewData(root, home, privateDir, packageManager string, vars map[string]interface{}, hostVars map[string]map[string]interface{}) (Data, error) {
+	hostname, _ := os.Hostname()
+
+	data := Data{
+		Hostname:       hostname,
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		PackageManager: packageManager,
+		User:           os.Getenv("USER"),
+		Home:           home,
+		Root:           root,
+		Vars:           MergeVars(hostname, vars, hostVars),
+	}
+
+	private, err := readPrivate(privateDir)
+	if err != nil {
+		return data, err
+	}
+	data.Private = private
+	return data, nil
+}
+
+// MergeVars returns vars with the host_variables of every pattern matching
+// hostname applied on top, in pattern order
+func MergeVars(hostname string, vars map[string]interface{}, hostVars map[string]map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for key, value := range vars {
+		merged[key] = value
+	}
+
+	var patterns []string
+	for pattern := range hostVars {
+		patterns = append(patterns, pattern)
+	}
+	// Exact hostnames win over patterns
+	sortPatterns(patterns, hostname)
+
+	short := strings.SplitN(hostname, ".", 2)[0]
+	for _, pattern := range patterns {
+		full, _ := path.Match(pattern, hostname)
+		shortMatch, _ := path.Match(pattern, short)
+		if !full && !shortMatch {
+			continue
+		}
+		for key, value := range hostVars[pattern] {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// sortPatterns orders patterns so that exact matches for hostname come last
+// and are applied over glob patterns
+func sortPatterns(patterns []string, hostname string) {
+	short := strings.SplitN(hostname, ".", 2)[0]
+	exact := func(p string) bool { return p == hostname || p == short }
+	sort.Slice(patterns, func(i, j int) bool {
+		if exact(patterns[i]) != exact(patterns[j]) {
+			return !exact(patterns[i])
+		}
+		return patterns[i] < patterns[j]
+	})
+}
+
+// readPrivate reads the regular files in the private directory, keyed by
+// slash-separated relative path
+func readPrivate(dir string) (map[string]string, error) {
+	values := make(map[string]string)
+	if dir == "" {
+		return values, nil
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > maxPrivateFile {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, p)
+		values[filepath.ToSlash(rel)] = strings.TrimRight(string(content), "\n")
+		return nil
+	})
+	return values, err
+}
+
+// funcs are the helper functions available to templates
+var funcs = template.FuncMap{
+	"env":       os.Getenv,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"replace":   strings.ReplaceAll,
+	"contains":  strings.Contains,
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"join": func(sep string, items []interface{}) string {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// File renders the template at path. Referencing a missing key is an error;
+// use {{ index .Vars "name" }} for optional variables.
+func File(templatePath string, data Data) ([]byte, error) {
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	return String(filepath.Base(templatePath), string(content), data)
+}
+
+// String renders template text
+func String(name, text string, data Data) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// IsTemplate reports whether a file name marks a template
+func IsTemplate(name string) bool {
+	return strings.HasSuffix(name, Suffix) && len(name) > len(Suffix)
+}
+
+// TargetName returns the name a template is rendered to
+func TargetName(name string) string {
+	return strings.TrimSuffix(name, Suffix)
+}
@@ -0,0 +1,188 @@
+// Package scripts runs the bootstrap scripts kept in the dotfiles scripts
+// directory. Scripts named run_once_* run once per machine and scripts named
+// run_onchange_* run again whenever their content changes. Other files are
+// ignored. Scripts run in name order.
+package scripts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Script name prefixes
+const (
+	OncePrefix     = "run_once_"
+	OnChangePrefix = "run_onchange_"
+)
+
+// Kind is when a script runs
+type Kind string
+
+const (
+	Once     Kind = "once"
+	OnChange Kind = "onchange"
+)
+
+// Script is a bootstrap script found in the scripts directory
+type Script struct {
+	Name string // File name, relative to the scripts directory
+	Path string
+	Kind Kind
+	Hash string // sha256 of the script content
+}
+
+// Entry records the last successful run of a script
+type Entry struct {
+	Hash  string    `json:"hash"`
+	RanAt time.Time `json:"ran_at"`
+}
+
+// State is the record of scripts that ran on this machine
+type State struct {
+	Scripts map[string]Entry `json:"scripts"`
+
+	path string
+}
+
+// Discover returns the run_once_ and run_onchange_ scripts in dir, sorted by
+// name. A missing directory has no scripts.
+func Discover(dir string) ([]Script, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var scripts []Script
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		var kind Kind
+		switch {
+		case strings.HasPrefix(name, OncePrefix):
+			kind = Once
+		case strings.HasPrefix(name, OnChangePrefix):
+			kind = OnChange
+		default:
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		hash, err := hashFile(path)
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, Script{Name: name, Path: path, Kind: kind, Hash: hash})
+	}
+
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].Name < scripts[j].Name })
+	return scripts, nil
+}
+
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// LoadState reads the script state file, or starts an empty state when there
+// is none
+func LoadState(path string) (*State, error) {
+	state := &State{Scripts: make(map[string]Entry), path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.Scripts == nil {
+		state.Scripts = make(map[string]Entry)
+	}
+	return state, nil
+}
+
+// Save writes the state file
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// Pending reports whether the script should run: run_once_ scripts that
+// never ran, and run_onchange_ scripts whose content changed since their
+// last run
+func (s *State) Pending(script Script) bool {
+	entry, ran := s.Scripts[script.Name]
+	if !ran {
+		return true
+	}
+	return script.Kind == OnChange && entry.Hash != script.Hash
+}
+
+// Status describes the script's state for listings: "pending", "changed"
+// or "done"
+func (s *State) Status(script Script) string {
+	if _, ran := s.Scripts[script.Name]; ran && s.Pending(script) {
+		return "changed"
+	}
+	if s.Pending(script) {
+		return "pending"
+	}
+	return "done"
+}
+
+// Record marks the script as run and saves the state
+func (s *State) Record(script Script) error {
+	s.Scripts[script.Name] = Entry{Hash: script.Hash, RanAt: time.Now()}
+	return s.Save()
+}
+
+// Run executes the script from workDir with the terminal attached. Executable
+// scripts run directly so their shebang is honoured; others run with sh.
+func Run(script Script, workDir string, env []string) error {
+	var cmd *exec.Cmd
+	if info, err := os.Stat(script.Path); err == nil && info.Mode()&0111 != 0 {
+		cmd = exec.Command(script.Path)
+	} else {
+		cmd = exec.Command("sh", script.Path)
+	}
+
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", script.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// AllowMarker on a line suppresses findings on that line
+const AllowMarker = "dotfiles:allow"
+
+// maxScanFile is the largest file the scanner reads
+const maxScanFile = 1 << 20
+
+// Rule is a kind of secret the scanner looks for
+type Rule struct {
+	ID          string
+	Description string
+	Pattern     *regexp.Regexp
+	// Group is the submatch holding the secret, 0 for the whole match
+	Group int
+	// Check filters matches, e.g. by entropy. Nil accepts every match.
+	Check func(secret string) bool
+	// Public matches, such as key headers, are not secret themselves and are
+	// printed in full
+	Public bool
+}
+
+// Rules are checked in order; a later rule does not report text an earlier
+// rule already matched
+var Rules = []Rule{
+	{
+		ID:          "private-key",
+		Description: "Private key",
+		Pattern:     regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`),
+		Public:      true,
+	},
+	{
+		ID:          "age-secret-key",
+		Description: "age secret key",
+		Pattern:     regexp.MustCompile(`AGE-SECRET-KEY-1[0-9A-Z]{58}`),
+	},
+	{
+		ID:          "aws-access-key-id",
+		Description: "AWS access key ID",
+		Pattern:     regexp.MustCompile(`\b(AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`),
+	},
+	{
+		ID:          "aws-secret-access-key",
+		Description: "AWS secret access key",
+		Pattern:     regexp.MustCompile(`(?i)aws_?secret_?(access_?)?key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})\b`),
+		Group:       2,
+	},
+	{
+		ID:          "github-token",
+		Description: "GitHub token",
+		Pattern:     regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`),
+	},
+	{
+		ID:          "slack-token",
+		Description: "Slack token",
+		Pattern:     regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`),
+	},
+	{
+		ID:          "secret-assignment",
+		Description: "Secret assigned to a key, token or password variable",
+		Pattern:     regexp.MustCompile(`(?i)(api_?key|secret|token|passw(or)?d|pwd|auth)[A-Za-z0-9_.-]*["']?\s*[=:]\s*["']?([^\s"'$<>{}]{16,})`),
+		Group:       3,
+		Check: func(secret string) bool {
+			return hasDigit(secret) && hasLetter(secret) && Entropy(secret) >= 3.5
+		},
+	},
+	{
+		ID:          "high-entropy",
+		Description: "High-entropy string",
+		Pattern:     regexp.MustCompile(`[A-Za-z0-9+/=_-]{20,}`),
+		Check: func(secret string) bool {
+			return hasDigit(secret) && hasUpper(secret) && hasLower(secret) && Entropy(secret) > 4.5
+		},
+	},
+}
+
+// Finding is a possible secret
+type Finding struct {
+	Path        string `json:"path"` // Slash-separated, relative to the scanned root
+	Line        int    `json:"line"`
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Redacted    string `json:"redacted"`    // Start of the secret, safe to print
+	Fingerprint string `json:"fingerprint"` // Identifies the secret in the allowlist
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s %s (%s)", f.Path, f.Line, f.Description, f.Redacted, f.Fingerprint)
+}
+
+// Allowlist suppresses findings. Each line of the allowlist file is a path
+// glob (a directory matches everything below it) or a fingerprint such as
+// sha256:3f9a0c1b2d4e5f60. Lines starting with # are comments.
+type Allowlist struct {
+	Paths        []string
+	Fingerprints []string
+}
+
+// LoadAllowlist reads an allowlist file. A missing file allows nothing.
+func LoadAllowlist(path string) (*Allowlist, error) {
+	allow := &Allowlist{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return allow, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "sha256:"):
+			allow.Fingerprints = append(allow.Fingerprints, line)
+		default:
+			allow.Paths = append(allow.Paths, strings.TrimSuffix(line, "/"))
+		}
+	}
+	return allow, scanner.Err()
+}
+
+// Allows reports whether the allowlist suppresses the finding
+func (a *Allowlist) Allows(f Finding) bool {
+	if a == nil {
+		return false
+	}
+	for _, fingerprint := range a.Fingerprints {
+		if strings.HasPrefix(f.Fingerprint, fingerprint) {
+			return true
+		}
+	}
+	for _, pattern := range a.Paths {
+		if f.Path == pattern || strings.HasPrefix(f.Path, pattern+"/") {
+			return true
+		}
+		if ok, _ := path.Match(pattern, f.Path); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(f.Path)); ok && !strings.Contains(pattern, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Scanner finds secrets in files and text
+type Scanner struct {
+	Allowlist *Allowlist
+}
+
+// ScanFile scans a file under root. Encrypted .age files, binary files and
+// files over 1MB are skipped.
+func (s *Scanner) ScanFile(root, rel string) ([]Finding, error) {
+	if strings.HasSuffix(rel, Ext) {
+		return nil, nil
+	}
+
+	full := filepath.Join(root, filepath.FromSlash(rel))
+	info, err := os.Stat(full)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxScanFile {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil, nil
+	}
+	return s.Scan(filepath.ToSlash(rel), data), nil
+}
+
+// Scan checks text for secrets, reporting findings under name
+func (s *Scanner) Scan(name string, data []byte) []Finding {
+	var findings []Finding
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.Contains(line, AllowMarker) {
+			continue
+		}
+
+		var taken [][2]int
+		for _, rule := range Rules {
+			for _, match := range rule.Pattern.FindAllStringSubmatchIndex(line, -1) {
+				start, end := match[2*rule.Group], match[2*rule.Group+1]
+				if start < 0 || overlaps(taken, start, end) {
+					continue
+				}
+				secret := line[start:end]
+				if rule.Check != nil && !rule.Check(secret) {
+					continue
+				}
+				taken = append(taken, [2]int{start, end})
+
+				finding := Finding{
+					Path:        name,
+					Line:        i + 1,
+					Rule:        rule.ID,
+					Description: rule.Description,
+					Redacted:    secret,
+					Fingerprint: Fingerprint(secret),
+				}
+				if rule.Public {
+					// Identical in every file, so tie the fingerprint to this one
+					finding.Fingerprint = Fingerprint(name + ":" + secret)
+				} else {
+					finding.Redacted = redact(secret)
+				}
+				if !s.Allowlist.Allows(finding) {
+					findings = append(findings, finding)
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// Fingerprint identifies a secret without revealing it
+func Fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// Entropy returns the Shannon entropy of s in bits per character
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	length := float64(len([]rune(s)))
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func redact(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", min(len(secret)-4, 12))
+}
+
+func overlaps(taken [][2]int, start, end int) bool {
+	for _, span := range taken {
+		if start < span[1] && end > span[0] {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDigit(s string) bool { return strings.ContainsAny(s, "0123456789") }
+func hasUpper(s string) bool { return strings.ToLower(s) != s }
+func hasLower(s string) bool { return strings.ToUpper(s) != s }
+func hasLetter(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') }) >= 0
+}
@@ -0,0 +1,455 @@
+// Package secrets encrypts the files in the private directory into a tree of
+// age files that can be committed, and decrypts them again on other
+// machines. Files are encrypted to every X25519 recipient listed in the
+// recipients file and decrypted with the machine's identity. The package
+// also scans files for secrets before they are committed or shared.
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// Ext is appended to the name of every encrypted file
+const Ext = ".age"
+
+// ErrNoIdentity is returned when the machine has no identity to decrypt with
+var ErrNoIdentity = errors.New("no age identity found, run 'dotfiles secrets keygen'")
+
+// ErrNoRecipients is returned when there is nobody to encrypt to
+var ErrNoRecipients = errors.New("no recipients configured, run 'dotfiles secrets keygen' or 'dotfiles secrets add-recipient'")
+
+// File states reported by Status
+const (
+	Unencrypted = "unencrypted" // Only in the private directory
+	Modified    = "modified"    // Private file differs from its encrypted copy
+	Encrypted   = "encrypted"   // Private file matches its encrypted copy
+	Missing     = "missing"     // Only encrypted, not decrypted on this machine
+	Unreadable  = "unreadable"  // Encrypted copy cannot be decrypted with the identity
+)
+
+// Store pairs the private directory with its encrypted copy
+type Store struct {
+	PrivateDir     string // Plain files, never committed
+	EncryptedDir   string // <file>.age for every private file, committed
+	RecipientsPath string // Public keys files are encrypted to, committed
+	IdentityPath   string // This machine's secret key, kept outside the repo
+}
+
+// FileStatus is the state of one private file
+type FileStatus struct {
+	Path  string // Slash-separated path relative to the private directory
+	State string
+	Err   error
+}
+
+// Result lists the files an encrypt, decrypt or rekey touched
+type Result struct {
+	Written   []string
+	Unchanged []string
+	Conflicts []string // Decrypt only: local files that differ and were kept
+}
+
+// GenerateIdentity creates a new identity at path. It refuses to overwrite an
+// existing identity.
+func GenerateIdentity(path string) (*age.X25519Identity, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("identity already exists at %s", path)
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+		time.Now().Format(time.RFC3339), identity.Recipient(), identity)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// Identities reads the identities in the identity file
+func (s *Store) Identities() ([]age.Identity, error) {
+	f, err := os.Open(s.IdentityPath)
+	if os.IsNotExist(err) {
+		return nil, ErrNoIdentity
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return age.ParseIdentities(f)
+}
+
+// PublicKeys returns the recipients of the machine's X25519 identities
+func (s *Store) PublicKeys() ([]string, error) {
+	identities, err := s.Identities()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, identity := range identities {
+		if x, ok := identity.(*age.X25519Identity); ok {
+			keys = append(keys, x.Recipient().String())
+		}
+	}
+	return keys, nil
+}
+
+// Recipients returns the public keys in the recipients file, without comments
+func (s *Store) Recipients() ([]string, error) {
+	f, err := os.Open(s.RecipientsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, scanner.Err()
+}
+
+// AddRecipient appends a public key to the recipients file with an optional
+// comment. It reports false when the key was already listed.
+func (s *Store) AddRecipient(key, comment string) (bool, error) {
+	if _, err := age.ParseX25519Recipient(key); err != nil {
+		return false, err
+	}
+
+	keys, err := s.Recipients()
+	if err != nil {
+		return false, err
+	}
+	for _, existing := range keys {
+		if existing == key {
+			return false, nil
+		}
+	}
+
+	f, err := os.OpenFile(s.RecipientsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	if comment != "" {
+		if _, err := fmt.Fprintf(f, "# %s\n", comment); err != nil {
+			return false, err
+		}
+	}
+	_, err = fmt.Fprintln(f, key)
+	return err == nil, err
+}
+
+func (s *Store) parsedRecipients() ([]age.Recipient, error) {
+	keys, err := s.Recipients()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	recipients := make([]age.Recipient, 0, len(keys))
+	for _, key := range keys {
+		recipient, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", s.RecipientsPath, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// PrivateFiles returns the regular files in the private directory
+func (s *Store) PrivateFiles() ([]string, error) {
+	return listFiles(s.PrivateDir, "")
+}
+
+// EncryptedFiles returns the private paths that have an encrypted copy
+func (s *Store) EncryptedFiles() ([]string, error) {
+	return listFiles(s.EncryptedDir, Ext)
+}
+
+// listFiles walks dir for regular files ending in ext and returns their
+// slash-separated relative paths with ext removed. A missing dir is empty.
+func listFiles(dir, ext string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || !strings.HasSuffix(d.Name(), ext) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, strings.TrimSuffix(filepath.ToSlash(rel), ext))
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+func (s *Store) privatePath(rel string) string {
+	return filepath.Join(s.PrivateDir, filepath.FromSlash(rel))
+}
+
+func (s *Store) encryptedPath(rel string) string {
+	return filepath.Join(s.EncryptedDir, filepath.FromSlash(rel)+Ext)
+}
+
+// Status compares every private file with its encrypted copy. Without an
+// identity, files that exist on both sides are reported as Unreadable.
+func (s *Store) Status() ([]FileStatus, error) {
+	private, err := s.PrivateFiles()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.EncryptedFiles()
+	if err != nil {
+		return nil, err
+	}
+	identities, idErr := s.Identities()
+
+	paths := append(append([]string{}, private...), encrypted...)
+	sort.Strings(paths)
+
+	var statuses []FileStatus
+	for i, rel := range paths {
+		if i > 0 && paths[i-1] == rel {
+			continue
+		}
+
+		plain, err := os.ReadFile(s.privatePath(rel))
+		hasPlain := err == nil
+		if !hasPlain && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		status := FileStatus{Path: rel}
+		switch {
+		case !fileExists(s.encryptedPath(rel)):
+			status.State = Unencrypted
+		case !hasPlain:
+			status.State = Missing
+		case idErr != nil:
+			status.State, status.Err = Unreadable, idErr
+		default:
+			decrypted, err := decryptFile(s.encryptedPath(rel), identities)
+			switch {
+			case err != nil:
+				status.State, status.Err = Unreadable, err
+			case bytes.Equal(decrypted, plain):
+				status.State = Encrypted
+			default:
+				status.State = Modified
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Encrypt writes the encrypted copy of the given private files, or of all
+// of them when paths is empty. Files whose encrypted copy already decrypts
+// to the same content are left alone, so unchanged secrets don't show up
+// as changes in git.
+func (s *Store) Encrypt(paths []string) (Result, error) {
+	var result Result
+
+	recipients, err := s.parsedRecipients()
+	if err != nil {
+		return result, err
+	}
+	// Without an identity every file is re-encrypted
+	identities, _ := s.Identities()
+
+	if len(paths) == 0 {
+		if paths, err = s.PrivateFiles(); err != nil {
+			return result, err
+		}
+	}
+
+	for _, rel := range paths {
+		rel = filepath.ToSlash(filepath.Clean(rel))
+		plain, err := os.ReadFile(s.privatePath(rel))
+		if err != nil {
+			return result, err
+		}
+
+		dest := s.encryptedPath(rel)
+		if identities != nil {
+			if current, err := decryptFile(dest, identities); err == nil && bytes.Equal(current, plain) {
+				result.Unchanged = append(result.Unchanged, rel)
+				continue
+			}
+		}
+
+		if err := writeEncrypted(dest, plain, recipients); err != nil {
+			return result, fmt.Errorf("%s: %v", rel, err)
+		}
+		result.Written = append(result.Written, rel)
+	}
+	return result, nil
+}
+
+// Decrypt writes the given encrypted files, or all of them when paths is
+// empty, into the private directory. Private files that differ from their
+// encrypted copy are kept and reported as conflicts unless force is set.
+func (s *Store) Decrypt(paths []string, force bool) (Result, error) {
+	var result Result
+
+	var err error
+	if len(paths) == 0 {
+		if paths, err = s.EncryptedFiles(); err != nil || len(paths) == 0 {
+			return result, err
+		}
+	}
+	identities, err := s.Identities()
+	if err != nil {
+		return result, err
+	}
+
+	for _, rel := range paths {
+		rel = filepath.ToSlash(filepath.Clean(rel))
+		plain, err := decryptFile(s.encryptedPath(rel), identities)
+		if err != nil {
+			return result, fmt.Errorf("%s: %v", rel, err)
+		}
+
+		dest := s.privatePath(rel)
+		if current, err := os.ReadFile(dest); err == nil {
+			if bytes.Equal(current, plain) {
+				result.Unchanged = append(result.Unchanged, rel)
+				continue
+			}
+			if !force {
+				result.Conflicts = append(result.Conflicts, rel)
+				continue
+			}
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return result, err
+		}
+		if err := os.WriteFile(dest, plain, 0600); err != nil {
+			return result, err
+		}
+		result.Written = append(result.Written, rel)
+	}
+	return result, nil
+}
+
+// Rekey re-encrypts every encrypted file to the current recipients, so added
+// recipients can decrypt them and removed ones no longer can
+func (s *Store) Rekey() (Result, error) {
+	var result Result
+
+	recipients, err := s.parsedRecipients()
+	if err != nil {
+		return result, err
+	}
+	identities, err := s.Identities()
+	if err != nil {
+		return result, err
+	}
+	encrypted, err := s.EncryptedFiles()
+	if err != nil {
+		return result, err
+	}
+
+	for _, rel := range encrypted {
+		path := s.encryptedPath(rel)
+		plain, err := decryptFile(path, identities)
+		if err != nil {
+			return result, fmt.Errorf("%s: %v", rel, err)
+		}
+		if err := writeEncrypted(path, plain, recipients); err != nil {
+			return result, fmt.Errorf("%s: %v", rel, err)
+		}
+		result.Written = append(result.Written, rel)
+	}
+	return result, nil
+}
+
+// writeEncrypted writes plain to path as an armored age file
+func writeEncrypted(path string, plain []byte, recipients []age.Recipient) error {
+	var buf bytes.Buffer
+	armored := armor.NewWriter(&buf)
+	w, err := age.Encrypt(armored, recipients...)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(plain); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := armored.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// decryptFile decrypts an armored or binary age file
+func decryptFile(path string, identities []age.Identity) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
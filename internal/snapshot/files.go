@@ -0,0 +1,305 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kinds of captured files, which decide the directory File.Path is in
+const (
+	KindStow    = "stow"    // A file of a stow package, relative to stow/
+	KindPrivate = "private" // A file in private/, relative to it
+	KindTarget  = "target"  // A file in the home directory, relative to it
+)
+
+// File is a file captured in a snapshot. Its content is stored once, by
+// hash, under state/objects/ and shared by every snapshot holding it.
+type File struct {
+	Kind string      `json:"kind"`
+	Path string      `json:"path"`
+	Hash string      `json:"hash,omitempty"` // sha256 of the content; empty for symlinks
+	Mode fs.FileMode `json:"mode"`
+	Size int64       `json:"size,omitempty"`
+	Link string      `json:"link,omitempty"` // Destination of a symlink
+}
+
+// ObjectsDir returns the content store of snapshot files. It lives in
+// state/, which is not committed, since it holds private/ and home files;
+// callers capturing files create state/ and its .gitignore first.
+func ObjectsDir(root string) string {
+	return filepath.Join(root, "state", "objects")
+}
+
+// kindDir returns the directory the files of a kind are relative to
+func kindDir(root, home, kind string) (string, error) {
+	switch kind {
+	case KindStow:
+		return filepath.Join(root, "stow"), nil
+	case KindPrivate:
+		return filepath.Join(root, "private"), nil
+	case KindTarget:
+		return home, nil
+	}
+	return "", fmt.Errorf("unknown file kind %q", kind)
+}
+
+// Location returns where the file belongs
+func (f File) Location(root, home string) (string, error) {
+	dir, err := kindDir(root, home, f.Kind)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(f.Path)), nil
+}
+
+// objectPath returns where the content with hash is stored
+func objectPath(root, hash string) string {
+	return filepath.Join(ObjectsDir(root), hash[:2], hash[2:])
+}
+
+// storeObject saves data under its hash, unless an identical file is
+// already stored, and returns the hash
+func storeObject(root string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := objectPath(root, hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return "", err
+	}
+	return hash, os.Rename(tmp, path)
+}
+
+// captureFile stores the file at path and records it as rel within kind
+func captureFile(root, kind, rel, path string) (File, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return File{}, err
+	}
+
+	file := File{Kind: kind, Path: filepath.ToSlash(rel), Mode: info.Mode()}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		file.Link, err = os.Readlink(path)
+		return file, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	file.Hash, err = storeObject(root, data)
+	file.Size = int64(len(data))
+	return file, err
+}
+
+// captureTree captures every file under dir, which may not exist
+func captureTree(root, kind, dir string) ([]File, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		file, err := captureFile(root, kind, rel, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
+// captureTargets captures files in the home directory, such as those about
+// to be replaced by stow links. Directories are captured whole; paths that
+// don't exist are skipped.
+func captureTargets(root, home string, paths []string) ([]File, error) {
+	var files []File
+	for _, path := range paths {
+		rel, err := filepath.Rel(home, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return nil, fmt.Errorf("%s is outside %s", path, home)
+		}
+
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			file, err := captureFile(root, KindTarget, rel, path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+			continue
+		}
+
+		tree, err := captureTree(root, KindTarget, path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range tree {
+			file.Path = filepath.ToSlash(filepath.Join(rel, file.Path))
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// RestoreFiles puts the files of a snapshot for which keep returns true
+// (all when keep is nil) back in place, and returns those that changed.
+// Files that already match are left alone. A symlink in the way, e.g. a
+// stow link where a replaced file was, is removed rather than written
+// through.
+func RestoreFiles(root, home string, snap *Snapshot, keep func(File) bool) ([]File, error) {
+	var restored []File
+	for _, file := range snap.Files {
+		if keep != nil && !keep(file) {
+			continue
+		}
+		path, err := file.Location(root, home)
+		if err != nil {
+			return restored, err
+		}
+
+		changed, err := restoreFile(root, path, file)
+		if err != nil {
+			return restored, fmt.Errorf("%s: %v", path, err)
+		}
+		if changed {
+			restored = append(restored, file)
+		}
+	}
+	return restored, nil
+}
+
+func restoreFile(root, path string, file File) (bool, error) {
+	if Matches(path, file) {
+		return false, nil
+	}
+
+	var data []byte
+	if file.Link == "" {
+		var err error
+		if data, err = os.ReadFile(objectPath(root, file.Hash)); err != nil {
+			return false, fmt.Errorf("content missing from %s", ObjectsDir(root))
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+		if err := os.Remove(path); err != nil {
+			return false, err
+		}
+	}
+
+	if file.Link != "" {
+		return true, os.Symlink(file.Link, path)
+	}
+	return true, os.WriteFile(path, data, file.Mode.Perm())
+}
+
+// Matches reports whether the file at path is the one captured
+func Matches(path string, file File) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	if file.Link != "" {
+		link, err := os.Readlink(path)
+		return err == nil && link == file.Link
+	}
+	if !info.Mode().IsRegular() || info.Size() != file.Size {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) == file.Hash
+}
+
+// PruneObjects removes stored content no snapshot refers to any more, and
+// returns how many objects it removed
+func PruneObjects(root string) (int, error) {
+	used, err := usedObjects(root)
+	if err != nil {
+		return 0, err
+	}
+
+	dir := ObjectsDir(root)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+	removed := 0
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		hash := filepath.Base(filepath.Dir(path)) + entry.Name()
+		if !used[hash] {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// usedObjects returns the hashes snapshots refer to. Unlike ListSnapshots it
+// fails on a snapshot it can't read, whose content must not be pruned.
+func usedObjects(root string) (map[string]bool, error) {
+	paths, err := filepath.Glob(filepath.Join(root, "snapshots", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+		for _, file := range snap.Files {
+			used[file.Hash] = true
+		}
+	}
+	return used, nil
+}
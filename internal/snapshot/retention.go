@@ -0,0 +1,96 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"dotfiles/internal/config"
+)
+
+// Retain works out which snapshots a retention policy keeps, and why: the
+// reasons are keyed by timestamp, e.g. "last 10, daily". Snapshots missing
+// from the result are the ones to remove. Tagged snapshots and those whose
+// timestamp can't be read are always kept.
+func Retain(snapshots []Snapshot, policy config.SnapshotRetention) map[string]string {
+	sorted := append([]Snapshot{}, snapshots...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Timestamp > sorted[j].Timestamp })
+
+	kept := make(map[string]string)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	dated := 0 // Snapshots with a date so far; the others don't count as the last ones
+	for _, snap := range sorted {
+		var reasons []string
+		if len(snap.Tags) > 0 {
+			reasons = append(reasons, "tagged "+strings.Join(snap.Tags, ", "))
+		}
+
+		t, err := time.ParseInLocation("20060102-150405", snap.Timestamp, time.Local)
+		if err != nil {
+			kept[snap.Timestamp] = "unknown date"
+			continue
+		}
+
+		if dated < policy.KeepLast {
+			reasons = append(reasons, fmt.Sprintf("last %d", policy.KeepLast))
+		}
+		dated++
+		// The newest snapshot of a day or week stands for it
+		if day := t.Format("2006-01-02"); !days[day] {
+			days[day] = true
+			if len(days) <= policy.KeepDaily {
+				reasons = append(reasons, "daily")
+			}
+		}
+		year, week := t.ISOWeek()
+		if key := fmt.Sprintf("%d-W%02d", year, week); !weeks[key] {
+			weeks[key] = true
+			if len(weeks) <= policy.KeepWeekly {
+				reasons = append(reasons, "weekly")
+			}
+		}
+
+		if len(reasons) > 0 {
+			kept[snap.Timestamp] = strings.Join(reasons, ", ")
+		}
+	}
+	return kept
+}
+
+// Prune removes the snapshots a retention policy doesn't keep, then the
+// file contents only they held, and returns the removed snapshots. With
+// dryRun nothing is removed. A policy without rules is refused rather than
+// removing every untagged snapshot.
+func Prune(root string, policy config.SnapshotRetention, dryRun bool) ([]Snapshot, error) {
+	if policy.IsEmpty() {
+		return nil, fmt.Errorf("the retention policy keeps nothing; set keep_last, keep_daily or keep_weekly")
+	}
+	snapshots, err := ListSnapshots(root)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := Retain(snapshots, policy)
+	var removed []Snapshot
+	for _, snap := range snapshots {
+		if _, ok := kept[snap.Timestamp]; ok {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(snapshotPath(root, snap.Timestamp)); err != nil {
+				return removed, err
+			}
+		}
+		removed = append(removed, snap)
+	}
+
+	if len(removed) > 0 && !dryRun {
+		if _, err := PruneObjects(root); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,179 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"dotfiles/internal/config"
+)
+
+// retentionSnapshots span two ISO weeks of 2024 and the last one of 2023,
+// listed out of order as ListSnapshots may return them
+func retentionSnapshots() []Snapshot {
+	return []Snapshot{
+		{Timestamp: "20240105-120000"},                               // Fri, 2024-W01
+		{Timestamp: "20240110-090000"},                               // Wed, 2024-W02
+		{Timestamp: "20231231-120000"},                               // Sun, 2023-W52
+		{Timestamp: "20240110-120000"},                               // Wed, 2024-W02, newest
+		{Timestamp: "20240101-080000", Tags: []string{"known-good"}}, // Mon, 2024-W01
+		{Timestamp: "20240109-120000"},                               // Tue, 2024-W02
+	}
+}
+
+func TestRetain(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy config.SnapshotRetention
+		want   map[string]string
+	}{
+		{
+			name:   "keep last",
+			policy: config.SnapshotRetention{KeepLast: 2},
+			want: map[string]string{
+				"20240110-120000": "last 2",
+				"20240110-090000": "last 2",
+				"20240101-080000": "tagged known-good",
+			},
+		},
+		{
+			name:   "daily keeps the newest of each day",
+			policy: config.SnapshotRetention{KeepDaily: 2},
+			want: map[string]string{
+				"20240110-120000": "daily",
+				"20240109-120000": "daily",
+				"20240101-080000": "tagged known-good",
+			},
+		},
+		{
+			name:   "weekly keeps the newest of each ISO week",
+			policy: config.SnapshotRetention{KeepWeekly: 3},
+			want: map[string]string{
+				"20240110-120000": "weekly",
+				"20240105-120000": "weekly",
+				"20231231-120000": "weekly",
+				"20240101-080000": "tagged known-good",
+			},
+		},
+		{
+			name:   "rules combine",
+			policy: config.SnapshotRetention{KeepLast: 1, KeepDaily: 1, KeepWeekly: 2},
+			want: map[string]string{
+				"20240110-120000": "last 1, daily, weekly",
+				"20240105-120000": "weekly",
+				"20240101-080000": "tagged known-good",
+			},
+		},
+		{
+			name:   "tagged snapshot a rule keeps too",
+			policy: config.SnapshotRetention{KeepDaily: 5},
+			want: map[string]string{
+				"20240110-120000": "daily",
+				"20240109-120000": "daily",
+				"20240105-120000": "daily",
+				"20240101-080000": "tagged known-good, daily",
+				"20231231-120000": "daily",
+			},
+		},
+		{
+			name:   "empty policy keeps only tagged",
+			policy: config.SnapshotRetention{},
+			want: map[string]string{
+				"20240101-080000": "tagged known-good",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Retain(retentionSnapshots(), tt.policy)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Retain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetainUnknownDate(t *testing.T) {
+	snapshots := []Snapshot{{Timestamp: "20240110-120000"}, {Timestamp: "imported"}}
+	got := Retain(snapshots, config.SnapshotRetention{KeepLast: 1})
+	want := map[string]string{"20240110-120000": "last 1", "imported": "unknown date"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Retain() = %v, want %v", got, want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  config.SnapshotRetention
+		dryRun  bool
+		removed []string
+		wantErr bool
+	}{
+		{
+			name:    "keep last",
+			policy:  config.SnapshotRetention{KeepLast: 2},
+			removed: []string{"20231231-120000", "20240105-120000", "20240109-120000"},
+		},
+		{
+			name:    "dry run",
+			policy:  config.SnapshotRetention{KeepLast: 2},
+			dryRun:  true,
+			removed: []string{"20231231-120000", "20240105-120000", "20240109-120000"},
+		},
+		{
+			name:    "empty policy is refused",
+			policy:  config.SnapshotRetention{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(root, "snapshots"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			all := retentionSnapshots()
+			for i := range all {
+				if err := Save(root, &all[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			removed, err := Prune(root, tt.policy, tt.dryRun)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Prune() succeeded, want an error")
+				}
+			} else if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+
+			var names []string
+			for _, snap := range removed {
+				names = append(names, snap.Timestamp)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.removed) {
+				t.Errorf("Prune() removed %v, want %v", names, tt.removed)
+			}
+
+			// Only an actual prune deletes files
+			left, err := ListSnapshots(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantLeft := len(all)
+			if !tt.dryRun {
+				wantLeft -= len(tt.removed)
+			}
+			if len(left) != wantLeft {
+				t.Errorf("%d snapshot(s) left, want %d", len(left), wantLeft)
+			}
+		})
+	}
+}
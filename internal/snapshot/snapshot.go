@@ -17,27 +17,62 @@ type Snapshot struct {
 	Description string            `json:"description"`
 	Config      *config.Config    `json:"config"`
 	Metadata    map[string]string `json:"metadata"`
+	Files       []File            `json:"files,omitempty"` // Captured dotfiles, see Options
+	Tags        []string          `json:"tags,omitempty"`  // Names such as "known-good"; tagged snapshots are never pruned
 }
 
-// CreateAutoSnapshot creates an automatic snapshot before major operations
-func CreateAutoSnapshot(description string) (string, error) {
-	home, err := os.UserHomeDir()
+// Options choose what a snapshot captures besides the configuration
+type Options struct {
+	Files    bool              // Every file of the stow packages and private/
+	Home     string            // Directory Targets are in
+	Targets  []string          // Home files about to be replaced, e.g. stow conflicts
+	Metadata map[string]string // Added to the default metadata
+}
+
+// CreateAutoSnapshot creates an automatic snapshot before major operations.
+// root is the dotfiles root directory holding config.json and snapshots/.
+// Old snapshots are pruned afterwards when the configuration sets a
+// snapshot_retention.
+func CreateAutoSnapshot(root, description string) (string, error) {
+	snap, err := Create(root, description, Options{Metadata: map[string]string{"created_by": "auto-snapshot"}})
 	if err != nil {
 		return "", err
 	}
+	if policy := snap.Config.SnapshotRetention; policy != nil && !policy.IsEmpty() {
+		if _, err := Prune(root, *policy, false); err != nil {
+			return snap.Timestamp, fmt.Errorf("snapshot created, but pruning failed: %v", err)
+		}
+	}
+	return snap.Timestamp, nil
+}
 
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+// Create saves a snapshot of the configuration and, as opts ask, of files.
+// File contents are stored once in ObjectsDir, however many snapshots hold
+// them.
+func Create(root, description string, opts Options) (*Snapshot, error) {
+	configPath := config.FindFile(root, "config")
 	cfg, err := config.Load(configPath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	snapshotsDir := filepath.Join(home, ".dotfiles", "snapshots")
+	snapshotsDir := filepath.Join(root, "snapshots")
 	if err := os.MkdirAll(snapshotsDir, 0755); err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// Snapshots taken within a second of each other, e.g. the one before
+	// removing stow conflicts and a backup, each get their own timestamp
+	now := time.Now()
+	timestamp := now.Format("20060102-150405")
+	for {
+		if _, err := os.Stat(filepath.Join(snapshotsDir, timestamp+".json")); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Second)
+		timestamp = now.Format("20060102-150405")
 	}
 
-	timestamp := time.Now().Format("20060102-150405")
 	snapshot := Snapshot{
 		Timestamp:   timestamp,
 		Description: description,
@@ -48,28 +83,76 @@ func CreateAutoSnapshot(description string) (string, error) {
 			"created_at": time.Now().Format(time.RFC3339),
 		},
 	}
+	for key, value := range opts.Metadata {
+		snapshot.Metadata[key] = value
+	}
 
-	snapshotPath := filepath.Join(snapshotsDir, timestamp+".json")
-	data, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return "", err
+	if opts.Files {
+		for _, kind := range []string{KindStow, KindPrivate} {
+			dir, _ := kindDir(root, opts.Home, kind)
+			files, err := captureTree(root, kind, dir)
+			if err != nil {
+				return nil, fmt.Errorf("error capturing %s: %v", dir, err)
+			}
+			snapshot.Files = append(snapshot.Files, files...)
+		}
+	}
+	if len(opts.Targets) > 0 {
+		files, err := captureTargets(root, opts.Home, opts.Targets)
+		if err != nil {
+			return nil, fmt.Errorf("error capturing files: %v", err)
+		}
+		snapshot.Files = append(snapshot.Files, files...)
 	}
 
-	if err := os.WriteFile(snapshotPath, data, 0644); err != nil {
-		return "", err
+	if err := Save(root, &snapshot); err != nil {
+		return nil, err
 	}
+	return &snapshot, nil
+}
 
-	return timestamp, nil
+// Save writes a snapshot to snapshots/<timestamp>.json
+func Save(root string, snap *Snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(snapshotPath(root, snap.Timestamp), data, 0644)
 }
 
-// ListSnapshots returns all available snapshots
-func ListSnapshots() ([]Snapshot, error) {
-	home, err := os.UserHomeDir()
+func snapshotPath(root, timestamp string) string {
+	return filepath.Join(root, "snapshots", timestamp+".json")
+}
+
+// Load reads a snapshot by timestamp or tag
+func Load(root, ref string) (*Snapshot, error) {
+	data, err := os.ReadFile(snapshotPath(root, ref))
+	if os.IsNotExist(err) && !timestampPattern.MatchString(ref) {
+		snap, findErr := FindTag(root, ref)
+		if findErr != nil {
+			return nil, findErr
+		}
+		if snap != nil {
+			return snap, nil
+		}
+	}
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot not found: %s", ref)
+		}
 		return nil, err
 	}
 
-	snapshotsDir := filepath.Join(home, ".dotfiles", "snapshots")
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("error reading snapshot %s: %v", ref, err)
+	}
+	return &snap, nil
+}
+
+// ListSnapshots returns all available snapshots under root
+func ListSnapshots(root string) ([]Snapshot, error) {
+	snapshotsDir := filepath.Join(root, "snapshots")
 	if _, err := os.Stat(snapshotsDir); os.IsNotExist(err) {
 		return []Snapshot{}, nil
 	}
@@ -100,30 +183,18 @@ func ListSnapshots() ([]Snapshot, error) {
 	return snapshots, nil
 }
 
-// RestoreSnapshot restores a snapshot by timestamp
-func RestoreSnapshot(timestamp string, createBackup bool) error {
-	home, err := os.UserHomeDir()
+// RestoreSnapshot restores a snapshot by timestamp or tag
+func RestoreSnapshot(root, timestamp string, createBackup bool) error {
+	snapshot, err := Load(root, timestamp)
 	if err != nil {
 		return err
 	}
 
-	// Load snapshot
-	snapshotPath := filepath.Join(home, ".dotfiles", "snapshots", timestamp+".json")
-	data, err := os.ReadFile(snapshotPath)
-	if err != nil {
-		return fmt.Errorf("snapshot not found: %s", timestamp)
-	}
-
-	var snapshot Snapshot
-	if err := json.Unmarshal(data, &snapshot); err != nil {
-		return fmt.Errorf("error reading snapshot: %v", err)
-	}
-
-	configPath := filepath.Join(home, ".dotfiles", "config.json")
+	configPath := config.FindFile(root, "config")
 
 	// Create backup if requested
 	if createBackup {
-		_, err := CreateAutoSnapshot("Auto-backup before restore from " + timestamp)
+		_, err := CreateAutoSnapshot(root, "Auto-backup before restore from "+timestamp)
 		if err != nil {
 			return fmt.Errorf("failed to create backup: %v", err)
 		}
@@ -137,19 +208,14 @@ func RestoreSnapshot(timestamp string, createBackup bool) error {
 	return nil
 }
 
-// CleanOldSnapshots removes snapshots older than specified days
-func CleanOldSnapshots(daysToKeep int) (int, error) {
-	snapshots, err := ListSnapshots()
+// CleanOldSnapshots removes untagged snapshots older than specified days
+func CleanOldSnapshots(root string, daysToKeep int) (int, error) {
+	snapshots, err := ListSnapshots(root)
 	if err != nil {
 		return 0, err
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return 0, err
-	}
-
-	snapshotsDir := filepath.Join(home, ".dotfiles", "snapshots")
+	snapshotsDir := filepath.Join(root, "snapshots")
 	cutoffTime := time.Now().AddDate(0, 0, -daysToKeep)
 	removed := 0
 
@@ -159,7 +225,7 @@ func CleanOldSnapshots(daysToKeep int) (int, error) {
 			continue
 		}
 
-		if t.Before(cutoffTime) {
+		if t.Before(cutoffTime) && len(snapshot.Tags) == 0 {
 			snapshotPath := filepath.Join(snapshotsDir, snapshot.Timestamp+".json")
 			if err := os.Remove(snapshotPath); err == nil {
 				removed++
@@ -167,5 +233,10 @@ func CleanOldSnapshots(daysToKeep int) (int, error) {
 		}
 	}
 
+	if removed > 0 {
+		if _, err := PruneObjects(root); err != nil {
+			return removed, err
+		}
+	}
 	return removed, nil
 }
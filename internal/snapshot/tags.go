@@ -0,0 +1,86 @@
+package snapshot
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	timestampPattern = regexp.MustCompile(`^\d{8}-\d{6}$`)
+	tagPattern       = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// ValidateTag checks a tag name. Tags can stand in for timestamps, so they
+// can't look like one.
+func ValidateTag(tag string) error {
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("invalid tag %q: use letters, digits, '.', '_' and '-'", tag)
+	}
+	if timestampPattern.MatchString(tag) {
+		return fmt.Errorf("invalid tag %q: tags can't look like a timestamp", tag)
+	}
+	return nil
+}
+
+// FindTag returns the snapshot holding tag, or nil when none does
+func FindTag(root, tag string) (*Snapshot, error) {
+	snapshots, err := ListSnapshots(root)
+	if err != nil {
+		return nil, err
+	}
+	for i := range snapshots {
+		if hasTag(snapshots[i].Tags, tag) {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Tag names a snapshot. A tag marks one snapshot, so it is moved when
+// another holds it; the timestamp of that snapshot is returned.
+func Tag(root, ref, tag string) (string, error) {
+	if err := ValidateTag(tag); err != nil {
+		return "", err
+	}
+	snap, err := Load(root, ref)
+	if err != nil {
+		return "", err
+	}
+	if hasTag(snap.Tags, tag) {
+		return "", nil
+	}
+
+	previous, err := Untag(root, tag)
+	if err != nil {
+		return "", err
+	}
+	snap.Tags = append(snap.Tags, tag)
+	return previous, Save(root, snap)
+}
+
+// Untag removes a tag and returns the timestamp of the snapshot that held
+// it, or "" when none did
+func Untag(root, tag string) (string, error) {
+	snap, err := FindTag(root, tag)
+	if err != nil || snap == nil {
+		return "", err
+	}
+
+	var tags []string
+	for _, t := range snap.Tags {
+		if t != tag {
+			tags = append(tags, t)
+		}
+	}
+	snap.Tags = tags
+	return snap.Timestamp, Save(root, snap)
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}